@@ -8,15 +8,29 @@ import (
   "os/signal"
   "syscall"
   "time"
+  // Bundled zone data so ANALYTICS_TIMEZONE works in minimal images
+  _ "time/tzdata"
 
-  "duskspendr/gateway/internal/config"
-  "duskspendr/gateway/internal/db"
-  httpapi "duskspendr/gateway/internal/http"
-  "duskspendr/gateway/internal/serverpod"
+  amqp "github.com/rabbitmq/amqp091-go"
+
+  "duskspendr-gateway/internal/config"
+  "duskspendr-gateway/internal/db"
+  "duskspendr-gateway/internal/erasure"
+  "duskspendr-gateway/internal/fx"
+  httpapi "duskspendr-gateway/internal/http"
+  "duskspendr-gateway/internal/merchant"
+  "duskspendr-gateway/internal/networth"
+  "duskspendr-gateway/internal/outbox"
+  "duskspendr-gateway/internal/serverpod"
+  "duskspendr-gateway/internal/services"
+  "duskspendr-gateway/internal/smsparse"
 )
 
 func main() {
   cfg := config.Load()
+  if err := cfg.Validate(); err != nil {
+    log.Fatalf("config: %v", err)
+  }
   ctx, cancel := context.WithCancel(context.Background())
   defer cancel()
 
@@ -26,10 +40,90 @@ func main() {
   }
   defer pool.Close()
 
+  redisClient, err := db.NewRedisClient(ctx, cfg.RedisURL)
+  if err != nil {
+    log.Printf("redis unavailable, using in-memory replay cache: %v", err)
+    redisClient = nil
+  } else {
+    defer redisClient.Close()
+  }
+
+  mqConn, err := amqp.Dial(cfg.RabbitMQURL)
+  if err != nil {
+    log.Printf("rabbitmq unavailable, transaction alerts disabled: %v", err)
+    mqConn = nil
+  } else {
+    defer mqConn.Close()
+  }
+  notifications := services.NewNotificationService(mqConn)
+  defer notifications.Close()
+
+  keyRing := services.NewKeyRing(cfg.JWTRefreshExpiry)
+  var keyStore services.KeyStore = &services.MemoryKeyStore{}
+  if cfg.JWTKeyEncryptionKey != "" {
+    cipher, err := services.NewKeyCipher(cfg.JWTKeyEncryptionKey)
+    if err != nil {
+      log.Fatalf("jwt: %v", err)
+    }
+    keyStore = &services.PostgresKeyStore{Pool: pool, Cipher: cipher}
+  } else {
+    log.Printf("jwt: JWT_KEY_ENCRYPTION_KEY unset, signing keys are kept in memory and lost on restart")
+  }
+  rotator := &services.Rotator{
+    Store: keyStore,
+    Ring:  keyRing,
+    Policy: services.RotationPolicy{
+      Algorithm:   cfg.JWTSigningAlg,
+      Interval:    cfg.JWTKeyRotationInterval,
+      Prepublish:  cfg.JWTKeyPrepublish,
+      MaxTokenAge: cfg.JWTRefreshExpiry,
+    },
+    Interval: cfg.JWTKeyReloadInterval,
+  }
+  if err := rotator.RotateOnce(ctx, time.Now()); err != nil {
+    log.Fatalf("jwt: loading signing keys failed: %v", err)
+  }
+  go rotator.Run(ctx)
+  jwtService := services.NewJWTService(keyRing, cfg.JWTAccessExpiry, cfg.JWTRefreshExpiry)
+  // Placeholder secrets are public, so tokens signed with them are not honoured
+  if cfg.JWTSecret != config.DefaultJWTSecret && time.Now().Before(cfg.JWTLegacyUntil) {
+    jwtService.AcceptLegacyHS256(cfg.JWTSecret, cfg.JWTLegacyUntil)
+    log.Printf("jwt: accepting legacy HS256 access tokens until %s", cfg.JWTLegacyUntil.Format(time.RFC3339))
+  }
+
   serverpodClient := serverpod.New(cfg.ServerpodURL, cfg.SyncSharedSecret)
+  dispatcher := outbox.NewDispatcher(pool, serverpodClient, cfg.SyncOutboxPollInterval, cfg.SyncOutboxMaxAttempts)
+  go dispatcher.Run(ctx)
+
+  smsParser := smsparse.NewRegistry()
+  go smsParser.Watch(ctx, pool, cfg.SMSTemplateReloadInterval)
+
+  merchants := merchant.NewDirectory()
+  go merchants.Watch(ctx, pool, cfg.MerchantReloadInterval)
+
+  snapshots := &networth.Snapshotter{Pool: pool, Location: cfg.AnalyticsLocation(), Interval: cfg.NetWorthSnapshotInterval}
+  go snapshots.Run(ctx)
+
+  eraser := &erasure.Eraser{Pool: pool, Interval: cfg.ErasureInterval, AccessTTL: jwtService.AccessExpiration()}
+  if redisClient != nil {
+    // Only a shared denylist reaches every instance; without Redis the
+    // erased user's access tokens simply run out
+    eraser.Denylist = services.NewRedisDenylist(redisClient)
+  }
+  go eraser.Run(ctx)
+
+  if n, err := fx.SeedBundled(ctx, pool); err != nil {
+    log.Printf("fx: seeding bundled rates failed: %v", err)
+  } else if n > 0 {
+    log.Printf("fx: seeded %d bundled reference rates", n)
+  }
+  if latest, ok, err := fx.LatestRateDate(ctx, pool); err == nil && (!ok || !fx.Current(latest, time.Now(), cfg.FXMaxRateAge)) {
+    log.Printf("fx: no reference rates cover today; foreign-currency writes are rejected until current rates are uploaded to POST /v1/admin/fx/rates")
+  }
+
   srv := &http.Server{
     Addr:              cfg.HTTPAddr,
-    Handler:           httpapi.NewServer(pool, serverpodClient, cfg),
+    Handler:           httpapi.NewServer(pool, redisClient, mqConn, jwtService, serverpodClient, smsParser, merchants, notifications, cfg),
     ReadHeaderTimeout: 5 * time.Second,
   }
 
@@ -0,0 +1,92 @@
+// Command rollup backfills and verifies the analytics rollup tables.
+//
+//	rollup rebuild [-user ID]
+//	rollup check [-user ID] [-limit N] [-fix]
+//
+// check exits 1 when mismatches remain; -fix rebuilds the affected users.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	// Bundled zone data so ANALYTICS_TIMEZONE works in minimal images
+	_ "time/tzdata"
+
+	"duskspendr-gateway/internal/analytics"
+	"duskspendr-gateway/internal/config"
+	"duskspendr-gateway/internal/db"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	userID := fs.String("user", "", "only this user id")
+	limit := fs.Int("limit", 100, "maximum mismatches reported per table")
+	fix := fs.Bool("fix", false, "rebuild users with mismatches")
+	_ = fs.Parse(os.Args[2:])
+
+	cfg := config.Load()
+	ctx := context.Background()
+	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("db init failed: %v", err)
+	}
+	defer pool.Close()
+
+	loc, err := time.LoadLocation(cfg.AnalyticsTimezone)
+	if err != nil {
+		log.Fatalf("invalid ANALYTICS_TIMEZONE %q: %v", cfg.AnalyticsTimezone, err)
+	}
+	rollups := &analytics.Rollups{Location: loc}
+
+	switch os.Args[1] {
+	case "rebuild":
+		n, err := rollups.Rebuild(ctx, pool, *userID)
+		if err != nil {
+			log.Fatalf("rebuild failed after %d users: %v", n, err)
+		}
+		log.Printf("rebuilt rollups for %d users", n)
+	case "check":
+		mismatches, err := rollups.Check(ctx, pool, *userID, *limit)
+		if err != nil {
+			log.Fatalf("check failed: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, m := range mismatches {
+			_ = enc.Encode(m)
+		}
+		if len(mismatches) == 0 {
+			log.Printf("rollups consistent")
+			return
+		}
+		if !*fix {
+			log.Printf("%d mismatched rollup rows", len(mismatches))
+			os.Exit(1)
+		}
+		users := map[string]bool{}
+		for _, m := range mismatches {
+			if users[m.UserID] {
+				continue
+			}
+			users[m.UserID] = true
+			if _, err := rollups.Rebuild(ctx, pool, m.UserID); err != nil {
+				log.Fatalf("rebuild %s failed: %v", m.UserID, err)
+			}
+		}
+		log.Printf("rebuilt rollups for %d users", len(users))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: rollup rebuild|check [-user ID] [-limit N] [-fix]")
+	os.Exit(2)
+}
@@ -0,0 +1,294 @@
+// Package analytics computes spending summaries, period comparisons, time
+// series and cash flow from a user's transactions.
+//
+// Amounts are in the user's base currency (amount_paisa). Debits count as
+// spend and expense, credits as received and income. Day, week (Monday) and
+// month buckets follow the configured location, so an Indian user's
+// late-night spends land on the right local day.
+package analytics
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Dimension is what totals are grouped by
+type Dimension string
+
+const (
+	ByCategory      Dimension = "category"
+	ByMerchant      Dimension = "merchant"
+	ByPaymentMethod Dimension = "payment_method"
+	ByAccount       Dimension = "account"
+)
+
+// Dimensions lists every supported grouping
+var Dimensions = []Dimension{ByCategory, ByMerchant, ByPaymentMethod, ByAccount}
+
+// ParseDimension validates a group_by value
+func ParseDimension(s string) (Dimension, error) {
+	for _, d := range Dimensions {
+		if string(d) == s {
+			return d, nil
+		}
+	}
+	return "", errors.New("invalid group_by")
+}
+
+// Interval is a time-series bucket size
+type Interval string
+
+const (
+	Day   Interval = "day"
+	Week  Interval = "week"
+	Month Interval = "month"
+)
+
+// ParseInterval validates an interval value; "daily", "weekly" and
+// "monthly" are accepted too.
+func ParseInterval(s string) (Interval, error) {
+	switch s {
+	case "day", "daily":
+		return Day, nil
+	case "week", "weekly":
+		return Week, nil
+	case "month", "monthly", "":
+		return Month, nil
+	}
+	return "", errors.New("invalid interval")
+}
+
+// Range is the half-open time range [From, To)
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Previous returns the range of the same length immediately before r
+func (r Range) Previous() Range {
+	return Range{From: r.From.Add(-r.To.Sub(r.From)), To: r.From}
+}
+
+// PreviousYear returns r shifted back one year
+func (r Range) PreviousYear() Range {
+	return Range{From: r.From.AddDate(-1, 0, 0), To: r.To.AddDate(-1, 0, 0)}
+}
+
+// Totals is spend and income over some set of transactions
+type Totals struct {
+	SpentPaisa       int64 `json:"spent_paisa"`
+	ReceivedPaisa    int64 `json:"received_paisa"`
+	TransactionCount int64 `json:"transaction_count"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.SpentPaisa += o.SpentPaisa
+	t.ReceivedPaisa += o.ReceivedPaisa
+	t.TransactionCount += o.TransactionCount
+}
+
+// Group is the totals for one value of a dimension. Key is nil for
+// transactions without a value (no merchant, payment method or account).
+type Group struct {
+	Key   *string `json:"key"`
+	Label *string `json:"label,omitempty"`
+	Totals
+}
+
+// Summary is the totals for a range, overall and per dimension
+type Summary struct {
+	Range  Range                 `json:"range"`
+	Totals Totals                `json:"totals"`
+	Groups map[Dimension][]Group `json:"groups"`
+}
+
+// Point is one time-series bucket starting at Start
+type Point struct {
+	Start time.Time `json:"start"`
+	Totals
+}
+
+// BucketStart returns the start of the bucket containing t in loc
+func BucketStart(t time.Time, interval Interval, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch interval {
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case Week:
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+func nextBucket(t time.Time, interval Interval) time.Time {
+	switch interval {
+	case Month:
+		return t.AddDate(0, 1, 0)
+	case Week:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// MaxPoints bounds the number of buckets a series may have
+const MaxPoints = 1000
+
+// ErrTooManyPoints is returned for a range with more than MaxPoints buckets
+var ErrTooManyPoints = errors.New("range has too many buckets for the interval")
+
+// Fill returns one point per bucket of r, taking totals from points and
+// zero for buckets without transactions.
+func Fill(points []Point, r Range, interval Interval, loc *time.Location) ([]Point, error) {
+	byStart := map[int64]Totals{}
+	for _, p := range points {
+		start := BucketStart(p.Start, interval, loc).Unix()
+		t := byStart[start]
+		t.add(p.Totals)
+		byStart[start] = t
+	}
+	out := []Point{}
+	for b := BucketStart(r.From, interval, loc); b.Before(r.To); b = nextBucket(b, interval) {
+		if len(out) == MaxPoints {
+			return nil, ErrTooManyPoints
+		}
+		out = append(out, Point{Start: b, Totals: byStart[b.Unix()]})
+	}
+	return out, nil
+}
+
+// Change compares a value across two periods. Percent is nil when the
+// previous value is zero.
+type Change struct {
+	Current  int64    `json:"current"`
+	Previous int64    `json:"previous"`
+	Delta    int64    `json:"delta"`
+	Percent  *float64 `json:"percent,omitempty"`
+}
+
+func change(cur, prev int64) Change {
+	c := Change{Current: cur, Previous: prev, Delta: cur - prev}
+	if prev != 0 {
+		pct := float64(cur-prev) / float64(prev) * 100
+		c.Percent = &pct
+	}
+	return c
+}
+
+// GroupChange is the change in spend for one dimension value
+type GroupChange struct {
+	Key   *string `json:"key"`
+	Label *string `json:"label,omitempty"`
+	Spent Change  `json:"spent"`
+}
+
+// Comparison is one period against another
+type Comparison struct {
+	Current  Range         `json:"current"`
+	Previous Range         `json:"previous"`
+	Spent    Change        `json:"spent"`
+	Received Change        `json:"received"`
+	Count    Change        `json:"transaction_count"`
+	Groups   []GroupChange `json:"groups"`
+}
+
+// Compare builds a comparison from each period's totals and groups. Groups
+// are ordered by the size of the change in spend, largest first.
+func Compare(cur, prev Range, curTotals, prevTotals Totals, curGroups, prevGroups []Group) Comparison {
+	c := Comparison{
+		Current:  cur,
+		Previous: prev,
+		Spent:    change(curTotals.SpentPaisa, prevTotals.SpentPaisa),
+		Received: change(curTotals.ReceivedPaisa, prevTotals.ReceivedPaisa),
+		Count:    change(curTotals.TransactionCount, prevTotals.TransactionCount),
+		Groups:   []GroupChange{},
+	}
+
+	type pair struct {
+		key, label *string
+		cur, prev  int64
+	}
+	pairs := map[string]*pair{}
+	order := []string{}
+	get := func(g Group) *pair {
+		k := "\x00"
+		if g.Key != nil {
+			k = *g.Key
+		}
+		p, ok := pairs[k]
+		if !ok {
+			p = &pair{key: g.Key, label: g.Label}
+			pairs[k] = p
+			order = append(order, k)
+		}
+		return p
+	}
+	for _, g := range curGroups {
+		get(g).cur += g.SpentPaisa
+	}
+	for _, g := range prevGroups {
+		get(g).prev += g.SpentPaisa
+	}
+	for _, k := range order {
+		p := pairs[k]
+		if p.cur == 0 && p.prev == 0 {
+			continue
+		}
+		c.Groups = append(c.Groups, GroupChange{Key: p.key, Label: p.label, Spent: change(p.cur, p.prev)})
+	}
+	sort.SliceStable(c.Groups, func(i, j int) bool {
+		return abs(c.Groups[i].Spent.Delta) > abs(c.Groups[j].Spent.Delta)
+	})
+	return c
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CashFlowPoint is income against expense for one bucket
+type CashFlowPoint struct {
+	Start        time.Time `json:"start"`
+	IncomePaisa  int64     `json:"income_paisa"`
+	ExpensePaisa int64     `json:"expense_paisa"`
+	NetPaisa     int64     `json:"net_paisa"`
+}
+
+// CashFlow is income against expense over a range
+type CashFlow struct {
+	Range        Range           `json:"range"`
+	Interval     Interval        `json:"interval"`
+	IncomePaisa  int64           `json:"income_paisa"`
+	ExpensePaisa int64           `json:"expense_paisa"`
+	NetPaisa     int64           `json:"net_paisa"`
+	SavingsRate  *float64        `json:"savings_rate,omitempty"`
+	Points       []CashFlowPoint `json:"points"`
+}
+
+// NewCashFlow derives cash flow from a filled series. SavingsRate is net
+// over income and is nil without income.
+func NewCashFlow(r Range, interval Interval, series []Point) CashFlow {
+	cf := CashFlow{Range: r, Interval: interval, Points: make([]CashFlowPoint, 0, len(series))}
+	for _, p := range series {
+		cf.Points = append(cf.Points, CashFlowPoint{
+			Start:        p.Start,
+			IncomePaisa:  p.ReceivedPaisa,
+			ExpensePaisa: p.SpentPaisa,
+			NetPaisa:     p.ReceivedPaisa - p.SpentPaisa,
+		})
+		cf.IncomePaisa += p.ReceivedPaisa
+		cf.ExpensePaisa += p.SpentPaisa
+	}
+	cf.NetPaisa = cf.IncomePaisa - cf.ExpensePaisa
+	if cf.IncomePaisa > 0 {
+		rate := float64(cf.NetPaisa) / float64(cf.IncomePaisa)
+		cf.SavingsRate = &rate
+	}
+	return cf
+}
@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoc(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	return loc
+}
+
+func TestBucketStart(t *testing.T) {
+	loc := mustLoc(t)
+	// 20:00 UTC on Sunday 14 April is 01:30 Monday 15 April in India
+	ts := time.Date(2024, 4, 14, 20, 0, 0, 0, time.UTC)
+	if got := BucketStart(ts, Day, loc); !got.Equal(time.Date(2024, 4, 15, 0, 0, 0, 0, loc)) {
+		t.Errorf("day = %v", got)
+	}
+	if got := BucketStart(ts, Week, loc); !got.Equal(time.Date(2024, 4, 15, 0, 0, 0, 0, loc)) {
+		t.Errorf("week = %v", got)
+	}
+	if got := BucketStart(time.Date(2024, 4, 14, 12, 0, 0, 0, loc), Week, loc); !got.Equal(time.Date(2024, 4, 8, 0, 0, 0, 0, loc)) {
+		t.Errorf("sunday week = %v", got)
+	}
+	if got := BucketStart(ts, Month, loc); !got.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, loc)) {
+		t.Errorf("month = %v", got)
+	}
+}
+
+func TestFill(t *testing.T) {
+	loc := mustLoc(t)
+	r := Range{
+		From: time.Date(2024, 1, 15, 0, 0, 0, 0, loc),
+		To:   time.Date(2024, 4, 1, 0, 0, 0, 0, loc),
+	}
+	points := []Point{
+		{Start: time.Date(2024, 3, 1, 0, 0, 0, 0, loc), Totals: Totals{SpentPaisa: 500, TransactionCount: 2}},
+	}
+	got, err := Fill(points, r, Month, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d points", len(got))
+	}
+	if got[0].SpentPaisa != 0 || got[2].SpentPaisa != 500 || !got[0].Start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, loc)) {
+		t.Errorf("points = %+v", got)
+	}
+
+	long := Range{From: time.Date(2000, 1, 1, 0, 0, 0, 0, loc), To: time.Date(2024, 1, 1, 0, 0, 0, 0, loc)}
+	if _, err := Fill(nil, long, Day, loc); err == nil {
+		t.Error("expected too many buckets")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	food, travel, none := "food", "travel", (*string)(nil)
+	cur := Range{From: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	prev := cur.Previous()
+	if !prev.From.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) || !prev.To.Equal(cur.From) {
+		t.Fatalf("previous = %+v", prev)
+	}
+
+	c := Compare(cur, prev,
+		Totals{SpentPaisa: 1500}, Totals{SpentPaisa: 1000},
+		[]Group{{Key: &food, Totals: Totals{SpentPaisa: 1200}}, {Key: none, Totals: Totals{SpentPaisa: 300}}},
+		[]Group{{Key: &food, Totals: Totals{SpentPaisa: 400}}, {Key: &travel, Totals: Totals{SpentPaisa: 600}}},
+	)
+	if c.Spent.Delta != 500 || c.Spent.Percent == nil || *c.Spent.Percent != 50 {
+		t.Errorf("spent = %+v", c.Spent)
+	}
+	if len(c.Groups) != 3 || *c.Groups[0].Key != "food" || c.Groups[0].Spent.Delta != 800 {
+		t.Fatalf("groups = %+v", c.Groups)
+	}
+	if *c.Groups[1].Key != "travel" || c.Groups[1].Spent.Current != 0 {
+		t.Errorf("travel = %+v", c.Groups[1])
+	}
+	if c.Groups[2].Key != nil || c.Groups[2].Spent.Percent != nil {
+		t.Errorf("unassigned = %+v", c.Groups[2])
+	}
+}
+
+func TestNewCashFlow(t *testing.T) {
+	cf := NewCashFlow(Range{}, Month, []Point{
+		{Totals: Totals{ReceivedPaisa: 5000000, SpentPaisa: 3000000}},
+		{Totals: Totals{ReceivedPaisa: 0, SpentPaisa: 1000000}},
+	})
+	if cf.NetPaisa != 1000000 || cf.Points[1].NetPaisa != -1000000 {
+		t.Fatalf("cash flow = %+v", cf)
+	}
+	if cf.SavingsRate == nil || *cf.SavingsRate != 0.2 {
+		t.Errorf("savings rate = %v", cf.SavingsRate)
+	}
+}
@@ -0,0 +1,242 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Execer is satisfied by *pgxpool.Pool and pgx.Tx
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Rollups maintains the daily and monthly aggregate tables. Every write
+// path calls Remove for the transactions it is about to change or delete
+// and Add for the ones it has written, in the same database transaction.
+// A nil *Rollups does nothing.
+type Rollups struct {
+	Location *time.Location
+}
+
+func (r *Rollups) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+	return r.Location
+}
+
+// rollupTables maps each table to its period column and the expression
+// deriving it from a transaction timestamp ($2 is the timezone).
+var rollupTables = []struct{ table, period, expr string }{
+	{"txn_rollup_daily", "day", "(t.timestamp AT TIME ZONE $2)::date"},
+	{"txn_rollup_monthly", "month", "date_trunc('month', t.timestamp AT TIME ZONE $2)::date"},
+}
+
+// Add adds the user's transactions ids to the rollups
+func (r *Rollups) Add(ctx context.Context, q Execer, userID string, ids []string) error {
+	return r.apply(ctx, q, userID, ids, 1)
+}
+
+// Remove takes the user's transactions ids out of the rollups. It first
+// locks them until q commits, so a concurrent write of the same
+// transactions waits and then subtracts the amounts this one wrote rather
+// than the ones both started from.
+func (r *Rollups) Remove(ctx context.Context, q Execer, userID string, ids []string) error {
+	if r == nil || len(ids) == 0 {
+		return nil
+	}
+	if err := lockTransactions(ctx, q, userID, ids); err != nil {
+		return err
+	}
+	return r.apply(ctx, q, userID, ids, -1)
+}
+
+// lockTransactions locks the rows of ids in id order, so bulk writers
+// cannot deadlock. Ids with no row yet, which an upsert is about to
+// insert, are held by a transaction-scoped advisory lock instead.
+func lockTransactions(ctx context.Context, q Execer, userID string, ids []string) error {
+	if _, err := q.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtextextended(id, 0))
+		  FROM (SELECT DISTINCT unnest($1::text[]) AS id ORDER BY 1) ids
+	`, ids); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx, `
+		SELECT 1 FROM transactions WHERE user_id = $1 AND id = ANY($2::uuid[]) ORDER BY id FOR UPDATE
+	`, userID, ids)
+	return err
+}
+
+func (r *Rollups) apply(ctx context.Context, q Execer, userID string, ids []string, sign int64) error {
+	if r == nil || len(ids) == 0 {
+		return nil
+	}
+	for _, t := range rollupTables {
+		if _, err := q.Exec(ctx, `
+			INSERT INTO `+t.table+` AS r (
+			  user_id, `+t.period+`, type, category, merchant, payment_method, account_id,
+			  amount_paisa, txn_count
+			)
+			SELECT t.user_id, `+t.expr+`, t.type, t.category, coalesce(t.merchant_name, ''),
+			       coalesce(t.payment_method, ''), coalesce(t.linked_account_id::text, ''),
+			       $4 * sum(t.amount_paisa)::bigint, $4 * count(*)
+			  FROM transactions t
+			 WHERE t.user_id = $1 AND t.id = ANY($3::uuid[])
+			 GROUP BY 1, 2, 3, 4, 5, 6, 7
+			ON CONFLICT (user_id, `+t.period+`, type, category, merchant, payment_method, account_id)
+			DO UPDATE SET amount_paisa = r.amount_paisa + EXCLUDED.amount_paisa,
+			              txn_count = r.txn_count + EXCLUDED.txn_count
+		`, userID, r.location().String(), ids, sign); err != nil {
+			return err
+		}
+		if sign < 0 {
+			if _, err := q.Exec(ctx, `
+				DELETE FROM `+t.table+` WHERE user_id = $1 AND txn_count = 0
+			`, userID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes the rollups of userID, or of every user when userID
+// is empty, one user per database transaction. It returns the number of
+// users rebuilt.
+func (r *Rollups) Rebuild(ctx context.Context, pool *pgxpool.Pool, userID string) (int, error) {
+	users := []string{userID}
+	if userID == "" {
+		var err error
+		if users, err = collectStrings(ctx, pool, `SELECT id::text FROM users ORDER BY id`); err != nil {
+			return 0, err
+		}
+	}
+	for i, u := range users {
+		if err := r.rebuildUser(ctx, pool, u); err != nil {
+			return i, err
+		}
+	}
+	return len(users), nil
+}
+
+func (r *Rollups) rebuildUser(ctx context.Context, pool *pgxpool.Pool, userID string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Block concurrent writes for this user so no delta is lost between the
+	// delete and the re-insert
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM transactions WHERE user_id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+	for _, t := range rollupTables {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+t.table+` WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO `+t.table+` (
+			  user_id, `+t.period+`, type, category, merchant, payment_method, account_id,
+			  amount_paisa, txn_count
+			)
+			SELECT t.user_id, `+t.expr+`, t.type, t.category, coalesce(t.merchant_name, ''),
+			       coalesce(t.payment_method, ''), coalesce(t.linked_account_id::text, ''),
+			       sum(t.amount_paisa)::bigint, count(*)
+			  FROM transactions t
+			 WHERE t.user_id = $1
+			 GROUP BY 1, 2, 3, 4, 5, 6, 7
+		`, userID, r.location().String()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Mismatch is a rollup row that differs from the transactions it covers
+type Mismatch struct {
+	Table         string    `json:"table"`
+	UserID        string    `json:"user_id"`
+	Period        time.Time `json:"period"`
+	Type          string    `json:"type"`
+	Category      string    `json:"category"`
+	Merchant      string    `json:"merchant"`
+	PaymentMethod string    `json:"payment_method"`
+	AccountID     string    `json:"account_id"`
+	ExpectedPaisa int64     `json:"expected_paisa"`
+	ActualPaisa   int64     `json:"actual_paisa"`
+	ExpectedCount int64     `json:"expected_count"`
+	ActualCount   int64     `json:"actual_count"`
+}
+
+// Check compares the rollups of userID (every user when empty) with the
+// transactions table and returns up to limit mismatching rows.
+func (r *Rollups) Check(ctx context.Context, q Querier, userID string, limit int) ([]Mismatch, error) {
+	var user any
+	if userID != "" {
+		user = userID
+	}
+	out := []Mismatch{}
+	for _, t := range rollupTables {
+		rows, err := q.Query(ctx, `
+			WITH expected AS (
+			  SELECT t.user_id, `+t.expr+` AS period, t.type, t.category,
+			         coalesce(t.merchant_name, '') AS merchant,
+			         coalesce(t.payment_method, '') AS payment_method,
+			         coalesce(t.linked_account_id::text, '') AS account_id,
+			         sum(t.amount_paisa)::bigint AS amount_paisa, count(*) AS txn_count
+			    FROM transactions t
+			   WHERE $1::uuid IS NULL OR t.user_id = $1
+			   GROUP BY 1, 2, 3, 4, 5, 6, 7
+			), actual AS (
+			  SELECT user_id, `+t.period+` AS period, type, category, merchant, payment_method,
+			         account_id, amount_paisa, txn_count
+			    FROM `+t.table+`
+			   WHERE $1::uuid IS NULL OR user_id = $1
+			)
+			SELECT coalesce(e.user_id, a.user_id)::text, coalesce(e.period, a.period),
+			       coalesce(e.type, a.type), coalesce(e.category, a.category),
+			       coalesce(e.merchant, a.merchant), coalesce(e.payment_method, a.payment_method),
+			       coalesce(e.account_id, a.account_id),
+			       coalesce(e.amount_paisa, 0), coalesce(a.amount_paisa, 0),
+			       coalesce(e.txn_count, 0), coalesce(a.txn_count, 0)
+			  FROM expected e
+			  FULL JOIN actual a
+			    ON (a.user_id, a.period, a.type, a.category, a.merchant, a.payment_method, a.account_id)
+			     = (e.user_id, e.period, e.type, e.category, e.merchant, e.payment_method, e.account_id)
+			 WHERE e.amount_paisa IS DISTINCT FROM a.amount_paisa
+			    OR e.txn_count IS DISTINCT FROM a.txn_count
+			 LIMIT $3
+		`, user, r.location().String(), limit)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			m := Mismatch{Table: t.table}
+			if err := rows.Scan(&m.UserID, &m.Period, &m.Type, &m.Category, &m.Merchant,
+				&m.PaymentMethod, &m.AccountID, &m.ExpectedPaisa, &m.ActualPaisa,
+				&m.ExpectedCount, &m.ActualCount); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out = append(out, m)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func collectStrings(ctx context.Context, q Querier, sql string, args ...any) ([]string, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
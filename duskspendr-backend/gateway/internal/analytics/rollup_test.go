@@ -0,0 +1,148 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestSplitRange(t *testing.T) {
+	loc := mustLoc(t)
+	r := Range{
+		From: time.Date(2024, 1, 20, 15, 30, 0, 0, loc),
+		To:   time.Date(2024, 4, 3, 9, 0, 0, 0, loc),
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, loc) }
+
+	s := splitRange(r, loc, true)
+	if !s.Raw[0].From.Equal(r.From) || !s.Raw[0].To.Equal(day(1, 21)) {
+		t.Errorf("head = %+v", s.Raw[0])
+	}
+	if !s.Raw[1].From.Equal(day(4, 3)) || !s.Raw[1].To.Equal(r.To) {
+		t.Errorf("tail = %+v", s.Raw[1])
+	}
+	if !s.Days[0][0].Equal(day(1, 21)) || !s.Days[0][1].Equal(day(2, 1)) {
+		t.Errorf("leading days = %v", s.Days[0])
+	}
+	if !s.Months[0].Equal(day(2, 1)) || !s.Months[1].Equal(day(4, 1)) {
+		t.Errorf("months = %v", s.Months)
+	}
+	if !s.Days[1][0].Equal(day(4, 1)) || !s.Days[1][1].Equal(day(4, 3)) {
+		t.Errorf("trailing days = %v", s.Days[1])
+	}
+
+	s = splitRange(r, loc, false)
+	if !s.Days[0][0].Equal(day(1, 21)) || !s.Days[0][1].Equal(day(4, 3)) || !s.Months[0].Equal(s.Months[1]) {
+		t.Errorf("days only = %+v", s)
+	}
+
+	// Midnight bounds leave nothing for the transactions table
+	s = splitRange(Range{From: day(3, 1), To: day(4, 1)}, loc, true)
+	if !s.Raw[0].From.Equal(s.Raw[0].To) || !s.Raw[1].From.Equal(s.Raw[1].To) {
+		t.Errorf("raw = %+v", s.Raw)
+	}
+	if !s.Months[0].Equal(day(3, 1)) || !s.Months[1].Equal(day(4, 1)) {
+		t.Errorf("whole month = %v", s.Months)
+	}
+	args := s.args("u", loc)
+	if args[6] != args[7] || args[10] != "2024-03-01" || args[11] != "2024-04-01" {
+		t.Errorf("args = %v", args)
+	}
+
+	// Within one day everything comes from transactions
+	inner := Range{From: r.From, To: r.From.Add(2 * time.Hour)}
+	s = splitRange(inner, loc, true)
+	if s.Raw[0] != inner || !s.Days[0][0].Equal(s.Days[0][1]) {
+		t.Errorf("same day = %+v", s)
+	}
+}
+
+// testPool connects to TEST_DATABASE_URL, a database with the migrations
+// applied, and skips the test when it is not set
+func testPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestRemoveSerializesOverlappingUpdates(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	rollups := &Rollups{}
+
+	userID, txnID := uuid.NewString(), uuid.NewString()
+	if _, err := pool.Exec(ctx, `INSERT INTO users (id, phone) VALUES ($1, $2)`, userID, "+91"+userID[:10]); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, userID) })
+	now := time.Now().UTC()
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO transactions (id, user_id, amount_paisa, type, category, timestamp, source, created_at, updated_at)
+		VALUES ($1, $2, 100, 'debit', 'food', $3, 'manual', $3, $3)
+	`, txnID, userID, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rollups.Rebuild(ctx, pool, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// update runs one edit the way TransactionHandler.Update does, calling
+	// hold between writing the row and committing
+	update := func(amount int64, hold func()) error {
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if err := rollups.Remove(ctx, tx, userID, []string{txnID}); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE transactions SET amount_paisa = $1 WHERE id = $2`, amount, txnID); err != nil {
+			return err
+		}
+		if err := rollups.Add(ctx, tx, userID, []string{txnID}); err != nil {
+			return err
+		}
+		hold()
+		return tx.Commit(ctx)
+	}
+
+	second := make(chan error, 1)
+	err := update(200, func() {
+		go func() { second <- update(300, func() {}) }()
+		// Let the second edit reach Remove while the first is uncommitted
+		time.Sleep(200 * time.Millisecond)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := rollups.Check(ctx, pool, userID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("rollups drifted: %+v", mismatches)
+	}
+	var total int64
+	if err := pool.QueryRow(ctx, `SELECT amount_paisa FROM txn_rollup_monthly WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	if total != 300 {
+		t.Errorf("monthly total = %d, want 300", total)
+	}
+}
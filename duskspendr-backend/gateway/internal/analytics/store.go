@@ -0,0 +1,225 @@
+package analytics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Store runs analytics queries over the rollup tables, topped up from the
+// transactions table for partial days
+type Store struct {
+	Q Querier
+	// Location defines day, week and month boundaries
+	Location *time.Location
+}
+
+func (s *Store) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// dimensionSQL is the grouping expression, label expression and join for
+// each dimension, over the src rows of sourceSQL.
+var dimensionSQL = map[Dimension][3]string{
+	ByCategory:      {"src.category", "NULL::text", ""},
+	ByMerchant:      {"nullif(src.merchant, '')", "NULL::text", ""},
+	ByPaymentMethod: {"nullif(src.payment_method, '')", "NULL::text", ""},
+	ByAccount: {
+		"nullif(src.account_id, '')",
+		"max(a.account_name)",
+		"LEFT JOIN linked_accounts a ON a.id::text = src.account_id",
+	},
+}
+
+const totalsSQL = `
+	coalesce(sum(src.amount_paisa) FILTER (WHERE src.type = 'debit'), 0)::bigint,
+	coalesce(sum(src.amount_paisa) FILTER (WHERE src.type = 'credit'), 0)::bigint,
+	coalesce(sum(src.txn_count), 0)::bigint`
+
+// sourceSQL yields the rows covering a span: transactions for the partial
+// days at either end, then the daily and monthly rollups. at is the local
+// start of the row's period. Parameters $1 to $12 come from span.args.
+const sourceSQL = `
+	WITH src AS (
+	  SELECT t.timestamp AT TIME ZONE $2 AS at, t.type, t.category,
+	         coalesce(t.merchant_name, '') AS merchant,
+	         coalesce(t.payment_method, '') AS payment_method,
+	         coalesce(t.linked_account_id::text, '') AS account_id,
+	         t.amount_paisa, 1::bigint AS txn_count
+	    FROM transactions t
+	   WHERE t.user_id = $1
+	     AND ((t.timestamp >= $3 AND t.timestamp < $4) OR (t.timestamp >= $5 AND t.timestamp < $6))
+	  UNION ALL
+	  SELECT d.day::timestamp, d.type, d.category, d.merchant, d.payment_method, d.account_id,
+	         d.amount_paisa, d.txn_count
+	    FROM txn_rollup_daily d
+	   WHERE d.user_id = $1
+	     AND ((d.day >= $7::date AND d.day < $8::date) OR (d.day >= $9::date AND d.day < $10::date))
+	  UNION ALL
+	  SELECT m.month::timestamp, m.type, m.category, m.merchant, m.payment_method, m.account_id,
+	         m.amount_paisa, m.txn_count
+	    FROM txn_rollup_monthly m
+	   WHERE m.user_id = $1 AND m.month >= $11::date AND m.month < $12::date
+	)`
+
+// span splits a range into what each source covers. Empty parts have
+// equal bounds.
+type span struct {
+	Raw    [2]Range
+	Days   [2][2]time.Time
+	Months [2]time.Time
+}
+
+// splitRange covers the whole local days of r with daily rollups and, when
+// months is set, the whole local months with monthly rollups. The partial
+// days at either end are read from transactions.
+func splitRange(r Range, loc *time.Location, months bool) span {
+	var s span
+	first := BucketStart(r.From, Day, loc)
+	if first.Before(r.From) {
+		first = first.AddDate(0, 0, 1)
+	}
+	last := BucketStart(r.To, Day, loc)
+	if !first.Before(last) {
+		s.Raw[0] = r
+		return s
+	}
+	s.Raw[0] = Range{From: r.From, To: first}
+	s.Raw[1] = Range{From: last, To: r.To}
+	s.Days[0] = [2]time.Time{first, last}
+	if !months {
+		return s
+	}
+	m0 := BucketStart(first, Month, loc)
+	if m0.Before(first) {
+		m0 = m0.AddDate(0, 1, 0)
+	}
+	m1 := BucketStart(last, Month, loc)
+	if !m0.Before(m1) {
+		return s
+	}
+	s.Days[0] = [2]time.Time{first, m0}
+	s.Days[1] = [2]time.Time{m1, last}
+	s.Months = [2]time.Time{m0, m1}
+	return s
+}
+
+// args returns $1 to $12 of sourceSQL
+func (s span) args(userID string, loc *time.Location) []any {
+	date := func(t time.Time) string { return t.In(loc).Format("2006-01-02") }
+	return []any{
+		userID, loc.String(),
+		s.Raw[0].From, s.Raw[0].To, s.Raw[1].From, s.Raw[1].To,
+		date(s.Days[0][0]), date(s.Days[0][1]), date(s.Days[1][0]), date(s.Days[1][1]),
+		date(s.Months[0]), date(s.Months[1]),
+	}
+}
+
+// Totals returns spend and income over r
+func (s *Store) Totals(ctx context.Context, userID string, r Range) (Totals, error) {
+	loc := s.location()
+	rows, err := s.Q.Query(ctx, sourceSQL+`
+		SELECT `+totalsSQL+` FROM src
+	`, splitRange(r, loc, true).args(userID, loc)...)
+	if err != nil {
+		return Totals{}, err
+	}
+	defer rows.Close()
+	var t Totals
+	if rows.Next() {
+		if err := rows.Scan(&t.SpentPaisa, &t.ReceivedPaisa, &t.TransactionCount); err != nil {
+			return Totals{}, err
+		}
+	}
+	return t, rows.Err()
+}
+
+// Groups returns totals over r grouped by dim, largest spend first. limit
+// <= 0 returns every group.
+func (s *Store) Groups(ctx context.Context, userID string, dim Dimension, r Range, limit int) ([]Group, error) {
+	loc := s.location()
+	expr := dimensionSQL[dim]
+	sql := sourceSQL + `
+		SELECT ` + expr[0] + `, ` + expr[1] + `,` + totalsSQL + `
+		  FROM src ` + expr[2] + `
+		 GROUP BY 1
+		 ORDER BY 3 DESC, 5 DESC, 1`
+	args := splitRange(r, loc, true).args(userID, loc)
+	if limit > 0 {
+		sql += ` LIMIT $13`
+		args = append(args, limit)
+	}
+	rows, err := s.Q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.Key, &g.Label, &g.SpentPaisa, &g.ReceivedPaisa, &g.TransactionCount); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// Summary returns the totals over r and the groups for each of dims
+func (s *Store) Summary(ctx context.Context, userID string, r Range, dims []Dimension, limit int) (Summary, error) {
+	totals, err := s.Totals(ctx, userID, r)
+	if err != nil {
+		return Summary{}, err
+	}
+	sum := Summary{Range: r, Totals: totals, Groups: map[Dimension][]Group{}}
+	for _, d := range dims {
+		groups, err := s.Groups(ctx, userID, d, r, limit)
+		if err != nil {
+			return Summary{}, err
+		}
+		sum.Groups[d] = groups
+	}
+	return sum, nil
+}
+
+// Series returns one point per interval bucket of r, including empty ones
+func (s *Store) Series(ctx context.Context, userID string, interval Interval, r Range) ([]Point, error) {
+	loc := s.location()
+	// Monthly rollups only line up with month buckets
+	args := splitRange(r, loc, interval == Month).args(userID, loc)
+	rows, err := s.Q.Query(ctx, sourceSQL+`
+		SELECT date_trunc($13, src.at),`+totalsSQL+`
+		  FROM src
+		 GROUP BY 1
+	`, append(args, string(interval))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []Point{}
+	for rows.Next() {
+		var p Point
+		var local time.Time
+		if err := rows.Scan(&local, &p.SpentPaisa, &p.ReceivedPaisa, &p.TransactionCount); err != nil {
+			return nil, err
+		}
+		// date_trunc yields a local wall-clock time without zone
+		p.Start = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return Fill(points, r, interval, loc)
+}
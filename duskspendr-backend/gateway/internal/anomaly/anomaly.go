@@ -0,0 +1,222 @@
+// Package anomaly flags unusual debits: amounts far above the user's
+// typical spend in the category (robust z-score over the median absolute
+// deviation), first-seen merchants, debits at hours the user rarely
+// spends, and rapid repeated debits at one merchant.
+package anomaly
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Rules
+const (
+	RuleAmount      = "amount_zscore"
+	RuleNewMerchant = "first_seen_merchant"
+	RuleUnusualHour = "unusual_hour"
+	RuleRapidRepeat = "rapid_repeat"
+)
+
+// Alert reasons understood by services.SendTransactionAlert
+const (
+	ReasonLargeAmount     = "large_amount"
+	ReasonUnusualMerchant = "unusual_merchant"
+	ReasonFraudSuspected  = "fraud_suspected"
+)
+
+// Sensitivity levels
+const (
+	Low    = "low"
+	Medium = "medium"
+	High   = "high"
+)
+
+// ValidSensitivity reports whether s is a sensitivity level
+func ValidSensitivity(s string) bool {
+	return s == Low || s == Medium || s == High
+}
+
+// Settings are a user's detector preferences
+type Settings struct {
+	Enabled        bool   `json:"enabled"`
+	Sensitivity    string `json:"sensitivity"`
+	AmountRule     bool   `json:"amount_rule"`
+	NewMerchant    bool   `json:"new_merchant_rule"`
+	UnusualHour    bool   `json:"unusual_hour_rule"`
+	RapidRepeat    bool   `json:"rapid_repeat_rule"`
+	MinAmountPaisa int64  `json:"min_amount_paisa"`
+}
+
+// DefaultSettings apply to users who never saved any
+func DefaultSettings() Settings {
+	return Settings{
+		Enabled:        true,
+		Sensitivity:    Medium,
+		AmountRule:     true,
+		NewMerchant:    true,
+		UnusualHour:    true,
+		RapidRepeat:    true,
+		MinAmountPaisa: 50000,
+	}
+}
+
+// Thresholds are the rule parameters for a sensitivity level
+type Thresholds struct {
+	// ZScore flags amounts whose modified z-score exceeds it
+	ZScore float64
+	// HourShare flags hours holding less than this share of past debits
+	HourShare float64
+	// RapidCount debits at one merchant within RapidWindow are flagged
+	RapidCount  int
+	RapidWindow time.Duration
+}
+
+// ThresholdsFor returns the thresholds for sensitivity, Medium when unknown
+func ThresholdsFor(sensitivity string) Thresholds {
+	switch sensitivity {
+	case Low:
+		return Thresholds{ZScore: 5, HourShare: 0.005, RapidCount: 4, RapidWindow: 5 * time.Minute}
+	case High:
+		return Thresholds{ZScore: 2.5, HourShare: 0.02, RapidCount: 2, RapidWindow: 15 * time.Minute}
+	}
+	return Thresholds{ZScore: 3.5, HourShare: 0.01, RapidCount: 3, RapidWindow: 10 * time.Minute}
+}
+
+// Minimum history before the statistical rules apply
+const (
+	MinAmountSamples = 10
+	MinHourSamples   = 30
+	// MinMerchantSamples past debits are needed before a merchant counts
+	// as new rather than the user's history just being short
+	MinMerchantSamples = 20
+)
+
+// Txn is the debit being checked
+type Txn struct {
+	ID          string
+	AmountPaisa int64
+	Category    string
+	Merchant    string
+	// Local is the timestamp in the user's analytics timezone
+	Local time.Time
+}
+
+// History describes the user's debits before Txn
+type History struct {
+	// CategoryAmounts are past debit amounts in Txn's category
+	CategoryAmounts []int64
+	// MerchantSeen is whether Txn's merchant was debited before
+	MerchantSeen bool
+	// HourCounts counts past debits by local hour
+	HourCounts [24]int
+	// RecentAtMerchant counts debits at Txn's merchant within the rapid
+	// window before Txn, Txn included
+	RecentAtMerchant int
+}
+
+// Finding is one rule that fired
+type Finding struct {
+	Rule   string  `json:"rule"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+// maxScore caps scores so a zero-spread history stays JSON-encodable
+const maxScore = 99
+
+// Detect applies the enabled rules to t
+func Detect(t Txn, h History, s Settings) []Finding {
+	if !s.Enabled {
+		return nil
+	}
+	th := ThresholdsFor(s.Sensitivity)
+	out := []Finding{}
+	if s.AmountRule && t.AmountPaisa >= s.MinAmountPaisa && len(h.CategoryAmounts) >= MinAmountSamples {
+		if z := ModifiedZScore(t.AmountPaisa, h.CategoryAmounts); z > th.ZScore {
+			out = append(out, Finding{Rule: RuleAmount, Score: round2(z), Detail: "amount far above usual " + t.Category + " spend"})
+		}
+	}
+	total := 0
+	for _, c := range h.HourCounts {
+		total += c
+	}
+	if s.NewMerchant && t.Merchant != "" && !h.MerchantSeen && t.AmountPaisa >= s.MinAmountPaisa && total >= MinMerchantSamples {
+		out = append(out, Finding{Rule: RuleNewMerchant, Score: 1, Detail: "first debit at " + t.Merchant})
+	}
+	if s.UnusualHour {
+		if total >= MinHourSamples {
+			share := float64(h.HourCounts[t.Local.Hour()]) / float64(total)
+			if share < th.HourShare {
+				out = append(out, Finding{Rule: RuleUnusualHour, Score: round2(1 - share), Detail: "debit at an hour you rarely spend"})
+			}
+		}
+	}
+	if s.RapidRepeat && t.Merchant != "" && h.RecentAtMerchant >= th.RapidCount {
+		out = append(out, Finding{Rule: RuleRapidRepeat, Score: float64(h.RecentAtMerchant), Detail: "repeated debits at " + t.Merchant})
+	}
+	return out
+}
+
+// AlertReason picks the notification reason for findings. An unusual hour
+// on its own is kept for review without an alert.
+func AlertReason(findings []Finding) (string, bool) {
+	has := map[string]bool{}
+	for _, f := range findings {
+		has[f.Rule] = true
+	}
+	switch {
+	case has[RuleRapidRepeat], has[RuleUnusualHour] && len(findings) > 1:
+		return ReasonFraudSuspected, true
+	case has[RuleAmount]:
+		return ReasonLargeAmount, true
+	case has[RuleNewMerchant]:
+		return ReasonUnusualMerchant, true
+	}
+	return "", false
+}
+
+// ModifiedZScore is 0.6745(x - median) / MAD (Iglewicz and Hoaglin). When
+// more than half the samples are equal the MAD is zero and the mean
+// absolute deviation, scaled to match, is used instead.
+func ModifiedZScore(x int64, samples []int64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	vals := make([]float64, len(samples))
+	for i, v := range samples {
+		vals[i] = float64(v)
+	}
+	med := median(vals)
+	dev := make([]float64, len(vals))
+	sum := 0.0
+	for i, v := range vals {
+		dev[i] = math.Abs(v - med)
+		sum += dev[i]
+	}
+	diff := float64(x) - med
+	if mad := median(dev); mad > 0 {
+		return math.Min(0.6745*diff/mad, maxScore)
+	}
+	if meanAD := sum / float64(len(dev)); meanAD > 0 {
+		return math.Min(diff/(1.253314*meanAD), maxScore)
+	}
+	if diff > 0 {
+		return maxScore
+	}
+	return 0
+}
+
+func median(vals []float64) float64 {
+	s := append([]float64(nil), vals...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
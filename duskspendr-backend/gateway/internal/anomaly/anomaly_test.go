@@ -0,0 +1,101 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModifiedZScore(t *testing.T) {
+	samples := []int64{100, 110, 90, 105, 95, 100, 102, 98, 101, 99}
+	if z := ModifiedZScore(100, samples); z != 0 {
+		t.Errorf("median z = %v", z)
+	}
+	if z := ModifiedZScore(500, samples); z < 10 {
+		t.Errorf("outlier z = %v", z)
+	}
+	if z := ModifiedZScore(50, samples); z >= 0 {
+		t.Errorf("small amounts score negative, got %v", z)
+	}
+	// Mostly identical history: MAD is zero, mean deviation takes over
+	flat := []int64{100, 100, 100, 100, 100, 100, 100, 100, 100, 200}
+	if z := ModifiedZScore(1000, flat); z <= 0 || z >= maxScore {
+		t.Errorf("flat z = %v", z)
+	}
+	if z := ModifiedZScore(101, []int64{100, 100, 100}); z != maxScore {
+		t.Errorf("zero spread z = %v", z)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	loc := time.UTC
+	var hours [24]int
+	hours[12] = 40
+	hours[19] = 40
+	hist := History{
+		CategoryAmounts: []int64{40000, 45000, 50000, 55000, 60000, 42000, 48000, 52000, 58000, 50000},
+		MerchantSeen:    true,
+		HourCounts:      hours,
+	}
+	txn := Txn{ID: "t1", AmountPaisa: 900000, Category: "food", Merchant: "Swiggy", Local: time.Date(2024, 5, 1, 3, 0, 0, 0, loc)}
+
+	findings := Detect(txn, hist, DefaultSettings())
+	rules := map[string]bool{}
+	for _, f := range findings {
+		rules[f.Rule] = true
+	}
+	if !rules[RuleAmount] || !rules[RuleUnusualHour] || rules[RuleNewMerchant] || rules[RuleRapidRepeat] {
+		t.Fatalf("findings = %+v", findings)
+	}
+	if reason, ok := AlertReason(findings); !ok || reason != ReasonFraudSuspected {
+		t.Errorf("reason = %q", reason)
+	}
+
+	// Below the minimum amount only the hour fires, which is not alerted
+	small := txn
+	small.AmountPaisa = 20000
+	findings = Detect(small, hist, DefaultSettings())
+	if len(findings) != 1 || findings[0].Rule != RuleUnusualHour {
+		t.Fatalf("small findings = %+v", findings)
+	}
+	if _, ok := AlertReason(findings); ok {
+		t.Error("unusual hour alone should not alert")
+	}
+
+	// New merchant, usual hour
+	usual := txn
+	usual.AmountPaisa = 50000
+	usual.Local = time.Date(2024, 5, 1, 12, 30, 0, 0, loc)
+	findings = Detect(usual, History{HourCounts: hours, RecentAtMerchant: 1}, DefaultSettings())
+	if reason, ok := AlertReason(findings); !ok || reason != ReasonUnusualMerchant || len(findings) != 1 {
+		t.Errorf("new merchant findings = %+v", findings)
+	}
+
+	// A small first-time debit, or one with too little history, is not flagged
+	cheap := usual
+	cheap.AmountPaisa = 20000
+	if f := Detect(cheap, History{HourCounts: hours, RecentAtMerchant: 1}, DefaultSettings()); len(f) != 0 {
+		t.Errorf("small new merchant findings = %+v", f)
+	}
+	var sparse [24]int
+	sparse[12] = MinMerchantSamples - 1
+	if f := Detect(usual, History{HourCounts: sparse, RecentAtMerchant: 1}, DefaultSettings()); len(f) != 0 {
+		t.Errorf("short history new merchant findings = %+v", f)
+	}
+
+	// Three debits in ten minutes trip medium but not low sensitivity
+	rapid := History{MerchantSeen: true, HourCounts: hours, RecentAtMerchant: 3}
+	if reason, _ := AlertReason(Detect(usual, rapid, DefaultSettings())); reason != ReasonFraudSuspected {
+		t.Errorf("rapid reason = %q", reason)
+	}
+	low := DefaultSettings()
+	low.Sensitivity = Low
+	if f := Detect(usual, rapid, low); len(f) != 0 {
+		t.Errorf("low sensitivity findings = %+v", f)
+	}
+
+	off := DefaultSettings()
+	off.Enabled = false
+	if f := Detect(txn, hist, off); f != nil {
+		t.Errorf("disabled findings = %+v", f)
+	}
+}
@@ -0,0 +1,221 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/services"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Notifier is satisfied by *services.NotificationService
+type Notifier interface {
+	SendTransactionAlert(ctx context.Context, userID string, alert services.TransactionAlertPayload) error
+}
+
+// Lookback bounds the history the statistical rules read
+const Lookback = 180 * 24 * time.Hour
+
+// LoadSettings returns the user's settings, DefaultSettings when unset
+func LoadSettings(ctx context.Context, q Querier, userID string) (Settings, error) {
+	s := DefaultSettings()
+	err := q.QueryRow(ctx, `
+		SELECT enabled, sensitivity, amount_rule, new_merchant_rule, unusual_hour_rule,
+		       rapid_repeat_rule, min_amount_paisa
+		  FROM anomaly_settings WHERE user_id = $1
+	`, userID).Scan(&s.Enabled, &s.Sensitivity, &s.AmountRule, &s.NewMerchant, &s.UnusualHour,
+		&s.RapidRepeat, &s.MinAmountPaisa)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultSettings(), nil
+	}
+	return s, err
+}
+
+// Runner checks newly written debits and alerts on findings. A nil
+// *Runner does nothing.
+type Runner struct {
+	Pool     *pgxpool.Pool
+	Notifier Notifier
+	// Location defines the local hour for the unusual-hour rule
+	Location *time.Location
+	// MaxAge skips debits older than this, such as imported statements
+	MaxAge time.Duration
+}
+
+// Go runs Check in the background, logging failures
+func (r *Runner) Go(userID string, ids []string) {
+	if r == nil || len(ids) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := r.Check(ctx, userID, ids); err != nil {
+			log.Printf("anomaly: check for user %s failed: %v", userID, err)
+		}
+	}()
+}
+
+// Check runs the detector over the user's debits among ids, stores new
+// findings and sends one alert per transaction. Findings already stored
+// for a transaction are not alerted again.
+func (r *Runner) Check(ctx context.Context, userID string, ids []string) error {
+	settings, err := LoadSettings(ctx, r.Pool, userID)
+	if err != nil || !settings.Enabled {
+		return err
+	}
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	maxAge := r.MaxAge
+	if maxAge <= 0 {
+		maxAge = 7 * 24 * time.Hour
+	}
+
+	rows, err := r.Pool.Query(ctx, `
+		SELECT id::text, amount_paisa, category, coalesce(merchant_name, ''), timestamp
+		  FROM transactions
+		 WHERE user_id = $1 AND id = ANY($2::uuid[]) AND type = 'debit' AND timestamp >= $3
+		 ORDER BY timestamp
+	`, userID, ids, time.Now().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+	txns := []Txn{}
+	for rows.Next() {
+		var t Txn
+		if err := rows.Scan(&t.ID, &t.AmountPaisa, &t.Category, &t.Merchant, &t.Local); err != nil {
+			rows.Close()
+			return err
+		}
+		t.Local = t.Local.In(loc)
+		txns = append(txns, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	th := ThresholdsFor(settings.Sensitivity)
+	for _, t := range txns {
+		h, err := r.history(ctx, userID, t, loc, th.RapidWindow)
+		if err != nil {
+			return err
+		}
+		findings := Detect(t, h, settings)
+		if len(findings) == 0 {
+			continue
+		}
+		fresh, err := r.record(ctx, userID, t.ID, findings)
+		if err != nil {
+			return err
+		}
+		if fresh == 0 {
+			continue
+		}
+		if err := r.alert(ctx, userID, t, findings); err != nil {
+			log.Printf("anomaly: alert for transaction %s failed: %v", t.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) history(ctx context.Context, userID string, t Txn, loc *time.Location, window time.Duration) (History, error) {
+	var h History
+	at := t.Local.UTC()
+	err := r.Pool.QueryRow(ctx, `
+		SELECT
+		  (SELECT coalesce(array_agg(s.amount_paisa), '{}')
+		     FROM (SELECT amount_paisa FROM transactions
+		            WHERE user_id = $1 AND type = 'debit' AND category = $2 AND id <> $4::uuid
+		              AND timestamp <= $5 AND timestamp >= $6
+		            ORDER BY timestamp DESC LIMIT 500) s),
+		  EXISTS (SELECT 1 FROM transactions
+		           WHERE user_id = $1 AND type = 'debit' AND merchant_name = $3
+		             AND id <> $4::uuid AND timestamp <= $5),
+		  (SELECT count(*) FROM transactions
+		    WHERE user_id = $1 AND type = 'debit' AND merchant_name = $3
+		      AND timestamp <= $5 AND timestamp > $7)
+	`, userID, t.Category, t.Merchant, t.ID, at, at.Add(-Lookback), at.Add(-window)).
+		Scan(&h.CategoryAmounts, &h.MerchantSeen, &h.RecentAtMerchant)
+	if err != nil {
+		return h, err
+	}
+
+	rows, err := r.Pool.Query(ctx, `
+		SELECT extract(hour FROM timestamp AT TIME ZONE $2)::int, count(*)
+		  FROM transactions
+		 WHERE user_id = $1 AND type = 'debit' AND id <> $3::uuid
+		   AND timestamp <= $4 AND timestamp >= $5
+		 GROUP BY 1
+	`, userID, loc.String(), t.ID, at, at.Add(-Lookback))
+	if err != nil {
+		return h, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hour, n int
+		if err := rows.Scan(&hour, &n); err != nil {
+			return h, err
+		}
+		if hour >= 0 && hour < 24 {
+			h.HourCounts[hour] = n
+		}
+	}
+	return h, rows.Err()
+}
+
+// record stores findings, skipping rules already flagged for the
+// transaction, and returns how many were new.
+func (r *Runner) record(ctx context.Context, userID, txnID string, findings []Finding) (int, error) {
+	reason, _ := AlertReason(findings)
+	fresh := 0
+	for _, f := range findings {
+		cmd, err := r.Pool.Exec(ctx, `
+			INSERT INTO anomaly_events (user_id, transaction_id, rule, reason, score, detail)
+			VALUES ($1, $2, $3, nullif($4, ''), $5, $6)
+			ON CONFLICT (transaction_id, rule) DO NOTHING
+		`, userID, txnID, f.Rule, reason, f.Score, f.Detail)
+		if err != nil {
+			return fresh, err
+		}
+		fresh += int(cmd.RowsAffected())
+	}
+	return fresh, nil
+}
+
+func (r *Runner) alert(ctx context.Context, userID string, t Txn, findings []Finding) error {
+	reason, ok := AlertReason(findings)
+	if !ok || r.Notifier == nil {
+		return nil
+	}
+	merchant := t.Merchant
+	if merchant == "" {
+		merchant = "an unknown merchant"
+	}
+	if err := r.Notifier.SendTransactionAlert(ctx, userID, services.TransactionAlertPayload{
+		TransactionID: t.ID,
+		MerchantName:  merchant,
+		Amount:        float64(t.AmountPaisa) / 100,
+		Category:      t.Category,
+		AlertReason:   reason,
+	}); err != nil {
+		return err
+	}
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE anomaly_events SET notified_at = now()
+		 WHERE transaction_id = $1 AND notified_at IS NULL
+	`, t.ID)
+	return err
+}
@@ -0,0 +1,168 @@
+// Package category defines the spending categories transactions are filed
+// under.
+//
+// Every user sees the system defaults (the built-ins below, optionally
+// overridden by rows in the categories table with no user) plus their own
+// categories. Categories form a two-level hierarchy: a custom category may
+// sit under any top-level category as a subcategory. Transactions and
+// budgets refer to a category by its Key.
+package category
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Other is the catch-all category every user has
+const Other = "other"
+
+type Category struct {
+	Key    string  `json:"key"`
+	Name   string  `json:"name"`
+	Parent *string `json:"parent,omitempty"`
+	Icon   *string `json:"icon,omitempty"`
+	Color  *string `json:"color,omitempty"`
+	// System is true for the defaults shared by all users
+	System bool `json:"system"`
+}
+
+func sys(key, name, icon, color string) Category {
+	return Category{Key: key, Name: name, Icon: &icon, Color: &color, System: true}
+}
+
+var builtin = []Category{
+	sys("food", "Food & Dining", "restaurant", "#FF7043"),
+	sys("transportation", "Transportation", "directions_car", "#42A5F5"),
+	sys("entertainment", "Entertainment", "movie", "#AB47BC"),
+	sys("education", "Education", "school", "#5C6BC0"),
+	sys("shopping", "Shopping", "shopping_bag", "#EC407A"),
+	sys("utilities", "Utilities", "bolt", "#FFA726"),
+	sys("healthcare", "Healthcare", "local_hospital", "#EF5350"),
+	sys("subscriptions", "Subscriptions", "autorenew", "#7E57C2"),
+	sys("investments", "Investments", "trending_up", "#66BB6A"),
+	sys("loans", "Loans", "account_balance", "#8D6E63"),
+	sys("shared", "Shared", "group", "#26A69A"),
+	sys("pocketMoney", "Pocket Money", "savings", "#FFCA28"),
+	sys(Other, "Other", "more_horiz", "#9E9E9E"),
+}
+
+// Builtin returns the built-in system categories
+func Builtin() []Category {
+	out := make([]Category, len(builtin))
+	copy(out, builtin)
+	return out
+}
+
+// Set is a user's category set keyed by Key
+type Set map[string]Category
+
+// NewSet returns the built-ins with cats applied over them in order, so a
+// later entry with the same key replaces an earlier one.
+func NewSet(cats ...Category) Set {
+	s := Set{}
+	for _, c := range builtin {
+		s[c.Key] = c
+	}
+	for _, c := range cats {
+		s[c.Key] = c
+	}
+	return s
+}
+
+// Has reports whether key is a category in the set
+func (s Set) Has(key string) bool {
+	_, ok := s[key]
+	return ok
+}
+
+// Children returns the keys of key's subcategories
+func (s Set) Children(key string) []string {
+	out := []string{}
+	for k, c := range s {
+		if c.Parent != nil && *c.Parent == key {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// WithChildren returns key followed by its subcategories, which is what a
+// filter or budget on key should match.
+func (s Set) WithChildren(key string) []string {
+	return append([]string{key}, s.Children(key)...)
+}
+
+// List returns the categories ordered as a tree: each top-level category by
+// name, followed by its subcategories by name.
+func (s Set) List() []Category {
+	out := make([]Category, 0, len(s))
+	for _, c := range s {
+		out = append(out, c)
+	}
+	root := func(c Category) string {
+		if c.Parent != nil {
+			if p, ok := s[*c.Parent]; ok {
+				return strings.ToLower(p.Name) + "\x00" + p.Key
+			}
+		}
+		return strings.ToLower(c.Name) + "\x00" + c.Key
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := root(out[i]), root(out[j])
+		if ri != rj {
+			return ri < rj
+		}
+		if (out[i].Parent == nil) != (out[j].Parent == nil) {
+			return out[i].Parent == nil
+		}
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out
+}
+
+var (
+	keyPattern   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,49}$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// ValidKey reports whether key can be used as a category key
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// ValidColor reports whether color is a #RRGGBB colour
+func ValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// KeyFor derives a camelCase key from a display name, in the style of the
+// built-in keys: "Rent & Bills" becomes "rentBills".
+func KeyFor(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if b.Len() == 0 {
+				if unicode.IsDigit(r) {
+					continue
+				}
+				b.WriteRune(unicode.ToLower(r))
+			} else if upper {
+				b.WriteRune(unicode.ToUpper(r))
+			} else {
+				b.WriteRune(r)
+			}
+			upper = false
+		default:
+			upper = b.Len() > 0
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package category
+
+import "testing"
+
+func strPtr(s string) *string { return &s }
+
+func TestKeyFor(t *testing.T) {
+	tests := map[string]string{
+		"Rent & Bills":      "rentBills",
+		"groceries":         "groceries",
+		"  Gym  membership": "gymMembership",
+		"2nd hand books":    "ndHandBooks",
+	}
+	for name, want := range tests {
+		if got := KeyFor(name); got != want {
+			t.Errorf("KeyFor(%q) = %q, want %q", name, got, want)
+		}
+		if !ValidKey(KeyFor(name)) {
+			t.Errorf("KeyFor(%q) is not a valid key", name)
+		}
+	}
+}
+
+func TestSetHierarchy(t *testing.T) {
+	s := NewSet(
+		Category{Key: "groceries", Name: "Groceries", Parent: strPtr("food")},
+		Category{Key: "eatingOut", Name: "Eating out", Parent: strPtr("food")},
+		Category{Key: "rent", Name: "Rent"},
+	)
+	if !s.Has("pocketMoney") || !s.Has("rent") || s.Has("nope") {
+		t.Fatal("set membership wrong")
+	}
+	got := s.WithChildren("food")
+	if len(got) != 3 || got[0] != "food" || got[1] != "eatingOut" || got[2] != "groceries" {
+		t.Fatalf("WithChildren(food) = %v", got)
+	}
+
+	list := s.List()
+	for i, c := range list {
+		if c.Key == "food" {
+			if list[i+1].Key != "eatingOut" || list[i+2].Key != "groceries" {
+				t.Fatalf("subcategories should follow their parent: %v %v", list[i+1].Key, list[i+2].Key)
+			}
+			return
+		}
+	}
+	t.Fatal("food missing from list")
+}
+
+func TestValidColor(t *testing.T) {
+	if !ValidColor("#FF7043") || ValidColor("red") || ValidColor("#FFF") {
+		t.Fatal("colour validation wrong")
+	}
+}
@@ -0,0 +1,49 @@
+package category
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Load returns userID's category set: the built-ins, system rows from the
+// categories table, then the user's own rows. A user row with a system key
+// only changes that category's name, icon and colour.
+func Load(ctx context.Context, q Querier, userID string) (Set, error) {
+	rows, err := q.Query(ctx, `
+		SELECT key, name, parent_key, icon, color, user_id IS NULL
+		  FROM categories
+		 WHERE user_id IS NULL OR user_id = $1
+		 ORDER BY user_id NULLS FIRST
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s := NewSet()
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.Key, &c.Name, &c.Parent, &c.Icon, &c.Color, &c.System); err != nil {
+			return nil, err
+		}
+		if base, ok := s[c.Key]; ok && base.System && !c.System {
+			base.Name = c.Name
+			if c.Icon != nil {
+				base.Icon = c.Icon
+			}
+			if c.Color != nil {
+				base.Color = c.Color
+			}
+			s[c.Key] = base
+			continue
+		}
+		s[c.Key] = c
+	}
+	return s, rows.Err()
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,11 +29,18 @@ type Config struct {
 	ServerpodURL string
 
 	// JWT
-	JWTSecret            string
-	JWTRefreshSecret     string
-	JWTAccessExpiry      time.Duration
-	JWTRefreshExpiry     time.Duration
-	AllowedOrigins       string
+	JWTSecret        string
+	JWTLegacyUntil   time.Time
+	JWTAccessExpiry  time.Duration
+	JWTRefreshExpiry time.Duration
+	AllowedOrigins   string
+
+	// JWT signing keys
+	JWTSigningAlg          string
+	JWTKeyRotationInterval time.Duration
+	JWTKeyPrepublish       time.Duration
+	JWTKeyReloadInterval   time.Duration
+	JWTKeyEncryptionKey    string
 
 	// Auth
 	AuthPepper           string
@@ -38,11 +49,49 @@ type Config struct {
 	OTPMaxAttempts       int
 	OTPMaxPerIPPerHour   int
 
+	// OTP channels. OTPChannels is the fallback order; a channel without
+	// a configured provider is skipped, or logged outside production.
+	OTPChannels              string
+	OTPMaxPerHourSMS         int
+	OTPMaxPerHourWhatsApp    int
+	OTPMaxPerHourVoice       int
+	OTPResendWindow          time.Duration
+	WhatsAppPhoneNumberID    string
+	WhatsAppAccessToken      string
+	WhatsAppOTPTemplate      string
+	WhatsAppTemplateLanguage string
+
+	// Passkeys (WebAuthn)
+	WebAuthnRPID         string
+	WebAuthnRPName       string
+	WebAuthnOrigins      string
+	WebAuthnChallengeTTL time.Duration
+
+	// Second factor (TOTP) and step-up
+	TOTPIssuer        string
+	TOTPEncryptionKey string
+	StepUpTTL         time.Duration
+
+	// Account deletion
+	AccountDeletionGrace time.Duration
+	ErasureInterval      time.Duration
+
 	// Twilio
 	TwilioAccountSID string
 	TwilioAuthToken  string
 	TwilioFromNumber string
 
+	// SMS providers. SMSProviders is the failover order; when empty every
+	// provider with credentials is used, Indian gateways first.
+	SMSProviders     string
+	SMSDLTTemplateID string
+	SMSDLTEntityID   string
+	SMSSenderID      string
+	MSG91AuthKey     string
+	GupshupUserID    string
+	GupshupPassword  string
+	SMSWebhookToken  string
+
 	// Services
 	AIServiceURL        string
 	AnalyticsServiceURL string
@@ -56,11 +105,52 @@ type Config struct {
 	SyncIngestRPM    int
 	SyncIngestBurst  int
 	SyncIngestIPRPM  int
+	SyncMaxSkew      time.Duration
+
+	// Sync outbox
+	SyncOutboxPollInterval time.Duration
+	SyncOutboxMaxAttempts  int
+
+	// SMS parsing
+	SMSTemplateReloadInterval time.Duration
+
+	// Merchant directory
+	MerchantReloadInterval time.Duration
+
+	// Analytics
+	AnalyticsTimezone string
+
+	// Anomaly detection
+	AnomalyMaxAge time.Duration
+
+	// Net worth
+	NetWorthSnapshotInterval time.Duration
+
+	// Exchange rates
+	FXMaxRateAge time.Duration
+	AdminToken   string
 
 	// Integrations
 	UpstoxClientID     string
 	UpstoxClientSecret string
 	UpstoxRedirectURI  string
+	ZerodhaAPIKey      string
+	ZerodhaAPISecret   string
+}
+
+// Placeholder secrets used when the environment does not set them; Validate
+// rejects them in production
+const (
+	DefaultJWTSecret = "your-super-secret-key-change-in-production"
+)
+
+// placeholderSecrets are values copied from .env.example that must not
+// reach production
+var placeholderSecrets = map[string]bool{
+	"":               true,
+	"change_me":      true,
+	"change_me_too":  true,
+	DefaultJWTSecret: true,
 }
 
 func Load() Config {
@@ -83,12 +173,19 @@ func Load() Config {
 		ServerpodURL: getEnv("SERVERPOD_URL", "http://localhost:8090"),
 
 		// JWT
-		JWTSecret:        getEnv("JWT_SECRET", "your-super-secret-key-change-in-production"),
-		JWTRefreshSecret: getEnv("JWT_REFRESH_SECRET", "your-refresh-secret-key-change-in-production"),
+		JWTSecret:        getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTLegacyUntil:   getTimeEnv("JWT_LEGACY_UNTIL"),
 		JWTAccessExpiry:  getDurationEnv("JWT_ACCESS_EXPIRY", 15*time.Minute),
 		JWTRefreshExpiry: getDurationEnv("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
 		AllowedOrigins:   getEnv("ALLOWED_ORIGINS", "*"),
 
+		// JWT signing keys
+		JWTSigningAlg:          getEnv("JWT_SIGNING_ALG", "RS256"),
+		JWTKeyRotationInterval: getDurationEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		JWTKeyPrepublish:       getDurationEnv("JWT_KEY_PREPUBLISH", 24*time.Hour),
+		JWTKeyReloadInterval:   getDurationEnv("JWT_KEY_RELOAD_INTERVAL", 5*time.Minute),
+		JWTKeyEncryptionKey:    getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+
 		// Auth
 		AuthPepper:           getEnv("AUTH_PEPPER", ""),
 		OTPMaxPerHour:        getEnvInt("OTP_MAX_PER_HOUR", 5),
@@ -96,11 +193,47 @@ func Load() Config {
 		OTPMaxAttempts:       getEnvInt("OTP_MAX_ATTEMPTS", 5),
 		OTPMaxPerIPPerHour:   getEnvInt("OTP_MAX_PER_IP_PER_HOUR", 30),
 
+		// OTP channels
+		OTPChannels:              getEnv("OTP_CHANNELS", "sms,whatsapp,voice"),
+		OTPMaxPerHourSMS:         getEnvInt("OTP_MAX_PER_HOUR_SMS", 5),
+		OTPMaxPerHourWhatsApp:    getEnvInt("OTP_MAX_PER_HOUR_WHATSAPP", 5),
+		OTPMaxPerHourVoice:       getEnvInt("OTP_MAX_PER_HOUR_VOICE", 3),
+		OTPResendWindow:          getDurationEnv("OTP_RESEND_WINDOW", 15*time.Minute),
+		WhatsAppPhoneNumberID:    getEnv("WHATSAPP_PHONE_NUMBER_ID", ""),
+		WhatsAppAccessToken:      getEnv("WHATSAPP_ACCESS_TOKEN", ""),
+		WhatsAppOTPTemplate:      getEnv("WHATSAPP_OTP_TEMPLATE", "otp_code"),
+		WhatsAppTemplateLanguage: getEnv("WHATSAPP_TEMPLATE_LANGUAGE", "en"),
+
+		// Passkeys (WebAuthn)
+		WebAuthnRPID:         getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:       getEnv("WEBAUTHN_RP_NAME", "DuskSpendr"),
+		WebAuthnOrigins:      getEnv("WEBAUTHN_ORIGINS", "http://localhost:8000"),
+		WebAuthnChallengeTTL: getDurationEnv("WEBAUTHN_CHALLENGE_TTL", 5*time.Minute),
+
+		// Second factor (TOTP) and step-up
+		TOTPIssuer:        getEnv("TOTP_ISSUER", "DuskSpendr"),
+		TOTPEncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+		StepUpTTL:         getDurationEnv("STEP_UP_TTL", 5*time.Minute),
+
+		// Account deletion
+		AccountDeletionGrace: getDurationEnv("ACCOUNT_DELETION_GRACE", 14*24*time.Hour),
+		ErasureInterval:      getDurationEnv("ERASURE_INTERVAL", 15*time.Minute),
+
 		// Twilio
 		TwilioAccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
 		TwilioAuthToken:  getEnv("TWILIO_AUTH_TOKEN", ""),
 		TwilioFromNumber: getEnv("TWILIO_FROM_NUMBER", ""),
 
+		// SMS providers
+		SMSProviders:     getEnv("SMS_PROVIDERS", ""),
+		SMSDLTTemplateID: getEnv("SMS_DLT_TEMPLATE_ID", ""),
+		SMSDLTEntityID:   getEnv("SMS_DLT_ENTITY_ID", ""),
+		SMSSenderID:      getEnv("SMS_SENDER_ID", "DUSKSP"),
+		MSG91AuthKey:     getEnv("MSG91_AUTH_KEY", ""),
+		GupshupUserID:    getEnv("GUPSHUP_USER_ID", ""),
+		GupshupPassword:  getEnv("GUPSHUP_PASSWORD", ""),
+		SMSWebhookToken:  getEnv("SMS_WEBHOOK_TOKEN", ""),
+
 		// Services
 		AIServiceURL:        getEnv("AI_SERVICE_URL", "http://localhost:8001"),
 		AnalyticsServiceURL: getEnv("ANALYTICS_SERVICE_URL", "http://localhost:8002"),
@@ -114,14 +247,208 @@ func Load() Config {
 		SyncIngestRPM:    getEnvInt("SYNC_INGEST_RPM", 120),
 		SyncIngestBurst:  getEnvInt("SYNC_INGEST_BURST", 60),
 		SyncIngestIPRPM:  getEnvInt("SYNC_INGEST_IP_RPM", 600),
+		SyncMaxSkew:      getDurationEnv("SYNC_MAX_SKEW", 5*time.Minute),
+
+		// Sync outbox
+		SyncOutboxPollInterval: getDurationEnv("SYNC_OUTBOX_POLL_INTERVAL", 5*time.Second),
+		SyncOutboxMaxAttempts:  getEnvInt("SYNC_OUTBOX_MAX_ATTEMPTS", 10),
+
+		// SMS parsing
+		SMSTemplateReloadInterval: getDurationEnv("SMS_TEMPLATE_RELOAD_INTERVAL", 5*time.Minute),
+
+		// Merchant directory
+		MerchantReloadInterval: getDurationEnv("MERCHANT_RELOAD_INTERVAL", 5*time.Minute),
+
+		// Analytics
+		AnalyticsTimezone: getEnv("ANALYTICS_TIMEZONE", "Asia/Kolkata"),
+
+		// Anomaly detection
+		AnomalyMaxAge: getDurationEnv("ANOMALY_MAX_AGE", 7*24*time.Hour),
+
+		// Net worth
+		NetWorthSnapshotInterval: getDurationEnv("NETWORTH_SNAPSHOT_INTERVAL", time.Hour),
+
+		// Exchange rates
+		FXMaxRateAge: getDurationEnv("FX_MAX_RATE_AGE", 7*24*time.Hour),
+		AdminToken:   getEnv("ADMIN_TOKEN", ""),
 
 		// Integrations
 		UpstoxClientID:     getEnv("UPSTOX_CLIENT_ID", ""),
 		UpstoxClientSecret: getEnv("UPSTOX_CLIENT_SECRET", ""),
 		UpstoxRedirectURI:  getEnv("UPSTOX_REDIRECT_URI", ""),
+		ZerodhaAPIKey:      getEnv("ZERODHA_API_KEY", ""),
+		ZerodhaAPISecret:   getEnv("ZERODHA_API_SECRET", ""),
 	}
 }
 
+// Validate reports configuration the server must not start with. In
+// production that includes default or placeholder secrets and signing keys
+// that would not be shared between instances.
+func (c Config) Validate() error {
+	var problems []string
+	if c.JWTSigningAlg != "RS256" && c.JWTSigningAlg != "EdDSA" {
+		problems = append(problems, fmt.Sprintf("JWT_SIGNING_ALG must be RS256 or EdDSA, got %q", c.JWTSigningAlg))
+	}
+	if c.JWTKeyPrepublish >= c.JWTKeyRotationInterval {
+		problems = append(problems, "JWT_KEY_PREPUBLISH must be shorter than JWT_KEY_ROTATION_INTERVAL")
+	}
+	if c.JWTKeyReloadInterval <= 0 || c.JWTKeyReloadInterval >= c.JWTKeyPrepublish {
+		problems = append(problems, "JWT_KEY_RELOAD_INTERVAL must be positive and shorter than JWT_KEY_PREPUBLISH")
+	}
+	// Legacy HS256 access tokens only need to outlive one access lifetime
+	// after the deploy that switched to the key ring
+	if c.JWTLegacyUntil.After(time.Now().Add(c.JWTAccessExpiry)) {
+		problems = append(problems, "JWT_LEGACY_UNTIL must be at most JWT_ACCESS_EXPIRY from now")
+	}
+	for _, name := range c.OTPChannelList() {
+		if name != "sms" && name != "whatsapp" && name != "voice" {
+			problems = append(problems, fmt.Sprintf("OTP_CHANNELS lists unknown channel %q", name))
+		}
+	}
+	providers := c.SMSProviderList()
+	for _, name := range providers {
+		if !c.smsProviderConfigured(name) {
+			problems = append(problems, fmt.Sprintf("SMS_PROVIDERS lists %q which is unknown or has no credentials", name))
+		}
+	}
+	if c.Env == "production" {
+		secrets := []struct{ name, value string }{
+			{"JWT_SECRET", c.JWTSecret},
+			{"AUTH_PEPPER", c.AuthPepper},
+			{"SYNC_SHARED_SECRET", c.SyncSharedSecret},
+		}
+		for _, s := range secrets {
+			if placeholderSecrets[s.value] {
+				problems = append(problems, s.name+" is unset or a default placeholder")
+			}
+		}
+		if c.TOTPEncryptionKey == "" {
+			problems = append(problems, "TOTP_ENCRYPTION_KEY is required to seal TOTP secrets")
+		}
+		if c.WebAuthnRPID == "" || c.WebAuthnRPID == "localhost" {
+			problems = append(problems, "WEBAUTHN_RP_ID must be the production domain")
+		}
+		if c.JWTKeyEncryptionKey == "" {
+			problems = append(problems, "JWT_KEY_ENCRYPTION_KEY is required so signing keys are shared between instances")
+		}
+		senders := 0
+		for _, name := range providers {
+			switch name {
+			case "log":
+				continue
+			case "msg91", "gupshup":
+				if c.SMSDLTTemplateID == "" {
+					problems = append(problems, "SMS_DLT_TEMPLATE_ID is required to send OTPs through "+name)
+				}
+				if name == "gupshup" && c.SMSDLTEntityID == "" {
+					problems = append(problems, "SMS_DLT_ENTITY_ID is required to send OTPs through gupshup")
+				}
+			}
+			senders++
+		}
+		if senders == 0 {
+			problems = append(problems, "no SMS provider is configured; OTPs would only be logged")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// WebAuthnOriginList splits WebAuthnOrigins. Besides https origins it may
+// hold app origins such as android:apk-key-hash:<hash>.
+func (c Config) WebAuthnOriginList() []string {
+	var origins []string
+	for _, o := range strings.Split(c.WebAuthnOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// OTPChannelList returns the OTP channels in fallback order. SMS is always
+// available and added last when OTPChannels leaves it out.
+func (c Config) OTPChannelList() []string {
+	var names []string
+	hasSMS := false
+	for _, n := range strings.Split(c.OTPChannels, ",") {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			names = append(names, n)
+			hasSMS = hasSMS || n == "sms"
+		}
+	}
+	if !hasSMS {
+		names = append(names, "sms")
+	}
+	return names
+}
+
+// OTPMaxPerHourFor is the hourly per-phone limit of codes sent over channel
+func (c Config) OTPMaxPerHourFor(channel string) int {
+	switch channel {
+	case "whatsapp":
+		return c.OTPMaxPerHourWhatsApp
+	case "voice":
+		return c.OTPMaxPerHourVoice
+	}
+	return c.OTPMaxPerHourSMS
+}
+
+// WhatsAppConfigured reports whether WhatsApp Cloud API credentials are set
+func (c Config) WhatsAppConfigured() bool {
+	return c.WhatsAppPhoneNumberID != "" && c.WhatsAppAccessToken != "" && c.WhatsAppOTPTemplate != ""
+}
+
+// SMSProviderList returns the SMS providers in failover order: those named
+// in SMSProviders, or else every provider with credentials with MSG91 and
+// Gupshup ahead of Twilio. Without any it is just the log sender.
+func (c Config) SMSProviderList() []string {
+	var names []string
+	for _, n := range strings.Split(c.SMSProviders, ",") {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) > 0 {
+		return names
+	}
+	for _, n := range []string{"msg91", "gupshup", "twilio"} {
+		if c.smsProviderConfigured(n) {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "log")
+	}
+	return names
+}
+
+func (c Config) smsProviderConfigured(name string) bool {
+	switch name {
+	case "msg91":
+		return c.MSG91AuthKey != "" && c.SMSSenderID != ""
+	case "gupshup":
+		return c.GupshupUserID != "" && c.GupshupPassword != ""
+	case "twilio":
+		return c.TwilioAccountSID != "" && c.TwilioAuthToken != "" && c.TwilioFromNumber != ""
+	case "log":
+		return true
+	}
+	return false
+}
+
+// AnalyticsLocation loads AnalyticsTimezone, falling back to UTC
+func (c Config) AnalyticsLocation() *time.Location {
+	loc, err := time.LoadLocation(c.AnalyticsTimezone)
+	if err != nil {
+		log.Printf("config: unknown ANALYTICS_TIMEZONE %q, using UTC: %v", c.AnalyticsTimezone, err)
+		return time.UTC
+	}
+	return loc
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -138,6 +465,17 @@ func getEnvInt(key string, def int) int {
 	return def
 }
 
+// getTimeEnv parses an RFC 3339 time, returning the zero time when unset or
+// invalid
+func getTimeEnv(key string) time.Time {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := time.Parse(time.RFC3339, v); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
 func getDurationEnv(key string, def time.Duration) time.Duration {
 	if v := os.Getenv(key); v != "" {
 		if parsed, err := time.ParseDuration(v); err == nil {
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validProduction() Config {
+	return Config{
+		Env:                    "production",
+		JWTSecret:              "a-real-access-secret",
+		JWTAccessExpiry:        15 * time.Minute,
+		AuthPepper:             "a-real-pepper",
+		SyncSharedSecret:       "a-real-sync-secret",
+		JWTSigningAlg:          "RS256",
+		JWTKeyRotationInterval: 30 * 24 * time.Hour,
+		JWTKeyPrepublish:       24 * time.Hour,
+		JWTKeyReloadInterval:   5 * time.Minute,
+		JWTKeyEncryptionKey:    "c2VjcmV0",
+		WebAuthnRPID:           "duskspendr.app",
+		TOTPEncryptionKey:      "c2VjcmV0",
+		MSG91AuthKey:           "a-real-msg91-key",
+		SMSSenderID:            "DUSKSP",
+		SMSDLTTemplateID:       "1107160000000000001",
+	}
+}
+
+func TestValidateProduction(t *testing.T) {
+	if err := validProduction().Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	cfg := validProduction()
+	cfg.JWTSecret = DefaultJWTSecret
+	cfg.AuthPepper = "change_me"
+	cfg.JWTKeyEncryptionKey = ""
+	cfg.WebAuthnRPID = "localhost"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("default secrets accepted in production")
+	}
+	for _, want := range []string{"JWT_SECRET", "AUTH_PEPPER", "JWT_KEY_ENCRYPTION_KEY", "WEBAUTHN_RP_ID"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not name %s", err, want)
+		}
+	}
+
+	cfg.Env = "local"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults rejected outside production: %v", err)
+	}
+}
+
+func TestValidateSigningSettings(t *testing.T) {
+	cfg := validProduction()
+	cfg.JWTSigningAlg = "HS256"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("HS256 accepted as signing algorithm")
+	}
+
+	cfg = validProduction()
+	cfg.JWTKeyReloadInterval = 48 * time.Hour
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("reload interval longer than prepublish accepted")
+	}
+
+	cfg = validProduction()
+	cfg.JWTLegacyUntil = time.Now().Add(10 * time.Minute)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("legacy cutoff within one access lifetime rejected: %v", err)
+	}
+	cfg.JWTLegacyUntil = time.Now().Add(24 * time.Hour)
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "JWT_LEGACY_UNTIL") {
+		t.Fatalf("legacy cutoff a day out accepted: %v", err)
+	}
+}
+
+func TestWebAuthnOriginList(t *testing.T) {
+	cfg := Config{WebAuthnOrigins: " https://duskspendr.app, ,android:apk-key-hash:abc "}
+	got := cfg.WebAuthnOriginList()
+	if len(got) != 2 || got[0] != "https://duskspendr.app" || got[1] != "android:apk-key-hash:abc" {
+		t.Fatalf("origins = %q", got)
+	}
+}
+
+func TestSMSProviderList(t *testing.T) {
+	cfg := Config{SMSSenderID: "DUSKSP", TwilioAccountSID: "AC1", TwilioAuthToken: "t", TwilioFromNumber: "+1555"}
+	if got := cfg.SMSProviderList(); len(got) != 1 || got[0] != "twilio" {
+		t.Fatalf("detected = %q", got)
+	}
+	cfg.MSG91AuthKey = "k"
+	if got := cfg.SMSProviderList(); len(got) != 2 || got[0] != "msg91" || got[1] != "twilio" {
+		t.Fatalf("detected = %q, want msg91 before twilio", got)
+	}
+	cfg.SMSProviders = " Twilio,msg91 "
+	if got := cfg.SMSProviderList(); len(got) != 2 || got[0] != "twilio" || got[1] != "msg91" {
+		t.Fatalf("explicit = %q", got)
+	}
+	if got := (Config{}).SMSProviderList(); len(got) != 1 || got[0] != "log" {
+		t.Fatalf("fallback = %q", got)
+	}
+}
+
+func TestValidateSMSProviders(t *testing.T) {
+	cfg := validProduction()
+	cfg.SMSDLTTemplateID = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "SMS_DLT_TEMPLATE_ID") {
+		t.Fatalf("msg91 without DLT template accepted: %v", err)
+	}
+
+	cfg = validProduction()
+	cfg.MSG91AuthKey = ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "no SMS provider") {
+		t.Fatalf("log-only SMS accepted in production: %v", err)
+	}
+
+	cfg = Config{Env: "local", JWTSigningAlg: "RS256", JWTKeyRotationInterval: time.Hour,
+		JWTKeyPrepublish: time.Minute, JWTKeyReloadInterval: time.Second, SMSProviders: "gupshup"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "gupshup") {
+		t.Fatalf("gupshup without credentials accepted: %v", err)
+	}
+}
+
+func TestOTPChannelList(t *testing.T) {
+	cfg := Config{OTPChannels: "WhatsApp, voice"}
+	if got := cfg.OTPChannelList(); strings.Join(got, ",") != "whatsapp,voice,sms" {
+		t.Fatalf("channels = %q, want sms appended", got)
+	}
+	cfg = Config{OTPMaxPerHourSMS: 5, OTPMaxPerHourVoice: 3}
+	if cfg.OTPMaxPerHourFor("voice") != 3 || cfg.OTPMaxPerHourFor("sms") != 5 {
+		t.Fatal("per-channel limits not applied")
+	}
+
+	prod := validProduction()
+	prod.OTPChannels = "sms,pigeon"
+	if err := prod.Validate(); err == nil || !strings.Contains(err.Error(), "pigeon") {
+		t.Fatalf("unknown channel accepted: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/outbox"
+	"duskspendr-gateway/internal/services"
+)
+
+// ErasedEvent is the data of the outbox event telling serverpod to erase
+// the user's synced data
+type ErasedEvent struct {
+	UserID    string    `json:"user_id"`
+	ReceiptID string    `json:"receipt_id"`
+	ErasedAt  time.Time `json:"erased_at"`
+}
+
+// Eraser erases the accounts whose grace period is over
+type Eraser struct {
+	Pool     *pgxpool.Pool
+	Interval time.Duration
+	// Denylist, when shared between instances, stops the user's access
+	// tokens at once instead of when they expire
+	Denylist  services.TokenDenylist
+	AccessTTL time.Duration
+	BatchSize int
+}
+
+// Run erases due accounts every Interval until ctx is cancelled
+func (e *Eraser) Run(ctx context.Context) {
+	if e.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := e.EraseDue(ctx); err != nil {
+			log.Printf("erasure failed: %v", err)
+		} else if n > 0 {
+			log.Printf("erasure: erased %d accounts", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EraseDue erases up to BatchSize accounts whose deletion is due. Each
+// runs in its own transaction with the deletion row locked, so replicas
+// never erase the same account twice.
+func (e *Eraser) EraseDue(ctx context.Context) (int, error) {
+	batch := e.BatchSize
+	if batch <= 0 {
+		batch = 50
+	}
+	done := 0
+	for done < batch {
+		erased, err := e.eraseNext(ctx)
+		if err != nil || !erased {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (e *Eraser) eraseNext(ctx context.Context) (bool, error) {
+	tx, err := e.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := scan(tx.QueryRow(ctx, `
+		SELECT `+columns+`
+		  FROM account_deletions
+		 WHERE status = 'scheduled' AND scheduled_for <= now()
+		 ORDER BY scheduled_for
+		 LIMIT 1
+		 FOR UPDATE SKIP LOCKED
+	`))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	families, err := Erase(ctx, tx, d, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	if e.Denylist != nil {
+		for _, f := range families {
+			if err := e.Denylist.DenyFamily(ctx, f, e.AccessTTL); err != nil {
+				log.Printf("erasure: denying token family of erased user failed: %v", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// Erase deletes the user of d inside tx and completes d as its receipt. It
+// returns the user's live refresh token families so their access tokens can
+// be denied once tx commits.
+//
+// Pending and delivered outbox events of the user are dropped, since they
+// carry transaction data, and replaced by one user.erased event.
+func Erase(ctx context.Context, tx pgx.Tx, d *Deletion, now time.Time) ([]string, error) {
+	counts, err := Count(ctx, tx, d.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT family_id FROM refresh_tokens
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+	`, d.UserID)
+	if err != nil {
+		return nil, err
+	}
+	families, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM sync_outbox WHERE user_id = $1`, d.UserID); err != nil {
+		return nil, err
+	}
+	err = outbox.Enqueue(ctx, tx, outbox.Event{
+		UserID:         d.UserID,
+		Type:           outbox.EventUserErased,
+		IdempotencyKey: PropagationKey(d.ID),
+		Data:           ErasedEvent{UserID: d.UserID, ReceiptID: d.ID, ErasedAt: now},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, d.UserID); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE account_deletions
+		   SET status = $2, erased_at = $3, erased_counts = $4
+		 WHERE id = $1
+	`, d.ID, StatusErased, now, counts)
+	if err != nil {
+		return nil, err
+	}
+	d.Status, d.ErasedAt, d.Erased = StatusErased, &now, counts
+	return families, nil
+}
+
+// PropagationKey is the outbox idempotency key of the user.erased event of
+// a deletion, used to report whether serverpod has received it
+func PropagationKey(deletionID string) string {
+	return "user.erased:" + deletionID
+}
+
+// Propagation reports the outbox status of the user.erased event of a
+// deletion: pending, delivered or failed, with when it was delivered
+func Propagation(ctx context.Context, q Querier, deletionID string) (string, *time.Time, error) {
+	var status string
+	var deliveredAt *time.Time
+	err := q.QueryRow(ctx, `
+		SELECT status, delivered_at FROM sync_outbox WHERE idempotency_key = $1
+	`, PropagationKey(deletionID)).Scan(&status, &deliveredAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, nil
+	}
+	return status, deliveredAt, err
+}
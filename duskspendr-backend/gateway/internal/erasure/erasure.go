@@ -0,0 +1,128 @@
+// Package erasure deletes accounts on request. A deletion is scheduled with
+// a grace period during which the user can cancel it; once due, the Eraser
+// removes every row of the user, tells serverpod through the sync outbox
+// and keeps an erasure receipt that holds no personal data.
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Deletion statuses
+const (
+	StatusScheduled = "scheduled"
+	StatusCancelled = "cancelled"
+	StatusErased    = "erased"
+)
+
+var (
+	// ErrPending is returned when the user already has a scheduled deletion
+	ErrPending = errors.New("account deletion already scheduled")
+	// ErrNotFound is returned when there is no matching deletion
+	ErrNotFound = errors.New("account deletion not found")
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Categories are the kinds of data counted in a receipt, each with the
+// table it is counted in. Every other table keyed by the user is erased
+// too, through its foreign key to users.
+var Categories = []struct{ Name, Table string }{
+	{"transactions", "transactions"},
+	{"budgets", "budgets"},
+	{"linked_accounts", "linked_accounts"},
+	{"otps", "auth_otps"},
+	{"sessions", "sessions"},
+	{"refresh_tokens", "refresh_tokens"},
+	{"anomaly_events", "anomaly_events"},
+	{"statement_imports", "imports"},
+}
+
+// Deletion is a deletion request and, once erased, its receipt
+type Deletion struct {
+	ID           string
+	UserID       string
+	Status       string
+	RequestedAt  time.Time
+	ScheduledFor time.Time
+	CancelledAt  *time.Time
+	ErasedAt     *time.Time
+	// Erased counts the rows erased per category of Categories
+	Erased    map[string]int64
+	TokenHash string
+}
+
+const columns = `id::text, user_id::text, status, requested_at, scheduled_for, cancelled_at, erased_at, erased_counts, receipt_token_hash`
+
+func scan(row pgx.Row) (*Deletion, error) {
+	var d Deletion
+	err := row.Scan(&d.ID, &d.UserID, &d.Status, &d.RequestedAt, &d.ScheduledFor, &d.CancelledAt, &d.ErasedAt, &d.Erased, &d.TokenHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Schedule records a deletion of userID due after grace. tokenHash is the
+// hash of the token that lets the user read the receipt after the account
+// is gone.
+func Schedule(ctx context.Context, q Querier, userID, tokenHash string, grace time.Duration) (*Deletion, error) {
+	d, err := scan(q.QueryRow(ctx, `
+		INSERT INTO account_deletions (user_id, status, receipt_token_hash, requested_at, scheduled_for)
+		VALUES ($1, $2, $3, now(), now() + make_interval(secs => $4))
+		ON CONFLICT (user_id) WHERE status = 'scheduled' DO NOTHING
+		RETURNING `+columns, userID, StatusScheduled, tokenHash, grace.Seconds()))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrPending
+	}
+	return d, err
+}
+
+// Pending returns the user's scheduled deletion
+func Pending(ctx context.Context, q Querier, userID string) (*Deletion, error) {
+	return scan(q.QueryRow(ctx, `
+		SELECT `+columns+` FROM account_deletions WHERE user_id = $1 AND status = $2
+	`, userID, StatusScheduled))
+}
+
+// Cancel cancels the user's scheduled deletion
+func Cancel(ctx context.Context, q Querier, userID string) (*Deletion, error) {
+	return scan(q.QueryRow(ctx, `
+		UPDATE account_deletions
+		   SET status = $2, cancelled_at = now()
+		 WHERE user_id = $1 AND status = 'scheduled'
+		RETURNING `+columns, userID, StatusCancelled))
+}
+
+// Get returns a deletion by id
+func Get(ctx context.Context, q Querier, id string) (*Deletion, error) {
+	return scan(q.QueryRow(ctx, `SELECT `+columns+` FROM account_deletions WHERE id::text = $1`, id))
+}
+
+// Count returns how many rows of userID each category holds
+func Count(ctx context.Context, q Querier, userID string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(Categories))
+	for _, c := range Categories {
+		var n int64
+		// Table names come from Categories, never from input
+		if err := q.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s WHERE user_id = $1`, c.Table), userID).Scan(&n); err != nil {
+			return nil, fmt.Errorf("counting %s: %w", c.Name, err)
+		}
+		counts[c.Name] = n
+	}
+	return counts, nil
+}
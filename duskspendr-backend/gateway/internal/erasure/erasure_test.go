@@ -0,0 +1,52 @@
+package erasure
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func readMigrations(t *testing.T) string {
+	t.Helper()
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations not found: %v", err)
+	}
+	var all strings.Builder
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all.Write(b)
+		all.WriteByte('\n')
+	}
+	return all.String()
+}
+
+func TestCategoriesExist(t *testing.T) {
+	sql := readMigrations(t)
+	for _, c := range Categories {
+		if !strings.Contains(sql, "CREATE TABLE IF NOT EXISTS "+c.Table+" (") {
+			t.Errorf("category %s counts missing table %s", c.Name, c.Table)
+		}
+		// A receipt names what was erased, so the name must say which table
+		// it counts (otps for auth_otps, statement_imports for imports)
+		if !strings.HasSuffix(c.Name, c.Table) && !strings.HasSuffix(c.Table, c.Name) {
+			t.Errorf("category %s does not name its table %s", c.Name, c.Table)
+		}
+	}
+}
+
+// Erase relies on deleting the users row to remove everything else, so
+// every reference to users must cascade
+func TestUserReferencesCascade(t *testing.T) {
+	refs := regexp.MustCompile(`(?i)REFERENCES users\(id\)[^,\n]*`)
+	for _, ref := range refs.FindAllString(readMigrations(t), -1) {
+		if !strings.Contains(strings.ToUpper(ref), "ON DELETE CASCADE") {
+			t.Errorf("reference to users does not cascade: %s", ref)
+		}
+	}
+}
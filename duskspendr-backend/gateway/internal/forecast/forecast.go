@@ -0,0 +1,543 @@
+// Package forecast projects daily balances per linked account from
+// detected recurring income and expenses, scheduled bills and the user's
+// historical discretionary spend. Everything is deterministic: recurring
+// series come from interval and amount regularity, discretionary spend
+// from the mean and standard deviation of past daily totals.
+package forecast
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Frequencies
+const (
+	Once     = "once"
+	Weekly   = "weekly"
+	Biweekly = "biweekly"
+	Monthly  = "monthly"
+	Yearly   = "yearly"
+)
+
+// ValidBillFrequency reports whether f can be used for a scheduled bill
+func ValidBillFrequency(f string) bool {
+	return f == Once || f == Weekly || f == Monthly || f == Yearly
+}
+
+// BandZ is the normal quantile of the 80% confidence band
+const BandZ = 1.2816
+
+// MinDays and MaxDays bound the horizon
+const (
+	MinDays = 30
+	MaxDays = 90
+)
+
+// Lookback is how much history recurring detection reads;
+// SpendLookback how much the discretionary statistics read
+const (
+	Lookback      = 180 * 24 * time.Hour
+	SpendLookback = 90
+)
+
+// Txn is a past transaction, Date its local calendar day
+type Txn struct {
+	AccountID   string
+	Type        string
+	AmountPaisa int64
+	Key         string
+	Date        time.Time
+	Recurring   bool
+}
+
+var (
+	digitsRE = regexp.MustCompile(`[0-9]+`)
+	spacesRE = regexp.MustCompile(`\s+`)
+)
+
+// SeriesKey groups transactions of one payee: the merchant, else the UPI
+// handle, else the description without reference numbers.
+func SeriesKey(merchant, vpa, description string) string {
+	for _, v := range []string{merchant, vpa} {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			return v
+		}
+	}
+	d := digitsRE.ReplaceAllString(strings.ToLower(description), "")
+	d = strings.TrimSpace(spacesRE.ReplaceAllString(d, " "))
+	if r := []rune(d); len(r) > 40 {
+		d = string(r[:40])
+	}
+	return d
+}
+
+// Series is a detected recurring income or expense
+type Series struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	AccountID   string    `json:"account_id,omitempty"`
+	AmountPaisa int64     `json:"amount_paisa"`
+	Frequency   string    `json:"frequency"`
+	Last        time.Time `json:"last"`
+	Count       int       `json:"occurrences"`
+}
+
+func (s Series) id() string {
+	return seriesID(s.AccountID, s.Type, s.Name)
+}
+
+func seriesID(account, typ, key string) string {
+	return account + "\x00" + typ + "\x00" + key
+}
+
+// nominal period in days per frequency
+var periodDays = map[string]float64{Weekly: 7, Biweekly: 14, Monthly: 30.44, Yearly: 365.25}
+
+func classify(medianGap float64) string {
+	switch {
+	case medianGap >= 6 && medianGap <= 8:
+		return Weekly
+	case medianGap >= 13 && medianGap <= 16:
+		return Biweekly
+	case medianGap >= 26 && medianGap <= 35:
+		return Monthly
+	}
+	return ""
+}
+
+// DetectRecurring finds payees paid or paying at a regular weekly,
+// fortnightly or monthly interval with a stable amount. A payee needs three
+// occurrences, or two when the client flagged them as recurring.
+func DetectRecurring(txns []Txn) []Series {
+	groups := map[string][]Txn{}
+	order := []string{}
+	for _, t := range txns {
+		if t.Key == "" {
+			continue
+		}
+		id := seriesID(t.AccountID, t.Type, t.Key)
+		if _, ok := groups[id]; !ok {
+			order = append(order, id)
+		}
+		groups[id] = append(groups[id], t)
+	}
+
+	out := []Series{}
+	for _, id := range order {
+		g := groups[id]
+		flagged := false
+		for _, t := range g {
+			flagged = flagged || t.Recurring
+		}
+		if len(g) < 3 && !(flagged && len(g) == 2) {
+			continue
+		}
+		sort.Slice(g, func(i, j int) bool { return g[i].Date.Before(g[j].Date) })
+
+		gaps := make([]float64, 0, len(g)-1)
+		for i := 1; i < len(g); i++ {
+			gaps = append(gaps, math.Round(g[i].Date.Sub(g[i-1].Date).Hours()/24))
+		}
+		freq := classify(median(gaps))
+		if freq == "" {
+			continue
+		}
+		p := periodDays[freq]
+		regular := 0
+		for _, gap := range gaps {
+			if math.Abs(gap-p) <= p*0.25 {
+				regular++
+			}
+		}
+		if regular*3 < len(gaps)*2 {
+			continue
+		}
+
+		amounts := make([]float64, len(g))
+		for i, t := range g {
+			amounts[i] = float64(t.AmountPaisa)
+		}
+		amt := median(amounts)
+		dev := make([]float64, len(amounts))
+		for i, a := range amounts {
+			dev[i] = math.Abs(a - amt)
+		}
+		if amt <= 0 || median(dev)/amt > 0.35 {
+			continue
+		}
+
+		last := g[len(g)-1]
+		out = append(out, Series{
+			Name:        last.Key,
+			Type:        last.Type,
+			AccountID:   last.AccountID,
+			AmountPaisa: int64(math.Round(amt)),
+			Frequency:   freq,
+			Last:        last.Date,
+			Count:       len(g),
+		})
+	}
+	return out
+}
+
+// step returns the k-th occurrence after anchor. Monthly and yearly keep
+// the anchor's day, clamped to the month's length.
+func step(anchor time.Time, freq string, k int) time.Time {
+	switch freq {
+	case Weekly:
+		return anchor.AddDate(0, 0, 7*k)
+	case Biweekly:
+		return anchor.AddDate(0, 0, 14*k)
+	case Monthly:
+		return addMonths(anchor, k)
+	case Yearly:
+		return addMonths(anchor, 12*k)
+	}
+	return anchor
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if d > last {
+		d = last
+	}
+	return time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, t.Location())
+}
+
+// occurrences of s between today and end inclusive. An occurrence overdue
+// by up to half a period is expected today; one overdue by more means the
+// series has stopped.
+func (s Series) occurrences(today, end time.Time) []time.Time {
+	out := []time.Time{}
+	half := time.Duration(periodDays[s.Frequency]*12) * time.Hour
+	for k := 1; k < 1000; k++ {
+		next := step(s.Last, s.Frequency, k)
+		if next.After(end) {
+			break
+		}
+		if next.Before(today) {
+			if today.Sub(next) > half {
+				return nil
+			}
+			next = today
+		}
+		out = append(out, next)
+	}
+	return out
+}
+
+// Bill is a user-scheduled payment or receipt
+type Bill struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	AccountID   string    `json:"account_id,omitempty"`
+	AmountPaisa int64     `json:"amount_paisa"`
+	Frequency   string    `json:"frequency"`
+	NextDue     time.Time `json:"next_due"`
+}
+
+// occurrences of b between today and end inclusive. Past due dates roll
+// forward; a one-off bill in the past is ignored.
+func (b Bill) occurrences(today, end time.Time) []time.Time {
+	out := []time.Time{}
+	for k := 0; k < 1000; k++ {
+		d := step(b.NextDue, b.Frequency, k)
+		if d.After(end) {
+			break
+		}
+		if !d.Before(today) {
+			out = append(out, d)
+		}
+		if b.Frequency == Once {
+			break
+		}
+	}
+	return out
+}
+
+// Stats are daily discretionary spend statistics, in paisa
+type Stats struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// DailySpend computes per-account Stats over the days in [from, to) from
+// debits not belonging to a recurring series.
+func DailySpend(txns []Txn, series []Series, from, to time.Time) map[string]Stats {
+	recurring := map[string]bool{}
+	for _, s := range series {
+		recurring[s.id()] = true
+	}
+	days := int(math.Round(to.Sub(from).Hours() / 24))
+	if days < 1 {
+		days = 1
+	}
+	totals := map[string]map[int]float64{}
+	for _, t := range txns {
+		if t.Type != "debit" || t.Date.Before(from) || !t.Date.Before(to) || recurring[seriesID(t.AccountID, t.Type, t.Key)] {
+			continue
+		}
+		if totals[t.AccountID] == nil {
+			totals[t.AccountID] = map[int]float64{}
+		}
+		totals[t.AccountID][int(math.Round(t.Date.Sub(from).Hours()/24))] += float64(t.AmountPaisa)
+	}
+	out := map[string]Stats{}
+	for acct, byDay := range totals {
+		sum := 0.0
+		for _, v := range byDay {
+			sum += v
+		}
+		mean := sum / float64(days)
+		sq := 0.0
+		for d := 0; d < days; d++ {
+			diff := byDay[d] - mean
+			sq += diff * diff
+		}
+		out[acct] = Stats{Mean: mean, StdDev: math.Sqrt(sq / float64(days))}
+	}
+	return out
+}
+
+// Account is a linked account and its current balance, if known
+type Account struct {
+	ID           string
+	Name         *string
+	BalancePaisa *int64
+}
+
+// Input is everything a projection needs
+type Input struct {
+	// Today is local midnight of the first projected day
+	Today    time.Time
+	Days     int
+	Accounts []Account
+	Series   []Series
+	Bills    []Bill
+	// Spend is keyed by account id, "" for transactions without one
+	Spend map[string]Stats
+}
+
+// Event is a recurring item or bill expected on a day
+type Event struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	AmountPaisa int64  `json:"amount_paisa"`
+	Source      string `json:"source"`
+}
+
+// Day is the projected end-of-day position
+type Day struct {
+	Date         string  `json:"date"`
+	BalancePaisa int64   `json:"balance_paisa"`
+	LowPaisa     int64   `json:"low_paisa"`
+	HighPaisa    int64   `json:"high_paisa"`
+	InflowPaisa  int64   `json:"inflow_paisa"`
+	OutflowPaisa int64   `json:"outflow_paisa"`
+	Events       []Event `json:"events,omitempty"`
+}
+
+// Projection is a balance at a point with its band
+type Projection struct {
+	Date         string `json:"date"`
+	BalancePaisa int64  `json:"balance_paisa"`
+	LowPaisa     int64  `json:"low_paisa"`
+	HighPaisa    int64  `json:"high_paisa"`
+}
+
+// AccountForecast is the projection for one account, the unassigned
+// bucket (AccountID nil) or the total
+type AccountForecast struct {
+	AccountID    *string `json:"account_id"`
+	AccountName  *string `json:"account_name,omitempty"`
+	BalanceKnown bool    `json:"balance_known"`
+	// StartBalancePaisa is the current balance, 0 when unknown
+	StartBalancePaisa int64      `json:"start_balance_paisa"`
+	DailySpend        Stats      `json:"daily_discretionary_paisa"`
+	MonthEnd          Projection `json:"month_end"`
+	// SafeToSpendTodayPaisa keeps the pessimistic balance above zero up to
+	// month end; nil when the balance is unknown
+	SafeToSpendTodayPaisa *int64 `json:"safe_to_spend_today_paisa"`
+	Days                  []Day  `json:"days"`
+}
+
+// Forecast is the result of Project
+type Forecast struct {
+	Today     string            `json:"today"`
+	Days      int               `json:"days"`
+	Band      float64           `json:"band_confidence"`
+	Total     AccountForecast   `json:"total"`
+	Accounts  []AccountForecast `json:"accounts"`
+	Recurring []Series          `json:"recurring"`
+}
+
+type flows map[string][]Event
+
+func (f flows) add(date time.Time, e Event) {
+	key := date.Format("2006-01-02")
+	f[key] = append(f[key], e)
+}
+
+// Project builds the daily projection for each account and the total.
+// Day 0 is today and holds today's expected recurring items and bills but
+// no discretionary spend, which is what safe-to-spend leaves room for.
+func Project(in Input) Forecast {
+	end := in.Today.AddDate(0, 0, in.Days)
+	byAccount := map[string]flows{}
+	addEvent := func(acct string, date time.Time, e Event) {
+		if byAccount[acct] == nil {
+			byAccount[acct] = flows{}
+		}
+		byAccount[acct].add(date, e)
+	}
+	// A bill entered for a detected payee replaces the detected series
+	billed := map[string]bool{}
+	for _, b := range in.Bills {
+		billed[seriesID(b.AccountID, b.Type, SeriesKey(b.Name, "", ""))] = true
+	}
+	series := []Series{}
+	for _, s := range in.Series {
+		if !billed[s.id()] {
+			series = append(series, s)
+		}
+	}
+	for _, s := range series {
+		for _, d := range s.occurrences(in.Today, end) {
+			addEvent(s.AccountID, d, Event{Name: s.Name, Type: s.Type, AmountPaisa: s.AmountPaisa, Source: "recurring"})
+		}
+	}
+	for _, b := range in.Bills {
+		for _, d := range b.occurrences(in.Today, end) {
+			addEvent(b.AccountID, d, Event{Name: b.Name, Type: b.Type, AmountPaisa: b.AmountPaisa, Source: "bill"})
+		}
+	}
+
+	out := Forecast{
+		Today:     in.Today.Format("2006-01-02"),
+		Days:      in.Days,
+		Band:      0.8,
+		Accounts:  []AccountForecast{},
+		Recurring: series,
+	}
+	known := map[string]bool{}
+	// The total has a balance, and so a safe-to-spend, once any account does
+	total := AccountForecast{}
+	all := flows{}
+	var totalSpend Stats
+	include := func(acct string) {
+		for day, evs := range byAccount[acct] {
+			all[day] = append(all[day], evs...)
+		}
+		s := in.Spend[acct]
+		totalSpend.Mean += s.Mean
+		totalSpend.StdDev = math.Sqrt(totalSpend.StdDev*totalSpend.StdDev + s.StdDev*s.StdDev)
+	}
+	for _, a := range in.Accounts {
+		known[a.ID] = true
+		id := a.ID
+		af := AccountForecast{AccountID: &id, AccountName: a.Name, DailySpend: in.Spend[a.ID]}
+		if a.BalancePaisa != nil {
+			af.BalanceKnown = true
+			af.StartBalancePaisa = *a.BalancePaisa
+			total.BalanceKnown = true
+			total.StartBalancePaisa += *a.BalancePaisa
+		}
+		project(&af, byAccount[a.ID], in.Today, in.Days)
+		out.Accounts = append(out.Accounts, af)
+		include(a.ID)
+	}
+	// Flows of accounts the user no longer has count as unassigned
+	unassigned := flows{}
+	for acct, f := range byAccount {
+		if known[acct] {
+			continue
+		}
+		for day, evs := range f {
+			unassigned[day] = append(unassigned[day], evs...)
+		}
+	}
+	var spend Stats
+	for acct, s := range in.Spend {
+		if !known[acct] {
+			spend.Mean += s.Mean
+			spend.StdDev = math.Sqrt(spend.StdDev*spend.StdDev + s.StdDev*s.StdDev)
+		}
+	}
+	if len(unassigned) > 0 || spend.Mean > 0 {
+		af := AccountForecast{DailySpend: spend}
+		project(&af, unassigned, in.Today, in.Days)
+		out.Accounts = append(out.Accounts, af)
+		for day, evs := range unassigned {
+			all[day] = append(all[day], evs...)
+		}
+		totalSpend.Mean += spend.Mean
+		totalSpend.StdDev = math.Sqrt(totalSpend.StdDev*totalSpend.StdDev + spend.StdDev*spend.StdDev)
+	}
+
+	total.DailySpend = totalSpend
+	project(&total, all, in.Today, in.Days)
+	out.Total = total
+	return out
+}
+
+func project(af *AccountForecast, events flows, today time.Time, days int) {
+	bal := float64(af.StartBalancePaisa)
+	variance := 0.0
+	monthEnd := today.AddDate(0, 1, -today.Day()+1).AddDate(0, 0, -1)
+	low := math.Inf(1)
+	af.Days = make([]Day, 0, days+1)
+	for d := 0; d <= days; d++ {
+		date := today.AddDate(0, 0, d)
+		key := date.Format("2006-01-02")
+		day := Day{Date: key, Events: events[key]}
+		for _, e := range day.Events {
+			if e.Type == "credit" {
+				day.InflowPaisa += e.AmountPaisa
+				bal += float64(e.AmountPaisa)
+			} else {
+				day.OutflowPaisa += e.AmountPaisa
+				bal -= float64(e.AmountPaisa)
+			}
+		}
+		if d > 0 {
+			day.OutflowPaisa += int64(math.Round(af.DailySpend.Mean))
+			bal -= af.DailySpend.Mean
+			variance += af.DailySpend.StdDev * af.DailySpend.StdDev
+		}
+		band := BandZ * math.Sqrt(variance)
+		day.BalancePaisa = int64(math.Round(bal))
+		day.LowPaisa = int64(math.Round(bal - band))
+		day.HighPaisa = int64(math.Round(bal + band))
+		af.Days = append(af.Days, day)
+
+		if !date.After(monthEnd) {
+			low = math.Min(low, bal-band)
+		}
+		if date.Equal(monthEnd) {
+			af.MonthEnd = Projection{Date: key, BalancePaisa: day.BalancePaisa, LowPaisa: day.LowPaisa, HighPaisa: day.HighPaisa}
+		}
+	}
+	if af.BalanceKnown {
+		safe := int64(math.Max(0, math.Floor(low)))
+		af.SafeToSpendTodayPaisa = &safe
+	}
+}
+
+func median(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), vals...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
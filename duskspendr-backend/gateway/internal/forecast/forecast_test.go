@@ -0,0 +1,162 @@
+package forecast
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestSeriesKey(t *testing.T) {
+	if got := SeriesKey(" Netflix ", "", "x"); got != "netflix" {
+		t.Errorf("merchant key = %q", got)
+	}
+	if got := SeriesKey("", "", "NEFT SALARY 000123 ACME"); got != "neft salary acme" {
+		t.Errorf("description key = %q", got)
+	}
+	long := strings.Repeat("किराया ", 10)
+	if got := SeriesKey("", "", long); !utf8.ValidString(got) || utf8.RuneCountInString(got) != 40 {
+		t.Errorf("long description key = %q", got)
+	}
+}
+
+func TestDetectRecurring(t *testing.T) {
+	txns := []Txn{
+		{Type: "credit", AmountPaisa: 2000000, Key: "acme payroll", Date: date(2024, 1, 1)},
+		{Type: "credit", AmountPaisa: 2000000, Key: "acme payroll", Date: date(2024, 2, 1)},
+		{Type: "credit", AmountPaisa: 2050000, Key: "acme payroll", Date: date(2024, 3, 1)},
+		{Type: "debit", AmountPaisa: 64900, Key: "netflix", Date: date(2024, 1, 5)},
+		{Type: "debit", AmountPaisa: 64900, Key: "netflix", Date: date(2024, 2, 5)},
+		{Type: "debit", AmountPaisa: 64900, Key: "netflix", Date: date(2024, 3, 6)},
+		// Irregular spend at one place is not recurring
+		{Type: "debit", AmountPaisa: 30000, Key: "cafe", Date: date(2024, 1, 2)},
+		{Type: "debit", AmountPaisa: 90000, Key: "cafe", Date: date(2024, 1, 3)},
+		{Type: "debit", AmountPaisa: 10000, Key: "cafe", Date: date(2024, 2, 20)},
+		// Two flagged payments are enough
+		{Type: "debit", AmountPaisa: 100000, Key: "gym", Date: date(2024, 2, 10), Recurring: true},
+		{Type: "debit", AmountPaisa: 100000, Key: "gym", Date: date(2024, 3, 10), Recurring: true},
+	}
+	got := DetectRecurring(txns)
+	names := map[string]Series{}
+	for _, s := range got {
+		names[s.Name] = s
+	}
+	if len(got) != 3 || names["cafe"].Name != "" {
+		t.Fatalf("series = %+v", got)
+	}
+	if s := names["acme payroll"]; s.Frequency != Monthly || s.AmountPaisa != 2000000 || !s.Last.Equal(date(2024, 3, 1)) {
+		t.Errorf("salary = %+v", s)
+	}
+	if names["gym"].Frequency != Monthly {
+		t.Errorf("gym = %+v", names["gym"])
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	s := Series{Frequency: Monthly, Last: date(2024, 1, 31)}
+	got := s.occurrences(date(2024, 2, 10), date(2024, 4, 30))
+	want := []time.Time{date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)}
+	if len(got) != len(want) {
+		t.Fatalf("occurrences = %v", got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	// Slightly late payments are expected today, long-missed ones dropped
+	if got := s.occurrences(date(2024, 3, 5), date(2024, 3, 10)); len(got) != 1 || !got[0].Equal(date(2024, 3, 5)) {
+		t.Errorf("overdue = %v", got)
+	}
+	if got := s.occurrences(date(2024, 3, 20), date(2024, 3, 25)); len(got) != 0 {
+		t.Errorf("lapsed = %v", got)
+	}
+
+	b := Bill{Frequency: Weekly, NextDue: date(2024, 3, 1)}
+	if got := b.occurrences(date(2024, 3, 10), date(2024, 3, 22)); len(got) != 2 || !got[0].Equal(date(2024, 3, 15)) {
+		t.Errorf("bill = %v", got)
+	}
+	once := Bill{Frequency: Once, NextDue: date(2024, 3, 1)}
+	if got := once.occurrences(date(2024, 3, 10), date(2024, 3, 20)); len(got) != 0 {
+		t.Errorf("past one-off = %v", got)
+	}
+}
+
+func TestDailySpend(t *testing.T) {
+	series := []Series{{Name: "rent", Type: "debit"}}
+	txns := []Txn{
+		{Type: "debit", AmountPaisa: 1000000, Key: "rent", Date: date(2024, 3, 1)},
+		{Type: "debit", AmountPaisa: 4000, Key: "cafe", Date: date(2024, 3, 1)},
+		{Type: "debit", AmountPaisa: 2000, Key: "cafe", Date: date(2024, 3, 3)},
+		{Type: "credit", AmountPaisa: 9000, Key: "refund", Date: date(2024, 3, 2)},
+	}
+	got := DailySpend(txns, series, date(2024, 3, 1), date(2024, 3, 5))[""]
+	if got.Mean != 1500 {
+		t.Errorf("mean = %v", got.Mean)
+	}
+	if got.StdDev <= 0 {
+		t.Errorf("std dev = %v", got.StdDev)
+	}
+}
+
+func TestProject(t *testing.T) {
+	bal := int64(500000)
+	name := "Savings"
+	in := Input{
+		Today:    date(2024, 3, 20),
+		Days:     30,
+		Accounts: []Account{{ID: "a1", Name: &name, BalancePaisa: &bal}},
+		Series: []Series{
+			{Name: "acme payroll", Type: "credit", AccountID: "a1", AmountPaisa: 2000000, Frequency: Monthly, Last: date(2024, 3, 1)},
+			{Name: "rent", Type: "debit", AccountID: "a1", AmountPaisa: 999999, Frequency: Monthly, Last: date(2024, 2, 25)},
+		},
+		Bills: []Bill{
+			{Name: "Rent", Type: "debit", AccountID: "a1", AmountPaisa: 300000, Frequency: Monthly, NextDue: date(2024, 1, 25)},
+		},
+		Spend: map[string]Stats{"a1": {Mean: 10000, StdDev: 5000}},
+	}
+	f := Project(in)
+	if len(f.Recurring) != 1 {
+		t.Fatalf("bill should replace detected rent: %+v", f.Recurring)
+	}
+	a := f.Accounts[0]
+	if len(a.Days) != 31 || a.Days[0].Date != "2024-03-20" {
+		t.Fatalf("days = %d, first %v", len(a.Days), a.Days[0])
+	}
+	// Day 0 has no discretionary spend and no band
+	if a.Days[0].BalancePaisa != bal || a.Days[0].LowPaisa != bal {
+		t.Errorf("day 0 = %+v", a.Days[0])
+	}
+	// 25 March: five days of spend and the rent bill
+	if d := a.Days[5]; d.BalancePaisa != bal-5*10000-300000 || len(d.Events) != 1 || d.LowPaisa >= d.BalancePaisa {
+		t.Errorf("rent day = %+v", d)
+	}
+	if a.MonthEnd.Date != "2024-03-31" || a.MonthEnd.BalancePaisa != bal-11*10000-300000 {
+		t.Errorf("month end = %+v", a.MonthEnd)
+	}
+	// The lowest pessimistic balance before month end bounds today's spend
+	if a.SafeToSpendTodayPaisa == nil || *a.SafeToSpendTodayPaisa != a.MonthEnd.LowPaisa {
+		t.Errorf("safe to spend = %v, month end low %d", a.SafeToSpendTodayPaisa, a.MonthEnd.LowPaisa)
+	}
+	// Salary lands on 1 April
+	if d := a.Days[12]; d.InflowPaisa != 2000000 {
+		t.Errorf("payday = %+v", d)
+	}
+	if f.Total.StartBalancePaisa != bal || f.Total.Days[30].BalancePaisa != a.Days[30].BalancePaisa {
+		t.Errorf("total = %+v", f.Total.Days[30])
+	}
+	if !f.Total.BalanceKnown || f.Total.SafeToSpendTodayPaisa == nil {
+		t.Errorf("total balance should be known: %+v", f.Total)
+	}
+
+	// Without any account balance the total has none either
+	in.Accounts[0].BalancePaisa = nil
+	f = Project(in)
+	if f.Total.BalanceKnown || f.Total.SafeToSpendTodayPaisa != nil {
+		t.Errorf("total with unknown balances: known %v, safe to spend %v", f.Total.BalanceKnown, f.Total.SafeToSpendTodayPaisa)
+	}
+}
@@ -0,0 +1,110 @@
+package forecast
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Load reads the user's accounts, bills and recent transactions and builds
+// the projection input for days days from now in loc.
+func Load(ctx context.Context, q Querier, userID string, loc *time.Location, now time.Time, days int) (Input, error) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	in := Input{Today: today, Days: days}
+
+	rows, err := q.Query(ctx, `
+		SELECT id::text, account_name, balance_paisa
+		  FROM linked_accounts
+		 WHERE user_id = $1
+		 ORDER BY linked_at, id
+	`, userID)
+	if err != nil {
+		return in, err
+	}
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.ID, &a.Name, &a.BalancePaisa); err != nil {
+			rows.Close()
+			return in, err
+		}
+		in.Accounts = append(in.Accounts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return in, err
+	}
+
+	rows, err = q.Query(ctx, `
+		SELECT id::text, name, type, coalesce(linked_account_id::text, ''), amount_paisa,
+		       frequency, next_due
+		  FROM scheduled_bills
+		 WHERE user_id = $1 AND is_active
+	`, userID)
+	if err != nil {
+		return in, err
+	}
+	for rows.Next() {
+		var b Bill
+		if err := rows.Scan(&b.ID, &b.Name, &b.Type, &b.AccountID, &b.AmountPaisa, &b.Frequency, &b.NextDue); err != nil {
+			rows.Close()
+			return in, err
+		}
+		b.NextDue = time.Date(b.NextDue.Year(), b.NextDue.Month(), b.NextDue.Day(), 0, 0, 0, 0, loc)
+		in.Bills = append(in.Bills, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return in, err
+	}
+
+	// History stops before today: today's spend is already in the balance
+	rows, err = q.Query(ctx, `
+		SELECT coalesce(linked_account_id::text, ''), type, amount_paisa,
+		       coalesce(merchant_name, ''), coalesce(counterparty_vpa, ''),
+		       coalesce(description, ''), timestamp, is_recurring
+		  FROM transactions
+		 WHERE user_id = $1 AND timestamp >= $2 AND timestamp < $3
+		 ORDER BY timestamp
+	`, userID, today.Add(-Lookback), today)
+	if err != nil {
+		return in, err
+	}
+	txns := []Txn{}
+	var first time.Time
+	for rows.Next() {
+		var t Txn
+		var merchant, vpa, description string
+		var ts time.Time
+		if err := rows.Scan(&t.AccountID, &t.Type, &t.AmountPaisa, &merchant, &vpa, &description, &ts, &t.Recurring); err != nil {
+			rows.Close()
+			return in, err
+		}
+		ts = ts.In(loc)
+		t.Date = time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, loc)
+		t.Key = SeriesKey(merchant, vpa, description)
+		if first.IsZero() {
+			first = t.Date
+		}
+		txns = append(txns, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return in, err
+	}
+
+	in.Series = DetectRecurring(txns)
+	// Newer users are averaged over the days they have history for
+	from := today.AddDate(0, 0, -SpendLookback)
+	if first.After(from) {
+		from = first
+	}
+	in.Spend = DailySpend(txns, in.Series, from, today)
+	return in, nil
+}
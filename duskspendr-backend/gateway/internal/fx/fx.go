@@ -0,0 +1,160 @@
+// Package fx converts transaction amounts between currencies using stored
+// reference rates.
+//
+// Rates are kept as quotes "1 Base = Rate Quote" for a date, as published
+// by reference-rate providers such as the ECB (base EUR). Conversions use a
+// direct quote, its inverse, or a cross rate through a shared base, taken
+// from the most recent date on or before the transaction date.
+package fx
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoRate is returned when no quote covers a conversion
+var ErrNoRate = errors.New("fx: no rate available")
+
+// DefaultMaxRateAge is how far back a quote may be used by default. It
+// covers weekends and bank holidays, when reference rates are not published.
+const DefaultMaxRateAge = 7 * 24 * time.Hour
+
+// Quote is one reference rate: 1 Base = Rate Quote on Date
+type Quote struct {
+	Base   string    `json:"base"`
+	Quote  string    `json:"quote"`
+	Date   time.Time `json:"date"`
+	Rate   float64   `json:"rate"`
+	Source string    `json:"source,omitempty"`
+}
+
+// Conversion is the rate used to convert From into To
+type Conversion struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Date time.Time `json:"date"`
+	Rate float64   `json:"rate"`
+}
+
+var codePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCode reports whether code looks like an ISO 4217 currency code
+func ValidCode(code string) bool {
+	return codePattern.MatchString(code)
+}
+
+// NormalizeCode upper-cases and trims a currency code
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Currencies whose minor unit is not 1/100 (ISO 4217)
+var minorUnits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// MinorUnits returns the number of decimal places of a currency
+func MinorUnits(code string) int {
+	if n, ok := minorUnits[code]; ok {
+		return n
+	}
+	return 2
+}
+
+// Convert converts an amount in from's minor units into to's minor units,
+// rounding half away from zero.
+func Convert(amountMinor int64, from, to string, rate float64) int64 {
+	major := float64(amountMinor) / math.Pow10(MinorUnits(from))
+	return int64(math.Round(major * rate * math.Pow10(MinorUnits(to))))
+}
+
+// FromCents rescales an amount parsed with two decimals (as statement
+// parsers do) into the currency's minor units.
+func FromCents(cents int64, code string) int64 {
+	switch n := MinorUnits(code); {
+	case n < 2:
+		return int64(math.Round(float64(cents) / math.Pow10(2-n)))
+	case n > 2:
+		return cents * int64(math.Pow10(n-2))
+	}
+	return cents
+}
+
+// FindRate picks the conversion from → to for date out of quotes. For each
+// date, newest first and no older than maxAge, it tries a direct quote, an
+// inverse quote, then a cross rate through any base quoting both.
+func FindRate(quotes []Quote, from, to string, date time.Time, maxAge time.Duration) (Conversion, error) {
+	day := truncateDay(date)
+	if from == to {
+		return Conversion{From: from, To: to, Date: day, Rate: 1}, nil
+	}
+
+	byDate := map[time.Time][]Quote{}
+	dates := []time.Time{}
+	for _, q := range quotes {
+		d := truncateDay(q.Date)
+		if d.After(day) || day.Sub(d) > maxAge || q.Rate <= 0 {
+			continue
+		}
+		if _, ok := byDate[d]; !ok {
+			dates = append(dates, d)
+		}
+		byDate[d] = append(byDate[d], q)
+	}
+	// Newest first
+	for i := 1; i < len(dates); i++ {
+		for j := i; j > 0 && dates[j].After(dates[j-1]); j-- {
+			dates[j], dates[j-1] = dates[j-1], dates[j]
+		}
+	}
+
+	for _, d := range dates {
+		if rate, ok := rateOn(byDate[d], from, to); ok {
+			return Conversion{From: from, To: to, Date: d, Rate: rate}, nil
+		}
+	}
+	return Conversion{}, ErrNoRate
+}
+
+// Current reports whether rates published up to latest can still convert
+// a spend made at now, given maxAge
+func Current(latest, now time.Time, maxAge time.Duration) bool {
+	return !truncateDay(now).After(truncateDay(latest).Add(maxAge))
+}
+
+func rateOn(quotes []Quote, from, to string) (float64, bool) {
+	// perBase[base][ccy] = units of ccy per 1 base, with the base itself at 1
+	perBase := map[string]map[string]float64{}
+	for _, q := range quotes {
+		if q.Base == from && q.Quote == to {
+			return q.Rate, true
+		}
+		if q.Base == to && q.Quote == from {
+			return 1 / q.Rate, true
+		}
+		m := perBase[q.Base]
+		if m == nil {
+			m = map[string]float64{q.Base: 1}
+			perBase[q.Base] = m
+		}
+		m[q.Quote] = q.Rate
+	}
+	for _, m := range perBase {
+		f, okFrom := m[from]
+		t, okTo := m[to]
+		if okFrom && okTo {
+			return t / f, true
+		}
+	}
+	return 0, false
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
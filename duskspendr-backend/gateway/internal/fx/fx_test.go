@@ -0,0 +1,131 @@
+package fx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		from, to string
+		rate     float64
+		want     int64
+	}{
+		{1549, "USD", "INR", 83.5, 129342},
+		{1500, "JPY", "INR", 0.55, 82500},
+		{10000, "INR", "USD", 0.012, 120},
+		{1000, "KWD", "INR", 270, 27000},
+	}
+	for _, tt := range tests {
+		if got := Convert(tt.amount, tt.from, tt.to, tt.rate); got != tt.want {
+			t.Errorf("Convert(%d %s→%s @%v) = %d, want %d", tt.amount, tt.from, tt.to, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestFromCents(t *testing.T) {
+	if got := FromCents(150000, "JPY"); got != 1500 {
+		t.Errorf("JPY = %d", got)
+	}
+	if got := FromCents(1250, "KWD"); got != 12500 {
+		t.Errorf("KWD = %d", got)
+	}
+	if got := FromCents(1549, "USD"); got != 1549 {
+		t.Errorf("USD = %d", got)
+	}
+}
+
+func TestFindRate(t *testing.T) {
+	quotes := []Quote{
+		{Base: "EUR", Quote: "USD", Date: day("2024-04-10"), Rate: 1.08},
+		{Base: "EUR", Quote: "INR", Date: day("2024-04-10"), Rate: 90},
+		{Base: "EUR", Quote: "USD", Date: day("2024-04-12"), Rate: 1.10},
+		{Base: "EUR", Quote: "INR", Date: day("2024-04-12"), Rate: 88},
+		{Base: "USD", Quote: "GBP", Date: day("2024-04-12"), Rate: 0.8},
+	}
+
+	// Cross rate through EUR on the latest date not after the transaction
+	c, err := FindRate(quotes, "USD", "INR", day("2024-04-14"), DefaultMaxRateAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Date.Equal(day("2024-04-12")) || c.Rate != 80 {
+		t.Errorf("USD→INR = %+v", c)
+	}
+
+	c, err = FindRate(quotes, "USD", "INR", day("2024-04-11"), DefaultMaxRateAge)
+	if err != nil || c.Rate != 90/1.08 {
+		t.Errorf("USD→INR on 11th = %+v, %v", c, err)
+	}
+
+	c, err = FindRate(quotes, "GBP", "USD", day("2024-04-12"), DefaultMaxRateAge)
+	if err != nil || c.Rate != 1/0.8 {
+		t.Errorf("inverse GBP→USD = %+v, %v", c, err)
+	}
+
+	if _, err := FindRate(quotes, "USD", "INR", day("2024-05-30"), DefaultMaxRateAge); err != ErrNoRate {
+		t.Errorf("stale quotes should not be used, got %v", err)
+	}
+	if _, err := FindRate(quotes, "USD", "INR", day("2024-04-01"), DefaultMaxRateAge); err != ErrNoRate {
+		t.Errorf("future quotes should not be used, got %v", err)
+	}
+	if c, _ := FindRate(nil, "INR", "INR", day("2024-04-01"), DefaultMaxRateAge); c.Rate != 1 {
+		t.Errorf("same currency rate = %v", c.Rate)
+	}
+}
+
+func TestParseRatesCSVWide(t *testing.T) {
+	in := "Date,USD,JPY,INR,CYP,\n2024-04-12,1.0652,163.16,88.9,N/A,\n2024-04-11,1.0729,164.18,89.5,N/A,\n"
+	quotes, err := ParseRatesCSV(strings.NewReader(in), "eur", "ecb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 6 {
+		t.Fatalf("got %d quotes: %+v", len(quotes), quotes)
+	}
+	if q := quotes[0]; q.Base != "EUR" || q.Quote != "USD" || q.Rate != 1.0652 || q.Source != "ecb" {
+		t.Errorf("quote = %+v", q)
+	}
+}
+
+func TestParseRatesCSVLong(t *testing.T) {
+	in := "date,base,quote,rate\n2024-04-12,usd,inr,83.4\n2024-04-12,AED,INR,22.7\n"
+	quotes, err := ParseRatesCSV(strings.NewReader(in), "", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 || quotes[0].Base != "USD" || quotes[1].Base != "AED" {
+		t.Fatalf("quotes = %+v", quotes)
+	}
+}
+
+func TestBundledRatesParse(t *testing.T) {
+	quotes, err := ParseRatesCSV(bytes.NewReader(bundledRates), "EUR", "bundled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindRate(quotes, "USD", "INR", day("2025-07-03"), DefaultMaxRateAge); err != nil {
+		t.Errorf("bundled rates cannot convert USD→INR: %v", err)
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	latest := day("2025-07-01")
+	if !Current(latest, day("2025-07-08").Add(23*time.Hour), DefaultMaxRateAge) {
+		t.Error("rates a week old should still convert")
+	}
+	if Current(latest, day("2025-07-09"), DefaultMaxRateAge) {
+		t.Error("rates eight days old should not convert")
+	}
+}
@@ -0,0 +1,98 @@
+package fx
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseRatesCSV reads reference rates in either of two layouts:
+//
+//   - wide, as in the ECB's eurofxref-hist.csv: a "Date" column followed by
+//     one column per currency, all quoted against base (EUR for the ECB);
+//   - long: columns date, base, quote, rate.
+//
+// Empty cells and "N/A" (currencies not yet or no longer quoted) are skipped.
+func ParseRatesCSV(r io.Reader, base, source string) ([]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+	if len(header) == 0 || !strings.EqualFold(header[0], "date") {
+		return nil, errors.New("fx: first column must be date")
+	}
+
+	long := len(header) >= 4 && strings.EqualFold(header[1], "base") &&
+		strings.EqualFold(header[2], "quote") && strings.EqualFold(header[3], "rate")
+	base = NormalizeCode(base)
+	if !long && !ValidCode(base) {
+		return nil, errors.New("fx: wide rate files need a base currency")
+	}
+
+	quotes := []Quote{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, errors.New("fx: invalid date " + row[0])
+		}
+
+		if long {
+			if len(row) < 4 {
+				continue
+			}
+			q := Quote{Base: NormalizeCode(row[1]), Quote: NormalizeCode(row[2]), Date: date, Source: source}
+			if !ValidCode(q.Base) || !ValidCode(q.Quote) {
+				return nil, errors.New("fx: invalid currency on " + row[0])
+			}
+			if q.Rate, err = parseRate(row[3]); err != nil {
+				continue
+			}
+			quotes = append(quotes, q)
+			continue
+		}
+
+		for i := 1; i < len(row) && i < len(header); i++ {
+			code := NormalizeCode(header[i])
+			if !ValidCode(code) {
+				continue
+			}
+			rate, err := parseRate(row[i])
+			if err != nil {
+				continue
+			}
+			quotes = append(quotes, Quote{Base: base, Quote: code, Date: date, Rate: rate, Source: source})
+		}
+	}
+	return quotes, nil
+}
+
+func parseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "N/A") {
+		return 0, errors.New("fx: no rate")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0, errors.New("fx: invalid rate")
+	}
+	return v, nil
+}
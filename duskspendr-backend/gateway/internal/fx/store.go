@@ -0,0 +1,130 @@
+package fx
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// bundledRates is a small ECB reference-rate snapshot (base EUR) used to
+// seed an empty fx_rates table. It covers only a few old dates, so the ECB
+// history must be uploaded before conversions work for recent spends.
+//
+//go:embed data/ecb_seed.csv
+var bundledRates []byte
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LookupRate finds the rate to convert from → to on date
+func LookupRate(ctx context.Context, q Querier, from, to string, date time.Time, maxAge time.Duration) (Conversion, error) {
+	if from == to {
+		return FindRate(nil, from, to, date, maxAge)
+	}
+	day := truncateDay(date)
+	rows, err := q.Query(ctx, `
+		SELECT base_currency, quote_currency, rate_date, rate::float8
+		  FROM fx_rates
+		 WHERE rate_date <= $1 AND rate_date >= $2
+		   AND (quote_currency IN ($3, $4) OR base_currency IN ($3, $4))
+	`, day, day.Add(-maxAge), from, to)
+	if err != nil {
+		return Conversion{}, err
+	}
+	defer rows.Close()
+
+	quotes := []Quote{}
+	for rows.Next() {
+		var quote Quote
+		if err := rows.Scan(&quote.Base, &quote.Quote, &quote.Date, &quote.Rate); err != nil {
+			return Conversion{}, err
+		}
+		quotes = append(quotes, quote)
+	}
+	if err := rows.Err(); err != nil {
+		return Conversion{}, err
+	}
+	return FindRate(quotes, from, to, date, maxAge)
+}
+
+// SaveQuotes upserts quotes in one transaction using COPY, so a full ECB
+// history file loads quickly.
+func SaveQuotes(ctx context.Context, pool *pgxpool.Pool, quotes []Quote) (int, error) {
+	if len(quotes) == 0 {
+		return 0, nil
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE fx_rates_load (
+		  base_currency TEXT, quote_currency TEXT, rate_date DATE, rate NUMERIC, source TEXT
+		) ON COMMIT DROP
+	`); err != nil {
+		return 0, err
+	}
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"fx_rates_load"},
+		[]string{"base_currency", "quote_currency", "rate_date", "rate", "source"},
+		pgx.CopyFromSlice(len(quotes), func(i int) ([]any, error) {
+			q := quotes[i]
+			return []any{q.Base, q.Quote, truncateDay(q.Date), q.Rate, q.Source}, nil
+		}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	cmd, err := tx.Exec(ctx, `
+		INSERT INTO fx_rates (base_currency, quote_currency, rate_date, rate, source)
+		SELECT DISTINCT ON (base_currency, quote_currency, rate_date)
+		       base_currency, quote_currency, rate_date, rate, source
+		  FROM fx_rates_load
+		ON CONFLICT (base_currency, quote_currency, rate_date)
+		DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, loaded_at = now()
+	`)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int(cmd.RowsAffected()), nil
+}
+
+// SeedBundled loads the bundled reference rates when fx_rates is empty
+func SeedBundled(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM fx_rates)`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, nil
+	}
+	quotes, err := ParseRatesCSV(bytes.NewReader(bundledRates), "EUR", "bundled")
+	if err != nil {
+		return 0, err
+	}
+	return SaveQuotes(ctx, pool, quotes)
+}
+
+// LatestRateDate returns the most recent date with stored rates; false when
+// there are none
+func LatestRateDate(ctx context.Context, pool *pgxpool.Pool) (time.Time, bool, error) {
+	var latest *time.Time
+	if err := pool.QueryRow(ctx, `SELECT max(rate_date) FROM fx_rates`).Scan(&latest); err != nil {
+		return time.Time{}, false, err
+	}
+	if latest == nil {
+		return time.Time{}, false, nil
+	}
+	return *latest, true, nil
+}
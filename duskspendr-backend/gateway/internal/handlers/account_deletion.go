@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/erasure"
+	"duskspendr-gateway/internal/models"
+)
+
+// DeleteMe schedules erasure of the caller's account after DeletionGrace.
+// The response carries a receipt token, the only way to read the erasure
+// receipt once the account is gone. Requires a step-up.
+func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	token, tokenHash, err := generateToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "token generation failed")
+		return
+	}
+	d, err := erasure.Schedule(r.Context(), h.Pool, userID.String(), tokenHash, h.DeletionGrace)
+	if errors.Is(err, erasure.ErrPending) {
+		writeErrorCode(w, http.StatusConflict, "DELETION_PENDING", "account deletion already scheduled")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "deletion request failed")
+		return
+	}
+	receipt := erasureReceipt(d)
+	receipt.ReceiptToken = token
+	writeJSON(w, http.StatusAccepted, receipt)
+}
+
+// DeletionStatus returns the caller's scheduled deletion
+func (h *UserHandler) DeletionStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	d, err := erasure.Pending(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, erasure.ErrNotFound) {
+		writeErrorCode(w, http.StatusNotFound, "NO_DELETION", "no account deletion scheduled")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "deletion lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, erasureReceipt(d))
+}
+
+// CancelDeletion cancels the caller's scheduled deletion during the grace
+// period
+func (h *UserHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	d, err := erasure.Cancel(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, erasure.ErrNotFound) {
+		writeErrorCode(w, http.StatusNotFound, "NO_DELETION", "no account deletion scheduled")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "deletion cancel failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, erasureReceipt(d))
+}
+
+// ErasureReceipt returns a deletion by receipt id to the holder of its
+// X-Receipt-Token, including after the account was erased
+func (h *UserHandler) ErasureReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	token := r.Header.Get("X-Receipt-Token")
+	if err != nil || token == "" {
+		writeError(w, http.StatusNotFound, "receipt not found")
+		return
+	}
+	d, err := erasure.Get(r.Context(), h.Pool, id.String())
+	if errors.Is(err, erasure.ErrNotFound) || (err == nil && !safeCompare(hashToken(token), d.TokenHash)) {
+		writeError(w, http.StatusNotFound, "receipt not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "receipt lookup failed")
+		return
+	}
+	receipt := erasureReceipt(d)
+	if d.Status == erasure.StatusErased {
+		status, deliveredAt, err := erasure.Propagation(r.Context(), h.Pool, d.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "receipt lookup failed")
+			return
+		}
+		receipt.Serverpod, receipt.ServerpodDeliveredAt = status, deliveredAt
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+func erasureReceipt(d *erasure.Deletion) models.ErasureReceipt {
+	return models.ErasureReceipt{
+		ReceiptID:    d.ID,
+		Status:       d.Status,
+		RequestedAt:  d.RequestedAt,
+		ScheduledFor: d.ScheduledFor,
+		CancelledAt:  d.CancelledAt,
+		ErasedAt:     d.ErasedAt,
+		Erased:       d.Erased,
+	}
+}
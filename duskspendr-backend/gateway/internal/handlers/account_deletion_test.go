@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAccountDeletionRequiresUser(t *testing.T) {
+	h := &UserHandler{}
+	for name, handler := range map[string]http.HandlerFunc{
+		"delete": h.DeleteMe,
+		"status": h.DeletionStatus,
+		"cancel": h.CancelDeletion,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("DELETE", "/users/me", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", name, rec.Code)
+		}
+	}
+}
+
+func TestErasureReceiptNeedsToken(t *testing.T) {
+	h := &UserHandler{}
+	for _, path := range []string{"/erasure-receipts/not-a-uuid", "/erasure-receipts/7f1c1a52-8c1f-4f0e-9d0e-3c1f6a1b2c3d"} {
+		rec := httptest.NewRecorder()
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", path[len("/erasure-receipts/"):])
+		req := httptest.NewRequest("GET", path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		h.ErasureReceipt(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, rec.Code)
+		}
+	}
+}
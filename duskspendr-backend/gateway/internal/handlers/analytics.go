@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"duskspendr-gateway/internal/analytics"
+)
+
+type AnalyticsHandler struct {
+	Store *analytics.Store
+}
+
+// SpendingSummary returns totals over ?from=&to= grouped by each of
+// ?group_by= (comma separated; category, merchant, payment_method, account;
+// all by default), keeping the top ?limit= groups per dimension.
+func (h *AnalyticsHandler) SpendingSummary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	rng, err := analyticsRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dims, err := analyticsDimensions(r.URL.Query().Get("group_by"), analytics.Dimensions)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	summary, err := h.Store.Summary(r.Context(), userID.String(), rng, dims, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Compare compares ?from=&to= with the period of the same length before it,
+// or the same period a year earlier with ?against=year, overall and per
+// ?group_by= value (category by default).
+func (h *AnalyticsHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	cur, err := analyticsRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var prev analytics.Range
+	switch r.URL.Query().Get("against") {
+	case "", "previous":
+		prev = cur.Previous()
+	case "year":
+		prev = cur.PreviousYear()
+	default:
+		writeError(w, http.StatusBadRequest, "against must be previous or year")
+		return
+	}
+	dims, err := analyticsDimensions(r.URL.Query().Get("group_by"), []analytics.Dimension{analytics.ByCategory})
+	if err != nil || len(dims) != 1 {
+		writeError(w, http.StatusBadRequest, "group_by must be one dimension")
+		return
+	}
+
+	ctx, uid := r.Context(), userID.String()
+	curSum, err := h.Store.Summary(ctx, uid, cur, dims, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	prevSum, err := h.Store.Summary(ctx, uid, prev, dims, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, analytics.Compare(cur, prev,
+		curSum.Totals, prevSum.Totals, curSum.Groups[dims[0]], prevSum.Groups[dims[0]]))
+}
+
+// Trends returns spend and income per ?interval= (day, week or month) over
+// ?from=&to=, with empty buckets included.
+func (h *AnalyticsHandler) Trends(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	interval, rng, ok := h.seriesParams(w, r)
+	if !ok {
+		return
+	}
+	points, err := h.Store.Series(r.Context(), userID.String(), interval, rng)
+	if errors.Is(err, analytics.ErrTooManyPoints) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"range": rng, "interval": interval, "points": points})
+}
+
+// CashFlow returns income against expense per ?interval= over ?from=&to=
+func (h *AnalyticsHandler) CashFlow(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	interval, rng, ok := h.seriesParams(w, r)
+	if !ok {
+		return
+	}
+	points, err := h.Store.Series(r.Context(), userID.String(), interval, rng)
+	if errors.Is(err, analytics.ErrTooManyPoints) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, analytics.NewCashFlow(rng, interval, points))
+}
+
+// seriesParams reads the interval and range for a series. Without ?from=
+// the range covers the last 30 days, 12 weeks or 12 months.
+func (h *AnalyticsHandler) seriesParams(w http.ResponseWriter, r *http.Request) (analytics.Interval, analytics.Range, bool) {
+	interval, err := analytics.ParseInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", analytics.Range{}, false
+	}
+	rng, err := analyticsRange(r, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", analytics.Range{}, false
+	}
+	if r.URL.Query().Get("from") == "" {
+		loc := h.Store.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		start := analytics.BucketStart(rng.To, interval, loc)
+		switch interval {
+		case analytics.Day:
+			rng.From = start.AddDate(0, 0, -29)
+		case analytics.Week:
+			rng.From = start.AddDate(0, 0, -7*11)
+		default:
+			rng.From = start.AddDate(0, -11, 0)
+		}
+	}
+	return interval, rng, true
+}
+
+// analyticsRange reads ?from=&to= (RFC 3339). to defaults to now and from
+// to defaultSpan before it.
+func analyticsRange(r *http.Request, defaultSpan time.Duration) (analytics.Range, error) {
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		return analytics.Range{}, err
+	}
+	rng := analytics.Range{To: time.Now().UTC()}
+	if to != nil {
+		rng.To = *to
+	}
+	rng.From = rng.To.Add(-defaultSpan)
+	if from != nil {
+		rng.From = *from
+	}
+	if !rng.From.Before(rng.To) {
+		return analytics.Range{}, errInvalid("from must be before to")
+	}
+	return rng, nil
+}
+
+func analyticsDimensions(v string, defaults []analytics.Dimension) ([]analytics.Dimension, error) {
+	if v == "" {
+		return defaults, nil
+	}
+	dims := []analytics.Dimension{}
+	for _, part := range strings.Split(v, ",") {
+		d, err := analytics.ParseDimension(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		dims = append(dims, d)
+	}
+	return dims, nil
+}
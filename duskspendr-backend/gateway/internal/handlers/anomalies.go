@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/anomaly"
+)
+
+type AnomalyHandler struct {
+	Pool *pgxpool.Pool
+}
+
+type anomalyEvent struct {
+	ID            string     `json:"id"`
+	TransactionID string     `json:"transaction_id"`
+	Rule          string     `json:"rule"`
+	Reason        *string    `json:"reason,omitempty"`
+	Score         float64    `json:"score"`
+	Detail        string     `json:"detail"`
+	Status        string     `json:"status"`
+	NotifiedAt    *time.Time `json:"notified_at,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	AmountPaisa   int64      `json:"amount_paisa"`
+	MerchantName  *string    `json:"merchant_name,omitempty"`
+	Category      string     `json:"category"`
+	Timestamp     time.Time  `json:"timestamp"`
+}
+
+// List returns flagged events, newest first. ?status= defaults to open;
+// all returns every status.
+func (h *AnomalyHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "open"
+	case "all":
+		status = ""
+	case "open", "dismissed", "confirmed":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT e.id::text, e.transaction_id::text, e.rule, e.reason, e.score, e.detail, e.status,
+		       e.notified_at, e.reviewed_at, e.created_at,
+		       t.amount_paisa, t.merchant_name, t.category, t.timestamp
+		  FROM anomaly_events e
+		  JOIN transactions t ON t.id = e.transaction_id
+		 WHERE e.user_id = $1 AND ($2 = '' OR e.status = $2)
+		 ORDER BY e.created_at DESC, e.id
+		 LIMIT $3
+	`, userID, status, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []anomalyEvent{}
+	for rows.Next() {
+		var e anomalyEvent
+		if err := rows.Scan(&e.ID, &e.TransactionID, &e.Rule, &e.Reason, &e.Score, &e.Detail, &e.Status,
+			&e.NotifiedAt, &e.ReviewedAt, &e.CreatedAt,
+			&e.AmountPaisa, &e.MerchantName, &e.Category, &e.Timestamp); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// Review marks an event dismissed, confirmed or open again
+func (h *AnomalyHandler) Review(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var input struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if input.Status != "open" && input.Status != "dismissed" && input.Status != "confirmed" {
+		writeError(w, http.StatusBadRequest, "status must be open, dismissed or confirmed")
+		return
+	}
+
+	cmd, err := h.Pool.Exec(r.Context(), `
+		UPDATE anomaly_events
+		   SET status = $3, reviewed_at = CASE WHEN $3 = 'open' THEN NULL ELSE now() END
+		 WHERE user_id = $1 AND id = $2
+	`, userID, id, input.Status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": input.Status})
+}
+
+// GetSettings returns the user's detector settings
+func (h *AnomalyHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	s, err := anomaly.LoadSettings(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// PutSettings updates the fields present in the body
+func (h *AnomalyHandler) PutSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	s, err := anomaly.LoadSettings(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if !anomaly.ValidSensitivity(s.Sensitivity) {
+		writeError(w, http.StatusBadRequest, "sensitivity must be low, medium or high")
+		return
+	}
+	if s.MinAmountPaisa < 0 {
+		writeError(w, http.StatusBadRequest, "invalid min_amount_paisa")
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(), `
+		INSERT INTO anomaly_settings (
+		  user_id, enabled, sensitivity, amount_rule, new_merchant_rule, unusual_hour_rule,
+		  rapid_repeat_rule, min_amount_paisa, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		ON CONFLICT (user_id) DO UPDATE SET
+		  enabled = EXCLUDED.enabled,
+		  sensitivity = EXCLUDED.sensitivity,
+		  amount_rule = EXCLUDED.amount_rule,
+		  new_merchant_rule = EXCLUDED.new_merchant_rule,
+		  unusual_hour_rule = EXCLUDED.unusual_hour_rule,
+		  rapid_repeat_rule = EXCLUDED.rapid_repeat_rule,
+		  min_amount_paisa = EXCLUDED.min_amount_paisa,
+		  updated_at = EXCLUDED.updated_at
+	`, userID, s.Enabled, s.Sensitivity, s.AmountRule, s.NewMerchant, s.UnusualHour,
+		s.RapidRepeat, s.MinAmountPaisa); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+  "context"
   "crypto/rand"
   "crypto/sha256"
   "crypto/subtle"
   "encoding/base64"
   "encoding/hex"
   "encoding/json"
+  "errors"
   "fmt"
+  "log"
   "math/big"
   "net"
   "net/http"
@@ -15,56 +18,108 @@ import (
   "strings"
   "time"
 
+  "github.com/go-webauthn/webauthn/webauthn"
   "github.com/google/uuid"
   "github.com/jackc/pgx/v5/pgxpool"
 
-  "duskspendr/gateway/internal/config"
-  "duskspendr/gateway/internal/models"
+  "duskspendr-gateway/internal/config"
+  "duskspendr-gateway/internal/models"
+  "duskspendr-gateway/internal/otpdelivery"
+  "duskspendr-gateway/internal/services"
+  "duskspendr-gateway/internal/session"
+  "duskspendr-gateway/internal/totp"
 )
 
-type HTTPAuthHandler struct {
-  Pool *pgxpool.Pool
-  Config config.Config
+// AuthStartResponse is the OTP start response; the code is only ever sent by SMS
+type AuthStartResponse = models.AuthStartResponse
+
+// AuthHandler serves phone OTP and passkey sign-in for both route trees. /v1
+// clients get an opaque session token from VerifySession; /api/v1 clients
+// get a JWT pair from Verify and rotate it with Refresh. WebAuthn and TOTP
+// are nil when passkeys or TOTP are not configured.
+type AuthHandler struct {
+  Pool     *pgxpool.Pool
+  Config   config.Config
+  JWT      *services.JWTService
+  Tokens   *services.TokenFamilies
+  Denylist services.TokenDenylist
+  Sessions *session.Revoker
+  Channels services.OTPChannels
+  WebAuthn *webauthn.WebAuthn
+  TOTP     *totp.Cipher
 }
 
-func (h *HTTPAuthHandler) Start(w http.ResponseWriter, r *http.Request) {
+func NewAuthHandler(pool *pgxpool.Pool, cfg config.Config, jwtSvc *services.JWTService, denylist services.TokenDenylist, channels services.OTPChannels) *AuthHandler {
+  tokens := services.NewTokenFamilies(jwtSvc, pool)
+  return &AuthHandler{
+    Pool:     pool,
+    Config:   cfg,
+    JWT:      jwtSvc,
+    Tokens:   tokens,
+    Denylist: denylist,
+    Sessions: &session.Revoker{
+      Pool:      pool,
+      Refresh:   tokens.Store,
+      Denylist:  denylist,
+      AccessTTL: jwtSvc.AccessExpiration(),
+    },
+    Channels: channels,
+    WebAuthn: newWebAuthn(cfg),
+    TOTP:     newTOTPCipher(cfg),
+  }
+}
+
+func (h *AuthHandler) Start(w http.ResponseWriter, r *http.Request) {
   var input models.AuthStartInput
   decoder := json.NewDecoder(r.Body)
   decoder.DisallowUnknownFields()
   if err := decoder.Decode(&input); err != nil {
-    writeError_HTTP(w, http.StatusBadRequest, "invalid json")
+    writeError(w, http.StatusBadRequest, "invalid json")
     return
   }
   input.Phone = strings.TrimSpace(input.Phone)
-  if !validatePhoneE164_HTTP(input.Phone) {
-    writeError_HTTP(w, http.StatusBadRequest, "invalid phone")
+  if !validatePhoneE164(input.Phone) {
+    writeErrorCode(w, http.StatusBadRequest, "INVALID_PHONE", "invalid phone")
+    return
+  }
+  if input.Channel == "" {
+    input.Channel = services.ChannelSMS
+  }
+  if !services.ValidChannel(input.Channel) {
+    writeErrorCode(w, http.StatusBadRequest, "INVALID_CHANNEL", "channel must be sms, whatsapp or voice")
+    return
+  }
+  channel, ok := h.Channels.Get(input.Channel)
+  if !ok {
+    writeErrorCode(w, http.StatusBadRequest, "CHANNEL_UNAVAILABLE", input.Channel+" delivery is not available")
     return
   }
   if h.Config.AuthPepper == "" {
-    writeError_HTTP(w, http.StatusInternalServerError, "auth not configured")
+    writeError(w, http.StatusInternalServerError, "auth not configured")
     return
   }
 
-  if err := h.enforceOTPSendLimits(r, input.Phone); err != nil {
-    writeError_HTTP(w, http.StatusTooManyRequests, err.Error())
+  channel = h.resendChannel(r, input.Phone, channel)
+  if err := h.enforceOTPSendLimits(r, input.Phone, channel.Channel()); err != nil {
+    writeError(w, http.StatusTooManyRequests, err.Error())
     return
   }
 
   userID, err := h.ensureUser(r, input.Phone)
   if err != nil {
-    writeError_HTTP(w, http.StatusInternalServerError, "user lookup failed")
+    writeError(w, http.StatusInternalServerError, "user lookup failed")
     return
   }
 
-  code, err := generateOTP_HTTP(6)
+  code, err := generateOTP(6)
   if err != nil {
-    writeError_HTTP(w, http.StatusInternalServerError, "otp generation failed")
+    writeError(w, http.StatusInternalServerError, "otp generation failed")
     return
   }
   otpID := uuid.New().String()
   expiresAt := time.Now().UTC().Add(5 * time.Minute)
   now := time.Now().UTC()
-  sendIP := clientIP_HTTP(r)
+  sendIP := clientIP(r)
 
   _, _ = h.Pool.Exec(r.Context(), `
     UPDATE auth_otps
@@ -72,44 +127,175 @@ func (h *HTTPAuthHandler) Start(w http.ResponseWriter, r *http.Request) {
      WHERE phone = $2 AND consumed_at IS NULL
   `, now, input.Phone)
 
-  codeHash := hashOTP_HTTP(h.Config.AuthPepper, otpID, code)
+  codeHash := hashOTP(h.Config.AuthPepper, otpID, code)
 
   _, err = h.Pool.Exec(r.Context(), `
-    INSERT INTO auth_otps (id, user_id, phone, code, code_hash, expires_at, created_at, attempts_remaining, send_ip)
-    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
-  `, otpID, userID, input.Phone, nil, codeHash, expiresAt, now, h.Config.OTPMaxAttempts, sendIP)
+    INSERT INTO auth_otps (id, user_id, phone, code, code_hash, expires_at, created_at, attempts_remaining, send_ip, channel)
+    VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+  `, otpID, userID, input.Phone, nil, codeHash, expiresAt, now, h.Config.OTPMaxAttempts, sendIP, channel.Channel())
   if err != nil {
-    writeError_HTTP(w, http.StatusInternalServerError, "otp insert failed")
+    writeError(w, http.StatusInternalServerError, "otp insert failed")
     return
   }
 
-  var devCode string
-  if h.Config.Env == "local" {
-    devCode = code
-  }
-  writeJSON_HTTP(w, http.StatusOK, models.AuthStartResponse{
+  go h.deliverOTP(otpID, input.Phone, code, channel.Channel())
+
+  writeJSON(w, http.StatusOK, AuthStartResponse{
     OTPID:     otpID,
     ExpiresAt: expiresAt,
+    Channel:   channel.Channel(),
   })
 }
 
-func (h *HTTPAuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
+// deliverOTP sends code on channel and, when every provider of the channel
+// fails, on the other channels in fallback order. Each attempt is recorded
+// for the delivery status endpoint.
+func (h *AuthHandler) deliverOTP(otpID, phone, code, channel string) {
+  ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+  defer cancel()
+  for _, ch := range h.Channels.From(channel) {
+    receipt, err := ch.SendOTP(ctx, phone, code)
+    if rerr := otpdelivery.Record(ctx, h.Pool, otpID, ch.Channel(), receipt, err); rerr != nil {
+      log.Printf("auth: recording delivery of otp %s failed: %v", otpID, rerr)
+    }
+    if err == nil {
+      return
+    }
+    log.Printf("auth: sending otp %s by %s failed: %v", otpID, ch.Channel(), err)
+    if ctx.Err() != nil {
+      return
+    }
+  }
+}
+
+// resendChannel moves a resend to the next channel. A request is a resend
+// when the phone's latest code, requested on the same channel within
+// OTPResendWindow, was neither used nor reported delivered; the next
+// channel follows the one that code was last tried on.
+func (h *AuthHandler) resendChannel(r *http.Request, phone string, requested services.OTPChannel) services.OTPChannel {
+  var prevID, prevChannel string
+  err := h.Pool.QueryRow(r.Context(), `
+    SELECT id, channel
+      FROM auth_otps
+     WHERE phone = $1 AND consumed_at IS NULL
+       AND created_at > now() - make_interval(secs => $2)
+     ORDER BY created_at DESC
+     LIMIT 1
+  `, phone, h.Config.OTPResendWindow.Seconds()).Scan(&prevID, &prevChannel)
+  if err != nil || prevChannel != requested.Channel() {
+    return requested
+  }
+  status, err := otpdelivery.Latest(r.Context(), h.Pool, prevID)
+  if err != nil || status.Status == services.SMSStatusDelivered {
+    return requested
+  }
+  last := prevChannel
+  if status.Channel != "" {
+    last = status.Channel
+  }
+  if next, ok := h.Channels.Next(last); ok {
+    return next
+  }
+  return requested
+}
+
+// VerifySession checks the code and issues an opaque session token (/v1)
+func (h *AuthHandler) VerifySession(w http.ResponseWriter, r *http.Request) {
+  input, userID, ok := h.verifyOTP(w, r)
+  if !ok {
+    return
+  }
+  h.issueSession(w, r, userID, deviceFrom(r, input))
+}
+
+// Verify checks the code and issues a JWT pair starting a new refresh token
+// family (/api/v1)
+func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
+  input, userID, ok := h.verifyOTP(w, r)
+  if !ok {
+    return
+  }
+  h.issueTokenPair(w, r, userID, input.Phone, deviceFrom(r, input))
+}
+
+// issueSession writes a new opaque session token for a signed-in user
+func (h *AuthHandler) issueSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID, device session.Device) {
+  token, tokenHash, err := generateToken()
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "token generation failed")
+    return
+  }
+
+  sessionExpires := time.Now().UTC().Add(30 * 24 * time.Hour)
+  _, err = session.CreateToken(r.Context(), h.Pool, userID.String(), tokenHash, device, sessionExpires)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "session insert failed")
+    return
+  }
+
+  writeJSON(w, http.StatusOK, models.AuthVerifyResponse{
+    Token:     token,
+    UserID:    userID.String(),
+    ExpiresAt: sessionExpires,
+  })
+}
+
+// issueTokenPair writes a JWT pair in a new refresh token family for a
+// signed-in user
+func (h *AuthHandler) issueTokenPair(w http.ResponseWriter, r *http.Request, userID uuid.UUID, phone string, device session.Device) {
+  var email *string
+  _ = h.Pool.QueryRow(r.Context(), `SELECT email FROM users WHERE id = $1`, userID).Scan(&email)
+  userEmail := ""
+  if email != nil {
+    userEmail = *email
+  }
+
+  pair, err := h.Tokens.Issue(r.Context(), userID.String(), userEmail, phone)
+  if err != nil {
+    writeErrorCode(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate tokens")
+    return
+  }
+  if _, err := session.CreateJWT(r.Context(), h.Pool, userID.String(), pair.FamilyID, device, pair.RefreshExpiresAt); err != nil {
+    writeErrorCode(w, http.StatusInternalServerError, "SESSION_ERROR", "failed to create session")
+    return
+  }
+
+  writeJSON(w, http.StatusOK, models.AuthTokenResponse{
+    AccessToken:  pair.AccessToken,
+    RefreshToken: pair.RefreshToken,
+    UserID:       userID.String(),
+    ExpiresIn:    int(pair.ExpiresIn),
+    ExpiresAt:    pair.ExpiresAt,
+  })
+}
+
+// verifyOTP checks the code against the phone's latest unconsumed OTP and
+// consumes it. On failure the error response has already been written.
+func (h *AuthHandler) verifyOTP(w http.ResponseWriter, r *http.Request) (models.AuthVerifyInput, uuid.UUID, bool) {
   var input models.AuthVerifyInput
   decoder := json.NewDecoder(r.Body)
   decoder.DisallowUnknownFields()
   if err := decoder.Decode(&input); err != nil {
-    writeError_HTTP(w, http.StatusBadRequest, "invalid json")
-    return
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return input, uuid.UUID{}, false
   }
   input.Phone = strings.TrimSpace(input.Phone)
   input.Code = strings.TrimSpace(input.Code)
-  if !validatePhoneE164_HTTP(input.Phone) || input.Code == "" {
-    writeError_HTTP(w, http.StatusBadRequest, "phone and code are required")
-    return
+  if !validatePhoneE164(input.Phone) || input.Code == "" {
+    writeError(w, http.StatusBadRequest, "phone and code are required")
+    return input, uuid.UUID{}, false
   }
+  userID, ok := h.consumeOTP(w, r, input.Phone, input.Code)
+  return input, userID, ok
+}
+
+// consumeOTP checks code against the phone's latest unconsumed OTP and
+// consumes it, marking the phone verified. On failure the error response
+// has already been written.
+func (h *AuthHandler) consumeOTP(w http.ResponseWriter, r *http.Request, phone, code string) (uuid.UUID, bool) {
   if h.Config.AuthPepper == "" {
-    writeError_HTTP(w, http.StatusInternalServerError, "auth not configured")
-    return
+    writeError(w, http.StatusInternalServerError, "auth not configured")
+    return uuid.UUID{}, false
   }
 
   var userID uuid.UUID
@@ -123,63 +309,145 @@ func (h *HTTPAuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
      WHERE phone = $1 AND consumed_at IS NULL
       ORDER BY created_at DESC
       LIMIT 1
-  `, input.Phone).Scan(&otpID, &userID, &expiresAt, &codeHash, &attemptsRemaining)
+  `, phone).Scan(&otpID, &userID, &expiresAt, &codeHash, &attemptsRemaining)
   if err != nil {
-    writeError_HTTP(w, http.StatusUnauthorized, "invalid code")
-    return
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_CODE", "invalid code")
+    return uuid.UUID{}, false
   }
   if time.Now().UTC().After(expiresAt) {
-    writeError_HTTP(w, http.StatusUnauthorized, "code expired")
-    return
+    writeErrorCode(w, http.StatusUnauthorized, "CODE_EXPIRED", "code expired")
+    return uuid.UUID{}, false
   }
   if attemptsRemaining <= 0 {
-    writeError_HTTP(w, http.StatusUnauthorized, "too many attempts")
-    return
+    writeErrorCode(w, http.StatusUnauthorized, "MAX_ATTEMPTS", "too many attempts")
+    return uuid.UUID{}, false
   }
 
-  expectedHash := hashOTP_HTTP(h.Config.AuthPepper, otpID, input.Code)
-  if !safeCompare_HTTP(codeHash, expectedHash) {
+  expectedHash := hashOTP(h.Config.AuthPepper, otpID, code)
+  if !safeCompare(codeHash, expectedHash) {
     _, _ = h.Pool.Exec(r.Context(), `
       UPDATE auth_otps
          SET attempts_remaining = GREATEST(attempts_remaining - 1, 0)
        WHERE id = $1
     `, otpID)
-    writeError_HTTP(w, http.StatusUnauthorized, "invalid code")
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_CODE", "invalid code")
+    return uuid.UUID{}, false
+  }
+
+  _, _ = h.Pool.Exec(r.Context(), `
+    UPDATE auth_otps
+       SET consumed_at = $1, verify_ip = $2
+     WHERE id = $3
+  `, time.Now().UTC(), clientIP(r), otpID)
+  // A verified phone is what allows the user to register passkeys
+  _, _ = h.Pool.Exec(r.Context(), `
+    UPDATE users
+       SET phone_verified_at = coalesce(phone_verified_at, now())
+     WHERE id = $1
+  `, userID)
+  return userID, true
+}
+
+// Refresh exchanges a refresh token for a new pair; the presented token is spent
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+  var input models.AuthRefreshInput
+  if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+    writeError(w, http.StatusBadRequest, "invalid json")
+    return
+  }
+  if input.RefreshToken == "" {
+    writeErrorCode(w, http.StatusBadRequest, "MISSING_TOKEN", "refresh token is required")
     return
   }
 
-  token, tokenHash, err := generateToken_HTTP()
-  if err != nil {
-    writeError_HTTP(w, http.StatusInternalServerError, "token generation failed")
+  pair, familyID, err := h.Tokens.Rotate(r.Context(), input.RefreshToken)
+  switch {
+  case errors.Is(err, services.ErrTokenReused):
+    // Whoever holds the family's live access tokens may be the thief: deny
+    // them and end the family's session, as logout does
+    if err := h.Sessions.RevokeFamily(r.Context(), familyID, "reuse"); err != nil {
+      log.Printf("session: revoking reused token family failed: %v", err)
+    }
+    writeErrorCode(w, http.StatusUnauthorized, "TOKEN_REUSED", "refresh token was already used; sign in again")
     return
+  case errors.Is(err, services.ErrTokenRevoked):
+    writeErrorCode(w, http.StatusUnauthorized, "TOKEN_REVOKED", "refresh token has been revoked")
+    return
+  case errors.Is(err, services.ErrInvalidToken), errors.Is(err, services.ErrExpiredToken), errors.Is(err, services.ErrInvalidTokenType):
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_TOKEN", "invalid or expired refresh token")
+    return
+  case err != nil:
+    writeErrorCode(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to refresh tokens")
+    return
+  }
+  if err := session.TouchFamily(r.Context(), h.Pool, pair.FamilyID, clientIP(r), pair.RefreshExpiresAt); err != nil {
+    log.Printf("session: touch family failed: %v", err)
   }
 
-  sessionID := uuid.New().String()
-  sessionExpires := time.Now().UTC().Add(30 * 24 * time.Hour)
+  writeJSON(w, http.StatusOK, models.AuthRefreshResponse{
+    AccessToken:  pair.AccessToken,
+    RefreshToken: pair.RefreshToken,
+    ExpiresIn:    int(pair.ExpiresIn),
+  })
+}
 
-  _, err = h.Pool.Exec(r.Context(), `
-    INSERT INTO sessions (id, user_id, token_hash, expires_at, created_at)
-    VALUES ($1,$2,$3,$4,$5)
-  `, sessionID, userID, tokenHash, sessionExpires, time.Now().UTC())
-  if err != nil {
-    writeError_HTTP(w, http.StatusInternalServerError, "session insert failed")
+// Logout ends the caller's session. A JWT session has its refresh family
+// revoked and its access tokens denied until they expire; an opaque token
+// session is deleted.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+  userID, ok := UserIDFromContext(r.Context())
+  if !ok {
+    writeError(w, http.StatusUnauthorized, "not authenticated")
     return
   }
 
-  _, _ = h.Pool.Exec(r.Context(), `
-    UPDATE auth_otps
-       SET consumed_at = $1, verify_ip = $2
-     WHERE id = $3
-  `, time.Now().UTC(), clientIP_HTTP(r), otpID)
+  if claims := ClaimsFromContext(r.Context()); claims != nil {
+    if claims.FamilyID != "" {
+      if err := h.Sessions.RevokeFamily(r.Context(), claims.FamilyID, "logout"); err != nil {
+        writeErrorCode(w, http.StatusInternalServerError, "LOGOUT_FAILED", "failed to revoke session")
+        return
+      }
+    }
+    if claims.ExpiresAt != nil {
+      if err := h.Denylist.DenyToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+        writeErrorCode(w, http.StatusInternalServerError, "LOGOUT_FAILED", "failed to revoke session")
+        return
+      }
+    }
+  } else if id := SessionIDFromContext(r.Context()); id != "" {
+    if err := h.Sessions.Revoke(r.Context(), userID.String(), id); err != nil && !errors.Is(err, session.ErrNotFound) {
+      writeErrorCode(w, http.StatusInternalServerError, "LOGOUT_FAILED", "failed to revoke session")
+      return
+    }
+  }
 
-  writeJSON_HTTP(w, http.StatusOK, models.AuthVerifyResponse{
-    Token:     token,
-    UserID:    userID.String(),
-    ExpiresAt: sessionExpires,
-  })
+  writeJSON(w, http.StatusOK, map[string]string{"status": "logged_out"})
+}
+
+// LogoutAll ends every session of the user on all devices, in both flows
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+  userID, ok := UserIDFromContext(r.Context())
+  if !ok {
+    writeError(w, http.StatusUnauthorized, "not authenticated")
+    return
+  }
+
+  revoked, err := h.Sessions.RevokeAll(r.Context(), userID.String(), "logout_all")
+  if err != nil {
+    writeErrorCode(w, http.StatusInternalServerError, "LOGOUT_FAILED", "failed to revoke sessions")
+    return
+  }
+  if claims := ClaimsFromContext(r.Context()); claims != nil && claims.ExpiresAt != nil {
+    if err := h.Denylist.DenyToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+      writeErrorCode(w, http.StatusInternalServerError, "LOGOUT_FAILED", "failed to revoke sessions")
+      return
+    }
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{"status": "logged_out", "sessions_revoked": revoked})
 }
 
-func (h *HTTPAuthHandler) ensureUser(r *http.Request, phone string) (uuid.UUID, error) {
+func (h *AuthHandler) ensureUser(r *http.Request, phone string) (uuid.UUID, error) {
   id := uuid.New()
   var userID uuid.UUID
   err := h.Pool.QueryRow(r.Context(), `
@@ -192,7 +460,7 @@ func (h *HTTPAuthHandler) ensureUser(r *http.Request, phone string) (uuid.UUID,
   return userID, err
 }
 
-func (h *HTTPAuthHandler) enforceOTPSendLimits(r *http.Request, phone string) error {
+func (h *AuthHandler) enforceOTPSendLimits(r *http.Request, phone, channel string) error {
   var recentCount int
   err := h.Pool.QueryRow(r.Context(), `
     SELECT COUNT(*)
@@ -206,7 +474,20 @@ func (h *HTTPAuthHandler) enforceOTPSendLimits(r *http.Request, phone string) er
     return fmt.Errorf("too many otp requests")
   }
 
-  ip := clientIP_HTTP(r)
+  var channelCount int
+  err = h.Pool.QueryRow(r.Context(), `
+    SELECT COUNT(*)
+      FROM auth_otps
+     WHERE phone = $1 AND channel = $2 AND created_at > (now() - interval '1 hour')
+  `, phone, channel).Scan(&channelCount)
+  if err != nil {
+    return fmt.Errorf("otp limit check failed")
+  }
+  if channelCount >= h.Config.OTPMaxPerHourFor(channel) {
+    return fmt.Errorf("too many otp requests on %s", channel)
+  }
+
+  ip := clientIP(r)
   if ip != "" {
     var ipCount int
     err = h.Pool.QueryRow(r.Context(), `
@@ -235,7 +516,17 @@ func (h *HTTPAuthHandler) enforceOTPSendLimits(r *http.Request, phone string) er
   return nil
 }
 
-func generateOTP_HTTP(length int) (string, error) {
+func deviceFrom(r *http.Request, input models.AuthVerifyInput) session.Device {
+  return session.Device{
+    Name:       input.DeviceName,
+    Platform:   input.Platform,
+    AppVersion: input.AppVersion,
+    IP:         clientIP(r),
+    UserAgent:  r.UserAgent(),
+  }
+}
+
+func generateOTP(length int) (string, error) {
   if length <= 0 {
     return "", fmt.Errorf("invalid otp length")
   }
@@ -251,33 +542,34 @@ func generateOTP_HTTP(length int) (string, error) {
   return string(buf), nil
 }
 
-func generateToken_HTTP() (string, string, error) {
+func generateToken() (string, string, error) {
   raw := make([]byte, 32)
   if _, err := rand.Read(raw); err != nil {
     return "", "", err
   }
   token := base64.RawURLEncoding.EncodeToString(raw)
-  return token, hashToken_HTTP(token), nil
+  return token, hashToken(token), nil
 }
 
-func validatePhoneE164_HTTP(phone string) bool {
-  re := regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
-  return re.MatchString(phone)
+var phoneE164 = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+
+func validatePhoneE164(phone string) bool {
+  return phoneE164.MatchString(phone)
 }
 
-func hashOTP_HTTP(pepper, otpID, code string) string {
+func hashOTP(pepper, otpID, code string) string {
   sum := sha256.Sum256([]byte(pepper + ":" + otpID + ":" + code))
   return hex.EncodeToString(sum[:])
 }
 
-func safeCompare_HTTP(a, b string) bool {
+func safeCompare(a, b string) bool {
   if len(a) != len(b) {
     return false
   }
   return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
 
-func clientIP_HTTP(r *http.Request) string {
+func clientIP(r *http.Request) string {
   if r.RemoteAddr == "" {
     return ""
   }
@@ -288,17 +580,7 @@ func clientIP_HTTP(r *http.Request) string {
   return r.RemoteAddr
 }
 
-func writeError_HTTP(w http.ResponseWriter, status int, message string) {
-  w.WriteHeader(status)
-  json.NewEncoder(w).Encode(map[string]string{"error": message})
-}
-
-func writeJSON_HTTP(w http.ResponseWriter, status int, data interface{}) {
-  w.WriteHeader(status)
-  json.NewEncoder(w).Encode(data)
-}
-
-func hashToken_HTTP(token string) string {
+func hashToken(token string) string {
   sum := sha256.Sum256([]byte(token))
   return hex.EncodeToString(sum[:])
 }
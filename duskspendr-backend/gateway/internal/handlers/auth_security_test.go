@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"duskspendr/gateway/internal/models"
+	"duskspendr-gateway/internal/models"
 )
 
 func TestAuthStartResponse_NoDevCode(t *testing.T) {
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/forecast"
+	"duskspendr-gateway/internal/models"
+)
+
+type BillHandler struct {
+	Pool *pgxpool.Pool
+}
+
+func (h *BillHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT id, name, type, amount_paisa, category, linked_account_id::text, frequency,
+		       to_char(next_due, 'YYYY-MM-DD'), is_active, created_at, updated_at
+		  FROM scheduled_bills
+		 WHERE user_id = $1
+		 ORDER BY next_due, name
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.ScheduledBill{}
+	for rows.Next() {
+		var b models.ScheduledBill
+		if err := rows.Scan(&b.ID, &b.Name, &b.Type, &b.AmountPaisa, &b.Category, &b.LinkedAccountID,
+			&b.Frequency, &b.NextDue, &b.IsActive, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *BillHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	input, ok := h.decode(w, r, userID.String())
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	id := uuid.New().String()
+	_, err := h.Pool.Exec(r.Context(), `
+		INSERT INTO scheduled_bills (
+		  id, user_id, name, type, amount_paisa, category, linked_account_id, frequency,
+		  next_due, is_active, created_at, updated_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$11)
+	`, id, userID, input.Name, input.Type, input.AmountPaisa, input.Category, input.LinkedAccountID,
+		input.Frequency, input.NextDue, input.IsActive == nil || *input.IsActive, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *BillHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	input, ok := h.decode(w, r, userID.String())
+	if !ok {
+		return
+	}
+
+	cmd, err := h.Pool.Exec(r.Context(), `
+		UPDATE scheduled_bills
+		   SET name = $3, type = $4, amount_paisa = $5, category = $6, linked_account_id = $7,
+		       frequency = $8, next_due = $9, is_active = $10, updated_at = $11
+		 WHERE user_id = $1 AND id = $2
+	`, userID, id, input.Name, input.Type, input.AmountPaisa, input.Category, input.LinkedAccountID,
+		input.Frequency, input.NextDue, input.IsActive == nil || *input.IsActive, time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+func (h *BillHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	cmd, err := h.Pool.Exec(r.Context(), `
+		DELETE FROM scheduled_bills WHERE user_id = $1 AND id = $2
+	`, userID, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// decode reads and validates a bill, writing the error response when it
+// is not valid.
+func (h *BillHandler) decode(w http.ResponseWriter, r *http.Request, userID string) (models.ScheduledBillInput, bool) {
+	var input models.ScheduledBillInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return input, false
+	}
+	if err := validateBillInput(&input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return input, false
+	}
+	if input.Category != nil {
+		categories, err := category.Load(r.Context(), h.Pool, userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return input, false
+		}
+		if !categories.Has(*input.Category) {
+			writeError(w, http.StatusBadRequest, "invalid category")
+			return input, false
+		}
+	}
+	if input.LinkedAccountID != nil {
+		owned, err := ownsAccount(r.Context(), h.Pool, userID, *input.LinkedAccountID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return input, false
+		}
+		if !owned {
+			writeError(w, http.StatusBadRequest, "invalid linked_account_id")
+			return input, false
+		}
+	}
+	return input, true
+}
+
+func validateBillInput(input *models.ScheduledBillInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return errInvalid("name is required")
+	}
+	if input.Type == "" {
+		input.Type = "debit"
+	}
+	if input.Type != "debit" && input.Type != "credit" {
+		return errInvalid("type must be debit or credit")
+	}
+	if input.AmountPaisa <= 0 {
+		return errInvalid("amount_paisa must be > 0")
+	}
+	if !forecast.ValidBillFrequency(input.Frequency) {
+		return errInvalid("frequency must be once, weekly, monthly or yearly")
+	}
+	if _, err := time.Parse("2006-01-02", input.NextDue); err != nil {
+		return errInvalid("next_due must be YYYY-MM-DD")
+	}
+	if input.LinkedAccountID != nil {
+		if _, err := uuid.Parse(*input.LinkedAccountID); err != nil {
+			return errInvalid("invalid linked_account_id")
+		}
+	}
+	return nil
+}
+
+func ownsAccount(ctx context.Context, pool *pgxpool.Pool, userID, accountID string) (bool, error) {
+	var owned bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM linked_accounts WHERE user_id = $1 AND id = $2)
+	`, userID, accountID).Scan(&owned)
+	return owned, err
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"duskspendr-gateway/internal/models"
+)
+
+func TestValidateBillInput(t *testing.T) {
+	valid := models.ScheduledBillInput{Name: " Rent ", AmountPaisa: 1500000, Frequency: "monthly", NextDue: "2024-04-05"}
+	if err := validateBillInput(&valid); err != nil {
+		t.Fatalf("valid bill: %v", err)
+	}
+	if valid.Name != "Rent" || valid.Type != "debit" {
+		t.Errorf("normalized = %+v", valid)
+	}
+
+	badAccount := "not-a-uuid"
+	cases := map[string]models.ScheduledBillInput{
+		"no name":     {AmountPaisa: 1, Frequency: "monthly", NextDue: "2024-04-05"},
+		"bad type":    {Name: "x", Type: "refund", AmountPaisa: 1, Frequency: "monthly", NextDue: "2024-04-05"},
+		"zero amount": {Name: "x", Frequency: "monthly", NextDue: "2024-04-05"},
+		"biweekly":    {Name: "x", AmountPaisa: 1, Frequency: "biweekly", NextDue: "2024-04-05"},
+		"bad date":    {Name: "x", AmountPaisa: 1, Frequency: "once", NextDue: "05/04/2024"},
+		"bad account": {Name: "x", AmountPaisa: 1, Frequency: "once", NextDue: "2024-04-05", LinkedAccountID: &badAccount},
+	}
+	for name, input := range cases {
+		if err := validateBillInput(&input); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
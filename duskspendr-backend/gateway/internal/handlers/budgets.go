@@ -9,11 +9,15 @@ import (
   "github.com/google/uuid"
   "github.com/jackc/pgx/v5/pgxpool"
 
-  "duskspendr/gateway/internal/models"
+  "duskspendr-gateway/internal/analytics"
+  "duskspendr-gateway/internal/category"
+  "duskspendr-gateway/internal/models"
 )
 
 type BudgetHandler struct {
   Pool *pgxpool.Pool
+  // Location is where a budget's day, week (Monday) and month start
+  Location *time.Location
 }
 
 func (h *BudgetHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -23,13 +27,39 @@ func (h *BudgetHandler) List(w http.ResponseWriter, r *http.Request) {
     return
   }
 
+  // Spend is summed from transactions for the current period. amount_paisa
+  // is always in the user's base currency, so foreign spends count at their
+  // converted value. Periods start in h.Location, as analytics buckets do.
+  now := time.Now()
   rows, err := h.Pool.Query(r.Context(), `
-    SELECT id, user_id, name, limit_paisa, spent_paisa, period, category,
-           alert_threshold, is_active, created_at, updated_at
-      FROM budgets
-     WHERE user_id = $1
-     ORDER BY created_at DESC
-  `, userID)
+    SELECT b.id, b.user_id, b.name, b.limit_paisa, coalesce(s.spent, 0), b.period,
+           b.category, b.alert_threshold, b.is_active, b.created_at, b.updated_at
+      FROM budgets b
+      LEFT JOIN LATERAL (
+        SELECT sum(t.amount_paisa) AS spent
+          FROM transactions t
+         WHERE t.user_id = b.user_id
+           AND t.type = 'debit'
+           AND (b.category IS NULL OR t.category = b.category OR t.category IN (
+                 SELECT c.key FROM categories c
+                  WHERE c.parent_key = b.category
+                    AND (c.user_id IS NULL OR c.user_id = b.user_id)))
+           AND t.timestamp >= CASE b.period
+                 WHEN 'daily' THEN $2::timestamptz
+                 WHEN 'weekly' THEN $3::timestamptz
+                 WHEN 'yearly' THEN $5::timestamptz
+                 ELSE $4::timestamptz
+               END
+      ) s ON true
+     WHERE b.user_id = $1
+     ORDER BY b.created_at DESC
+  `,
+    userID,
+    budgetPeriodStart("daily", now, h.Location),
+    budgetPeriodStart("weekly", now, h.Location),
+    budgetPeriodStart("monthly", now, h.Location),
+    budgetPeriodStart("yearly", now, h.Location),
+  )
   if err != nil {
     writeError(w, http.StatusInternalServerError, "query failed")
     return
@@ -85,6 +115,9 @@ func (h *BudgetHandler) Create(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "period is required")
     return
   }
+  if !h.validCategory(w, r, userID.String(), input.Category) {
+    return
+  }
 
   alertThreshold := 0.8
   if input.AlertThreshold != nil {
@@ -153,6 +186,9 @@ func (h *BudgetHandler) Update(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "period is required")
     return
   }
+  if !h.validCategory(w, r, userID.String(), input.Category) {
+    return
+  }
 
   alertThreshold := 0.8
   if input.AlertThreshold != nil {
@@ -197,3 +233,38 @@ func (h *BudgetHandler) Update(w http.ResponseWriter, r *http.Request) {
 
   writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
+
+// budgetPeriodStart returns when the budget period containing now began in
+// loc; unknown periods are monthly, like the spent query's fallback.
+func budgetPeriodStart(period string, now time.Time, loc *time.Location) time.Time {
+  if loc == nil {
+    loc = time.UTC
+  }
+  switch period {
+  case "daily":
+    return analytics.BucketStart(now, analytics.Day, loc)
+  case "weekly":
+    return analytics.BucketStart(now, analytics.Week, loc)
+  case "yearly":
+    return time.Date(now.In(loc).Year(), time.January, 1, 0, 0, 0, 0, loc)
+  }
+  return analytics.BucketStart(now, analytics.Month, loc)
+}
+
+// validCategory checks a budget's category against the user's categories,
+// writing the error response when it is not valid.
+func (h *BudgetHandler) validCategory(w http.ResponseWriter, r *http.Request, userID string, key *string) bool {
+  if key == nil {
+    return true
+  }
+  categories, err := category.Load(r.Context(), h.Pool, userID)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "query failed")
+    return false
+  }
+  if !categories.Has(*key) {
+    writeError(w, http.StatusBadRequest, "invalid category")
+    return false
+  }
+  return true
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetPeriodStart(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	// Wednesday 1 Jan 2025, 02:00 in Kolkata; still 31 Dec 2024 in UTC
+	now := time.Date(2025, time.January, 1, 2, 0, 0, 0, ist)
+	cases := map[string]time.Time{
+		"daily":   time.Date(2025, time.January, 1, 0, 0, 0, 0, ist),
+		"weekly":  time.Date(2024, time.December, 30, 0, 0, 0, 0, ist),
+		"monthly": time.Date(2025, time.January, 1, 0, 0, 0, 0, ist),
+		"yearly":  time.Date(2025, time.January, 1, 0, 0, 0, 0, ist),
+		"":        time.Date(2025, time.January, 1, 0, 0, 0, 0, ist),
+	}
+	for period, want := range cases {
+		if got := budgetPeriodStart(period, now, ist); !got.Equal(want) {
+			t.Errorf("%q start = %v, want %v", period, got, want)
+		}
+	}
+	if got := budgetPeriodStart("yearly", now, nil); got.Year() != 2024 {
+		t.Errorf("UTC yearly start = %v", got)
+	}
+}
@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/analytics"
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/models"
+)
+
+type CategoryHandler struct {
+	Pool    *pgxpool.Pool
+	Rollups *analytics.Rollups
+}
+
+// List returns the user's categories, system defaults included, as a tree
+// flattened parent-first.
+func (h *CategoryHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	categories, err := category.Load(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": categories.List()})
+}
+
+type categoryInput struct {
+	Key    *string `json:"key,omitempty"`
+	Name   *string `json:"name,omitempty"`
+	Parent *string `json:"parent,omitempty"`
+	Icon   *string `json:"icon,omitempty"`
+	Color  *string `json:"color,omitempty"`
+}
+
+// Create adds a custom category, or a subcategory when parent is set. The
+// key defaults to a camelCase form of the name.
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	var input categoryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if input.Name == nil {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if input.Key == nil {
+		key := category.KeyFor(*input.Name)
+		input.Key = &key
+	}
+
+	categories, err := category.Load(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+	c := category.Category{Key: *input.Key}
+	if err := applyCategoryInput(categories, &c, input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if categories.Has(c.Key) {
+		writeError(w, http.StatusConflict, "category already exists")
+		return
+	}
+
+	_, err = h.Pool.Exec(r.Context(), `
+		INSERT INTO categories (user_id, key, name, parent_key, icon, color)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, userID, c.Key, c.Name, c.Parent, c.Icon, c.Color)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// Update changes a category. Custom categories can be renamed (a new key
+// re-points transactions, budgets and merchant overrides) and moved under
+// another parent; system categories only take a new name, icon and colour
+// for this user.
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	key := chi.URLParam(r, "key")
+	var input categoryInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	categories, err := loadCategoriesForUpdate(r.Context(), tx, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	current, ok := categories[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	updated := current
+	if err := applyCategoryInput(categories, &updated, input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if updated.Parent != nil && len(categories.Children(key)) > 0 {
+		writeError(w, http.StatusBadRequest, "a category with subcategories cannot become a subcategory")
+		return
+	}
+
+	var repointed repointCounts
+	if current.System {
+		if updated.Key != current.Key || !sameString(updated.Parent, current.Parent) {
+			writeError(w, http.StatusBadRequest, "system categories cannot be renamed or moved")
+			return
+		}
+		_, err = tx.Exec(r.Context(), `
+			INSERT INTO categories (user_id, key, name, icon, color)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, key) WHERE user_id IS NOT NULL DO UPDATE SET
+			  name = EXCLUDED.name, icon = EXCLUDED.icon, color = EXCLUDED.color, updated_at = now()
+		`, userID, key, updated.Name, updated.Icon, updated.Color)
+	} else {
+		if updated.Key != key && categories.Has(updated.Key) {
+			writeError(w, http.StatusConflict, "category already exists")
+			return
+		}
+		_, err = tx.Exec(r.Context(), `
+			UPDATE categories
+			   SET key = $3, name = $4, parent_key = $5, icon = $6, color = $7, updated_at = now()
+			 WHERE user_id = $1 AND key = $2
+		`, userID, key, updated.Key, updated.Name, updated.Parent, updated.Icon, updated.Color)
+		if err == nil && updated.Key != key {
+			_, err = tx.Exec(r.Context(), `
+				UPDATE categories SET parent_key = $3, updated_at = now()
+				 WHERE user_id = $1 AND parent_key = $2
+			`, userID, key, updated.Key)
+		}
+		if err == nil && updated.Key != key {
+			repointed, err = repointCategory(r.Context(), tx, h.Rollups, userID.String(), key, updated.Key)
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"category": updated, "repointed": repointed})
+}
+
+// Merge folds a custom category into another: its transactions, budgets,
+// merchant overrides and subcategories move to the target and it is deleted.
+func (h *CategoryHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	var input struct {
+		Into string `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	h.merge(w, r, userID.String(), chi.URLParam(r, "key"), input.Into)
+}
+
+// Delete removes a custom category, moving what was filed under it to its
+// parent, or to "other" for a top-level category. For a system category it
+// only drops the user's name, icon and colour changes.
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	key := chi.URLParam(r, "key")
+
+	categories, err := category.Load(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		return
+	}
+	c, ok := categories[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if c.System {
+		if _, err := h.Pool.Exec(r.Context(), `
+			DELETE FROM categories WHERE user_id = $1 AND key = $2
+		`, userID, key); err != nil {
+			writeError(w, http.StatusInternalServerError, "delete failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+		return
+	}
+	into := category.Other
+	if c.Parent != nil {
+		into = *c.Parent
+	}
+	h.merge(w, r, userID.String(), key, into)
+}
+
+func (h *CategoryHandler) merge(w http.ResponseWriter, r *http.Request, userID, from, into string) {
+	if from == into {
+		writeError(w, http.StatusBadRequest, "cannot merge a category into itself")
+		return
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "merge failed")
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	categories, err := loadCategoriesForUpdate(r.Context(), tx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "merge failed")
+		return
+	}
+	source, ok := categories[from]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if source.System {
+		writeError(w, http.StatusBadRequest, "system categories cannot be merged away")
+		return
+	}
+	if _, ok := categories[into]; !ok {
+		writeError(w, http.StatusBadRequest, "invalid into")
+		return
+	}
+
+	for key, parent := range mergedParents(categories, from, into) {
+		if _, err := tx.Exec(r.Context(), `
+			UPDATE categories SET parent_key = $3, updated_at = now()
+			 WHERE user_id = $1 AND key = $2
+		`, userID, key, parent); err != nil {
+			writeError(w, http.StatusInternalServerError, "merge failed")
+			return
+		}
+	}
+	counts, err := repointCategory(r.Context(), tx, h.Rollups, userID, from, into)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "merge failed")
+		return
+	}
+	if _, err := tx.Exec(r.Context(), `
+		DELETE FROM categories WHERE user_id = $1 AND key = $2
+	`, userID, from); err != nil {
+		writeError(w, http.StatusInternalServerError, "merge failed")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "merge failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "merged", "into": into, "repointed": counts})
+}
+
+// mergedParents returns the new parent of every category that moves when
+// from is merged into into. Subcategories of from follow it, staying one
+// level below a top-level category. When into is itself one of them it
+// takes from's place, and its former siblings move under it.
+func mergedParents(categories category.Set, from, into string) map[string]*string {
+	moved := map[string]*string{}
+	newParent := into
+	if target := categories[into]; target.Parent != nil {
+		if *target.Parent == from {
+			moved[into] = categories[from].Parent
+		} else {
+			newParent = *target.Parent
+		}
+	}
+	for _, child := range categories.Children(from) {
+		if child != into {
+			moved[child] = &newParent
+		}
+	}
+	return moved
+}
+
+// loadCategoriesForUpdate locks the user's category rows so concurrent
+// renames and merges are applied one after another.
+func loadCategoriesForUpdate(ctx context.Context, tx pgx.Tx, userID string) (category.Set, error) {
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM categories WHERE user_id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, err
+	}
+	return category.Load(ctx, tx, userID)
+}
+
+// applyCategoryInput validates input and applies it to c
+func applyCategoryInput(categories category.Set, c *category.Category, input categoryInput) error {
+	if input.Key != nil {
+		if !category.ValidKey(*input.Key) {
+			return errInvalid("invalid key")
+		}
+		c.Key = *input.Key
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || len(name) > 50 {
+			return errInvalid("invalid name")
+		}
+		c.Name = name
+	}
+	if input.Parent != nil {
+		if *input.Parent == "" {
+			c.Parent = nil
+		} else {
+			parent, ok := categories[*input.Parent]
+			if !ok || parent.Parent != nil || parent.Key == c.Key {
+				return errInvalid("parent must be a top-level category")
+			}
+			c.Parent = &parent.Key
+		}
+	}
+	if input.Icon != nil {
+		if len(*input.Icon) > 50 {
+			return errInvalid("invalid icon")
+		}
+		c.Icon = input.Icon
+	}
+	if input.Color != nil {
+		if !category.ValidColor(*input.Color) {
+			return errInvalid("color must be #RRGGBB")
+		}
+		c.Color = input.Color
+	}
+	return nil
+}
+
+type repointCounts struct {
+	Transactions int64 `json:"transactions"`
+	Budgets      int64 `json:"budgets"`
+	Bills        int64 `json:"bills"`
+}
+
+// repointCategory moves everything filed under from to to and queues the
+// changed transactions for sync.
+func repointCategory(ctx context.Context, tx pgx.Tx, rollups *analytics.Rollups, userID, from, to string) (repointCounts, error) {
+	var counts repointCounts
+	now := time.Now().UTC()
+	idRows, err := tx.Query(ctx, `
+		SELECT id::text FROM transactions WHERE user_id = $1 AND category = $2 FOR UPDATE
+	`, userID, from)
+	if err != nil {
+		return counts, err
+	}
+	ids, err := pgx.CollectRows(idRows, pgx.RowTo[string])
+	if err != nil {
+		return counts, err
+	}
+	if err := rollups.Remove(ctx, tx, userID, ids); err != nil {
+		return counts, err
+	}
+	rows, err := tx.Query(ctx, `
+		UPDATE transactions SET category = $3, updated_at = $4
+		 WHERE user_id = $1 AND category = $2
+		RETURNING `+transactionColumns, userID, from, to, now)
+	if err != nil {
+		return counts, err
+	}
+	items := []models.Transaction{}
+	for rows.Next() {
+		item, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			return counts, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return counts, err
+	}
+	counts.Transactions = int64(len(items))
+	if err := rollups.Add(ctx, tx, userID, ids); err != nil {
+		return counts, err
+	}
+
+	cmd, err := tx.Exec(ctx, `
+		UPDATE budgets SET category = $3, updated_at = $4
+		 WHERE user_id = $1 AND category = $2
+	`, userID, from, to, now)
+	if err != nil {
+		return counts, err
+	}
+	counts.Budgets = cmd.RowsAffected()
+
+	cmd, err = tx.Exec(ctx, `
+		UPDATE scheduled_bills SET category = $3, updated_at = $4
+		 WHERE user_id = $1 AND category = $2
+	`, userID, from, to, now)
+	if err != nil {
+		return counts, err
+	}
+	counts.Bills = cmd.RowsAffected()
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_merchant_overrides SET category = $3, updated_at = $4
+		 WHERE user_id = $1 AND category = $2
+	`, userID, from, to, now); err != nil {
+		return counts, err
+	}
+
+	if err := enqueueTransactionUpsert(ctx, tx, userID, items); err != nil {
+		return counts, err
+	}
+	return counts, nil
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/analytics"
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/models"
+)
+
+func TestApplyCategoryInput(t *testing.T) {
+	categories := category.NewSet(
+		category.Category{Key: "groceries", Name: "Groceries", Parent: strPtr("food")},
+	)
+
+	c := category.Category{Key: "rent"}
+	err := applyCategoryInput(categories, &c, categoryInput{
+		Name:  strPtr(" Rent "),
+		Icon:  strPtr("home"),
+		Color: strPtr("#00AA88"),
+	})
+	if err != nil || c.Name != "Rent" || *c.Color != "#00AA88" {
+		t.Fatalf("c = %+v, err = %v", c, err)
+	}
+
+	if err := applyCategoryInput(categories, &c, categoryInput{Parent: strPtr("groceries")}); err == nil {
+		t.Error("subcategory accepted as a parent")
+	}
+	if err := applyCategoryInput(categories, &c, categoryInput{Parent: strPtr("nope")}); err == nil {
+		t.Error("unknown parent accepted")
+	}
+	if err := applyCategoryInput(categories, &c, categoryInput{Color: strPtr("blue")}); err == nil {
+		t.Error("invalid colour accepted")
+	}
+	if err := applyCategoryInput(categories, &c, categoryInput{Key: strPtr("has space")}); err == nil {
+		t.Error("invalid key accepted")
+	}
+	if err := applyCategoryInput(categories, &c, categoryInput{Parent: strPtr("utilities")}); err != nil || *c.Parent != "utilities" {
+		t.Errorf("parent = %v, err = %v", c.Parent, err)
+	}
+	if err := applyCategoryInput(categories, &c, categoryInput{Parent: strPtr("")}); err != nil || c.Parent != nil {
+		t.Errorf("clearing parent: %v, err = %v", c.Parent, err)
+	}
+}
+
+func TestMergedParents(t *testing.T) {
+	categories := category.NewSet(
+		category.Category{Key: "hobbies", Name: "Hobbies"},
+		category.Category{Key: "music", Name: "Music", Parent: strPtr("hobbies")},
+		category.Category{Key: "games", Name: "Games", Parent: strPtr("hobbies")},
+		category.Category{Key: "groceries", Name: "Groceries", Parent: strPtr("food")},
+	)
+	parent := func(moved map[string]*string, key string) string {
+		p, ok := moved[key]
+		if !ok {
+			return "unmoved"
+		}
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+
+	// Into a top-level category: subcategories follow
+	moved := mergedParents(categories, "hobbies", "shopping")
+	if len(moved) != 2 || parent(moved, "music") != "shopping" || parent(moved, "games") != "shopping" {
+		t.Errorf("into top level: %v", moved)
+	}
+
+	// Into a subcategory elsewhere: they stay one level down, under its parent
+	moved = mergedParents(categories, "hobbies", "groceries")
+	if parent(moved, "music") != "food" || parent(moved, "groceries") != "unmoved" {
+		t.Errorf("into subcategory: %v", moved)
+	}
+
+	// Into its own subcategory: that one becomes top level and keeps the rest
+	moved = mergedParents(categories, "hobbies", "music")
+	if len(moved) != 2 || parent(moved, "music") != "" || parent(moved, "games") != "music" {
+		t.Errorf("into own subcategory: %v", moved)
+	}
+}
+
+func testPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestRepointCategoryMovesEverythingFiled(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	userID := uuid.NewString()
+	if _, err := pool.Exec(ctx, `INSERT INTO users (id, phone) VALUES ($1, $2)`, userID, "+91"+userID[:10]); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, userID) })
+	now := time.Now().UTC()
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO transactions (id, user_id, amount_paisa, type, category, timestamp, source, created_at, updated_at)
+		VALUES ($1, $2, 100, 'debit', 'hobbies', $3, 'manual', $3, $3)
+	`, uuid.NewString(), userID, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO budgets (id, user_id, name, limit_paisa, period, category, created_at, updated_at)
+		VALUES ($1, $2, 'Fun', 500000, 'monthly', 'hobbies', $3, $3)
+	`, uuid.NewString(), userID, now); err != nil {
+		t.Fatal(err)
+	}
+	billID := uuid.NewString()
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO scheduled_bills (id, user_id, name, amount_paisa, category, frequency, next_due, created_at, updated_at)
+		VALUES ($1, $2, 'Guitar class', 150000, 'hobbies', 'monthly', $3, $4, $4)
+	`, billID, userID, now.AddDate(0, 0, 7), now); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	counts, err := repointCategory(ctx, tx, &analytics.Rollups{}, userID, "hobbies", "shopping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if counts != (repointCounts{Transactions: 1, Budgets: 1, Bills: 1}) {
+		t.Errorf("counts = %+v", counts)
+	}
+
+	var billCategory string
+	if err := pool.QueryRow(ctx, `SELECT category FROM scheduled_bills WHERE id = $1`, billID).Scan(&billCategory); err != nil {
+		t.Fatal(err)
+	}
+	if billCategory != "shopping" {
+		t.Errorf("bill category = %q, want shopping", billCategory)
+	}
+}
+
+func TestValidateIngestItemUsesUserCategories(t *testing.T) {
+	item := models.SyncIngestItem{
+		ID:          uuid.NewString(),
+		AmountPaisa: 12000,
+		Type:        "debit",
+		Category:    "rent",
+		Timestamp:   time.Now().UTC(),
+		Source:      "manual",
+		Tags:        []string{},
+	}
+	if err := validateIngestItem(item, category.NewSet()); err == nil {
+		t.Fatal("unknown category accepted")
+	}
+	custom := category.NewSet(category.Category{Key: "rent", Name: "Rent"})
+	if err := validateIngestItem(item, custom); err != nil {
+		t.Fatalf("custom category rejected: %v", err)
+	}
+}
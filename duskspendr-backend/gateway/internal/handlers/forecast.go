@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/forecast"
+)
+
+type ForecastHandler struct {
+	Pool *pgxpool.Pool
+	// Location defines "today" and month end
+	Location *time.Location
+}
+
+// Get projects daily balances for ?days= (30 to 90, default 30) per linked
+// account and in total. ?account_id= limits the accounts returned.
+func (h *ForecastHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	days := forecast.MinDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < forecast.MinDays || n > forecast.MaxDays {
+			writeError(w, http.StatusBadRequest, "days must be between 30 and 90")
+			return
+		}
+		days = n
+	}
+	loc := h.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	in, err := forecast.Load(r.Context(), h.Pool, userID.String(), loc, time.Now(), days)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	f := forecast.Project(in)
+	if id := r.URL.Query().Get("account_id"); id != "" {
+		accounts := []forecast.AccountForecast{}
+		for _, a := range f.Accounts {
+			if a.AccountID != nil && *a.AccountID == id {
+				accounts = append(accounts, a)
+			}
+		}
+		if len(accounts) == 0 {
+			writeError(w, http.StatusNotFound, "account not found")
+			return
+		}
+		f.Accounts = accounts
+	}
+	writeJSON(w, http.StatusOK, f)
+}
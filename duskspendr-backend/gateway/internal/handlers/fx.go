@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/fx"
+)
+
+type FXHandler struct {
+	Pool       *pgxpool.Pool
+	AdminToken string
+	MaxRateAge time.Duration
+}
+
+// Rate returns the conversion used for ?from=&to=&date= (date defaults to
+// today, YYYY-MM-DD).
+func (h *FXHandler) Rate(w http.ResponseWriter, r *http.Request) {
+	from := fx.NormalizeCode(r.URL.Query().Get("from"))
+	to := fx.NormalizeCode(r.URL.Query().Get("to"))
+	if !fx.ValidCode(from) || !fx.ValidCode(to) {
+		writeError(w, http.StatusBadRequest, "from and to must be currency codes")
+		return
+	}
+	date := time.Now().UTC()
+	if v := r.URL.Query().Get("date"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid date")
+			return
+		}
+		date = d
+	}
+
+	conv, err := fx.LookupRate(r.Context(), h.Pool, from, to, date, rateAge(h.MaxRateAge))
+	if errors.Is(err, fx.ErrNoRate) {
+		writeError(w, http.StatusNotFound, "no fx rate")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, conv)
+}
+
+// UploadRates loads a reference-rate CSV sent as the request body. The ECB
+// layout (Date plus one column per currency) needs ?base=EUR; the long
+// layout (date,base,quote,rate) carries its own base. Requires X-Admin-Token.
+func (h *FXHandler) UploadRates(w http.ResponseWriter, r *http.Request) {
+	if h.AdminToken == "" {
+		writeError(w, http.StatusServiceUnavailable, "admin api disabled")
+		return
+	}
+	token := r.Header.Get("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = "upload"
+	}
+	body := http.MaxBytesReader(w, r.Body, 32<<20)
+	quotes, err := fx.ParseRatesCSV(body, r.URL.Query().Get("base"), source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Drain so a truncated upload is reported instead of half-loaded
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
+	n, err := fx.SaveQuotes(r.Context(), h.Pool, quotes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"loaded": n})
+}
+
+// GetCurrency returns the user's base currency
+func (h *FXHandler) GetCurrency(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	base, err := loadBaseCurrency(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"base_currency": base})
+}
+
+// PutCurrency changes the user's base currency. Stored amounts are in the
+// old base, so this is only allowed before any transaction exists.
+func (h *FXHandler) PutCurrency(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	var input struct {
+		BaseCurrency string `json:"base_currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	base := fx.NormalizeCode(input.BaseCurrency)
+	if !fx.ValidCode(base) {
+		writeError(w, http.StatusBadRequest, "invalid base_currency")
+		return
+	}
+
+	cmd, err := h.Pool.Exec(r.Context(), `
+		UPDATE users SET base_currency = $2
+		 WHERE id = $1
+		   AND (base_currency = $2 OR NOT EXISTS (SELECT 1 FROM transactions WHERE user_id = $1))
+	`, userID, base)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusConflict, "base currency cannot change once transactions exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"base_currency": base})
+}
+
+type baseCurrencyQuerier interface {
+	fx.Querier
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func loadBaseCurrency(ctx context.Context, q baseCurrencyQuerier, userID string) (string, error) {
+	var base string
+	err := q.QueryRow(ctx, `SELECT base_currency FROM users WHERE id = $1`, userID).Scan(&base)
+	return base, err
+}
+
+// currencyFields points at the amount fields shared by the transaction
+// write paths.
+type currencyFields struct {
+	AmountPaisa         *int64
+	OriginalCurrency    **string
+	OriginalAmountMinor **int64
+	Timestamp           time.Time
+}
+
+// rateCache memoises rate lookups for the duration of one request, so a
+// statement full of same-day card spends costs one query per day.
+type rateCache struct {
+	q      fx.Querier
+	maxAge time.Duration
+	seen   map[string]fx.Conversion
+}
+
+func newRateCache(q fx.Querier, maxAge time.Duration) *rateCache {
+	return &rateCache{q: q, maxAge: rateAge(maxAge), seen: map[string]fx.Conversion{}}
+}
+
+func (c *rateCache) lookup(ctx context.Context, from, to string, date time.Time) (fx.Conversion, error) {
+	key := from + to + date.UTC().Format("2006-01-02")
+	if conv, ok := c.seen[key]; ok {
+		if conv.Rate == 0 {
+			return conv, fx.ErrNoRate
+		}
+		return conv, nil
+	}
+	conv, err := fx.LookupRate(ctx, c.q, from, to, date, c.maxAge)
+	if err != nil && !errors.Is(err, fx.ErrNoRate) {
+		return conv, err
+	}
+	c.seen[key] = conv
+	return conv, err
+}
+
+// convertCurrency fills AmountPaisa from the original amount at the rate for
+// the transaction date. Amounts already in the base currency clear the
+// original fields. When no rate is stored, an amount_paisa sent by the
+// client is kept as is and the returned conversion is nil.
+func convertCurrency(ctx context.Context, rates *rateCache, base string, f currencyFields) (*fx.Conversion, error) {
+	if *f.OriginalCurrency == nil {
+		if *f.OriginalAmountMinor != nil {
+			return nil, errInvalid("original_currency is required with original_amount_minor")
+		}
+		return nil, nil
+	}
+	code := fx.NormalizeCode(**f.OriginalCurrency)
+	if !fx.ValidCode(code) {
+		return nil, errInvalid("invalid original_currency")
+	}
+	if *f.OriginalAmountMinor == nil || **f.OriginalAmountMinor <= 0 {
+		return nil, errInvalid("original_amount_minor is required with original_currency")
+	}
+	amount := **f.OriginalAmountMinor
+	if code == base {
+		*f.AmountPaisa = amount
+		*f.OriginalCurrency = nil
+		*f.OriginalAmountMinor = nil
+		return nil, nil
+	}
+	*f.OriginalCurrency = &code
+
+	conv, err := rates.lookup(ctx, code, base, f.Timestamp)
+	if errors.Is(err, fx.ErrNoRate) {
+		if *f.AmountPaisa > 0 {
+			return nil, nil
+		}
+		return nil, errInvalid("no fx rate for " + code + " to " + base + " on " + f.Timestamp.UTC().Format("2006-01-02"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	*f.AmountPaisa = fx.Convert(amount, code, base, conv.Rate)
+	return &conv, nil
+}
+
+func rateAge(d time.Duration) time.Duration {
+	if d <= 0 {
+		return fx.DefaultMaxRateAge
+	}
+	return d
+}
+
+// fxColumns returns the fx_rate and fx_rate_date values to store
+func fxColumns(conv *fx.Conversion) (*float64, *time.Time) {
+	if conv == nil {
+		return nil, nil
+	}
+	rate, date := conv.Rate, conv.Date
+	return &rate, &date
+}
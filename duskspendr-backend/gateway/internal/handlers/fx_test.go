@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConvertCurrencyValidation(t *testing.T) {
+	ctx := context.Background()
+	rates := newRateCache(nil, 0)
+	amount := int64(0)
+	var code *string
+	minor := int64Ptr(1549)
+
+	_, err := convertCurrency(ctx, rates, "INR", currencyFields{&amount, &code, &minor, time.Now()})
+	if _, ok := err.(invalidError); !ok {
+		t.Fatalf("amount without currency: err = %v", err)
+	}
+
+	code, minor = strPtr("usdx"), int64Ptr(1549)
+	if _, err := convertCurrency(ctx, rates, "INR", currencyFields{&amount, &code, &minor, time.Now()}); err == nil {
+		t.Fatal("invalid currency code accepted")
+	}
+
+	code, minor = strPtr("usd"), nil
+	if _, err := convertCurrency(ctx, rates, "INR", currencyFields{&amount, &code, &minor, time.Now()}); err == nil {
+		t.Fatal("currency without amount accepted")
+	}
+}
+
+func TestConvertCurrencySameAsBase(t *testing.T) {
+	amount := int64(0)
+	code, minor := strPtr(" inr"), int64Ptr(25000)
+	conv, err := convertCurrency(context.Background(), newRateCache(nil, 0), "INR", currencyFields{&amount, &code, &minor, time.Now()})
+	if err != nil || conv != nil {
+		t.Fatalf("conv = %v, err = %v", conv, err)
+	}
+	if amount != 25000 || code != nil || minor != nil {
+		t.Fatalf("amount = %d, code = %v, minor = %v", amount, code, minor)
+	}
+}
+
+func int64Ptr(v int64) *int64 { return &v }
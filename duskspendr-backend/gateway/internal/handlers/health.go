@@ -3,20 +3,26 @@ package handlers
 
 import (
 	"context"
+	"net/http"
 	"sync"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	amqp "github.com/rabbitmq/amqp091-go"
 
 	"duskspendr-gateway/internal/db"
-	"duskspendr-gateway/internal/queue"
+	"duskspendr-gateway/internal/fx"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
+	pool      *pgxpool.Pool
 	redis     *db.RedisClient
-	rabbitmq  *queue.Connection
+	rabbitmq  *amqp.Connection
 	startTime time.Time
+	// FXMaxRateAge, when set, adds a check that stored reference rates
+	// still cover today's spends
+	FXMaxRateAge time.Duration
 }
 
 // HealthResponse represents the health check response
@@ -34,9 +40,11 @@ type HealthCheck struct {
 	Message string `json:"message,omitempty"`
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(redis *db.RedisClient, rabbitmq *queue.Connection) *HealthHandler {
+// NewHealthHandler creates a new health handler; redis and rabbitmq may be
+// nil when the server runs without them
+func NewHealthHandler(pool *pgxpool.Pool, redis *db.RedisClient, rabbitmq *amqp.Connection) *HealthHandler {
 	return &HealthHandler{
+		pool:      pool,
 		redis:     redis,
 		rabbitmq:  rabbitmq,
 		startTime: time.Now(),
@@ -44,77 +52,103 @@ func NewHealthHandler(redis *db.RedisClient, rabbitmq *queue.Connection) *Health
 }
 
 // Health performs a basic health check
-func (h *HealthHandler) Health(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
 		"status":    "ok",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
-// Ready performs readiness check including dependencies
-func (h *HealthHandler) Ready(c *fiber.Ctx) error {
-	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+// Ready performs readiness check including dependencies. Only the database
+// is required; Redis and RabbitMQ have in-process fallbacks, so losing them
+// reports degraded without taking the instance out of rotation. Stale FX
+// rates report degraded too, since rates are uploaded through this API.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	checks := map[string]func(context.Context) HealthCheck{
+		"database": h.checkDatabase,
+		"redis":    h.checkRedis,
+		"rabbitmq": h.checkRabbitMQ,
+	}
+	if h.FXMaxRateAge > 0 {
+		checks["fx_rates"] = h.checkFXRates
+	}
+
 	services := make(map[string]HealthCheck)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	allHealthy := true
-
-	// Check Redis
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		check := h.checkRedis(ctx)
-		mu.Lock()
-		services["redis"] = check
-		if check.Status != "healthy" {
-			allHealthy = false
-		}
-		mu.Unlock()
-	}()
-
-	// Check RabbitMQ
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		check := h.checkRabbitMQ()
-		mu.Lock()
-		services["rabbitmq"] = check
-		if check.Status != "healthy" {
-			allHealthy = false
-		}
-		mu.Unlock()
-	}()
-
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) HealthCheck) {
+			defer wg.Done()
+			result := check(ctx)
+			mu.Lock()
+			services[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
 	wg.Wait()
 
 	status := "ok"
-	statusCode := fiber.StatusOK
-	if !allHealthy {
+	statusCode := http.StatusOK
+	for name, check := range services {
+		if check.Status == "healthy" {
+			continue
+		}
+		if name == "database" {
+			status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+			break
+		}
 		status = "degraded"
-		statusCode = fiber.StatusServiceUnavailable
 	}
 
-	response := HealthResponse{
+	writeJSON(w, statusCode, HealthResponse{
 		Status:    status,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Uptime:    time.Since(h.startTime).String(),
 		Services:  services,
-	}
-
-	return c.Status(statusCode).JSON(response)
+	})
 }
 
 // Live performs liveness check (is the service running)
-func (h *HealthHandler) Live(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
 		"status":    "alive",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 		"uptime":    time.Since(h.startTime).String(),
 	})
 }
 
+// checkDatabase checks Postgres connectivity
+func (h *HealthHandler) checkDatabase(ctx context.Context) HealthCheck {
+	if h.pool == nil {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Message: "database pool not configured",
+		}
+	}
+
+	start := time.Now()
+	err := h.pool.Ping(ctx)
+	latency := time.Since(start)
+
+	if err != nil {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Latency: latency.String(),
+			Message: err.Error(),
+		}
+	}
+
+	return HealthCheck{
+		Status:  "healthy",
+		Latency: latency.String(),
+	}
+}
+
 // checkRedis checks Redis connectivity
 func (h *HealthHandler) checkRedis(ctx context.Context) HealthCheck {
 	if h.redis == nil {
@@ -143,7 +177,7 @@ func (h *HealthHandler) checkRedis(ctx context.Context) HealthCheck {
 }
 
 // checkRabbitMQ checks RabbitMQ connectivity
-func (h *HealthHandler) checkRabbitMQ() HealthCheck {
+func (h *HealthHandler) checkRabbitMQ(ctx context.Context) HealthCheck {
 	if h.rabbitmq == nil {
 		return HealthCheck{
 			Status:  "unhealthy",
@@ -151,12 +185,10 @@ func (h *HealthHandler) checkRabbitMQ() HealthCheck {
 		}
 	}
 
-	// Check if channel is open
-	ch := h.rabbitmq.Channel()
-	if ch == nil || ch.IsClosed() {
+	if h.rabbitmq.IsClosed() {
 		return HealthCheck{
 			Status:  "unhealthy",
-			Message: "rabbitmq channel is closed",
+			Message: "rabbitmq connection is closed",
 		}
 	}
 
@@ -165,9 +197,46 @@ func (h *HealthHandler) checkRabbitMQ() HealthCheck {
 	}
 }
 
+// checkFXRates checks that the newest stored rates can convert a spend
+// made today
+func (h *HealthHandler) checkFXRates(ctx context.Context) HealthCheck {
+	if h.pool == nil {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Message: "database pool not configured",
+		}
+	}
+	latest, ok, err := fx.LatestRateDate(ctx, h.pool)
+	if err != nil {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Message: err.Error(),
+		}
+	}
+	return fxRatesCheck(latest, ok, time.Now(), h.FXMaxRateAge)
+}
+
+func fxRatesCheck(latest time.Time, ok bool, now time.Time, maxAge time.Duration) HealthCheck {
+	if !ok {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Message: "no fx rates loaded; upload them with POST /v1/admin/fx/rates",
+		}
+	}
+	if !fx.Current(latest, now, maxAge) {
+		return HealthCheck{
+			Status:  "unhealthy",
+			Message: "newest fx rates are from " + latest.Format("2006-01-02") + "; upload current rates with POST /v1/admin/fx/rates",
+		}
+	}
+	return HealthCheck{
+		Status: "healthy",
+	}
+}
+
 // Metrics returns basic metrics
-func (h *HealthHandler) Metrics(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
+func (h *HealthHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
 		"uptime_seconds": time.Since(h.startTime).Seconds(),
 		"timestamp":      time.Now().UTC().Format(time.RFC3339),
 	})
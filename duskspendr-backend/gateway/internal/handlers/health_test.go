@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"duskspendr-gateway/internal/fx"
+)
+
+func TestFXRatesCheck(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	if c := fxRatesCheck(time.Time{}, false, now, fx.DefaultMaxRateAge); c.Status != "unhealthy" {
+		t.Errorf("no rates: %+v", c)
+	}
+	// Only the bundled snapshot loaded
+	if c := fxRatesCheck(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), true, now, fx.DefaultMaxRateAge); c.Status != "unhealthy" {
+		t.Errorf("stale rates: %+v", c)
+	}
+	if c := fxRatesCheck(now.AddDate(0, 0, -3), true, now, fx.DefaultMaxRateAge); c.Status != "healthy" {
+		t.Errorf("current rates: %+v", c)
+	}
+}
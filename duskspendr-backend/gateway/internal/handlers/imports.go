@@ -0,0 +1,712 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/fx"
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/statement"
+)
+
+const maxImportBytes = 10 << 20
+
+// ImportHandler turns uploaded bank statements into transactions in two
+// steps: POST /imports parses the file and stores a preview, and
+// POST /imports/{id}/commit writes the reviewed rows through the ingest path.
+type ImportHandler struct {
+	Pool *pgxpool.Pool
+	Sync *SyncHandler
+}
+
+// Duplicate markers on preview rows
+const (
+	duplicateExact    = "exact"
+	duplicatePossible = "possible"
+)
+
+type importRow struct {
+	Line        int                   `json:"line"`
+	Item        models.SyncIngestItem `json:"item"`
+	Duplicate   string                `json:"duplicate,omitempty"`
+	DuplicateOf *string               `json:"duplicate_of,omitempty"`
+}
+
+type importResponse struct {
+	ID              string             `json:"id"`
+	FileName        string             `json:"file_name"`
+	Format          string             `json:"format"`
+	Status          string             `json:"status"`
+	LinkedAccountID *string            `json:"linked_account_id,omitempty"`
+	Mapping         *statement.Mapping `json:"mapping,omitempty"`
+	Report          importReport       `json:"report"`
+	Rows            []importRow        `json:"rows"`
+	CreatedAt       time.Time          `json:"created_at"`
+	CommittedAt     *time.Time         `json:"committed_at,omitempty"`
+}
+
+type importReport struct {
+	statement.Report
+	Inserted int `json:"inserted,omitempty"`
+}
+
+// Create parses an uploaded statement (multipart field "file") and returns a
+// preview. Optional fields: linked_account_id, profile_id, mapping (JSON)
+// and save_profile (a name under which to save the mapping used).
+func (h *ImportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid upload")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportBytes+1))
+	if err != nil || len(data) > maxImportBytes {
+		writeError(w, http.StatusBadRequest, "file too large")
+		return
+	}
+
+	var linkedAccountID *string
+	if v := strings.TrimSpace(r.FormValue("linked_account_id")); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid linked_account_id")
+			return
+		}
+		linkedAccountID = &v
+	}
+
+	mapping, err := h.requestMapping(r, userID)
+	if err != nil {
+		if _, ok := err.(invalidError); ok {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	parsed, err := parseStatement(header.Filename, data, mapping)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	scope := ""
+	if linkedAccountID != nil {
+		scope = *linkedAccountID
+	}
+	rows, report := buildImportRows(userID.String(), scope, linkedAccountID, parsed)
+	if err := h.markDuplicates(r.Context(), userID, rows, &report); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	if name := strings.TrimSpace(r.FormValue("save_profile")); name != "" && parsed.Mapping != nil {
+		if err := h.saveProfile(r.Context(), userID, name, *parsed.Mapping); err != nil {
+			writeError(w, http.StatusInternalServerError, "save failed")
+			return
+		}
+	}
+
+	resp := importResponse{
+		ID:              uuid.New().String(),
+		FileName:        filepath.Base(header.Filename),
+		Format:          parsed.Report.Format,
+		Status:          "preview",
+		LinkedAccountID: linkedAccountID,
+		Mapping:         parsed.Mapping,
+		Report:          importReport{Report: report},
+		Rows:            rows,
+		CreatedAt:       time.Now().UTC(),
+	}
+	rowsJSON, _ := json.Marshal(resp.Rows)
+	reportJSON, _ := json.Marshal(resp.Report)
+	mappingJSON, _ := json.Marshal(resp.Mapping)
+	_, err = h.Pool.Exec(r.Context(), `
+		INSERT INTO imports (id, user_id, file_name, format, status, linked_account_id, mapping, rows, report, created_at)
+		VALUES ($1, $2, $3, $4, 'preview', $5, $6, $7, $8, $9)
+	`, resp.ID, userID, resp.FileName, resp.Format, linkedAccountID, mappingJSON, rowsJSON, reportJSON, resp.CreatedAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+type importSummary struct {
+	ID          string       `json:"id"`
+	FileName    string       `json:"file_name"`
+	Format      string       `json:"format"`
+	Status      string       `json:"status"`
+	Report      importReport `json:"report"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CommittedAt *time.Time   `json:"committed_at,omitempty"`
+}
+
+// List returns the user's recent imports with their per-file reports
+func (h *ImportHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT id, file_name, format, status, report, created_at, committed_at
+		  FROM imports
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC
+		 LIMIT 100
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []importSummary{}
+	for rows.Next() {
+		var s importSummary
+		var reportRaw []byte
+		if err := rows.Scan(&s.ID, &s.FileName, &s.Format, &s.Status, &reportRaw, &s.CreatedAt, &s.CommittedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		_ = json.Unmarshal(reportRaw, &s.Report)
+		items = append(items, s)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// Get returns an import with its rows and report
+func (h *ImportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	imp, err := h.load(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, imp)
+}
+
+type importCommitInput struct {
+	IncludeDuplicates bool  `json:"include_duplicates"`
+	ExcludeLines      []int `json:"exclude_lines"`
+}
+
+// Commit writes the previewed rows. Exact duplicates (rows already
+// imported) are never rewritten; possible duplicates are skipped unless
+// include_duplicates is set.
+func (h *ImportHandler) Commit(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	// The options are optional: an empty body, chunked or not, commits with
+	// the defaults
+	var input importCommitInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	imp, err := h.load(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if imp.Status != "preview" {
+		writeError(w, http.StatusConflict, "import already committed")
+		return
+	}
+
+	excluded := map[int]bool{}
+	for _, line := range input.ExcludeLines {
+		excluded[line] = true
+	}
+	items := []models.SyncIngestItem{}
+	for _, row := range imp.Rows {
+		if excluded[row.Line] || row.Duplicate == duplicateExact {
+			continue
+		}
+		if row.Duplicate == duplicatePossible && !input.IncludeDuplicates {
+			continue
+		}
+		items = append(items, row.Item)
+	}
+
+	// Claim the preview before writing so concurrent commits of one import
+	// cannot both write it; a failed write hands it back for a retry.
+	now := time.Now().UTC()
+	claim, err := h.Pool.Exec(r.Context(), `
+		UPDATE imports SET status = 'committed', committed_at = $1
+		 WHERE id = $2 AND user_id = $3 AND status = 'preview'
+	`, now, imp.ID, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if claim.RowsAffected() == 0 {
+		writeError(w, http.StatusConflict, "import already committed")
+		return
+	}
+	release := func() {
+		if _, err := h.Pool.Exec(context.WithoutCancel(r.Context()), `
+			UPDATE imports SET status = 'preview', committed_at = NULL
+			 WHERE id = $1 AND user_id = $2
+		`, imp.ID, userID); err != nil {
+			log.Printf("imports: releasing %s after a failed commit: %v", imp.ID, err)
+		}
+	}
+
+	// upsertIngestItems caps a batch at 500; ids are stable, so a commit that
+	// fails part way can simply be retried.
+	inserted := 0
+	for start := 0; start < len(items); start += 500 {
+		end := start + 500
+		if end > len(items) {
+			end = len(items)
+		}
+		n, err := h.Sync.upsertIngestItems(r.Context(), userID, items[start:end], true)
+		if err != nil {
+			release()
+			if _, ok := err.(invalidError); ok {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "insert failed")
+			return
+		}
+		inserted += n
+	}
+
+	imp.Report.Inserted = inserted
+	imp.Status = "committed"
+	imp.CommittedAt = &now
+	reportJSON, _ := json.Marshal(imp.Report)
+	if _, err := h.Pool.Exec(r.Context(), `
+		UPDATE imports SET report = $1
+		 WHERE id = $2 AND user_id = $3
+	`, reportJSON, imp.ID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"id": imp.ID, "inserted": inserted, "report": imp.Report})
+}
+
+func (h *ImportHandler) load(ctx context.Context, userID uuid.UUID, id string) (*importResponse, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, err
+	}
+	var imp importResponse
+	var mappingRaw, rowsRaw, reportRaw []byte
+	err := h.Pool.QueryRow(ctx, `
+		SELECT id, file_name, format, status, linked_account_id, mapping, rows, report, created_at, committed_at
+		  FROM imports
+		 WHERE id = $1 AND user_id = $2
+	`, id, userID).Scan(
+		&imp.ID, &imp.FileName, &imp.Format, &imp.Status, &imp.LinkedAccountID,
+		&mappingRaw, &rowsRaw, &reportRaw, &imp.CreatedAt, &imp.CommittedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(mappingRaw) > 0 && string(mappingRaw) != "null" {
+		imp.Mapping = &statement.Mapping{}
+		if err := json.Unmarshal(mappingRaw, imp.Mapping); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(rowsRaw, &imp.Rows); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(reportRaw, &imp.Report); err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// requestMapping returns the mapping the caller asked for: an inline
+// "mapping" field wins over a saved "profile_id". nil means auto-detect.
+func (h *ImportHandler) requestMapping(r *http.Request, userID uuid.UUID) (*statement.Mapping, error) {
+	if raw := strings.TrimSpace(r.FormValue("mapping")); raw != "" {
+		var m statement.Mapping
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			return nil, errInvalid("invalid mapping")
+		}
+		return &m, nil
+	}
+	profileID := strings.TrimSpace(r.FormValue("profile_id"))
+	if profileID == "" {
+		return nil, nil
+	}
+	if _, err := uuid.Parse(profileID); err != nil {
+		return nil, errInvalid("invalid profile_id")
+	}
+	var raw []byte
+	err := h.Pool.QueryRow(r.Context(), `
+		SELECT mapping FROM import_profiles WHERE id = $1 AND user_id = $2
+	`, profileID, userID).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errInvalid("profile not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m statement.Mapping
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// parsedStatement is the format-independent result of parsing an upload
+type parsedStatement struct {
+	Records []statement.Record
+	Report  statement.Report
+	// Mapping is set for tabular formats
+	Mapping *statement.Mapping
+}
+
+// parseStatement parses an uploaded statement. OFX/QFX, QIF and CAMT.053
+// files describe themselves; CSV, TSV and XLSX go through a column mapping,
+// detected when mapping is nil.
+func parseStatement(fileName string, data []byte, mapping *statement.Mapping) (*parsedStatement, error) {
+	format := statement.DetectFormat(fileName, data)
+	if format != statement.FormatCSV && format != statement.FormatXLSX {
+		records, report, err := statement.Parse(format, data)
+		if err != nil {
+			return nil, errInvalid("could not read " + format + " file")
+		}
+		return &parsedStatement{Records: records, Report: report}, nil
+	}
+
+	var delimiter rune
+	if mapping != nil && mapping.Delimiter != "" {
+		delimiter = []rune(mapping.Delimiter)[0]
+	}
+	table, err := statement.ReadTable(data, delimiter)
+	if err != nil {
+		return nil, errInvalid("could not read file")
+	}
+
+	m := mapping
+	if m == nil {
+		detected, err := statement.DetectMapping(table)
+		if err != nil {
+			return nil, errInvalid("could not detect columns; send a mapping")
+		}
+		m = &detected
+	}
+	records, report, err := statement.ParseTable(table, *m)
+	if err != nil {
+		return nil, errInvalid(err.Error())
+	}
+	report.Format = format
+	return &parsedStatement{Records: records, Report: report, Mapping: m}, nil
+}
+
+// buildImportRows converts records into ingest items and drops rows that
+// would fail ingest validation, recording them in the report.
+func buildImportRows(userID, scope string, linkedAccountID *string, parsed *parsedStatement) ([]importRow, statement.Report) {
+	report := parsed.Report
+	ids := statement.AssignIDs(userID, scope, parsed.Records)
+	rows := make([]importRow, 0, len(parsed.Records))
+	for i, rec := range parsed.Records {
+		item := models.SyncIngestItem{
+			ID:              ids[i],
+			AmountPaisa:     rec.AmountPaisa,
+			Type:            rec.Type,
+			Category:        "other",
+			Timestamp:       rec.Date,
+			Source:          "imported",
+			LinkedAccountID: linkedAccountID,
+			Tags:            []string{},
+		}
+		if rec.Description != "" {
+			// Cut to the 500 bytes ingest accepts, on a rune boundary
+			desc := rec.Description
+			if len(desc) > 500 {
+				n := 500
+				for n > 0 && !utf8.RuneStart(desc[n]) {
+					n--
+				}
+				desc = desc[:n]
+			}
+			item.Description = &desc
+			if strings.Contains(strings.ToUpper(desc), "UPI") {
+				method := "upi"
+				item.PaymentMethod = &method
+			}
+		}
+		if rec.Reference != "" && len(rec.Reference) <= 100 {
+			ref := rec.Reference
+			item.ReferenceID = &ref
+		}
+		// Foreign-currency rows are converted into the base currency at commit
+		check := item
+		if rec.Currency != "" && rec.Currency != "INR" {
+			code, amount := rec.Currency, fx.FromCents(rec.AmountPaisa, rec.Currency)
+			item.OriginalCurrency, item.OriginalAmountMinor = &code, &amount
+			item.AmountPaisa = 0
+		}
+		if err := validateIngestItem(check, category.NewSet()); err != nil {
+			report.RowsParsed--
+			report.RowsSkipped++
+			report.Skipped = append(report.Skipped, statement.Skip{Line: rec.Line, Reason: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{Line: rec.Line, Item: item})
+	}
+	return rows, report
+}
+
+// markDuplicates flags rows whose id already exists (a re-import) as exact
+// duplicates, and rows matching an existing transaction's amount and type
+// within a day as possible duplicates.
+func (h *ImportHandler) markDuplicates(ctx context.Context, userID uuid.UUID, rows []importRow, report *statement.Report) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	from, to := rows[0].Item.Timestamp, rows[0].Item.Timestamp
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Item.Timestamp.Before(from) {
+			from = row.Item.Timestamp
+		}
+		if row.Item.Timestamp.After(to) {
+			to = row.Item.Timestamp
+		}
+		ids = append(ids, row.Item.ID)
+	}
+
+	dbRows, err := h.Pool.Query(ctx, `
+		SELECT id, amount_paisa, type, timestamp, original_currency, original_amount_minor
+		  FROM transactions
+		 WHERE user_id = $1
+		   AND (id = ANY($2::uuid[]) OR timestamp BETWEEN $3 AND $4)
+	`, userID, ids, from.Add(-24*time.Hour), to.Add(48*time.Hour))
+	if err != nil {
+		return err
+	}
+	defer dbRows.Close()
+
+	candidates := []existingTxn{}
+	for dbRows.Next() {
+		var e existingTxn
+		if err := dbRows.Scan(&e.id, &e.amount, &e.txType, &e.timestamp, &e.currency, &e.originalMinor); err != nil {
+			return err
+		}
+		candidates = append(candidates, e)
+	}
+	if err := dbRows.Err(); err != nil {
+		return err
+	}
+	flagDuplicates(rows, candidates, report)
+	return nil
+}
+
+// existingTxn is a stored transaction a preview row may duplicate
+type existingTxn struct {
+	id            string
+	amount        int64
+	txType        string
+	timestamp     time.Time
+	currency      *string
+	originalMinor *int64
+}
+
+// flagDuplicates marks rows against candidates. Foreign-currency rows carry
+// no base amount until commit, so they match on the original currency and
+// amount instead.
+func flagDuplicates(rows []importRow, candidates []existingTxn, report *statement.Report) {
+	byID := map[string]bool{}
+	for _, c := range candidates {
+		byID[c.id] = true
+	}
+	claimed := map[string]bool{}
+	for i := range rows {
+		item := rows[i].Item
+		if byID[item.ID] {
+			rows[i].Duplicate = duplicateExact
+			report.Duplicates++
+			continue
+		}
+		for _, c := range candidates {
+			if claimed[c.id] || c.txType != item.Type || !sameAmount(item, c) {
+				continue
+			}
+			diff := c.timestamp.Sub(item.Timestamp)
+			if diff < -24*time.Hour || diff > 48*time.Hour {
+				continue
+			}
+			claimed[c.id] = true
+			id := c.id
+			rows[i].Duplicate = duplicatePossible
+			rows[i].DuplicateOf = &id
+			report.Duplicates++
+			break
+		}
+	}
+}
+
+func sameAmount(item models.SyncIngestItem, c existingTxn) bool {
+	if item.OriginalCurrency == nil {
+		return c.amount == item.AmountPaisa
+	}
+	return c.currency != nil && c.originalMinor != nil && item.OriginalAmountMinor != nil &&
+		*c.currency == *item.OriginalCurrency && *c.originalMinor == *item.OriginalAmountMinor
+}
+
+type importProfile struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Mapping   statement.Mapping `json:"mapping"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ListProfiles returns the user's saved mapping profiles
+func (h *ImportHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT id, name, mapping, created_at, updated_at
+		  FROM import_profiles
+		 WHERE user_id = $1
+		 ORDER BY name
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []importProfile{}
+	for rows.Next() {
+		var p importProfile
+		var raw []byte
+		if err := rows.Scan(&p.ID, &p.Name, &raw, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		_ = json.Unmarshal(raw, &p.Mapping)
+		items = append(items, p)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+type importProfileInput struct {
+	Name    string            `json:"name"`
+	Mapping statement.Mapping `json:"mapping"`
+}
+
+// SaveProfile creates or replaces a named mapping profile
+func (h *ImportHandler) SaveProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	var input importProfileInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 80 {
+		writeError(w, http.StatusBadRequest, "invalid name")
+		return
+	}
+	if input.Mapping.Date == "" || input.Mapping.DateFormat == "" {
+		writeError(w, http.StatusBadRequest, "mapping needs date and date_format")
+		return
+	}
+	if input.Mapping.Amount == "" && (input.Mapping.Debit == "" || input.Mapping.Credit == "") {
+		writeError(w, http.StatusBadRequest, "mapping needs amount or debit and credit")
+		return
+	}
+
+	if err := h.saveProfile(r.Context(), userID, input.Name, input.Mapping); err != nil {
+		writeError(w, http.StatusInternalServerError, "save failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "saved"})
+}
+
+// DeleteProfile removes a mapping profile
+func (h *ImportHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	cmd, err := h.Pool.Exec(r.Context(), `
+		DELETE FROM import_profiles WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (h *ImportHandler) saveProfile(ctx context.Context, userID uuid.UUID, name string, m statement.Mapping) error {
+	raw, _ := json.Marshal(m)
+	_, err := h.Pool.Exec(ctx, `
+		INSERT INTO import_profiles (user_id, name, mapping)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET mapping = EXCLUDED.mapping, updated_at = now()
+	`, userID, name, raw)
+	return err
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/statement"
+)
+
+func TestBuildImportRows(t *testing.T) {
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	parsed := &parsedStatement{
+		Records: []statement.Record{
+			{Line: 2, Date: now, Description: "UPI-ZOMATO-zomato@hdfcbank-412345678901", AmountPaisa: 45000, Type: "debit", Reference: "412345678901"},
+			{Line: 3, Date: now.AddDate(-10, 0, 0), Description: "Ancient row", AmountPaisa: 100, Type: "credit"},
+			{Line: 4, Date: now, Description: "NEFT salary", AmountPaisa: 8500000, Type: "credit"},
+		},
+		Report: statement.Report{Format: "csv", RowsParsed: 3},
+	}
+
+	rows, report := buildImportRows("user-1", "acct-1", nil, parsed)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if report.RowsParsed != 2 || report.RowsSkipped != 1 || report.Skipped[0].Line != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	first := rows[0].Item
+	if first.Source != "imported" || first.Category != "other" {
+		t.Fatalf("item = %+v", first)
+	}
+	if first.PaymentMethod == nil || *first.PaymentMethod != "upi" {
+		t.Fatalf("payment method = %v, want upi", first.PaymentMethod)
+	}
+	if rows[1].Item.PaymentMethod != nil {
+		t.Fatalf("non-UPI row got payment method %v", *rows[1].Item.PaymentMethod)
+	}
+
+	again, _ := buildImportRows("user-1", "acct-1", nil, parsed)
+	if again[0].Item.ID != first.ID {
+		t.Fatal("re-import must produce the same ids")
+	}
+}
+
+func TestBuildImportRowsKeepsForeignCurrency(t *testing.T) {
+	parsed := &parsedStatement{
+		Records: []statement.Record{
+			{Line: 1, Date: time.Now().UTC(), AmountPaisa: 1549, Type: "debit", ExternalID: "CC-1", Currency: "USD"},
+			{Line: 2, Date: time.Now().UTC(), AmountPaisa: 150000, Type: "debit", ExternalID: "CC-2", Currency: "JPY"},
+		},
+		Report: statement.Report{Format: "ofx", RowsParsed: 2},
+	}
+	rows, report := buildImportRows("user-1", "", nil, parsed)
+	if len(rows) != 2 || report.RowsSkipped != 0 {
+		t.Fatalf("rows = %d, report = %+v", len(rows), report)
+	}
+	usd := rows[0].Item
+	if usd.AmountPaisa != 0 || *usd.OriginalCurrency != "USD" || *usd.OriginalAmountMinor != 1549 {
+		t.Errorf("usd item = %+v", usd)
+	}
+	if jpy := rows[1].Item; *jpy.OriginalAmountMinor != 1500 {
+		t.Errorf("jpy minor units = %d", *jpy.OriginalAmountMinor)
+	}
+}
+
+func TestBuildImportRowsCutsLongDescriptionOnRune(t *testing.T) {
+	parsed := &parsedStatement{
+		Records: []statement.Record{
+			{Line: 1, Date: time.Now().UTC(), Description: "a" + strings.Repeat("किराया", 100), AmountPaisa: 100, Type: "debit"},
+		},
+		Report: statement.Report{Format: "csv", RowsParsed: 1},
+	}
+	rows, _ := buildImportRows("user-1", "", nil, parsed)
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	desc := *rows[0].Item.Description
+	if len(desc) > 500 || !utf8.ValidString(desc) {
+		t.Errorf("description cut to %d bytes, valid UTF-8 %v", len(desc), utf8.ValidString(desc))
+	}
+}
+
+func TestFlagDuplicatesMatchesForeignCurrency(t *testing.T) {
+	now := time.Now().UTC()
+	usd, cents := "USD", int64(1549)
+	stored, storedCents := "USD", int64(1549)
+	rows := []importRow{
+		{Line: 1, Item: models.SyncIngestItem{ID: "new-1", Type: "debit", Timestamp: now, OriginalCurrency: &usd, OriginalAmountMinor: &cents}},
+		{Line: 2, Item: models.SyncIngestItem{ID: "new-2", Type: "debit", Timestamp: now, AmountPaisa: 129500}},
+	}
+	candidates := []existingTxn{
+		{id: "old-1", amount: 129500, txType: "debit", timestamp: now.Add(time.Hour), currency: &stored, originalMinor: &storedCents},
+	}
+	var report statement.Report
+	flagDuplicates(rows, candidates, &report)
+	if rows[0].Duplicate != duplicatePossible || *rows[0].DuplicateOf != "old-1" {
+		t.Errorf("foreign row = %+v", rows[0])
+	}
+	// The candidate is claimed by the first match
+	if rows[1].Duplicate != "" || report.Duplicates != 1 {
+		t.Errorf("second row = %+v, duplicates = %d", rows[1], report.Duplicates)
+	}
+}
@@ -7,9 +7,7 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/gofiber/fiber/v2"
-
-	"duskspendr/gateway/internal/config"
+	"duskspendr-gateway/internal/config"
 )
 
 type IntegrationsHandler struct {
@@ -27,20 +25,16 @@ type UpstoxTokenRequest struct {
 	RedirectURI string `json:"redirect_uri,omitempty"`
 }
 
-func (h *IntegrationsHandler) UpstoxTokenExchange(c *fiber.Ctx) error {
+func (h *IntegrationsHandler) UpstoxTokenExchange(w http.ResponseWriter, r *http.Request) {
 	var req UpstoxTokenRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "INVALID_REQUEST", "message": "Invalid JSON body"},
-		})
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
 	}
 
 	if req.Code == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "INVALID_INPUT", "message": "Authorization code is required"},
-		})
+		writeErrorCode(w, http.StatusBadRequest, "INVALID_INPUT", "authorization code is required")
+		return
 	}
 
 	// Use configured redirect URI if not provided, or if the provided one matches
@@ -50,10 +44,8 @@ func (h *IntegrationsHandler) UpstoxTokenExchange(c *fiber.Ctx) error {
 	}
 
 	if h.Config.UpstoxClientSecret == "" || h.Config.UpstoxClientID == "" {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "CONFIG_ERROR", "message": "Provider not configured"},
-		})
+		writeErrorCode(w, http.StatusInternalServerError, "CONFIG_ERROR", "provider not configured")
+		return
 	}
 
 	// Prepare request to Upstox
@@ -71,41 +63,34 @@ func (h *IntegrationsHandler) UpstoxTokenExchange(c *fiber.Ctx) error {
 		strings.NewReader(data.Encode()),
 	)
 	if err != nil {
-		return c.Status(502).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTREAM_ERROR", "message": "Failed to contact Upstox: " + err.Error()},
-		})
+		writeError(w, http.StatusBadGateway, "failed to contact Upstox: "+err.Error())
+		return
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(502).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTREAM_ERROR", "message": "Failed to read Upstox response"},
-		})
+		writeError(w, http.StatusBadGateway, "failed to read Upstox response")
+		return
 	}
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode != http.StatusOK {
 		// Forward the error from Upstox
-		var errorResp map[string]interface{}
+		var errorResp map[string]any
 		_ = json.Unmarshal(bodyBytes, &errorResp)
-		return c.Status(resp.StatusCode).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTOX_ERROR", "message": "Upstox rejected the request", "details": errorResp},
-		})
+		writeJSON(w, resp.StatusCode, errorBody{Error: errorInfo{
+			Code:    "UPSTOX_ERROR",
+			Message: "Upstox rejected the request",
+			Details: errorResp,
+		}})
+		return
 	}
 
-	var tokenData map[string]interface{}
+	var tokenData map[string]any
 	if err := json.Unmarshal(bodyBytes, &tokenData); err != nil {
-		return c.Status(502).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTREAM_ERROR", "message": "Invalid response from Upstox"},
-		})
+		writeError(w, http.StatusBadGateway, "invalid response from Upstox")
+		return
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    tokenData,
-	})
+	writeJSON(w, http.StatusOK, tokenData)
 }
@@ -2,25 +2,22 @@ package handlers
 
 import (
 	"bytes"
-	"duskspendr/gateway/internal/config"
+	"duskspendr-gateway/internal/config"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
-
-	"github.com/gofiber/fiber/v2"
 )
 
 func TestUpstoxTokenExchange_Validation(t *testing.T) {
-	app := fiber.New()
 	cfg := config.Config{} // Empty config
 	h := NewIntegrationsHandler(cfg)
-	app.Post("/token", h.UpstoxTokenExchange)
 
 	t.Run("Missing Body", func(t *testing.T) {
 		req := httptest.NewRequest("POST", "/token", nil)
-		resp, _ := app.Test(req)
-		if resp.StatusCode != 400 {
-			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		rec := httptest.NewRecorder()
+		h.UpstoxTokenExchange(rec, req)
+		if rec.Code != 400 {
+			t.Errorf("Expected 400, got %d", rec.Code)
 		}
 	})
 
@@ -28,9 +25,10 @@ func TestUpstoxTokenExchange_Validation(t *testing.T) {
 		body, _ := json.Marshal(map[string]string{})
 		req := httptest.NewRequest("POST", "/token", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		resp, _ := app.Test(req)
-		if resp.StatusCode != 400 {
-			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		rec := httptest.NewRecorder()
+		h.UpstoxTokenExchange(rec, req)
+		if rec.Code != 400 {
+			t.Errorf("Expected 400, got %d", rec.Code)
 		}
 	})
 
@@ -38,9 +36,10 @@ func TestUpstoxTokenExchange_Validation(t *testing.T) {
 		body, _ := json.Marshal(map[string]string{"code": "123"})
 		req := httptest.NewRequest("POST", "/token", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		resp, _ := app.Test(req)
-		if resp.StatusCode != 500 {
-			t.Errorf("Expected 500 (Config Error), got %d", resp.StatusCode)
+		rec := httptest.NewRecorder()
+		h.UpstoxTokenExchange(rec, req)
+		if rec.Code != 500 {
+			t.Errorf("Expected 500 (Config Error), got %d", rec.Code)
 		}
 	})
 }
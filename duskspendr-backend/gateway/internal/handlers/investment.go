@@ -9,9 +9,7 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/gofiber/fiber/v2"
-
-	"duskspendr/gateway/internal/config"
+	"duskspendr-gateway/internal/config"
 )
 
 // InvestmentHandler handles investment platform integrations
@@ -32,31 +30,25 @@ type ZerodhaExchangeRequest struct {
 }
 
 // ZerodhaExchange handles the token exchange for Zerodha Kite
-func (h *InvestmentHandler) ZerodhaExchange(c *fiber.Ctx) error {
+func (h *InvestmentHandler) ZerodhaExchange(w http.ResponseWriter, r *http.Request) {
 	var req ZerodhaExchangeRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "INVALID_REQUEST", "message": "Invalid JSON body"},
-		})
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
 	}
 
 	req.Code = strings.TrimSpace(req.Code)
 	if req.Code == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "MISSING_CODE", "message": "Authorization code is required"},
-		})
+		writeErrorCode(w, http.StatusBadRequest, "MISSING_CODE", "authorization code is required")
+		return
 	}
 
-	apiKey := h.Config.ZerodhaApiKey
-	apiSecret := h.Config.ZerodhaApiSecret
+	apiKey := h.Config.ZerodhaAPIKey
+	apiSecret := h.Config.ZerodhaAPISecret
 
 	if apiKey == "" || apiSecret == "" {
-		return c.Status(500).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "CONFIG_ERROR", "message": "Zerodha provider not configured"},
-		})
+		writeErrorCode(w, http.StatusInternalServerError, "CONFIG_ERROR", "Zerodha provider not configured")
+		return
 	}
 
 	// Calculate checksum: SHA256(api_key + request_token + api_secret)
@@ -73,23 +65,26 @@ func (h *InvestmentHandler) ZerodhaExchange(c *fiber.Ctx) error {
 	// Call Zerodha API
 	resp, err := http.PostForm("https://api.kite.trade/session/token", formData)
 	if err != nil {
-		return c.Status(502).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTREAM_ERROR", "message": "Failed to contact Zerodha"},
-		})
+		writeError(w, http.StatusBadGateway, "failed to contact Zerodha")
+		return
 	}
 	defer resp.Body.Close()
 
 	// Parse response
-	var result map[string]interface{}
+	var result map[string]any
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return c.Status(502).JSON(fiber.Map{
-			"success": false,
-			"error":   fiber.Map{"code": "UPSTREAM_ERROR", "message": "Invalid response from Zerodha"},
-		})
+		writeError(w, http.StatusBadGateway, "invalid response from Zerodha")
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		writeJSON(w, resp.StatusCode, errorBody{Error: errorInfo{
+			Code:    "ZERODHA_ERROR",
+			Message: "Zerodha rejected the request",
+			Details: result,
+		}})
+		return
 	}
 
-	// Return the response as is (Zerodha returns status 200 on success)
-	// If Zerodha returns error (e.g. 403), we forward that too.
-	return c.Status(resp.StatusCode).JSON(result)
+	writeJSON(w, http.StatusOK, result)
 }
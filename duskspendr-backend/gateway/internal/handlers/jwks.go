@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"duskspendr-gateway/internal/services"
+)
+
+// JWKSHandler publishes the public keys that verify gateway JWTs, for
+// serverpod and the Python services
+type JWKSHandler struct {
+	JWT *services.JWTService
+}
+
+// Get serves /.well-known/jwks.json. The cache lifetime is well below the
+// key prepublish period, so verifiers see a new key before it signs.
+func (h *JWKSHandler) Get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.JWT.JWKS())
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/merchant"
+)
+
+type MerchantHandler struct {
+	Pool      *pgxpool.Pool
+	Directory *merchant.Directory
+}
+
+type merchantSpend struct {
+	Name             string    `json:"name"`
+	Category         *string   `json:"category,omitempty"`
+	TransactionCount int64     `json:"transaction_count"`
+	SpentPaisa       int64     `json:"spent_paisa"`
+	ReceivedPaisa    int64     `json:"received_paisa"`
+	LastSeenAt       time.Time `json:"last_seen_at"`
+}
+
+// List returns the user's merchants ordered by spend
+func (h *MerchantHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT merchant_name,
+		       mode() WITHIN GROUP (ORDER BY category),
+		       count(*),
+		       coalesce(sum(amount_paisa) FILTER (WHERE type = 'debit'), 0),
+		       coalesce(sum(amount_paisa) FILTER (WHERE type = 'credit'), 0),
+		       max(timestamp)
+		  FROM transactions
+		 WHERE user_id = $1
+		   AND merchant_name IS NOT NULL AND merchant_name <> ''
+		   AND ($2::timestamptz IS NULL OR timestamp >= $2)
+		   AND ($3::timestamptz IS NULL OR timestamp < $3)
+		 GROUP BY merchant_name
+		 ORDER BY 4 DESC, 3 DESC
+		 LIMIT $4
+	`, userID, from, to, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []merchantSpend{}
+	for rows.Next() {
+		var m merchantSpend
+		if err := rows.Scan(&m.Name, &m.Category, &m.TransactionCount, &m.SpentPaisa, &m.ReceivedPaisa, &m.LastSeenAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, m)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// ListOverrides returns the user's merchant overrides
+func (h *MerchantHandler) ListOverrides(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	overrides, err := merchant.LoadOverrides(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	items := make([]merchant.Override, 0, len(overrides))
+	for _, o := range overrides {
+		items = append(items, o)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+type merchantOverrideInput struct {
+	Match        string  `json:"match"`
+	MerchantName string  `json:"merchant_name"`
+	Category     *string `json:"category,omitempty"`
+}
+
+// PutOverride creates or replaces an override. It applies to transactions
+// written from now on.
+func (h *MerchantHandler) PutOverride(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	var input merchantOverrideInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	key := merchant.Key(input.Match)
+	input.MerchantName = strings.TrimSpace(input.MerchantName)
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "match is required")
+		return
+	}
+	if input.MerchantName == "" || len(input.MerchantName) > 120 {
+		writeError(w, http.StatusBadRequest, "invalid merchant_name")
+		return
+	}
+	if input.Category != nil {
+		categories, err := category.Load(r.Context(), h.Pool, userID.String())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "save failed")
+			return
+		}
+		if !categories.Has(*input.Category) {
+			writeError(w, http.StatusBadRequest, "invalid category")
+			return
+		}
+	}
+
+	_, err := h.Pool.Exec(r.Context(), `
+		INSERT INTO user_merchant_overrides (user_id, match_key, merchant_name, category)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, match_key) DO UPDATE SET
+		  merchant_name = EXCLUDED.merchant_name,
+		  category = EXCLUDED.category,
+		  updated_at = now()
+	`, userID, key, input.MerchantName, input.Category)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "save failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, merchant.Override{Key: key, MerchantName: input.MerchantName, Category: input.Category})
+}
+
+// DeleteOverride removes the override for ?match=
+func (h *MerchantHandler) DeleteOverride(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	key := merchant.Key(r.URL.Query().Get("match"))
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "match is required")
+		return
+	}
+
+	cmd, err := h.Pool.Exec(r.Context(), `
+		DELETE FROM user_merchant_overrides WHERE user_id = $1 AND match_key = $2
+	`, userID, key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// normalizeMerchant rewrites merchantName to its canonical form and fills
+// category from the merchant's default when the client sent none or
+// "other". It returns the original name for merchant_raw.
+func normalizeMerchant(dir *merchant.Directory, overrides merchant.Overrides, merchantName **string, category *string) *string {
+	raw := *merchantName
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return raw
+	}
+	match := dir.Resolve(*raw, overrides)
+	if match.Name != "" {
+		name := match.Name
+		*merchantName = &name
+	}
+	if match.Category != "" && (*category == "" || *category == "other") {
+		*category = match.Category
+	}
+	return raw
+}
+
+// loadMerchantOverrides skips the query when nothing needs normalising
+func loadMerchantOverrides(ctx context.Context, q merchant.Querier, userID string, needed bool) (merchant.Overrides, error) {
+	if !needed {
+		return nil, nil
+	}
+	return merchant.LoadOverrides(ctx, q, userID)
+}
+
+// parseTimeRange reads optional RFC 3339 from/to query parameters
+func parseTimeRange(r *http.Request) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, errInvalid("invalid from")
+		}
+		from = &t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, errInvalid("invalid to")
+		}
+		to = &t
+	}
+	return from, to, nil
+}
@@ -3,16 +3,26 @@ package handlers
 import (
   "context"
   "encoding/json"
+  "errors"
+  "log"
   "net/http"
   "strings"
+  "time"
 
   "github.com/google/uuid"
   "github.com/jackc/pgx/v5/pgxpool"
+
+  "duskspendr-gateway/internal/services"
+  "duskspendr-gateway/internal/session"
 )
 
 type ctxKey string
 
-const userIDKey ctxKey = "user_id"
+const (
+  userIDKey    ctxKey = "user_id"
+  sessionIDKey ctxKey = "session_id"
+  claimsKey    ctxKey = "claims"
+)
 
 func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
   v := ctx.Value(userIDKey)
@@ -23,36 +33,132 @@ func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
   return id, ok
 }
 
-func RequireUserID(pool *pgxpool.Pool) func(http.Handler) http.Handler {
-  return func(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-      authHeader := r.Header.Get("Authorization")
-      if authHeader == "" {
-        writeError(w, http.StatusUnauthorized, "missing Authorization header")
-        return
-      }
-      token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer"))
-      if token == "" {
-        writeError(w, http.StatusUnauthorized, "invalid Authorization header")
-        return
-      }
-
-      tokenHash := hashToken(token)
-      var userID uuid.UUID
-      err := pool.QueryRow(r.Context(), `
-        SELECT user_id
-          FROM sessions
-         WHERE token_hash = $1 AND expires_at > now()
-      `, tokenHash).Scan(&userID)
-      if err != nil {
-        writeError(w, http.StatusUnauthorized, "invalid session")
-        return
-      }
-
-      ctx := context.WithValue(r.Context(), userIDKey, userID)
-      next.ServeHTTP(w, r.WithContext(ctx))
-    })
+// SessionIDFromContext returns the opaque token session set by RequireUserID
+func SessionIDFromContext(ctx context.Context) string {
+  id, _ := ctx.Value(sessionIDKey).(string)
+  return id
+}
+
+// ClaimsFromContext returns the JWT claims set by RequireUserID, or nil when
+// the request used an opaque session token
+func ClaimsFromContext(ctx context.Context) *services.Claims {
+  claims, _ := ctx.Value(claimsKey).(*services.Claims)
+  return claims
+}
+
+// Authenticator resolves bearer tokens for both sign-in flows while clients
+// migrate: JWT access tokens are verified locally and checked against the
+// denylist, anything else is looked up as an opaque session token.
+type Authenticator struct {
+  Pool     *pgxpool.Pool
+  JWT      *services.JWTService
+  Denylist services.TokenDenylist
+}
+
+func (a *Authenticator) RequireUserID(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    authHeader := r.Header.Get("Authorization")
+    if authHeader == "" {
+      writeError(w, http.StatusUnauthorized, "missing Authorization header")
+      return
+    }
+    token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer"))
+    if token == "" {
+      writeError(w, http.StatusUnauthorized, "invalid Authorization header")
+      return
+    }
+
+    var ctx context.Context
+    var ok bool
+    if isJWT(token) {
+      ctx, ok = a.fromJWT(w, r, token)
+    } else {
+      ctx, ok = a.fromSession(w, r, token)
+    }
+    if !ok {
+      return
+    }
+    next.ServeHTTP(w, r.WithContext(ctx))
+  })
+}
+
+func (a *Authenticator) fromJWT(w http.ResponseWriter, r *http.Request, token string) (context.Context, bool) {
+  if a.JWT == nil {
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_TOKEN", "invalid access token")
+    return nil, false
+  }
+  claims, err := a.JWT.ValidateAccessToken(token)
+  if errors.Is(err, services.ErrExpiredToken) {
+    writeErrorCode(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "access token has expired")
+    return nil, false
+  }
+  if err != nil {
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_TOKEN", "invalid access token")
+    return nil, false
+  }
+  userID, err := uuid.Parse(claims.UserID)
+  if err != nil {
+    writeErrorCode(w, http.StatusUnauthorized, "INVALID_TOKEN", "invalid access token")
+    return nil, false
+  }
+  if a.Denylist != nil {
+    denied, err := a.Denylist.Denied(r.Context(), claims)
+    if err != nil {
+      writeErrorCode(w, http.StatusServiceUnavailable, "AUTH_UNAVAILABLE", "unable to verify access token")
+      return nil, false
+    }
+    if denied {
+      writeErrorCode(w, http.StatusUnauthorized, "TOKEN_REVOKED", "access token has been revoked")
+      return nil, false
+    }
+  }
+
+  ctx := context.WithValue(r.Context(), userIDKey, userID)
+  ctx = context.WithValue(ctx, claimsKey, claims)
+  return ctx, true
+}
+
+func (a *Authenticator) fromSession(w http.ResponseWriter, r *http.Request, token string) (context.Context, bool) {
+  tokenHash := hashToken(token)
+  var userID uuid.UUID
+  var sessionID string
+  err := a.Pool.QueryRow(r.Context(), `
+    SELECT user_id, id::text
+      FROM sessions
+     WHERE token_hash = $1 AND expires_at > now()
+  `, tokenHash).Scan(&userID, &sessionID)
+  if err != nil {
+    writeError(w, http.StatusUnauthorized, "invalid session")
+    return nil, false
+  }
+  if err := session.Touch(r.Context(), a.Pool, sessionID, clientIP(r)); err != nil {
+    log.Printf("session: touch %s failed: %v", sessionID, err)
   }
+
+  ctx := context.WithValue(r.Context(), userIDKey, userID)
+  ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+  return ctx, true
+}
+
+// RequireStepUp guards sensitive routes behind RequireUserID: the request
+// must carry an access token from POST /auth/step-up whose elevation has not
+// expired. Opaque session tokens never qualify; their holders step up too
+// and use the returned token for the guarded call.
+func (a *Authenticator) RequireStepUp(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    claims := ClaimsFromContext(r.Context())
+    if claims == nil || !claims.Elevated(time.Now()) {
+      writeErrorCode(w, http.StatusForbidden, "STEP_UP_REQUIRED", "confirm with a second factor at /auth/step-up")
+      return
+    }
+    next.ServeHTTP(w, r)
+  })
+}
+
+// isJWT distinguishes a compact JWT (header.payload.signature) from an
+// opaque session token, which is unpadded base64url without dots
+func isJWT(token string) bool {
+  return strings.Count(token, ".") == 2
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -61,6 +167,55 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
   _ = json.NewEncoder(w).Encode(v)
 }
 
+// errorBody is the error envelope shared by every route:
+// {"success": false, "error": {"code": "...", "message": "..."}}
+type errorBody struct {
+  Success bool      `json:"success"`
+  Error   errorInfo `json:"error"`
+}
+
+type errorInfo struct {
+  Code    string `json:"code"`
+  Message string `json:"message"`
+  Details any    `json:"details,omitempty"`
+}
+
+// writeError writes the error envelope with the default code for status
 func writeError(w http.ResponseWriter, status int, msg string) {
-  writeJSON(w, status, map[string]string{"error": msg})
+  writeErrorCode(w, status, errorCode(status), msg)
+}
+
+// writeErrorCode writes the error envelope with a specific machine-readable code
+func writeErrorCode(w http.ResponseWriter, status int, code, msg string) {
+  writeJSON(w, status, errorBody{Error: errorInfo{Code: code, Message: msg}})
+}
+
+func errorCode(status int) string {
+  switch status {
+  case http.StatusBadRequest:
+    return "INVALID_REQUEST"
+  case http.StatusUnauthorized:
+    return "UNAUTHORIZED"
+  case http.StatusForbidden:
+    return "FORBIDDEN"
+  case http.StatusNotFound:
+    return "NOT_FOUND"
+  case http.StatusConflict:
+    return "CONFLICT"
+  case http.StatusRequestEntityTooLarge:
+    return "PAYLOAD_TOO_LARGE"
+  case http.StatusUnprocessableEntity:
+    return "UNPROCESSABLE"
+  case http.StatusTooManyRequests:
+    return "RATE_LIMITED"
+  case http.StatusBadGateway:
+    return "UPSTREAM_ERROR"
+  case http.StatusServiceUnavailable:
+    return "UNAVAILABLE"
+  default:
+    if status >= 500 {
+      return "INTERNAL_ERROR"
+    }
+    return "ERROR"
+  }
 }
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"duskspendr-gateway/internal/services"
+)
+
+func TestRequireUserIDJWT(t *testing.T) {
+	key, err := services.GenerateSigningKey(services.AlgEdDSA, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSvc := services.NewJWTService(services.NewKeyRing(time.Hour, key), time.Minute, time.Hour)
+	denylist := services.NewMemoryDenylist()
+	auth := &Authenticator{JWT: jwtSvc, Denylist: denylist}
+
+	var gotUser string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := UserIDFromContext(r.Context())
+		gotUser = id.String()
+		if ClaimsFromContext(r.Context()) == nil {
+			t.Error("claims missing from context")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := auth.RequireUserID(next)
+
+	userID := "6f1c2f4e-8a55-4c1b-9a7e-2b7f3d1e9c10"
+	pair, err := jwtSvc.GenerateTokenPair(userID, "", "+919999999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/transactions", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := call("Bearer " + pair.AccessToken); rec.Code != http.StatusOK || gotUser != userID {
+		t.Fatalf("valid token: status %d user %q", rec.Code, gotUser)
+	}
+	if rec := call("Bearer " + pair.RefreshToken); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token as access: status %d", rec.Code)
+	}
+
+	claims, _ := jwtSvc.ValidateAccessToken(pair.AccessToken)
+	if err := denylist.DenyToken(context.Background(), claims.ID, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	rec := call("Bearer " + pair.AccessToken)
+	var body errorBody
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusUnauthorized || body.Error.Code != "TOKEN_REVOKED" {
+		t.Fatalf("denied token: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = call("")
+	body = errorBody{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusUnauthorized || body.Success || body.Error.Code != "UNAUTHORIZED" {
+		t.Fatalf("missing header: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIsJWT(t *testing.T) {
+	if !isJWT("a.b.c") {
+		t.Error("compact JWT not detected")
+	}
+	token, _, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isJWT(token) {
+		t.Error("opaque session token detected as JWT")
+	}
+}
+
+func TestRequireStepUp(t *testing.T) {
+	key, err := services.GenerateSigningKey(services.AlgEdDSA, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSvc := services.NewJWTService(services.NewKeyRing(time.Hour, key), 15*time.Minute, time.Hour)
+	auth := &Authenticator{JWT: jwtSvc}
+	h := auth.RequireUserID(auth.RequireStepUp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	call := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/accounts/link", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	userID := "6f1c2f4e-8a55-4c1b-9a7e-2b7f3d1e9c10"
+	pair, err := jwtSvc.GenerateTokenPair(userID, "", "+919999999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := call(pair.AccessToken)
+	var body errorBody
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusForbidden || body.Error.Code != "STEP_UP_REQUIRED" {
+		t.Fatalf("plain token: %d %s", rec.Code, rec.Body.String())
+	}
+
+	elevated, _, err := jwtSvc.GenerateElevatedAccessToken(userID, "", "+919999999999", pair.FamilyID, 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := call(elevated); rec.Code != http.StatusOK {
+		t.Fatalf("elevated token: %d %s", rec.Code, rec.Body.String())
+	}
+}
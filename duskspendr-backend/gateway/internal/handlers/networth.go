@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/networth"
+)
+
+type NetWorthHandler struct {
+	Pool *pgxpool.Pool
+	// Location defines the snapshot date
+	Location *time.Location
+}
+
+func (h *NetWorthHandler) location() *time.Location {
+	if h.Location == nil {
+		return time.UTC
+	}
+	return h.Location
+}
+
+// Get returns the current net worth with its breakdown, refreshing today's
+// snapshot, and the trend over the last ?days= (default 90, at most 730).
+func (h *NetWorthHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	days := 90
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 730 {
+			writeError(w, http.StatusBadRequest, "days must be between 1 and 730")
+			return
+		}
+		days = n
+	}
+
+	now := time.Now()
+	today := now.In(h.location())
+	items, err := networth.Load(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	summary := networth.Compute(items, now.UTC())
+	if err := networth.SaveSnapshot(r.Context(), h.Pool, userID.String(), today, summary); err != nil {
+		writeError(w, http.StatusInternalServerError, "snapshot failed")
+		return
+	}
+	points, err := networth.History(r.Context(), h.Pool, userID.String(), today.AddDate(0, 0, 1-days), today)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		networth.Summary
+		Trend networth.Trend `json:"trend"`
+	}{summary, networth.NewTrend(points)})
+}
+
+type netWorthItemInput struct {
+	Kind       string     `json:"kind"`
+	Category   string     `json:"category"`
+	Name       string     `json:"name"`
+	ValuePaisa int64      `json:"value_paisa"`
+	Notes      *string    `json:"notes,omitempty"`
+	AsOf       *time.Time `json:"as_of,omitempty"`
+}
+
+func validateNetWorthItem(input *netWorthItemInput) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Name) > 100 {
+		return errInvalid("name is required")
+	}
+	if input.Kind != networth.Asset && input.Kind != networth.Liability {
+		return errInvalid("kind must be asset or liability")
+	}
+	if !networth.ValidManual(input.Kind, input.Category) {
+		return errInvalid("invalid category for " + input.Kind)
+	}
+	if input.ValuePaisa < 0 {
+		return errInvalid("value_paisa must be >= 0")
+	}
+	return nil
+}
+
+// ListItems returns the manually entered assets and liabilities
+func (h *NetWorthHandler) ListItems(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT id::text, kind, category, name, value_paisa, notes, as_of
+		  FROM networth_items
+		 WHERE user_id = $1
+		 ORDER BY kind, category, name
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	type item struct {
+		networth.Item
+		Notes *string `json:"notes,omitempty"`
+	}
+	items := []item{}
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.ID, &it.Kind, &it.Category, &it.Name, &it.ValuePaisa, &it.Notes, &it.AsOf); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		it.Source = networth.SourceManual
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *NetWorthHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	var input netWorthItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := validateNetWorthItem(&input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	asOf := now
+	if input.AsOf != nil {
+		asOf = *input.AsOf
+	}
+	id := uuid.New().String()
+	if _, err := h.Pool.Exec(r.Context(), `
+		INSERT INTO networth_items (
+		  id, user_id, kind, category, name, value_paisa, notes, as_of, created_at, updated_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$9)
+	`, id, userID, input.Kind, input.Category, input.Name, input.ValuePaisa, input.Notes, asOf, now); err != nil {
+		writeError(w, http.StatusInternalServerError, "insert failed")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (h *NetWorthHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var input netWorthItemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if err := validateNetWorthItem(&input); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	asOf := now
+	if input.AsOf != nil {
+		asOf = *input.AsOf
+	}
+	cmd, err := h.Pool.Exec(r.Context(), `
+		UPDATE networth_items
+		   SET kind = $3, category = $4, name = $5, value_paisa = $6, notes = $7, as_of = $8,
+		       updated_at = $9
+		 WHERE user_id = $1 AND id = $2
+	`, userID, id, input.Kind, input.Category, input.Name, input.ValuePaisa, input.Notes, asOf, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+func (h *NetWorthHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	cmd, err := h.Pool.Exec(r.Context(), `
+		DELETE FROM networth_items WHERE user_id = $1 AND id = $2
+	`, userID, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "delete failed")
+		return
+	}
+	if cmd.RowsAffected() == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+type holding struct {
+	Provider   string    `json:"provider"`
+	Symbol     string    `json:"symbol"`
+	Name       *string   `json:"name,omitempty"`
+	Quantity   float64   `json:"quantity"`
+	ValuePaisa int64     `json:"value_paisa"`
+	AsOf       time.Time `json:"as_of"`
+}
+
+// ListHoldings returns the user's investment holdings
+func (h *NetWorthHandler) ListHoldings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT provider, symbol, name, quantity, value_paisa, as_of
+		  FROM investment_holdings
+		 WHERE user_id = $1
+		 ORDER BY value_paisa DESC, provider, symbol
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+	items := []holding{}
+	for rows.Next() {
+		var it holding
+		if err := rows.Scan(&it.Provider, &it.Symbol, &it.Name, &it.Quantity, &it.ValuePaisa, &it.AsOf); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// PutHoldings replaces the holdings of one provider with the body's
+func (h *NetWorthHandler) PutHoldings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	var input struct {
+		Provider string    `json:"provider"`
+		Holdings []holding `json:"holdings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	input.Provider = strings.ToLower(strings.TrimSpace(input.Provider))
+	if input.Provider == "" || len(input.Provider) > 50 {
+		writeError(w, http.StatusBadRequest, "provider is required")
+		return
+	}
+	if len(input.Holdings) > 1000 {
+		writeError(w, http.StatusBadRequest, "too many holdings")
+		return
+	}
+	seen := map[string]bool{}
+	for i := range input.Holdings {
+		it := &input.Holdings[i]
+		it.Symbol = strings.ToUpper(strings.TrimSpace(it.Symbol))
+		if it.Symbol == "" || seen[it.Symbol] {
+			writeError(w, http.StatusBadRequest, "holdings need distinct symbols")
+			return
+		}
+		if it.ValuePaisa < 0 || it.Quantity < 0 {
+			writeError(w, http.StatusBadRequest, "invalid holding "+it.Symbol)
+			return
+		}
+		seen[it.Symbol] = true
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	if _, err := tx.Exec(r.Context(), `
+		DELETE FROM investment_holdings WHERE user_id = $1 AND provider = $2
+	`, userID, input.Provider); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	now := time.Now().UTC()
+	for _, it := range input.Holdings {
+		asOf := it.AsOf
+		if asOf.IsZero() {
+			asOf = now
+		}
+		if _, err := tx.Exec(r.Context(), `
+			INSERT INTO investment_holdings (user_id, provider, symbol, name, quantity, value_paisa, as_of)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, userID, input.Provider, it.Symbol, it.Name, it.Quantity, it.ValuePaisa, asOf); err != nil {
+			writeError(w, http.StatusInternalServerError, "update failed")
+			return
+		}
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"provider": input.Provider, "count": len(input.Holdings)})
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+func TestValidateNetWorthItem(t *testing.T) {
+	ok := netWorthItemInput{Kind: "asset", Category: "gold", Name: " Coins ", ValuePaisa: 100}
+	if err := validateNetWorthItem(&ok); err != nil || ok.Name != "Coins" {
+		t.Fatalf("valid item: %v %+v", err, ok)
+	}
+	for name, input := range map[string]netWorthItemInput{
+		"kind":     {Kind: "equity", Category: "other", Name: "x"},
+		"category": {Kind: "liability", Category: "gold", Name: "x"},
+		"negative": {Kind: "liability", Category: "loan", Name: "x", ValuePaisa: -1},
+		"name":     {Kind: "asset", Category: "cash"},
+	} {
+		if err := validateNetWorthItem(&input); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
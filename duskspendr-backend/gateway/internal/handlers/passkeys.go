@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/config"
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/passkey"
+	"duskspendr-gateway/internal/session"
+)
+
+// newWebAuthn configures the relying party from cfg, or returns nil so the
+// passkey endpoints answer PASSKEYS_DISABLED
+func newWebAuthn(cfg config.Config) *webauthn.WebAuthn {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.WebAuthnChallengeTTL, TimeoutUVD: cfg.WebAuthnChallengeTTL}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOriginList(),
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		log.Printf("auth: passkeys disabled: %v", err)
+		return nil
+	}
+	return wa
+}
+
+func (h *AuthHandler) passkeysEnabled(w http.ResponseWriter) bool {
+	if h.WebAuthn == nil {
+		writeErrorCode(w, http.StatusServiceUnavailable, "PASSKEYS_DISABLED", "passkeys are not configured")
+		return false
+	}
+	return true
+}
+
+// BeginPasskeyRegistration starts registering a passkey for the signed-in
+// user. Only users who verified their phone by OTP may register one.
+func (h *AuthHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	if !h.passkeysEnabled(w) {
+		return
+	}
+
+	user, err := passkey.LoadUser(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, passkey.ErrPhoneNotVerified) {
+		writeErrorCode(w, http.StatusForbidden, "PHONE_NOT_VERIFIED", "verify your phone before adding a passkey")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+
+	options, data, err := h.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(user.Exclusions()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start registration")
+		return
+	}
+	h.writeChallenge(w, r, passkey.CeremonyRegistration, userID.String(), options, data)
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation and
+// stores the new credential
+func (h *AuthHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	if !h.passkeysEnabled(w) {
+		return
+	}
+	var input models.PasskeyRegisterInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || len(input.Credential) == 0 {
+		writeError(w, http.StatusBadRequest, "challenge_id and credential are required")
+		return
+	}
+
+	data, ok := h.takeChallenge(w, r, input.ChallengeID, passkey.CeremonyRegistration, userID.String())
+	if !ok {
+		return
+	}
+	user, err := passkey.LoadUser(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(input.Credential))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, "INVALID_CREDENTIAL", "invalid credential")
+		return
+	}
+	cred, err := h.WebAuthn.CreateCredential(user, data, parsed)
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, "INVALID_CREDENTIAL", "passkey could not be verified")
+		return
+	}
+
+	created, err := passkey.Create(r.Context(), h.Pool, userID.String(), input.Name, cred)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save passkey")
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// BeginPasskeyLogin starts a sign-in with a discoverable passkey. No phone
+// is asked for, so the response does not reveal whether an account exists.
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.passkeysEnabled(w) {
+		return
+	}
+	options, data, err := h.WebAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start sign-in")
+		return
+	}
+	h.writeChallenge(w, r, passkey.CeremonyLogin, "", options, data)
+}
+
+// FinishPasskeyLoginSession verifies the assertion and issues an opaque
+// session token, like VerifySession (/v1)
+func (h *AuthHandler) FinishPasskeyLoginSession(w http.ResponseWriter, r *http.Request) {
+	user, device, ok := h.verifyPasskey(w, r)
+	if !ok {
+		return
+	}
+	h.issueSession(w, r, user, device)
+}
+
+// FinishPasskeyLogin verifies the assertion and issues a JWT pair, like
+// Verify (/api/v1)
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	user, device, ok := h.verifyPasskey(w, r)
+	if !ok {
+		return
+	}
+	var phone string
+	_ = h.Pool.QueryRow(r.Context(), `SELECT phone FROM users WHERE id = $1`, user).Scan(&phone)
+	h.issueTokenPair(w, r, user, phone, device)
+}
+
+// verifyPasskey checks an assertion against the stored credential and
+// advances its signature counter. On failure the error response has
+// already been written.
+func (h *AuthHandler) verifyPasskey(w http.ResponseWriter, r *http.Request) (uuid.UUID, session.Device, bool) {
+	var device session.Device
+	if !h.passkeysEnabled(w) {
+		return uuid.UUID{}, device, false
+	}
+	var input models.PasskeyLoginInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || len(input.Credential) == 0 {
+		writeError(w, http.StatusBadRequest, "challenge_id and credential are required")
+		return uuid.UUID{}, device, false
+	}
+	device = deviceFrom(r, models.AuthVerifyInput{
+		DeviceName: input.DeviceName,
+		Platform:   input.Platform,
+		AppVersion: input.AppVersion,
+	})
+
+	data, ok := h.takeChallenge(w, r, input.ChallengeID, passkey.CeremonyLogin, "")
+	if !ok {
+		return uuid.UUID{}, device, false
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(input.Credential))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, "INVALID_CREDENTIAL", "invalid credential")
+		return uuid.UUID{}, device, false
+	}
+
+	var owner *passkey.User
+	lookup := func(rawID, userHandle []byte) (webauthn.User, error) {
+		u, err := passkey.UserByHandle(r.Context(), h.Pool, userHandle)
+		if err != nil {
+			return nil, err
+		}
+		owner = u
+		return u, nil
+	}
+	cred, err := h.WebAuthn.ValidateDiscoverableLogin(lookup, data, parsed)
+	if err != nil || owner == nil {
+		writeErrorCode(w, http.StatusUnauthorized, "INVALID_PASSKEY", "passkey sign-in failed")
+		return uuid.UUID{}, device, false
+	}
+
+	stored, _ := owner.Find(cred.ID)
+	err = passkey.CheckSignCount(stored.Authenticator.SignCount, cred)
+	if err == nil {
+		err = passkey.RecordLogin(r.Context(), h.Pool, stored.Authenticator.SignCount, cred)
+	}
+	if errors.Is(err, passkey.ErrSignCount) {
+		log.Printf("auth: passkey %x of user %s failed the signature counter check", cred.ID, owner.ID)
+		writeErrorCode(w, http.StatusUnauthorized, "PASSKEY_CLONED", "passkey sign-in failed")
+		return uuid.UUID{}, device, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record sign-in")
+		return uuid.UUID{}, device, false
+	}
+
+	userID, err := uuid.Parse(owner.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "invalid user")
+		return uuid.UUID{}, device, false
+	}
+	return userID, device, true
+}
+
+// ListPasskeys returns the passkeys registered by the signed-in user
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	passkeys, err := passkey.List(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list passkeys")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"passkeys": passkeys})
+}
+
+// DeletePasskey removes one of the user's passkeys. Sessions signed in with
+// it stay valid; they are ended through /users/me/sessions.
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	err := passkey.Delete(r.Context(), h.Pool, userID.String(), chi.URLParam(r, "id"))
+	if errors.Is(err, passkey.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "passkey not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete passkey")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (h *AuthHandler) writeChallenge(w http.ResponseWriter, r *http.Request, ceremony, userID string, options any, data *webauthn.SessionData) {
+	expiresAt := data.Expires
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(h.Config.WebAuthnChallengeTTL)
+	}
+	id, err := passkey.SaveChallenge(r.Context(), h.Pool, ceremony, userID, data, expiresAt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to store challenge")
+		return
+	}
+	writeJSON(w, http.StatusOK, models.PasskeyChallengeResponse{
+		ChallengeID: id,
+		Options:     options,
+		ExpiresAt:   expiresAt.UTC(),
+	})
+}
+
+func (h *AuthHandler) takeChallenge(w http.ResponseWriter, r *http.Request, id, ceremony, userID string) (webauthn.SessionData, bool) {
+	data, err := passkey.TakeChallenge(r.Context(), h.Pool, strings.TrimSpace(id), ceremony, userID)
+	if errors.Is(err, passkey.ErrChallengeNotFound) {
+		writeErrorCode(w, http.StatusBadRequest, "CHALLENGE_EXPIRED", "challenge expired; start again")
+		return data, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load challenge")
+		return data, false
+	}
+	return data, true
+}
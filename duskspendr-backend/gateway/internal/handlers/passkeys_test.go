@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"duskspendr-gateway/internal/config"
+)
+
+func TestNewWebAuthn(t *testing.T) {
+	cfg := config.Config{WebAuthnRPID: "duskspendr.app", WebAuthnRPName: "DuskSpendr", WebAuthnOrigins: "https://duskspendr.app"}
+	if newWebAuthn(cfg) == nil {
+		t.Fatal("valid relying party rejected")
+	}
+	cfg.WebAuthnOrigins = ""
+	if newWebAuthn(cfg) != nil {
+		t.Fatal("relying party without origins accepted")
+	}
+}
+
+func TestPasskeysDisabled(t *testing.T) {
+	h := &AuthHandler{}
+	for name, handler := range map[string]http.HandlerFunc{
+		"login begin":  h.BeginPasskeyLogin,
+		"login finish": h.FinishPasskeyLogin,
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+		var body errorBody
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != http.StatusServiceUnavailable || body.Error.Code != "PASSKEYS_DISABLED" {
+			t.Errorf("%s: %d %s", name, rec.Code, rec.Body.String())
+		}
+	}
+}
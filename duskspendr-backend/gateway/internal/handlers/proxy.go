@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ProxyTo forwards authenticated requests to an internal Python service with
+// the caller's user id in X-User-Id. The path is forwarded unchanged, minus
+// the route tree prefix, so /api/v1/ai/categorize reaches /ai/categorize.
+func ProxyTo(target string, prefixes ...string) http.HandlerFunc {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		log.Printf("proxy: invalid upstream %q: %v", target, err)
+		return func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusServiceUnavailable, "upstream not configured")
+		}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(u)
+			pr.SetXForwarded()
+			pr.Out.URL.Path = joinPath(u.Path, stripPrefix(pr.In.URL.Path, prefixes))
+			pr.Out.URL.RawPath = ""
+			pr.Out.Header.Del("X-User-Id")
+			if userID, ok := UserIDFromContext(pr.In.Context()); ok {
+				pr.Out.Header.Set("X-User-Id", userID.String())
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("proxy: %s %s: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusBadGateway, "upstream unavailable")
+		},
+	}
+	return proxy.ServeHTTP
+}
+
+func stripPrefix(path string, prefixes []string) string {
+	for _, p := range prefixes {
+		if len(path) > len(p) && path[:len(p)] == p && path[len(p)] == '/' {
+			return path[len(p):]
+		}
+	}
+	return path
+}
+
+func joinPath(base, path string) string {
+	if base == "" || base == "/" {
+		return path
+	}
+	if base[len(base)-1] == '/' {
+		base = base[:len(base)-1]
+	}
+	return base + path
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/session"
+)
+
+type SessionHandler struct {
+	Pool    *pgxpool.Pool
+	Revoker *session.Revoker
+}
+
+// List returns the devices the user is signed in on; the caller's own
+// session is flagged current.
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	currentFamily := ""
+	if claims := ClaimsFromContext(r.Context()); claims != nil {
+		currentFamily = claims.FamilyID
+	}
+	sessions, err := session.List(r.Context(), h.Pool, userID.String(), SessionIDFromContext(r.Context()), currentFamily)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": sessions})
+}
+
+// Delete signs one device out. Revoking a JWT session also revokes its
+// refresh tokens and denies its access tokens.
+func (h *SessionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	err := h.Revoker.Revoke(r.Context(), userID.String(), chi.URLParam(r, "id"))
+	if errors.Is(err, session.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
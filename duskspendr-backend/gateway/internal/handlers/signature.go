@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/db"
+	"duskspendr-gateway/internal/serverpod"
+)
+
+// NonceStore remembers signatures that have already been accepted so a
+// captured request cannot be replayed within the skew window.
+type NonceStore interface {
+	// Remember records key for ttl and reports false if it was already seen.
+	Remember(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceStore is a process-local NonceStore used when Redis is unavailable
+type MemoryNonceStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{entries: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) Remember(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, expires := range s.entries {
+		if now.After(expires) {
+			delete(s.entries, k)
+		}
+	}
+	if _, seen := s.entries[key]; seen {
+		return false, nil
+	}
+	s.entries[key] = now.Add(ttl)
+	return true, nil
+}
+
+// RedisNonceStore shares replay protection across gateway replicas
+type RedisNonceStore struct {
+	Redis  *db.RedisClient
+	Prefix string
+}
+
+func NewRedisNonceStore(redis *db.RedisClient) *RedisNonceStore {
+	return &RedisNonceStore{Redis: redis, Prefix: "sync_nonce:"}
+}
+
+func (s *RedisNonceStore) Remember(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.Redis.SetNX(ctx, s.Prefix+key, 1, ttl)
+}
+
+// RequireSyncSignature verifies inbound requests signed with the serverpod
+// sync scheme (X-Sync-Timestamp / X-Sync-Signature over timestamp, X-User-Id
+// and body). Each trusted service gets its own secret. On success the user
+// from X-User-Id is placed in the request context like RequireUserID does.
+func RequireSyncSignature(secret string, maxSkew time.Duration, nonces NonceStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				writeError(w, http.StatusServiceUnavailable, "sync secret not configured")
+				return
+			}
+
+			userID, err := uuid.Parse(r.Header.Get("X-User-Id"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "missing user id")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			signature := r.Header.Get("X-Sync-Signature")
+			if err := serverpod.VerifySignature(
+				secret,
+				r.Header.Get("X-Sync-Timestamp"),
+				userID.String(),
+				body,
+				signature,
+				maxSkew,
+				time.Now(),
+			); err != nil {
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			// A signature is only valid inside the skew window on either side
+			// of now, so remembering it for twice that long is sufficient.
+			fresh, err := nonces.Remember(r.Context(), signature, 2*maxSkew)
+			if err != nil {
+				writeError(w, http.StatusServiceUnavailable, "replay check failed")
+				return
+			}
+			if !fresh {
+				writeError(w, http.StatusConflict, "replayed request")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func signForTest(secret, timestamp, userID string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + userID + "."))
+	mac.Write(body)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequireSyncSignature(t *testing.T) {
+	userID := uuid.New()
+	var gotUser uuid.UUID
+	handler := RequireSyncSignature("secret", 5*time.Minute, NewMemoryNonceStore())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotUser, _ = UserIDFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	body := []byte(`{"items":[]}`)
+	newRequest := func(ts time.Time, signature string) *http.Request {
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		if signature == "" {
+			signature = signForTest("secret", timestamp, userID.String(), body)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/serverpod/transactions", bytes.NewReader(body))
+		req.Header.Set("X-User-Id", userID.String())
+		req.Header.Set("X-Sync-Timestamp", timestamp)
+		req.Header.Set("X-Sync-Signature", signature)
+		return req
+	}
+
+	now := time.Now()
+	req := newRequest(now, "")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("valid request: expected 200, got %d", w.Code)
+	}
+	if gotUser != userID {
+		t.Errorf("user in context = %v, want %v", gotUser, userID)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(now, ""))
+	if w.Code != http.StatusConflict {
+		t.Errorf("replayed request: expected 409, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(now.Add(-10*time.Minute), ""))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("stale request: expected 401, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest(now, "forged"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("forged signature: expected 401, got %d", w.Code)
+	}
+}
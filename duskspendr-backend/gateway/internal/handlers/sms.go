@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/smsparse"
+)
+
+type SMSHandler struct {
+	Parser *smsparse.Registry
+}
+
+// maxSMSBody bounds the SMS text handed to the parser, on /sms/parse and as
+// sms_body on ingest
+const maxSMSBody = 2000
+
+type smsParseInput struct {
+	Sender string `json:"sender"`
+	Body   string `json:"body"`
+}
+
+// Parse extracts a transaction from a bank or wallet SMS
+func (h *SMSHandler) Parse(w http.ResponseWriter, r *http.Request) {
+	var input smsParseInput
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	input.Body = strings.TrimSpace(input.Body)
+	if input.Body == "" {
+		writeError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+	if len(input.Body) > maxSMSBody {
+		writeError(w, http.StatusBadRequest, "body too long")
+		return
+	}
+
+	res, err := h.Parser.Parse(input.Sender, input.Body)
+	if errors.Is(err, smsparse.ErrNoMatch) {
+		writeError(w, http.StatusUnprocessableEntity, "unrecognised sms")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "parse failed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// enrichFromSMS fills fields the client left empty on an ingest item from its
+// raw SMS body. Values sent by the client always win.
+func enrichFromSMS(parser *smsparse.Registry, item models.SyncIngestItem) models.SyncIngestItem {
+	if parser == nil || item.Source != "sms" || item.SMSBody == nil {
+		return item
+	}
+	sender := ""
+	if item.SMSSender != nil {
+		sender = *item.SMSSender
+	}
+	res, err := parser.Parse(sender, *item.SMSBody)
+	if err != nil {
+		return item
+	}
+
+	if item.AmountPaisa == 0 {
+		item.AmountPaisa = res.AmountPaisa
+	}
+	if item.Type == "" {
+		item.Type = res.Type
+	}
+	if item.Category == "" {
+		item.Category = "other"
+	}
+	if item.MerchantName == nil {
+		if res.Merchant != nil {
+			item.MerchantName = res.Merchant
+		} else if res.VPA != nil {
+			item.MerchantName = res.VPA
+		}
+	}
+	if item.ReferenceID == nil {
+		item.ReferenceID = res.Reference
+	}
+	if item.PaymentMethod == nil {
+		item.PaymentMethod = res.PaymentMethod
+	}
+	return item
+}
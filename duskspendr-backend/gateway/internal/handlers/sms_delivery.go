@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/otpdelivery"
+	"duskspendr-gateway/internal/services"
+)
+
+// SMSDeliveryHandler receives delivery reports from OTP providers and
+// serves the delivery status of OTPs
+type SMSDeliveryHandler struct {
+	Pool     *pgxpool.Pool
+	Channels services.OTPChannels
+	// Token is the shared secret in the report URLs configured at the
+	// providers; reports are refused while it is empty
+	Token string
+}
+
+// Report applies the delivery reports a provider posts to
+// /webhooks/sms/{provider}?token=...
+func (h *SMSDeliveryHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if h.Token == "" {
+		writeError(w, http.StatusServiceUnavailable, "delivery reports disabled")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(h.Token)) != 1 {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	name := chi.URLParam(r, "provider")
+	reporter, ok := h.Channels.Reporter(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown sms provider")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	reports, err := reporter.ParseDeliveryReports(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	matched, err := otpdelivery.ApplyReports(r.Context(), h.Pool, name, reports)
+	if err != nil {
+		log.Printf("sms delivery: applying %s reports failed: %v", name, err)
+		writeError(w, http.StatusInternalServerError, "report update failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"received": len(reports), "matched": matched})
+}
+
+// OTPStatus returns the delivery status of an OTP. The id is only known to
+// the client that started the sign-in, so no authentication is required.
+func (h *SMSDeliveryHandler) OTPStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid otp id")
+		return
+	}
+	status, err := otpdelivery.Latest(r.Context(), h.Pool, id.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "status lookup failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
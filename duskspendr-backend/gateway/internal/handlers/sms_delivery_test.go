@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"duskspendr-gateway/internal/services"
+)
+
+func TestSMSDeliveryReportAuth(t *testing.T) {
+	channels := services.OTPChannels{
+		&services.SMSChannel{Sender: services.NewFailoverSender(services.NewLogSender(), services.NewTwilioSender("AC1", "t", "+1555"))},
+		&services.LogChannel{Name: services.ChannelWhatsApp},
+		services.NewTwilioVoiceSender("AC1", "t", "+1555"),
+	}
+	cases := []struct {
+		name     string
+		token    string
+		provider string
+		query    string
+		want     int
+	}{
+		{"disabled", "", "twilio", "?token=", http.StatusServiceUnavailable},
+		{"wrong token", "secret", "twilio", "?token=guess", http.StatusUnauthorized},
+		{"unknown provider", "secret", "msg91", "?token=secret", http.StatusNotFound},
+		{"no reports", "secret", "log", "?token=secret", http.StatusNotFound},
+		{"bad report", "secret", "twilio", "?token=secret", http.StatusBadRequest},
+		{"bad voice report", "secret", "twilio_voice", "?token=secret", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		h := &SMSDeliveryHandler{Channels: channels, Token: tc.token}
+		req := httptest.NewRequest("POST", "/webhooks/sms/"+tc.provider+tc.query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("provider", tc.provider)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rec := httptest.NewRecorder()
+		h.Report(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d (%s)", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+}
+
+func TestStartChannelValidation(t *testing.T) {
+	h := &AuthHandler{Channels: services.OTPChannels{&services.LogChannel{Name: services.ChannelSMS}}}
+	for body, want := range map[string]string{
+		`{"phone":"+919876543210","channel":"fax"}`:   "INVALID_CHANNEL",
+		`{"phone":"+919876543210","channel":"voice"}`: "CHANNEL_UNAVAILABLE",
+	} {
+		rec := httptest.NewRecorder()
+		h.Start(rec, httptest.NewRequest("POST", "/auth/start", strings.NewReader(body)))
+		var resp errorBody
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusBadRequest || resp.Error.Code != want {
+			t.Errorf("%s: %d %s, want %s", body, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/category"
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/smsparse"
+)
+
+func TestIngestAcceptsLongNonASCIIMerchantFromSMS(t *testing.T) {
+	sender := "JD-HDFCBK"
+	body := "Spent Rs.1,299.00 On HDFC Bank Card 9876 At " + strings.Repeat("किराना भंडार ", 10) +
+		"On 2024-01-05:10:20:30.Not You? To Block+Reissue Call 18002586161/SMS BLOCK CC 9876 to 7308080808"
+	item := enrichFromSMS(smsparse.NewRegistry(), models.SyncIngestItem{
+		ID:        uuid.NewString(),
+		Timestamp: time.Now().UTC(),
+		Source:    "sms",
+		SMSSender: &sender,
+		SMSBody:   &body,
+	})
+	if item.MerchantName == nil || !strings.HasPrefix(*item.MerchantName, "किराना") {
+		t.Fatalf("merchant not taken from the SMS: %v", item.MerchantName)
+	}
+	if err := validateIngestItem(item, category.NewSet(category.Category{Key: "other", Name: "Other"})); err != nil {
+		t.Fatalf("enriched item rejected: %v", err)
+	}
+}
+
+func TestIngestRejectsOversizedSMSBody(t *testing.T) {
+	body := strings.Repeat("x", maxSMSBody+1)
+	h := &SyncHandler{SMSParser: smsparse.NewRegistry()}
+	_, err := h.upsertIngestItems(context.Background(), uuid.New(), []models.SyncIngestItem{{
+		ID:        uuid.NewString(),
+		Timestamp: time.Now().UTC(),
+		Source:    "sms",
+		SMSBody:   &body,
+	}}, false)
+	if _, ok := err.(invalidError); !ok {
+		t.Fatalf("err = %v, want invalidError", err)
+	}
+}
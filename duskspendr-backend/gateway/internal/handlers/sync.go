@@ -1,21 +1,34 @@
 package handlers
 
 import (
+  "context"
   "encoding/json"
+  "errors"
   "net/http"
   "strings"
   "time"
 
   "github.com/google/uuid"
+  "github.com/jackc/pgx/v5"
   "github.com/jackc/pgx/v5/pgxpool"
 
-  "duskspendr/gateway/internal/models"
-  "duskspendr/gateway/internal/serverpod"
+  "duskspendr-gateway/internal/analytics"
+  "duskspendr-gateway/internal/anomaly"
+  "duskspendr-gateway/internal/category"
+  "duskspendr-gateway/internal/fx"
+  "duskspendr-gateway/internal/merchant"
+  "duskspendr-gateway/internal/models"
+  "duskspendr-gateway/internal/outbox"
+  "duskspendr-gateway/internal/smsparse"
 )
 
 type SyncHandler struct {
-  Pool   *pgxpool.Pool
-  Client *serverpod.Client
+  Pool       *pgxpool.Pool
+  SMSParser  *smsparse.Registry
+  Merchants  *merchant.Directory
+  MaxRateAge time.Duration
+  Rollups    *analytics.Rollups
+  Anomalies  *anomaly.Runner
 }
 
 func (h *SyncHandler) SyncTransactions(w http.ResponseWriter, r *http.Request) {
@@ -24,25 +37,116 @@ func (h *SyncHandler) SyncTransactions(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "missing user context")
     return
   }
-  if h.Client == nil {
-    writeError(w, http.StatusServiceUnavailable, "serverpod unavailable")
-    return
-  }
 
-  payload, err := h.loadTransactions(r, userID.String())
+  items, err := h.loadTransactions(r, userID.String())
   if err != nil {
     writeError(w, http.StatusInternalServerError, "failed to load transactions")
     return
   }
-  ok = h.Client.SyncTransactions(r.Context(), userID.String(), payload)
+  if err := outbox.Enqueue(r.Context(), h.Pool, outbox.Event{
+    UserID: userID.String(),
+    Type:   outbox.EventTransactionsSnapshot,
+    Data:   map[string]any{"items": items},
+  }); err != nil {
+    writeError(w, http.StatusInternalServerError, "failed to queue sync")
+    return
+  }
+  writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
+
+// Status reports outbox deliveries for the current user that have not
+// reached serverpod yet, including the most recent failures.
+func (h *SyncHandler) Status(w http.ResponseWriter, r *http.Request) {
+  userID, ok := UserIDFromContext(r.Context())
   if !ok {
-    writeError(w, http.StatusBadGateway, "sync failed")
+    writeError(w, http.StatusBadRequest, "missing user context")
+    return
+  }
+
+  counts := map[string]int64{
+    outbox.StatusPending:   0,
+    outbox.StatusDelivered: 0,
+    outbox.StatusFailed:    0,
+  }
+  rows, err := h.Pool.Query(r.Context(), `
+    SELECT status, COUNT(*)
+      FROM sync_outbox
+     WHERE user_id = $1
+     GROUP BY status
+  `, userID)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "query failed")
     return
   }
-  writeJSON(w, http.StatusOK, map[string]string{"status": "queued"})
+  for rows.Next() {
+    var status string
+    var n int64
+    if err := rows.Scan(&status, &n); err != nil {
+      rows.Close()
+      writeError(w, http.StatusInternalServerError, "scan failed")
+      return
+    }
+    counts[status] = n
+  }
+  rows.Close()
+
+  type delivery struct {
+    ID            string     `json:"id"`
+    EventType     string     `json:"event_type"`
+    Status        string     `json:"status"`
+    Attempts      int        `json:"attempts"`
+    NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+    LastError     *string    `json:"last_error,omitempty"`
+    CreatedAt     time.Time  `json:"created_at"`
+  }
+
+  rows, err = h.Pool.Query(r.Context(), `
+    SELECT id, event_type, status, attempts, next_attempt_at, last_error, created_at
+      FROM sync_outbox
+     WHERE user_id = $1 AND status <> $2
+     ORDER BY created_at DESC
+     LIMIT 50
+  `, userID, outbox.StatusDelivered)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "query failed")
+    return
+  }
+  defer rows.Close()
+
+  items := []delivery{}
+  for rows.Next() {
+    var d delivery
+    var next time.Time
+    if err := rows.Scan(&d.ID, &d.EventType, &d.Status, &d.Attempts, &next, &d.LastError, &d.CreatedAt); err != nil {
+      writeError(w, http.StatusInternalServerError, "scan failed")
+      return
+    }
+    if d.Status == outbox.StatusPending {
+      d.NextAttemptAt = &next
+    }
+    items = append(items, d)
+  }
+
+  writeJSON(w, http.StatusOK, map[string]any{
+    "pending":   counts[outbox.StatusPending],
+    "failed":    counts[outbox.StatusFailed],
+    "delivered": counts[outbox.StatusDelivered],
+    "items":     items,
+  })
 }
 
 func (h *SyncHandler) IngestTransactions(w http.ResponseWriter, r *http.Request) {
+  h.ingestRequest(w, r, true)
+}
+
+// ServerpodPush accepts server-originated transaction updates from serverpod.
+// It runs behind RequireSyncSignature and shares the ingest upsert path, but
+// does not queue the items back to serverpod.
+func (h *SyncHandler) ServerpodPush(w http.ResponseWriter, r *http.Request) {
+  h.ingestRequest(w, r, false)
+}
+
+func (h *SyncHandler) ingestRequest(w http.ResponseWriter, r *http.Request, emitSync bool) {
   userID, ok := UserIDFromContext(r.Context())
   if !ok {
     writeError(w, http.StatusBadRequest, "missing user context")
@@ -57,50 +161,108 @@ func (h *SyncHandler) IngestTransactions(w http.ResponseWriter, r *http.Request)
     return
   }
 
-  if len(input.Items) == 0 {
-    writeJSON(w, http.StatusOK, map[string]any{"inserted": 0})
+  inserted, err := h.upsertIngestItems(r.Context(), userID, input.Items, emitSync)
+  if err != nil {
+    if _, ok := err.(invalidError); ok {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    writeError(w, http.StatusInternalServerError, "insert failed")
     return
   }
-  if len(input.Items) > 500 {
-    writeError(w, http.StatusBadRequest, "too many items")
-    return
+
+  writeJSON(w, http.StatusOK, map[string]any{"inserted": inserted})
+}
+
+// upsertIngestItems validates and upserts items in a single transaction.
+// Validation failures are returned as invalidError.
+func (h *SyncHandler) upsertIngestItems(ctx context.Context, userID uuid.UUID, items []models.SyncIngestItem, emitSync bool) (int, error) {
+  if len(items) == 0 {
+    return 0, nil
+  }
+  if len(items) > 500 {
+    return 0, errInvalid("too many items")
   }
 
-  now := time.Now().UTC()
-  inserted := 0
+  needMerchants := false
+  for i := range items {
+    if items[i].SMSBody != nil && len(*items[i].SMSBody) > maxSMSBody {
+      return 0, errInvalid("sms_body too long")
+    }
+    items[i] = enrichFromSMS(h.SMSParser, items[i])
+    if err := enrichIngestUPI(&items[i]); err != nil {
+      return 0, err
+    }
+    needMerchants = needMerchants || items[i].MerchantName != nil
+  }
 
-  for _, item := range input.Items {
-    if err := validateIngestItem(item); err != nil {
-      writeError(w, http.StatusBadRequest, err.Error())
-      return
+  overrides, err := loadMerchantOverrides(ctx, h.Pool, userID.String(), needMerchants)
+  if err != nil {
+    return 0, err
+  }
+  rawMerchants := make([]*string, len(items))
+  for i := range items {
+    rawMerchants[i] = normalizeMerchant(h.Merchants, overrides, &items[i].MerchantName, &items[i].Category)
+  }
+  convs, err := h.convertIngestCurrencies(ctx, userID.String(), items)
+  if err != nil {
+    return 0, err
+  }
+
+  categories, err := category.Load(ctx, h.Pool, userID.String())
+  if err != nil {
+    return 0, err
+  }
+  for _, item := range items {
+    if err := validateIngestItem(item, categories); err != nil {
+      return 0, err
     }
     if _, err := uuid.Parse(item.ID); err != nil {
-      writeError(w, http.StatusBadRequest, "invalid id")
-      return
+      return 0, errInvalid("invalid id")
     }
   }
 
-  for _, item := range input.Items {
+  now := time.Now().UTC()
+
+  tx, err := h.Pool.Begin(ctx)
+  if err != nil {
+    return 0, err
+  }
+  defer tx.Rollback(ctx)
+
+  ids := make([]string, len(items))
+  for i, item := range items {
+    ids[i] = item.ID
+  }
+  if err := h.Rollups.Remove(ctx, tx, userID.String(), ids); err != nil {
+    return 0, err
+  }
+  // written holds only rows the upsert touched; an id owned by another
+  // user is skipped by the conflict guard and must not be synced.
+  written := make([]models.Transaction, 0, len(items))
+  for i, item := range items {
     tagsBytes, _ := json.Marshal(normalizeTags(item.Tags))
+    fxRate, fxRateDate := fxColumns(convs[i])
 
     var linkedAccountID *string
     if item.LinkedAccountID != nil && strings.TrimSpace(*item.LinkedAccountID) != "" {
       val := strings.TrimSpace(*item.LinkedAccountID)
       if _, err := uuid.Parse(val); err != nil {
-        writeError(w, http.StatusBadRequest, "invalid linked_account_id")
-        return
+        return 0, errInvalid("invalid linked_account_id")
       }
       linkedAccountID = &val
     }
 
-    cmd, err := h.Pool.Exec(r.Context(), `
+    t, err := scanTransaction(tx.QueryRow(ctx, `
       INSERT INTO transactions (
         id, user_id, amount_paisa, type, category, merchant_name, description,
         timestamp, source, payment_method, linked_account_id, reference_id,
         category_confidence, is_recurring, is_shared, tags, notes,
-        created_at, updated_at
+        counterparty_vpa, upi_kind, merchant_raw, original_currency,
+        original_amount_minor, fx_rate, fx_rate_date, created_at, updated_at
       ) VALUES (
-        $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19
+        $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,
+        $21,$22,$23,$24,$25,$26
       )
       ON CONFLICT (id) DO UPDATE SET
         amount_paisa = EXCLUDED.amount_paisa,
@@ -118,9 +280,16 @@ func (h *SyncHandler) IngestTransactions(w http.ResponseWriter, r *http.Request)
         is_shared = EXCLUDED.is_shared,
         tags = EXCLUDED.tags,
         notes = EXCLUDED.notes,
+        counterparty_vpa = EXCLUDED.counterparty_vpa,
+        upi_kind = EXCLUDED.upi_kind,
+        merchant_raw = EXCLUDED.merchant_raw,
+        original_currency = EXCLUDED.original_currency,
+        original_amount_minor = EXCLUDED.original_amount_minor,
+        fx_rate = EXCLUDED.fx_rate,
+        fx_rate_date = EXCLUDED.fx_rate_date,
         updated_at = EXCLUDED.updated_at
       WHERE transactions.user_id = EXCLUDED.user_id
-    `,
+      RETURNING `+transactionColumns,
       item.ID,
       userID,
       item.AmountPaisa,
@@ -138,22 +307,53 @@ func (h *SyncHandler) IngestTransactions(w http.ResponseWriter, r *http.Request)
       item.IsShared,
       tagsBytes,
       item.Notes,
+      item.CounterpartyVPA,
+      item.UPIKind,
+      rawMerchants[i],
+      item.OriginalCurrency,
+      item.OriginalAmountMinor,
+      fxRate,
+      fxRateDate,
       now,
       now,
-    )
+    ))
+    if errors.Is(err, pgx.ErrNoRows) {
+      continue
+    }
     if err != nil {
-      writeError(w, http.StatusInternalServerError, "insert failed")
-      return
+      return 0, err
     }
-    if cmd.RowsAffected() > 0 {
-      inserted++
+    written = append(written, t)
+  }
+  if err := h.Rollups.Add(ctx, tx, userID.String(), ids); err != nil {
+    return 0, err
+  }
+
+  if emitSync {
+    if err := enqueueTransactionUpsert(ctx, tx, userID.String(), written); err != nil {
+      return 0, err
     }
   }
+  if err := tx.Commit(ctx); err != nil {
+    return 0, err
+  }
+  h.Anomalies.Go(userID.String(), ids)
+  return len(written), nil
+}
 
-  writeJSON(w, http.StatusOK, map[string]any{"inserted": inserted})
+type syncTransaction struct {
+  ID           string   `json:"id"`
+  AmountPaisa  int64    `json:"amount_paisa"`
+  Type         string   `json:"type"`
+  Category     string   `json:"category"`
+  MerchantName *string  `json:"merchant_name,omitempty"`
+  Description  *string  `json:"description,omitempty"`
+  Timestamp    string   `json:"timestamp"`
+  Source       string   `json:"source"`
+  Tags         []string `json:"tags,omitempty"`
 }
 
-func (h *SyncHandler) loadTransactions(r *http.Request, userID string) ([]byte, error) {
+func (h *SyncHandler) loadTransactions(r *http.Request, userID string) ([]syncTransaction, error) {
   rows, err := h.Pool.Query(r.Context(), `
     SELECT id, amount_paisa, type, category, merchant_name, description, timestamp, source
       FROM transactions
@@ -166,20 +366,9 @@ func (h *SyncHandler) loadTransactions(r *http.Request, userID string) ([]byte,
   }
   defer rows.Close()
 
-  type tx struct {
-    ID           string  `json:"id"`
-    AmountPaisa  int64   `json:"amount_paisa"`
-    Type         string  `json:"type"`
-    Category     string  `json:"category"`
-    MerchantName *string `json:"merchant_name,omitempty"`
-    Description  *string `json:"description,omitempty"`
-    Timestamp    string  `json:"timestamp"`
-    Source       string  `json:"source"`
-  }
-
-  items := []tx{}
+  items := []syncTransaction{}
   for rows.Next() {
-    var item tx
+    var item syncTransaction
     var ts time.Time
     if err := rows.Scan(
       &item.ID,
@@ -197,12 +386,58 @@ func (h *SyncHandler) loadTransactions(r *http.Request, userID string) ([]byte,
     items = append(items, item)
   }
 
-  return json.Marshal(map[string]any{
-    "items": items,
+  return items, rows.Err()
+}
+
+// convertIngestCurrencies converts foreign-currency items into the user's
+// base currency, returning the conversion used for each item.
+func (h *SyncHandler) convertIngestCurrencies(ctx context.Context, userID string, items []models.SyncIngestItem) ([]*fx.Conversion, error) {
+  convs := make([]*fx.Conversion, len(items))
+  var base string
+  var rates *rateCache
+  for i := range items {
+    item := &items[i]
+    if item.OriginalCurrency == nil && item.OriginalAmountMinor == nil {
+      continue
+    }
+    if rates == nil {
+      var err error
+      if base, err = loadBaseCurrency(ctx, h.Pool, userID); err != nil {
+        return nil, err
+      }
+      rates = newRateCache(h.Pool, h.MaxRateAge)
+    }
+    conv, err := convertCurrency(ctx, rates, base, currencyFields{
+      AmountPaisa:         &item.AmountPaisa,
+      OriginalCurrency:    &item.OriginalCurrency,
+      OriginalAmountMinor: &item.OriginalAmountMinor,
+      Timestamp:           item.Timestamp,
+    })
+    if err != nil {
+      return nil, err
+    }
+    convs[i] = conv
+  }
+  return convs, nil
+}
+
+func enrichIngestUPI(item *models.SyncIngestItem) error {
+  return enrichUPI(upiFields{
+    Source:          item.Source,
+    Category:        &item.Category,
+    MerchantName:    &item.MerchantName,
+    Description:     item.Description,
+    Notes:           item.Notes,
+    PaymentMethod:   &item.PaymentMethod,
+    ReferenceID:     &item.ReferenceID,
+    CounterpartyVPA: &item.CounterpartyVPA,
+    UPIKind:         &item.UPIKind,
   })
 }
 
-func validateIngestItem(input models.SyncIngestItem) error {
+// validateIngestItem checks an item before it is written; categories is the
+// user's category set.
+func validateIngestItem(input models.SyncIngestItem, categories category.Set) error {
   if input.ID == "" {
     return errInvalid("id is required")
   }
@@ -224,7 +459,7 @@ func validateIngestItem(input models.SyncIngestItem) error {
   if input.Timestamp.IsZero() {
     return errInvalid("timestamp is required")
   }
-  if err := validateIngestEnums(input); err != nil {
+  if err := validateIngestEnums(input, categories); err != nil {
     return err
   }
   if err := validateIngestStrings(input); err != nil {
@@ -244,7 +479,7 @@ func validateIngestItem(input models.SyncIngestItem) error {
   return nil
 }
 
-func validateIngestEnums(input models.SyncIngestItem) error {
+func validateIngestEnums(input models.SyncIngestItem, categories category.Set) error {
   allowedTypes := map[string]bool{"debit": true, "credit": true}
   allowedSources := map[string]bool{
     "manual": true,
@@ -253,21 +488,6 @@ func validateIngestEnums(input models.SyncIngestItem) error {
     "bankApi": true,
     "imported": true,
   }
-  allowedCategories := map[string]bool{
-    "food": true,
-    "transportation": true,
-    "entertainment": true,
-    "education": true,
-    "shopping": true,
-    "utilities": true,
-    "healthcare": true,
-    "subscriptions": true,
-    "investments": true,
-    "loans": true,
-    "shared": true,
-    "pocketMoney": true,
-    "other": true,
-  }
   allowedPayments := map[string]bool{
     "upi": true,
     "card": true,
@@ -283,7 +503,7 @@ func validateIngestEnums(input models.SyncIngestItem) error {
   if !allowedSources[input.Source] {
     return errInvalid("invalid source")
   }
-  if !allowedCategories[input.Category] {
+  if !categories.Has(input.Category) {
     return errInvalid("invalid category")
   }
   if input.PaymentMethod != nil && *input.PaymentMethod != "" {
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/models"
+)
+
+type TagHandler struct {
+	Pool *pgxpool.Pool
+}
+
+type tagUsage struct {
+	Tag              string     `json:"tag"`
+	TransactionCount int64      `json:"transaction_count"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+}
+
+// List returns the user's tags with how many transactions carry each
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT tag, count(*), max(t.timestamp)
+		  FROM transactions t, jsonb_array_elements_text(t.tags) AS tag
+		 WHERE t.user_id = $1
+		 GROUP BY tag
+		 ORDER BY count(*) DESC, tag
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []tagUsage{}
+	for rows.Next() {
+		var u tagUsage
+		if err := rows.Scan(&u.Tag, &u.TransactionCount, &u.LastUsedAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, u)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+type tagSpend struct {
+	Tag              string `json:"tag"`
+	TransactionCount int64  `json:"transaction_count"`
+	SpentPaisa       int64  `json:"spent_paisa"`
+	ReceivedPaisa    int64  `json:"received_paisa"`
+}
+
+// Spend returns debit and credit totals per tag over ?from=&to= (RFC 3339,
+// both optional), in the user's base currency.
+func (h *TagHandler) Spend(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), `
+		SELECT tag,
+		       count(*),
+		       coalesce(sum(t.amount_paisa) FILTER (WHERE t.type = 'debit'), 0),
+		       coalesce(sum(t.amount_paisa) FILTER (WHERE t.type = 'credit'), 0)
+		  FROM transactions t, jsonb_array_elements_text(t.tags) AS tag
+		 WHERE t.user_id = $1
+		   AND t.tags <> '[]'::jsonb
+		   AND ($2::timestamptz IS NULL OR t.timestamp >= $2)
+		   AND ($3::timestamptz IS NULL OR t.timestamp < $3)
+		 GROUP BY tag
+		 ORDER BY 3 DESC, tag
+	`, userID, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	defer rows.Close()
+
+	items := []tagSpend{}
+	for rows.Next() {
+		var s tagSpend
+		if err := rows.Scan(&s.Tag, &s.TransactionCount, &s.SpentPaisa, &s.ReceivedPaisa); err != nil {
+			writeError(w, http.StatusInternalServerError, "scan failed")
+			return
+		}
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// Rename renames a tag on all of the user's transactions:
+// {"from": "trip", "to": "travel"}. Renaming onto an existing tag merges them.
+func (h *TagHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	h.rewrite(w, r, []string{input.From}, input.To)
+}
+
+// Merge replaces several tags with one on all of the user's transactions:
+// {"from": ["trip", "vacation"], "into": "travel"}.
+func (h *TagHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		From []string `json:"from"`
+		Into string   `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	h.rewrite(w, r, input.From, input.Into)
+}
+
+// rewrite replaces every tag in from with to in a single UPDATE, keeping
+// each transaction's tag order and dropping the duplicates a merge creates.
+func (h *TagHandler) rewrite(w http.ResponseWriter, r *http.Request, from []string, to string) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusBadRequest, "missing user context")
+		return
+	}
+	to = strings.TrimSpace(to)
+	if err := validateIngestTags([]string{to}); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(from) == 0 || len(from) > 50 {
+		writeError(w, http.StatusBadRequest, "from must list 1 to 50 tags")
+		return
+	}
+	for i, tag := range from {
+		from[i] = strings.TrimSpace(tag)
+		if err := validateIngestTags(from[i : i+1]); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	rows, err := tx.Query(r.Context(), `
+		UPDATE transactions t
+		   SET tags = (
+		         SELECT coalesce(jsonb_agg(x.tag ORDER BY x.pos), '[]'::jsonb)
+		           FROM (
+		             SELECT CASE WHEN e.tag = ANY($2) THEN $3 ELSE e.tag END AS tag,
+		                    min(e.pos) AS pos
+		               FROM jsonb_array_elements_text(t.tags) WITH ORDINALITY AS e(tag, pos)
+		              GROUP BY 1
+		           ) x
+		       ),
+		       updated_at = now()
+		 WHERE t.user_id = $1 AND t.tags ?| $2
+		RETURNING `+transactionColumns, userID, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	items := []models.Transaction{}
+	for rows.Next() {
+		item, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			writeError(w, http.StatusInternalServerError, "update failed")
+			return
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+
+	if err := enqueueTransactionUpsert(r.Context(), tx, userID.String(), items); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "update failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tag": to, "updated": len(items)})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTagRewriteValidation(t *testing.T) {
+	h := &TagHandler{}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"empty target", h.Rename, `{"from":"trip","to":"  "}`},
+		{"target too long", h.Rename, `{"from":"trip","to":"` + string(bytes.Repeat([]byte("x"), 33)) + `"}`},
+		{"empty source", h.Rename, `{"from":"","to":"travel"}`},
+		{"no sources", h.Merge, `{"from":[],"into":"travel"}`},
+		{"blank source", h.Merge, `{"from":["trip","  "],"into":"travel"}`},
+		{"source too long", h.Merge, `{"from":["` + string(bytes.Repeat([]byte("x"), 33)) + `"],"into":"travel"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/tags/rename", bytes.NewBufferString(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey, uuid.New()))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, req)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
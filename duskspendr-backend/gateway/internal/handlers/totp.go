@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/config"
+	"duskspendr-gateway/internal/models"
+	"duskspendr-gateway/internal/totp"
+)
+
+// totpLockout is how long TOTP verification stays locked after
+// OTPMaxAttempts failures in a row
+const totpLockout = 15 * time.Minute
+
+// newTOTPCipher seals secrets with TOTP_ENCRYPTION_KEY, or outside
+// production with a key derived from AUTH_PEPPER. Nil disables enrollment.
+func newTOTPCipher(cfg config.Config) *totp.Cipher {
+	var c *totp.Cipher
+	var err error
+	switch {
+	case cfg.TOTPEncryptionKey != "":
+		c, err = totp.NewCipher(cfg.TOTPEncryptionKey)
+	case cfg.AuthPepper != "":
+		c, err = totp.DerivedCipher(cfg.AuthPepper)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Printf("auth: totp disabled: %v", err)
+		return nil
+	}
+	return c
+}
+
+// TOTPStatus reports whether the user has TOTP enabled and how many
+// recovery codes are left
+func (h *AuthHandler) TOTPStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	enr, err := totp.Load(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, totp.ErrNotEnrolled) {
+		writeJSON(w, http.StatusOK, map[string]any{"enabled": false})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load totp")
+		return
+	}
+	remaining, err := totp.RemainingRecoveryCodes(r.Context(), h.Pool, userID.String())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load totp")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":                  enr.Confirmed(),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTOTP creates a pending secret. It only takes effect once
+// ConfirmTOTP proves the authenticator app produces matching codes.
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	if h.TOTP == nil {
+		writeErrorCode(w, http.StatusServiceUnavailable, "TOTP_DISABLED", "totp is not configured")
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "secret generation failed")
+		return
+	}
+	sealed, err := h.TOTP.Seal(userID.String(), secret)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "secret generation failed")
+		return
+	}
+	err = totp.Begin(r.Context(), h.Pool, userID.String(), sealed)
+	if errors.Is(err, totp.ErrAlreadyEnrolled) {
+		writeErrorCode(w, http.StatusConflict, "TOTP_ALREADY_ENABLED", "totp is already enabled")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to store secret")
+		return
+	}
+
+	var phone string
+	_ = h.Pool.QueryRow(r.Context(), `SELECT phone FROM users WHERE id = $1`, userID).Scan(&phone)
+	writeJSON(w, http.StatusOK, models.TOTPEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.Config.TOTPIssuer, phone, secret),
+		Digits:     totp.Digits,
+		Period:     int(totp.Period / time.Second),
+	})
+}
+
+// ConfirmTOTP enables a pending secret given a current code and returns the
+// recovery codes, which are never shown again
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	var input models.TOTPCodeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || strings.TrimSpace(input.Code) == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+	enr, err := totp.Load(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, totp.ErrNotEnrolled) {
+		writeErrorCode(w, http.StatusConflict, "TOTP_NOT_ENROLLED", "start enrollment first")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load totp")
+		return
+	}
+	if enr.Confirmed() {
+		writeErrorCode(w, http.StatusConflict, "TOTP_ALREADY_ENABLED", "totp is already enabled")
+		return
+	}
+
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to enable totp")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	if !h.checkTOTP(w, r, tx, userID, enr, input.Code, true) {
+		return
+	}
+	codes, ok := h.newRecoveryCodes(w, r, tx, userID)
+	if !ok {
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to enable totp")
+		return
+	}
+	writeJSON(w, http.StatusOK, models.TOTPRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. Requires step-up.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	enr, err := totp.Load(r.Context(), h.Pool, userID.String())
+	if errors.Is(err, totp.ErrNotEnrolled) || (err == nil && !enr.Confirmed()) {
+		writeErrorCode(w, http.StatusConflict, "TOTP_NOT_ENROLLED", "totp is not enabled")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load totp")
+		return
+	}
+	codes, ok := h.newRecoveryCodes(w, r, h.Pool, userID)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, models.TOTPRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP removes the secret and recovery codes. Requires step-up.
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	tx, err := h.Pool.Begin(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to disable totp")
+		return
+	}
+	defer tx.Rollback(r.Context())
+	if err := totp.Disable(r.Context(), tx, userID.String()); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to disable totp")
+		return
+	}
+	if err := tx.Commit(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to disable totp")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
+}
+
+// StepUp proves a second factor and issues a short-lived access token with
+// the elevated claim that RequireStepUp checks. Users with TOTP give a TOTP
+// or recovery code; users without it give the code sent by /auth/start.
+func (h *AuthHandler) StepUp(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	var input models.StepUpInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	input.Code = strings.TrimSpace(input.Code)
+	input.RecoveryCode = strings.TrimSpace(input.RecoveryCode)
+	if input.Code == "" && input.RecoveryCode == "" {
+		writeError(w, http.StatusBadRequest, "code or recovery_code is required")
+		return
+	}
+
+	var email *string
+	var phone string
+	if err := h.Pool.QueryRow(r.Context(), `SELECT email, phone FROM users WHERE id = $1`, userID).Scan(&email, &phone); err != nil {
+		writeError(w, http.StatusInternalServerError, "user lookup failed")
+		return
+	}
+
+	enr, err := totp.Load(r.Context(), h.Pool, userID.String())
+	switch {
+	case err == nil && enr.Confirmed():
+		if input.RecoveryCode != "" {
+			ok = h.checkRecoveryCode(w, r, userID, enr, input.RecoveryCode)
+		} else {
+			ok = h.checkTOTP(w, r, h.Pool, userID, enr, input.Code, false)
+		}
+	case err == nil || errors.Is(err, totp.ErrNotEnrolled):
+		if input.Code == "" {
+			writeErrorCode(w, http.StatusBadRequest, "TOTP_NOT_ENROLLED", "totp is not enabled; use the code sent by /auth/start")
+			return
+		}
+		var otpUser uuid.UUID
+		otpUser, ok = h.consumeOTP(w, r, phone, input.Code)
+		if ok && otpUser != userID {
+			writeErrorCode(w, http.StatusUnauthorized, "INVALID_CODE", "invalid code")
+			return
+		}
+	default:
+		writeError(w, http.StatusInternalServerError, "failed to load totp")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	familyID := ""
+	if claims := ClaimsFromContext(r.Context()); claims != nil {
+		familyID = claims.FamilyID
+	}
+	userEmail := ""
+	if email != nil {
+		userEmail = *email
+	}
+	token, until, err := h.JWT.GenerateElevatedAccessToken(userID.String(), userEmail, phone, familyID, h.Config.StepUpTTL)
+	if err != nil {
+		writeErrorCode(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+		return
+	}
+	writeJSON(w, http.StatusOK, models.StepUpResponse{
+		AccessToken:   token,
+		ExpiresIn:     int(time.Until(until).Seconds()),
+		ElevatedUntil: until.UTC(),
+	})
+}
+
+// checkTOTP verifies code against the user's secret and records the step.
+// Failures count towards the lockout. On failure the error response has
+// already been written.
+func (h *AuthHandler) checkTOTP(w http.ResponseWriter, r *http.Request, q totp.Querier, userID uuid.UUID, enr *totp.Enrollment, code string, confirm bool) bool {
+	if !h.totpUnlocked(w, enr) {
+		return false
+	}
+	if h.TOTP == nil {
+		writeErrorCode(w, http.StatusServiceUnavailable, "TOTP_DISABLED", "totp is not configured")
+		return false
+	}
+	secret, err := h.TOTP.Open(userID.String(), enr.Sealed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to read totp secret")
+		return false
+	}
+	step, ok := totp.Verify(secret, code, time.Now(), enr.LastStep)
+	if ok {
+		err = totp.Use(r.Context(), q, userID.String(), step, confirm)
+	}
+	if !ok || errors.Is(err, totp.ErrInvalidCode) {
+		h.totpFailed(r, userID)
+		writeErrorCode(w, http.StatusUnauthorized, "INVALID_CODE", "invalid code")
+		return false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to record totp use")
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) checkRecoveryCode(w http.ResponseWriter, r *http.Request, userID uuid.UUID, enr *totp.Enrollment, code string) bool {
+	if !h.totpUnlocked(w, enr) {
+		return false
+	}
+	hash := totp.HashRecoveryCode(h.Config.AuthPepper, userID.String(), code)
+	err := totp.UseRecoveryCode(r.Context(), h.Pool, userID.String(), hash)
+	if errors.Is(err, totp.ErrInvalidCode) {
+		h.totpFailed(r, userID)
+		writeErrorCode(w, http.StatusUnauthorized, "INVALID_CODE", "invalid recovery code")
+		return false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to use recovery code")
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) totpUnlocked(w http.ResponseWriter, enr *totp.Enrollment) bool {
+	if enr.LockedUntil != nil && time.Now().Before(*enr.LockedUntil) {
+		writeErrorCode(w, http.StatusTooManyRequests, "TOTP_LOCKED", "too many failed attempts; try again later")
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) totpFailed(r *http.Request, userID uuid.UUID) {
+	if err := totp.Fail(r.Context(), h.Pool, userID.String(), h.Config.OTPMaxAttempts, totpLockout); err != nil {
+		log.Printf("auth: recording totp failure failed: %v", err)
+	}
+}
+
+func (h *AuthHandler) newRecoveryCodes(w http.ResponseWriter, r *http.Request, q totp.Querier, userID uuid.UUID) ([]string, bool) {
+	codes, err := totp.NewRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "recovery code generation failed")
+		return nil, false
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = totp.HashRecoveryCode(h.Config.AuthPepper, userID.String(), c)
+	}
+	if err := totp.ReplaceRecoveryCodes(r.Context(), q, userID.String(), hashes); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to store recovery codes")
+		return nil, false
+	}
+	return codes, true
+}
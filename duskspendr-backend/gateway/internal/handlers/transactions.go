@@ -1,7 +1,9 @@
 package handlers
 
 import (
+  "context"
   "encoding/json"
+  "errors"
   "net/http"
   "strconv"
   "strings"
@@ -9,13 +11,23 @@ import (
 
   "github.com/go-chi/chi/v5"
   "github.com/google/uuid"
-  "github.com/jackc/pgx/v5/pgxpool"
-
-  "duskspendr/gateway/internal/models"
+  "github.com/jackc/pgx/v5"
+
+  "duskspendr-gateway/internal/analytics"
+  "duskspendr-gateway/internal/anomaly"
+  "duskspendr-gateway/internal/category"
+  "duskspendr-gateway/internal/fx"
+  "duskspendr-gateway/internal/merchant"
+  "duskspendr-gateway/internal/models"
+  "duskspendr-gateway/internal/outbox"
 )
 
 type TransactionHandler struct {
-  Pool DBPool
+  Pool       DBPool
+  Merchants  *merchant.Directory
+  MaxRateAge time.Duration
+  Rollups    *analytics.Rollups
+  Anomalies  *anomaly.Runner
 }
 
 func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -43,19 +55,28 @@ func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
     }
   }
 
-  sql := `
-    SELECT id, user_id, amount_paisa, type, category, merchant_name, description,
-           timestamp, source, payment_method, linked_account_id, reference_id,
-           category_confidence, is_recurring, is_shared, tags, notes,
-           created_at, updated_at
+  sql := `SELECT ` + transactionColumns + `
       FROM transactions
      WHERE user_id = $1`
 
   args := []any{userID}
   idx := 2
   if categoryFilter != "" {
-    sql += " AND category = $" + strconv.Itoa(idx)
-    args = append(args, categoryFilter)
+    // A top-level category also matches its subcategories
+    categories, err := category.Load(r.Context(), h.Pool, userID.String())
+    if err != nil {
+      writeError(w, http.StatusInternalServerError, "query failed")
+      return
+    }
+    sql += " AND category = ANY($" + strconv.Itoa(idx) + ")"
+    args = append(args, categories.WithChildren(categoryFilter))
+    idx++
+  }
+  // ?tag= may repeat; a transaction must carry every tag given
+  if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+    tagsJSON, _ := json.Marshal(tags)
+    sql += " AND tags @> $" + strconv.Itoa(idx) + "::jsonb"
+    args = append(args, string(tagsJSON))
     idx++
   }
   if queryText != "" {
@@ -80,33 +101,11 @@ func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
 
   items := make([]models.Transaction, 0, limit)
   for rows.Next() {
-    var t models.Transaction
-    var tagsRaw []byte
-    if err := rows.Scan(
-      &t.ID,
-      &t.UserID,
-      &t.AmountPaisa,
-      &t.Type,
-      &t.Category,
-      &t.MerchantName,
-      &t.Description,
-      &t.Timestamp,
-      &t.Source,
-      &t.PaymentMethod,
-      &t.LinkedAccountID,
-      &t.ReferenceID,
-      &t.CategoryConfidence,
-      &t.IsRecurring,
-      &t.IsShared,
-      &tagsRaw,
-      &t.Notes,
-      &t.CreatedAt,
-      &t.UpdatedAt,
-    ); err != nil {
+    t, err := scanTransaction(rows)
+    if err != nil {
       writeError(w, http.StatusInternalServerError, "scan failed")
       return
     }
-    t.Tags = decodeTags(tagsRaw)
     items = append(items, t)
   }
 
@@ -136,41 +135,15 @@ func (h *TransactionHandler) Get(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  var t models.Transaction
-  var tagsRaw []byte
-  err := h.Pool.QueryRow(r.Context(), `
-    SELECT id, user_id, amount_paisa, type, category, merchant_name, description,
-           timestamp, source, payment_method, linked_account_id, reference_id,
-           category_confidence, is_recurring, is_shared, tags, notes,
-           created_at, updated_at
+  t, err := scanTransaction(h.Pool.QueryRow(r.Context(), `
+    SELECT `+transactionColumns+`
       FROM transactions
      WHERE user_id = $1 AND id = $2
-  `, userID, id).Scan(
-    &t.ID,
-    &t.UserID,
-    &t.AmountPaisa,
-    &t.Type,
-    &t.Category,
-    &t.MerchantName,
-    &t.Description,
-    &t.Timestamp,
-    &t.Source,
-    &t.PaymentMethod,
-    &t.LinkedAccountID,
-    &t.ReferenceID,
-    &t.CategoryConfidence,
-    &t.IsRecurring,
-    &t.IsShared,
-    &tagsRaw,
-    &t.Notes,
-    &t.CreatedAt,
-    &t.UpdatedAt,
-  )
+  `, userID, id))
   if err != nil {
     writeError(w, http.StatusNotFound, "not found")
     return
   }
-  t.Tags = decodeTags(tagsRaw)
 
   writeJSON(w, http.StatusOK, t)
 }
@@ -187,7 +160,31 @@ func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "invalid json")
     return
   }
-  if err := validateTransactionInput(input); err != nil {
+  if err := enrichTransactionUPI(&input); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  merchantRaw, err := h.normalizeMerchant(r.Context(), userID.String(), &input)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  conv, err := h.convertCurrency(r.Context(), userID.String(), &input)
+  if err != nil {
+    if _, ok := err.(invalidError); ok {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  fxRate, fxRateDate := fxColumns(conv)
+  categories, err := category.Load(r.Context(), h.Pool, userID.String())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  if err := validateTransactionInput(input, categories); err != nil {
     writeError(w, http.StatusBadRequest, err.Error())
     return
   }
@@ -196,16 +193,25 @@ func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
   id := uuid.New().String()
   tagsBytes, _ := json.Marshal(normalizeTags(input.Tags))
 
-  _, err := h.Pool.Exec(r.Context(), `
+  tx, err := h.Pool.Begin(r.Context())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  defer tx.Rollback(r.Context())
+
+  created, err := scanTransaction(tx.QueryRow(r.Context(), `
     INSERT INTO transactions (
       id, user_id, amount_paisa, type, category, merchant_name, description,
       timestamp, source, payment_method, linked_account_id, reference_id,
       category_confidence, is_recurring, is_shared, tags, notes,
-      created_at, updated_at
+      counterparty_vpa, upi_kind, merchant_raw, original_currency,
+      original_amount_minor, fx_rate, fx_rate_date, created_at, updated_at
     ) VALUES (
-      $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19
+      $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,
+      $21,$22,$23,$24,$25,$26
     )
-  `,
+    RETURNING `+transactionColumns,
     id,
     userID,
     input.AmountPaisa,
@@ -223,13 +229,33 @@ func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
     input.IsShared,
     tagsBytes,
     input.Notes,
+    input.CounterpartyVPA,
+    input.UPIKind,
+    merchantRaw,
+    input.OriginalCurrency,
+    input.OriginalAmountMinor,
+    fxRate,
+    fxRateDate,
     now,
     now,
-  )
+  ))
   if err != nil {
     writeError(w, http.StatusInternalServerError, "insert failed")
     return
   }
+  if err := h.Rollups.Add(r.Context(), tx, userID.String(), []string{id}); err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  if err := enqueueTransactionUpsert(r.Context(), tx, userID.String(), []models.Transaction{created}); err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  if err := tx.Commit(r.Context()); err != nil {
+    writeError(w, http.StatusInternalServerError, "insert failed")
+    return
+  }
+  h.Anomalies.Go(userID.String(), []string{id})
 
   writeJSON(w, http.StatusCreated, map[string]string{"id": id})
 }
@@ -251,7 +277,31 @@ func (h *TransactionHandler) Update(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusBadRequest, "invalid json")
     return
   }
-  if err := validateTransactionInput(input); err != nil {
+  if err := enrichTransactionUPI(&input); err != nil {
+    writeError(w, http.StatusBadRequest, err.Error())
+    return
+  }
+  merchantRaw, err := h.normalizeMerchant(r.Context(), userID.String(), &input)
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  conv, err := h.convertCurrency(r.Context(), userID.String(), &input)
+  if err != nil {
+    if _, ok := err.(invalidError); ok {
+      writeError(w, http.StatusBadRequest, err.Error())
+      return
+    }
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  fxRate, fxRateDate := fxColumns(conv)
+  categories, err := category.Load(r.Context(), h.Pool, userID.String())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  if err := validateTransactionInput(input, categories); err != nil {
     writeError(w, http.StatusBadRequest, err.Error())
     return
   }
@@ -259,7 +309,18 @@ func (h *TransactionHandler) Update(w http.ResponseWriter, r *http.Request) {
   now := time.Now().UTC()
   tagsBytes, _ := json.Marshal(normalizeTags(input.Tags))
 
-  cmd, err := h.Pool.Exec(r.Context(), `
+  tx, err := h.Pool.Begin(r.Context())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  defer tx.Rollback(r.Context())
+
+  if err := h.Rollups.Remove(r.Context(), tx, userID.String(), []string{id}); err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  updated, err := scanTransaction(tx.QueryRow(r.Context(), `
     UPDATE transactions
        SET amount_paisa = $1,
            type = $2,
@@ -276,9 +337,16 @@ func (h *TransactionHandler) Update(w http.ResponseWriter, r *http.Request) {
            is_shared = $13,
            tags = $14,
            notes = $15,
-           updated_at = $16
-     WHERE user_id = $17 AND id = $18
-  `,
+           counterparty_vpa = $16,
+           upi_kind = $17,
+           merchant_raw = $18,
+           original_currency = $19,
+           original_amount_minor = $20,
+           fx_rate = $21,
+           fx_rate_date = $22,
+           updated_at = $23
+     WHERE user_id = $24 AND id = $25
+    RETURNING `+transactionColumns,
     input.AmountPaisa,
     input.Type,
     input.Category,
@@ -294,16 +362,35 @@ func (h *TransactionHandler) Update(w http.ResponseWriter, r *http.Request) {
     input.IsShared,
     tagsBytes,
     input.Notes,
+    input.CounterpartyVPA,
+    input.UPIKind,
+    merchantRaw,
+    input.OriginalCurrency,
+    input.OriginalAmountMinor,
+    fxRate,
+    fxRateDate,
     now,
     userID,
     id,
-  )
+  ))
+  if errors.Is(err, pgx.ErrNoRows) {
+    writeError(w, http.StatusNotFound, "not found")
+    return
+  }
   if err != nil {
     writeError(w, http.StatusInternalServerError, "update failed")
     return
   }
-  if cmd.RowsAffected() == 0 {
-    writeError(w, http.StatusNotFound, "not found")
+  if err := h.Rollups.Add(r.Context(), tx, userID.String(), []string{id}); err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  if err := enqueueTransactionUpsert(r.Context(), tx, userID.String(), []models.Transaction{updated}); err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
+    return
+  }
+  if err := tx.Commit(r.Context()); err != nil {
+    writeError(w, http.StatusInternalServerError, "update failed")
     return
   }
 
@@ -322,7 +409,18 @@ func (h *TransactionHandler) Delete(w http.ResponseWriter, r *http.Request) {
     return
   }
 
-  cmd, err := h.Pool.Exec(r.Context(), `
+  tx, err := h.Pool.Begin(r.Context())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
+  defer tx.Rollback(r.Context())
+
+  if err := h.Rollups.Remove(r.Context(), tx, userID.String(), []string{id}); err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
+  cmd, err := tx.Exec(r.Context(), `
     DELETE FROM transactions
      WHERE user_id = $1 AND id = $2
   `, userID, id)
@@ -334,6 +432,14 @@ func (h *TransactionHandler) Delete(w http.ResponseWriter, r *http.Request) {
     writeError(w, http.StatusNotFound, "not found")
     return
   }
+  if err := enqueueTransactionDelete(r.Context(), tx, userID.String(), []string{id}); err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
+  if err := tx.Commit(r.Context()); err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
 
   writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -357,7 +463,18 @@ func (h *TransactionHandler) BulkDelete(w http.ResponseWriter, r *http.Request)
     return
   }
 
-  cmd, err := h.Pool.Exec(r.Context(), `
+  tx, err := h.Pool.Begin(r.Context())
+  if err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
+  defer tx.Rollback(r.Context())
+
+  if err := h.Rollups.Remove(r.Context(), tx, userID.String(), input.IDs); err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
+  cmd, err := tx.Exec(r.Context(), `
     DELETE FROM transactions
      WHERE user_id = $1 AND id = ANY($2)
   `, userID, input.IDs)
@@ -366,6 +483,16 @@ func (h *TransactionHandler) BulkDelete(w http.ResponseWriter, r *http.Request)
     writeError(w, http.StatusInternalServerError, "delete failed")
     return
   }
+  if cmd.RowsAffected() > 0 {
+    if err := enqueueTransactionDelete(r.Context(), tx, userID.String(), input.IDs); err != nil {
+      writeError(w, http.StatusInternalServerError, "delete failed")
+      return
+    }
+  }
+  if err := tx.Commit(r.Context()); err != nil {
+    writeError(w, http.StatusInternalServerError, "delete failed")
+    return
+  }
 
   writeJSON(w, http.StatusOK, map[string]any{
     "status": "deleted",
@@ -373,7 +500,7 @@ func (h *TransactionHandler) BulkDelete(w http.ResponseWriter, r *http.Request)
   })
 }
 
-func validateTransactionInput(input models.TransactionInput) error {
+func validateTransactionInput(input models.TransactionInput, categories category.Set) error {
   if input.AmountPaisa == 0 {
     return errInvalid("amount_paisa is required")
   }
@@ -383,6 +510,9 @@ func validateTransactionInput(input models.TransactionInput) error {
   if input.Category == "" {
     return errInvalid("category is required")
   }
+  if !categories.Has(input.Category) {
+    return errInvalid("invalid category")
+  }
   if input.Source == "" {
     return errInvalid("source is required")
   }
@@ -392,6 +522,112 @@ func validateTransactionInput(input models.TransactionInput) error {
   return nil
 }
 
+func enrichTransactionUPI(input *models.TransactionInput) error {
+  return enrichUPI(upiFields{
+    Source:          input.Source,
+    Category:        &input.Category,
+    MerchantName:    &input.MerchantName,
+    Description:     input.Description,
+    Notes:           input.Notes,
+    PaymentMethod:   &input.PaymentMethod,
+    ReferenceID:     &input.ReferenceID,
+    CounterpartyVPA: &input.CounterpartyVPA,
+    UPIKind:         &input.UPIKind,
+  })
+}
+
+// normalizeMerchant canonicalises input.MerchantName using the directory and
+// the user's overrides, returning the name as sent.
+func (h *TransactionHandler) normalizeMerchant(ctx context.Context, userID string, input *models.TransactionInput) (*string, error) {
+  overrides, err := loadMerchantOverrides(ctx, h.Pool, userID, input.MerchantName != nil)
+  if err != nil {
+    return nil, err
+  }
+  return normalizeMerchant(h.Merchants, overrides, &input.MerchantName, &input.Category), nil
+}
+
+// convertCurrency sets input.AmountPaisa from a foreign-currency amount,
+// converted into the user's base currency.
+func (h *TransactionHandler) convertCurrency(ctx context.Context, userID string, input *models.TransactionInput) (*fx.Conversion, error) {
+  if input.OriginalCurrency == nil && input.OriginalAmountMinor == nil {
+    return nil, nil
+  }
+  base, err := loadBaseCurrency(ctx, h.Pool, userID)
+  if err != nil {
+    return nil, err
+  }
+  return convertCurrency(ctx, newRateCache(h.Pool, h.MaxRateAge), base, currencyFields{
+    AmountPaisa:         &input.AmountPaisa,
+    OriginalCurrency:    &input.OriginalCurrency,
+    OriginalAmountMinor: &input.OriginalAmountMinor,
+    Timestamp:           input.Timestamp,
+  })
+}
+
+// transactionColumns selects a full models.Transaction row, in the order
+// scanTransaction reads it.
+const transactionColumns = `id, user_id, amount_paisa, type, category, merchant_name, description,
+           timestamp, source, payment_method, linked_account_id, reference_id,
+           category_confidence, is_recurring, is_shared, tags, notes,
+           counterparty_vpa, upi_kind, original_currency, original_amount_minor,
+           fx_rate::float8, fx_rate_date, created_at, updated_at`
+
+func scanTransaction(row pgx.Row) (models.Transaction, error) {
+  var t models.Transaction
+  var tagsRaw []byte
+  if err := row.Scan(
+    &t.ID,
+    &t.UserID,
+    &t.AmountPaisa,
+    &t.Type,
+    &t.Category,
+    &t.MerchantName,
+    &t.Description,
+    &t.Timestamp,
+    &t.Source,
+    &t.PaymentMethod,
+    &t.LinkedAccountID,
+    &t.ReferenceID,
+    &t.CategoryConfidence,
+    &t.IsRecurring,
+    &t.IsShared,
+    &tagsRaw,
+    &t.Notes,
+    &t.CounterpartyVPA,
+    &t.UPIKind,
+    &t.OriginalCurrency,
+    &t.OriginalAmountMinor,
+    &t.FXRate,
+    &t.FXRateDate,
+    &t.CreatedAt,
+    &t.UpdatedAt,
+  ); err != nil {
+    return models.Transaction{}, err
+  }
+  t.Tags = decodeTags(tagsRaw)
+  return t, nil
+}
+
+// enqueueTransactionUpsert queues the rows as written, so every
+// transactions.upserted event carries the same full models.Transaction shape.
+func enqueueTransactionUpsert(ctx context.Context, q outbox.Execer, userID string, items []models.Transaction) error {
+  if len(items) == 0 {
+    return nil
+  }
+  return outbox.Enqueue(ctx, q, outbox.Event{
+    UserID: userID,
+    Type:   outbox.EventTransactionsUpserted,
+    Data:   map[string]any{"items": items},
+  })
+}
+func enqueueTransactionDelete(ctx context.Context, q outbox.Execer, userID string, ids []string) error {
+  return outbox.Enqueue(ctx, q, outbox.Event{
+    UserID: userID,
+    Type:   outbox.EventTransactionsDeleted,
+    Data:   map[string]any{"ids": ids},
+  })
+}
+
 func normalizeTags(tags []string) []string {
   if tags == nil {
     return []string{}
@@ -29,7 +29,24 @@ func (m *MockDBPool) QueryRow(ctx context.Context, sql string, arguments ...any)
 }
 
 func (m *MockDBPool) Begin(ctx context.Context) (pgx.Tx, error) {
-	return nil, nil
+	return &MockTx{}, nil
+}
+
+// MockTx embeds pgx.Tx so only the methods the handlers use need stubbing.
+type MockTx struct {
+	pgx.Tx
+}
+
+func (m *MockTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("DELETE 1"), nil
+}
+
+func (m *MockTx) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockTx) Rollback(ctx context.Context) error {
+	return nil
 }
 
 func BenchmarkDeleteTransactions(b *testing.B) {
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"strings"
+
+	"duskspendr-gateway/internal/upi"
+)
+
+// upiFields points at the transaction fields that UPI enrichment reads and
+// fills, so create, update and ingest share one implementation.
+type upiFields struct {
+	Source          string
+	Category        *string
+	MerchantName    **string
+	Description     *string
+	Notes           *string
+	PaymentMethod   **string
+	ReferenceID     **string
+	CounterpartyVPA **string
+	UPIKind         **string
+}
+
+// enrichUPI resolves the counterparty VPA of a UPI transaction. Known
+// merchant VPAs are replaced by their canonical name and, when the client
+// left the category as "other", their default category. The VPA itself and
+// whether the payment is P2P or P2M are kept alongside.
+func enrichUPI(f upiFields) error {
+	*f.UPIKind = nil
+
+	isUPI := f.Source == "upiNotification" || (*f.PaymentMethod != nil && **f.PaymentMethod == "upi")
+
+	var vpa upi.VPA
+	var found bool
+	if *f.CounterpartyVPA != nil && strings.TrimSpace(**f.CounterpartyVPA) != "" {
+		vpa, found = upi.ParseVPA(**f.CounterpartyVPA)
+		if !found {
+			return errInvalid("invalid counterparty_vpa")
+		}
+		isUPI = true
+	} else if isUPI {
+		for _, text := range []*string{*f.MerchantName, f.Description, f.Notes} {
+			if text == nil {
+				continue
+			}
+			if vpa, found = upi.ParseVPA(*text); found {
+				break
+			}
+		}
+	}
+	if !isUPI {
+		return nil
+	}
+
+	if *f.PaymentMethod == nil || **f.PaymentMethod == "" {
+		method := "upi"
+		*f.PaymentMethod = &method
+	}
+	if *f.ReferenceID == nil || strings.TrimSpace(**f.ReferenceID) == "" {
+		for _, text := range []*string{f.Description, f.Notes} {
+			if text == nil {
+				continue
+			}
+			if ref, ok := upi.ParseReference(*text); ok {
+				*f.ReferenceID = &ref
+				break
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	info := upi.Resolve(vpa)
+	address := info.VPA.Address
+	kind := info.Kind
+	*f.CounterpartyVPA = &address
+	*f.UPIKind = &kind
+
+	if info.Merchant != "" {
+		merchant := *f.MerchantName
+		if merchant == nil || strings.TrimSpace(*merchant) == "" || strings.Contains(strings.ToLower(*merchant), address) {
+			name := info.Merchant
+			*f.MerchantName = &name
+		}
+		if info.Category != "" && (*f.Category == "" || *f.Category == "other") {
+			*f.Category = info.Category
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"testing"
+
+	"duskspendr-gateway/internal/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestEnrichTransactionUPIKnownMerchant(t *testing.T) {
+	input := models.TransactionInput{
+		Source:       "upiNotification",
+		Category:     "other",
+		MerchantName: strPtr("zomato@hdfcbank"),
+		Description:  strPtr("UPI Ref No 412345678901"),
+	}
+	if err := enrichTransactionUPI(&input); err != nil {
+		t.Fatalf("enrich: %v", err)
+	}
+	if input.MerchantName == nil || *input.MerchantName != "Zomato" {
+		t.Fatalf("merchant = %v, want Zomato", input.MerchantName)
+	}
+	if input.Category != "food" {
+		t.Fatalf("category = %q, want food", input.Category)
+	}
+	if input.CounterpartyVPA == nil || *input.CounterpartyVPA != "zomato@hdfcbank" {
+		t.Fatalf("vpa = %v", input.CounterpartyVPA)
+	}
+	if input.UPIKind == nil || *input.UPIKind != "p2m" {
+		t.Fatalf("kind = %v, want p2m", input.UPIKind)
+	}
+	if input.PaymentMethod == nil || *input.PaymentMethod != "upi" {
+		t.Fatalf("payment method = %v, want upi", input.PaymentMethod)
+	}
+	if input.ReferenceID == nil || *input.ReferenceID != "412345678901" {
+		t.Fatalf("reference = %v", input.ReferenceID)
+	}
+}
+
+func TestEnrichTransactionUPIKeepsClientChoices(t *testing.T) {
+	input := models.TransactionInput{
+		Source:        "manual",
+		Category:      "shared",
+		MerchantName:  strPtr("Rent to Anil"),
+		PaymentMethod: strPtr("upi"),
+		Notes:         strPtr("sent to 9876543210@ybl"),
+	}
+	if err := enrichTransactionUPI(&input); err != nil {
+		t.Fatalf("enrich: %v", err)
+	}
+	if *input.MerchantName != "Rent to Anil" || input.Category != "shared" {
+		t.Fatalf("client fields overwritten: %q %q", *input.MerchantName, input.Category)
+	}
+	if input.UPIKind == nil || *input.UPIKind != "p2p" {
+		t.Fatalf("kind = %v, want p2p", input.UPIKind)
+	}
+}
+
+func TestEnrichTransactionUPIIgnoresOtherPayments(t *testing.T) {
+	input := models.TransactionInput{
+		Source:        "manual",
+		Category:      "other",
+		MerchantName:  strPtr("zomato@hdfcbank"),
+		PaymentMethod: strPtr("card"),
+		UPIKind:       strPtr("p2m"),
+	}
+	if err := enrichTransactionUPI(&input); err != nil {
+		t.Fatalf("enrich: %v", err)
+	}
+	if input.CounterpartyVPA != nil || input.UPIKind != nil || input.Category != "other" {
+		t.Fatalf("non-upi transaction enriched: %+v", input)
+	}
+}
+
+func TestEnrichTransactionUPIRejectsInvalidVPA(t *testing.T) {
+	input := models.TransactionInput{Source: "manual", CounterpartyVPA: strPtr("not a vpa")}
+	if err := enrichTransactionUPI(&input); err == nil {
+		t.Fatal("expected error for invalid counterparty_vpa")
+	}
+}
@@ -8,11 +8,13 @@ import (
   "github.com/google/uuid"
   "github.com/jackc/pgx/v5/pgxpool"
 
-  "duskspendr/gateway/internal/models"
+  "duskspendr-gateway/internal/models"
 )
 
 type UserHandler struct {
   Pool *pgxpool.Pool
+  // DeletionGrace is how long a deleted account can still be restored
+  DeletionGrace time.Duration
 }
 
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// cors allows browser clients from the configured origins. origins is the
+// comma separated ALLOWED_ORIGINS value; "*" allows any origin.
+func cors(origins string) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := map[string]bool{}
+	for _, o := range strings.Split(origins, ",") {
+		o = strings.TrimSpace(o)
+		if o == "*" {
+			allowAll = true
+		} else if o != "" {
+			allowed[o] = true
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!allowAll && !allowed[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+				h.Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID, X-API-Version")
+				h.Set("Access-Control-Max-Age", "86400")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestSize limits request bodies to limit. Statement uploads are left
+// to the import handler, which allows files of up to 10 MB; a limit set
+// here could not be raised again further down.
+func requestSize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isImportUpload(r) {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isImportUpload reports whether r is POST /v1/imports on either tree
+func isImportUpload(r *http.Request) bool {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	return r.Method == http.MethodPost && path == "/v1/imports"
+}
+
+// dataEnvelope wraps successful JSON responses as {"success": true, "data": ...},
+// the shape /api/v1 clients were built against. Error responses already use
+// the shared envelope and pass through unchanged.
+func dataEnvelope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.buf.Bytes()
+		if rec.status >= 200 && rec.status < 300 && len(bytes.TrimSpace(body)) > 0 &&
+			strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			wrapped, err := json.Marshal(struct {
+				Success bool            `json:"success"`
+				Data    json.RawMessage `json:"data"`
+			}{true, json.RawMessage(body)})
+			if err == nil {
+				body = append(wrapped, '\n')
+			}
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(body)
+	})
+}
+
+// bufferedWriter holds the response so dataEnvelope can rewrite it
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
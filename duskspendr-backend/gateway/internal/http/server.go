@@ -7,41 +7,137 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
+	amqp "github.com/rabbitmq/amqp091-go"
 
-	"duskspendr/gateway/internal/config"
-	"duskspendr/gateway/internal/handlers"
-	mw "duskspendr/gateway/internal/middleware"
-	"duskspendr/gateway/internal/serverpod"
+	"duskspendr-gateway/internal/analytics"
+	"duskspendr-gateway/internal/anomaly"
+	"duskspendr-gateway/internal/config"
+	"duskspendr-gateway/internal/db"
+	"duskspendr-gateway/internal/handlers"
+	"duskspendr-gateway/internal/merchant"
+	mw "duskspendr-gateway/internal/middleware"
+	"duskspendr-gateway/internal/serverpod"
+	"duskspendr-gateway/internal/services"
+	"duskspendr-gateway/internal/smsparse"
 )
 
-func NewServer(pool *pgxpool.Pool, serverpodClient *serverpod.Client, cfg config.Config) http.Handler {
+func NewServer(pool *pgxpool.Pool, redisClient *db.RedisClient, mqConn *amqp.Connection, jwtService *services.JWTService, serverpodClient *serverpod.Client, smsParser *smsparse.Registry, merchants *merchant.Directory, notifications *services.NotificationService, cfg config.Config) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(mw.Tracing("gateway"))
+	r.Use(cors(cfg.AllowedOrigins))
+	r.Use(mw.NewRateLimiter(redisClient, mw.RateLimiterConfig{
+		Max:    cfg.RateLimitRequests,
+		Window: cfg.RateLimitWindow,
+	}).Handler)
 	r.Use(middleware.Timeout(30 * time.Second))
-	r.Use(middleware.RequestSize(1 << 20))
+	r.Use(requestSize(1 << 20))
 	r.Use(securityHeaders)
 	r.Use(mw.APIVersioning(mw.DefaultVersionConfig()))
 
-	r.Get("/health", handlers.Health)
+	health := handlers.NewHealthHandler(pool, redisClient, mqConn)
+	health.FXMaxRateAge = cfg.FXMaxRateAge
+	r.Get("/health", health.Health)
+	r.Get("/ready", health.Ready)
+	r.Get("/live", health.Live)
+	r.Get("/.well-known/jwks.json", (&handlers.JWKSHandler{JWT: jwtService}).Get)
 
-	userHandler := &handlers.UserHandler{Pool: pool}
-	authHandler := &handlers.HTTPAuthHandler{Pool: pool, Config: cfg}
-	txHandler := &handlers.TransactionHandler{Pool: pool}
+	location := cfg.AnalyticsLocation()
+	rollups := &analytics.Rollups{Location: location}
+	anomalies := &anomaly.Runner{Pool: pool, Location: location, MaxAge: cfg.AnomalyMaxAge}
+	if notifications != nil {
+		anomalies.Notifier = notifications
+	}
+
+	var nonces handlers.NonceStore = handlers.NewMemoryNonceStore()
+	var denylist services.TokenDenylist = services.NewMemoryDenylist()
+	if redisClient != nil {
+		nonces = handlers.NewRedisNonceStore(redisClient)
+		denylist = services.NewRedisDenylist(redisClient)
+	}
+	otpChannels := newOTPChannels(cfg)
+	authenticator := &handlers.Authenticator{Pool: pool, JWT: jwtService, Denylist: denylist}
+
+	userHandler := &handlers.UserHandler{Pool: pool, DeletionGrace: cfg.AccountDeletionGrace}
+	smsDeliveryHandler := &handlers.SMSDeliveryHandler{Pool: pool, Channels: otpChannels, Token: cfg.SMSWebhookToken}
+	authHandler := handlers.NewAuthHandler(pool, cfg, jwtService, denylist, otpChannels)
+	txHandler := &handlers.TransactionHandler{
+		Pool:       pool,
+		Merchants:  merchants,
+		MaxRateAge: cfg.FXMaxRateAge,
+		Rollups:    rollups,
+		Anomalies:  anomalies,
+	}
 	accountHandler := &handlers.AccountHandler{Pool: pool}
-	budgetHandler := &handlers.BudgetHandler{Pool: pool}
+	budgetHandler := &handlers.BudgetHandler{Pool: pool, Location: location}
 	serverpodHandler := &handlers.ServerpodHandler{Client: serverpodClient}
-	syncHandler := &handlers.SyncHandler{Client: serverpodClient, Pool: pool}
+	syncHandler := &handlers.SyncHandler{
+		Pool:       pool,
+		SMSParser:  smsParser,
+		Merchants:  merchants,
+		MaxRateAge: cfg.FXMaxRateAge,
+		Rollups:    rollups,
+		Anomalies:  anomalies,
+	}
+	merchantHandler := &handlers.MerchantHandler{Pool: pool, Directory: merchants}
+	importHandler := &handlers.ImportHandler{Pool: pool, Sync: syncHandler}
+	smsHandler := &handlers.SMSHandler{Parser: smsParser}
+	categoryHandler := &handlers.CategoryHandler{Pool: pool, Rollups: rollups}
+	tagHandler := &handlers.TagHandler{Pool: pool}
+	anomalyHandler := &handlers.AnomalyHandler{Pool: pool}
+	billHandler := &handlers.BillHandler{Pool: pool}
+	forecastHandler := &handlers.ForecastHandler{Pool: pool, Location: location}
+	netWorthHandler := &handlers.NetWorthHandler{Pool: pool, Location: location}
+	analyticsHandler := &handlers.AnalyticsHandler{
+		Store: &analytics.Store{Q: pool, Location: location},
+	}
+	fxHandler := &handlers.FXHandler{Pool: pool, AdminToken: cfg.AdminToken, MaxRateAge: cfg.FXMaxRateAge}
+	sessionHandler := &handlers.SessionHandler{Pool: pool, Revoker: authHandler.Sessions}
+	integrationsHandler := handlers.NewIntegrationsHandler(cfg)
+	investmentHandler := handlers.NewInvestmentHandler(cfg)
+	analyticsProxy := handlers.ProxyTo(cfg.AnalyticsServiceURL, "/v1", "/api/v1")
+	aiProxy := handlers.ProxyTo(cfg.AIServiceURL, "/v1", "/api/v1")
 
-  r.Route("/v1", func(v1 chi.Router) {
+  // routes registers the API once for both trees. They differ only in what
+  // /auth/verify and a passkey sign-in issue: /v1 returns an opaque session
+  // token, /api/v1 a JWT pair. Either credential is accepted on every
+  // authenticated route.
+  routes := func(v1 chi.Router, verify, passkeyLogin http.HandlerFunc) {
     v1.Post("/users", userHandler.Create)
     v1.Post("/auth/start", authHandler.Start)
-    v1.Post("/auth/verify", authHandler.Verify)
+    v1.Get("/auth/otp/{id}/delivery", smsDeliveryHandler.OTPStatus)
+    v1.Post("/auth/verify", verify)
+    v1.Post("/auth/refresh", authHandler.Refresh)
+    v1.Post("/auth/passkeys/login/begin", authHandler.BeginPasskeyLogin)
+    v1.Post("/auth/passkeys/login/finish", passkeyLogin)
     v1.Get("/serverpod/health", serverpodHandler.Health)
+    v1.Get("/erasure-receipts/{id}", userHandler.ErasureReceipt)
+
+    // Server-to-server callbacks, authenticated by the sync HMAC signature
+    v1.With(handlers.RequireSyncSignature(cfg.SyncSharedSecret, cfg.SyncMaxSkew, nonces)).Post(
+      "/webhooks/serverpod/transactions",
+      syncHandler.ServerpodPush,
+    )
+
+    // SMS delivery reports, authenticated by the token in the report URL
+    v1.Get("/webhooks/sms/{provider}", smsDeliveryHandler.Report)
+    v1.Post("/webhooks/sms/{provider}", smsDeliveryHandler.Report)
+
+    // Operator endpoints, authenticated by X-Admin-Token
+    v1.Post("/admin/fx/rates", fxHandler.UploadRates)
 
     v1.Group(func(auth chi.Router) {
-      auth.Use(handlers.RequireUserID(pool))
+      auth.Use(authenticator.RequireUserID)
+
+      auth.Post("/auth/logout", authHandler.Logout)
+      auth.Post("/auth/logout-all", authHandler.LogoutAll)
+      auth.Get("/auth/totp", authHandler.TOTPStatus)
+      auth.Post("/auth/totp/enroll", authHandler.EnrollTOTP)
+      auth.Post("/auth/totp/confirm", authHandler.ConfirmTOTP)
+      auth.Post("/auth/step-up", authHandler.StepUp)
 
       auth.Get("/transactions", txHandler.List)
       auth.Post("/transactions", txHandler.Create)
@@ -51,23 +147,155 @@ func NewServer(pool *pgxpool.Pool, serverpodClient *serverpod.Client, cfg config
       auth.Post("/transactions/bulk-delete", txHandler.BulkDelete)
 
       auth.Post("/sync/transactions", syncHandler.SyncTransactions)
+      auth.Get("/sync/status", syncHandler.Status)
       auth.With(handlers.SyncIngestRateLimit(cfg)).Post(
         "/sync/transactions/ingest",
         syncHandler.IngestTransactions,
       )
 
+      auth.Post("/sms/parse", smsHandler.Parse)
+
+      auth.Get("/categories", categoryHandler.List)
+      auth.Post("/categories", categoryHandler.Create)
+      auth.Patch("/categories/{key}", categoryHandler.Update)
+      auth.Delete("/categories/{key}", categoryHandler.Delete)
+      auth.Post("/categories/{key}/merge", categoryHandler.Merge)
+
+      auth.Get("/analytics/spending-summary", analyticsHandler.SpendingSummary)
+      auth.Get("/analytics/compare", analyticsHandler.Compare)
+      auth.Get("/analytics/trends", analyticsHandler.Trends)
+      auth.Get("/analytics/cash-flow", analyticsHandler.CashFlow)
+      auth.Get("/analytics/insights", analyticsProxy)
+
+      auth.Post("/ai/categorize", aiProxy)
+      auth.Post("/ai/insights", aiProxy)
+      auth.Post("/ai/predict", aiProxy)
+
+      auth.Get("/networth", netWorthHandler.Get)
+      auth.Get("/networth/items", netWorthHandler.ListItems)
+      auth.Post("/networth/items", netWorthHandler.CreateItem)
+      auth.Put("/networth/items/{id}", netWorthHandler.UpdateItem)
+      auth.Delete("/networth/items/{id}", netWorthHandler.DeleteItem)
+      auth.Get("/investments/holdings", netWorthHandler.ListHoldings)
+      auth.Put("/investments/holdings", netWorthHandler.PutHoldings)
+
+      auth.Get("/forecast", forecastHandler.Get)
+      auth.Get("/bills", billHandler.List)
+      auth.Post("/bills", billHandler.Create)
+      auth.Put("/bills/{id}", billHandler.Update)
+      auth.Delete("/bills/{id}", billHandler.Delete)
+
+      auth.Get("/anomalies", anomalyHandler.List)
+      auth.Patch("/anomalies/{id}", anomalyHandler.Review)
+      auth.Get("/users/me/anomaly-settings", anomalyHandler.GetSettings)
+      auth.Put("/users/me/anomaly-settings", anomalyHandler.PutSettings)
+
+      auth.Get("/tags", tagHandler.List)
+      auth.Get("/tags/spend", tagHandler.Spend)
+      auth.Post("/tags/rename", tagHandler.Rename)
+      auth.Post("/tags/merge", tagHandler.Merge)
+
+      auth.Get("/merchants", merchantHandler.List)
+      auth.Get("/merchants/overrides", merchantHandler.ListOverrides)
+      auth.Put("/merchants/overrides", merchantHandler.PutOverride)
+      auth.Delete("/merchants/overrides", merchantHandler.DeleteOverride)
+
+      auth.Get("/imports", importHandler.List)
+      auth.Post("/imports", importHandler.Create)
+      auth.Get("/imports/profiles", importHandler.ListProfiles)
+      auth.Post("/imports/profiles", importHandler.SaveProfile)
+      auth.Delete("/imports/profiles/{id}", importHandler.DeleteProfile)
+      auth.Get("/imports/{id}", importHandler.Get)
+      auth.Post("/imports/{id}/commit", importHandler.Commit)
+
+      auth.Get("/users/me/deletion", userHandler.DeletionStatus)
+      auth.Delete("/users/me/deletion", userHandler.CancelDeletion)
+      auth.Get("/users/me/sessions", sessionHandler.List)
+      auth.Delete("/users/me/sessions/{id}", sessionHandler.Delete)
+      auth.Get("/users/me/passkeys", authHandler.ListPasskeys)
+      auth.Delete("/users/me/passkeys/{id}", authHandler.DeletePasskey)
+
+      auth.Get("/fx/rates", fxHandler.Rate)
+      auth.Get("/users/me/currency", fxHandler.GetCurrency)
+      auth.Put("/users/me/currency", fxHandler.PutCurrency)
+
       auth.Get("/accounts", accountHandler.List)
-      auth.Post("/accounts", accountHandler.Create)
 
       auth.Get("/budgets", budgetHandler.List)
       auth.Post("/budgets", budgetHandler.Create)
       auth.Put("/budgets/{id}", budgetHandler.Update)
+
+      // Sensitive operations need a fresh second factor (POST /auth/step-up)
+      auth.Group(func(elevated chi.Router) {
+        elevated.Use(authenticator.RequireStepUp)
+
+        elevated.Post("/accounts", accountHandler.Create)
+        elevated.Post("/accounts/link", accountHandler.Create)
+        elevated.Post("/integrations/upstox/token", integrationsHandler.UpstoxTokenExchange)
+        elevated.Post("/investments/zerodha/exchange", investmentHandler.ZerodhaExchange)
+
+        elevated.Post("/auth/passkeys/register/begin", authHandler.BeginPasskeyRegistration)
+        elevated.Post("/auth/passkeys/register/finish", authHandler.FinishPasskeyRegistration)
+        elevated.Post("/auth/totp/recovery-codes", authHandler.RegenerateRecoveryCodes)
+        elevated.Delete("/auth/totp", authHandler.DisableTOTP)
+        elevated.Delete("/users/me", userHandler.DeleteMe)
+      })
     })
+  }
+
+  r.Route("/v1", func(v1 chi.Router) {
+    routes(v1, authHandler.VerifySession, authHandler.FinishPasskeyLoginSession)
+  })
+
+  // /api/v1 is the tree the former Fiber gateway served; successful
+  // responses keep its {"success": true, "data": ...} envelope
+  r.Route("/api/v1", func(v1 chi.Router) {
+    v1.Use(dataEnvelope)
+    routes(v1, authHandler.Verify, authHandler.FinishPasskeyLogin)
   })
 
   return r
 }
 
+// newSMSSender builds the OTP sender from the providers in
+// cfg.SMSProviderList, in failover order
+func newSMSSender(cfg config.Config) *services.FailoverSender {
+  var providers []services.SMSProvider
+  for _, name := range cfg.SMSProviderList() {
+    switch name {
+    case "msg91":
+      providers = append(providers, services.NewMSG91Sender(cfg.MSG91AuthKey, cfg.SMSSenderID))
+    case "gupshup":
+      providers = append(providers, services.NewGupshupSender(cfg.GupshupUserID, cfg.GupshupPassword, cfg.SMSDLTEntityID, cfg.SMSSenderID))
+    case "twilio":
+      providers = append(providers, services.NewTwilioSender(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFromNumber))
+    case "log":
+      providers = append(providers, services.NewLogSender())
+    }
+  }
+  return services.NewFailoverSender(providers...)
+}
+
+// newOTPChannels builds the OTP channels in cfg.OTPChannelList order. A
+// channel without credentials is left out in production and logs the code
+// elsewhere, so every channel can be tried locally.
+func newOTPChannels(cfg config.Config) services.OTPChannels {
+  var channels services.OTPChannels
+  for _, name := range cfg.OTPChannelList() {
+    switch {
+    case name == services.ChannelSMS:
+      channels = append(channels, &services.SMSChannel{Sender: newSMSSender(cfg), TemplateID: cfg.SMSDLTTemplateID})
+    case name == services.ChannelWhatsApp && cfg.WhatsAppConfigured():
+      channels = append(channels, services.NewWhatsAppSender(cfg.WhatsAppPhoneNumberID, cfg.WhatsAppAccessToken, cfg.WhatsAppOTPTemplate, cfg.WhatsAppTemplateLanguage))
+    case name == services.ChannelVoice && cfg.TwilioAccountSID != "" && cfg.TwilioAuthToken != "" && cfg.TwilioFromNumber != "":
+      channels = append(channels, services.NewTwilioVoiceSender(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFromNumber))
+    case cfg.Env != "production" && services.ValidChannel(name):
+      channels = append(channels, &services.LogChannel{Name: name})
+    }
+  }
+  return channels
+}
+
 func securityHeaders(next http.Handler) http.Handler {
   return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("X-Content-Type-Options", "nosniff")
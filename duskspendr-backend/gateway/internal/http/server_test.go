@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"duskspendr-gateway/internal/config"
+	"duskspendr-gateway/internal/services"
+)
+
+// testRouter builds the full router without backing stores and signs a
+// plain (not stepped-up) token pair for it.
+func testRouter(t *testing.T) (http.Handler, *services.TokenPair) {
+	t.Helper()
+	key, err := services.GenerateSigningKey(services.AlgEdDSA, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtSvc := services.NewJWTService(services.NewKeyRing(time.Hour, key), time.Minute, time.Hour)
+	pair, err := jwtSvc.GenerateTokenPair(uuid.NewString(), "", "+919999999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewServer(nil, nil, nil, jwtSvc, nil, nil, nil, nil, config.Config{RateLimitRequests: 100, RateLimitWindow: time.Minute})
+	return router, pair
+}
+
+func TestImportUploadAboveDefaultBodyLimit(t *testing.T) {
+	router, pair := testRouter(t)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "statement.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(bytes.Repeat([]byte("x"), 3<<20))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/v1/imports", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// The upload is read in full and rejected only for its format
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	// Other routes keep the 1 MiB limit
+	big, _ := json.Marshal(map[string]string{"phone": string(bytes.Repeat([]byte("9"), 2<<20))})
+	req = httptest.NewRequest("POST", "/v1/auth/start", bytes.NewReader(big))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest && rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized auth/start status = %d", rec.Code)
+	}
+}
+
+func TestSensitiveRoutesNeedStepUp(t *testing.T) {
+	router, pair := testRouter(t)
+
+	paths := []string{
+		"/v1/accounts", "/v1/accounts/link", "/api/v1/accounts", "/api/v1/accounts/link",
+		"/v1/auth/passkeys/register/begin", "/v1/auth/passkeys/register/finish",
+		"/api/v1/auth/passkeys/register/begin", "/api/v1/auth/passkeys/register/finish",
+	}
+	for _, path := range paths {
+		req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s without elv: status = %d, body %s", path, rec.Code, rec.Body)
+		}
+	}
+}
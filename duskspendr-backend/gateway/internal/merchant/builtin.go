@@ -0,0 +1,43 @@
+package merchant
+
+// Categories use the transaction category names
+var builtinMerchants = []Merchant{
+	{Name: "Swiggy", Aliases: []string{"swiggy food", "bundl technologies"}, Category: "food"},
+	{Name: "Swiggy Instamart", Aliases: []string{"instamart"}, Category: "shopping"},
+	{Name: "Zomato", Aliases: []string{"zomato online", "zomato order", "zomato media"}, Category: "food"},
+	{Name: "Domino's", Aliases: []string{"dominos", "domino s", "jubilant foodworks"}, Category: "food"},
+	{Name: "McDonald's", Aliases: []string{"mcdonalds", "mcdonald s"}, Category: "food"},
+	{Name: "Starbucks", Aliases: []string{"tata starbucks"}, Category: "food"},
+	{Name: "KFC", Category: "food"},
+	{Name: "Uber", Aliases: []string{"uber india", "uber trip", "uber bv"}, Category: "transportation"},
+	{Name: "Ola", Aliases: []string{"olacabs", "ola cabs", "ani technologies"}, Category: "transportation"},
+	{Name: "Rapido", Aliases: []string{"roppen transportation"}, Category: "transportation"},
+	{Name: "IRCTC", Aliases: []string{"irctc online", "irctconline"}, Category: "transportation"},
+	{Name: "redBus", Aliases: []string{"redbus"}, Category: "transportation"},
+	{Name: "Amazon", Aliases: []string{"amazon in", "amazon pay", "amazon seller services", "amzn"}, Category: "shopping"},
+	{Name: "Flipkart", Aliases: []string{"flipkart internet"}, Category: "shopping"},
+	{Name: "Myntra", Aliases: []string{"myntra designs"}, Category: "shopping"},
+	{Name: "BigBasket", Aliases: []string{"bigbasket", "big basket", "supermarket grocery supplies"}, Category: "shopping"},
+	{Name: "Blinkit", Aliases: []string{"grofers", "blink commerce"}, Category: "shopping"},
+	{Name: "Zepto", Aliases: []string{"kiranakart"}, Category: "shopping"},
+	{Name: "DMart", Aliases: []string{"d mart", "avenue supermarts"}, Category: "shopping"},
+	{Name: "BookMyShow", Aliases: []string{"bookmyshow", "big tree entertainment"}, Category: "entertainment"},
+	{Name: "PVR INOX", Aliases: []string{"pvr", "inox"}, Category: "entertainment"},
+	{Name: "Netflix", Aliases: []string{"netflix com"}, Category: "subscriptions"},
+	{Name: "Spotify", Aliases: []string{"spotify india"}, Category: "subscriptions"},
+	{Name: "Disney+ Hotstar", Aliases: []string{"hotstar", "disney hotstar", "novi digital"}, Category: "subscriptions"},
+	{Name: "YouTube", Aliases: []string{"youtube premium", "google youtube"}, Category: "subscriptions"},
+	{Name: "Airtel", Aliases: []string{"bharti airtel", "airtel payments bank"}, Category: "utilities"},
+	{Name: "Jio", Aliases: []string{"reliance jio", "jio mobility"}, Category: "utilities"},
+	{Name: "BSNL", Category: "utilities"},
+	{Name: "BESCOM", Category: "utilities"},
+	{Name: "Tata Power", Category: "utilities"},
+	{Name: "Apollo Pharmacy", Aliases: []string{"apollo pharmacies"}, Category: "healthcare"},
+	{Name: "PharmEasy", Aliases: []string{"pharmeasy"}, Category: "healthcare"},
+	{Name: "Tata 1mg", Aliases: []string{"1mg"}, Category: "healthcare"},
+	{Name: "Zerodha", Aliases: []string{"zerodha broking"}, Category: "investments"},
+	{Name: "Groww", Aliases: []string{"nextbillion technology"}, Category: "investments"},
+	{Name: "Upstox", Aliases: []string{"rksv securities"}, Category: "investments"},
+	{Name: "BYJU'S", Aliases: []string{"byjus", "think and learn"}, Category: "education"},
+	{Name: "Unacademy", Aliases: []string{"sorting hat technologies"}, Category: "education"},
+}
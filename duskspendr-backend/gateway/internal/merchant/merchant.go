@@ -0,0 +1,250 @@
+// Package merchant maps raw merchant strings from SMS, UPI, statements and
+// manual entry onto canonical merchant names.
+//
+// Matching works on a normalised key: lower case, payment-rail prefixes and
+// order suffixes removed ("SWIGGY*ORDER 1234" and "UPI-Swiggy" both become
+// "swiggy"). The longest alias that is a whole-word prefix of the key wins, so
+// "swiggy instamart" resolves to Swiggy Instamart rather than Swiggy.
+package merchant
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Merchant is a canonical merchant with the aliases that resolve to it
+type Merchant struct {
+	Name     string   `json:"name"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Category string   `json:"category,omitempty"`
+}
+
+// Match is the result of normalising a raw merchant name
+type Match struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	// Known is true when the name came from the directory or an override
+	Known bool `json:"known"`
+}
+
+// Override is a per-user rule renaming a merchant and optionally setting its
+// default category
+type Override struct {
+	Key          string  `json:"match"`
+	MerchantName string  `json:"merchant_name"`
+	Category     *string `json:"category,omitempty"`
+}
+
+// Overrides are a user's overrides indexed by key
+type Overrides map[string]Override
+
+type entry struct {
+	key      string
+	merchant Merchant
+}
+
+// Directory resolves raw names against built-in and runtime-loaded merchants.
+// It is safe for concurrent use.
+type Directory struct {
+	mu       sync.RWMutex
+	builtins []Merchant
+	entries  []entry
+	byKey    map[string]Merchant
+}
+
+// NewDirectory creates a directory with the built-in merchants
+func NewDirectory() *Directory {
+	d := &Directory{builtins: builtinMerchants}
+	d.SetCustom(nil)
+	return d
+}
+
+// SetCustom replaces the runtime-loaded merchants, keeping the built-ins.
+// A custom merchant with the same name as a built-in replaces it.
+func (d *Directory) SetCustom(custom []Merchant) {
+	replaced := map[string]bool{}
+	for _, m := range custom {
+		replaced[strings.ToLower(m.Name)] = true
+	}
+	all := append([]Merchant(nil), custom...)
+	for _, m := range d.builtins {
+		if !replaced[strings.ToLower(m.Name)] {
+			all = append(all, m)
+		}
+	}
+
+	byKey := map[string]Merchant{}
+	entries := []entry{}
+	for _, m := range all {
+		for _, alias := range append([]string{m.Name}, m.Aliases...) {
+			k := Key(alias)
+			if k == "" {
+				continue
+			}
+			if _, dup := byKey[k]; dup {
+				continue
+			}
+			byKey[k] = m
+			entries = append(entries, entry{key: k, merchant: m})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].key) > len(entries[j].key)
+	})
+
+	d.mu.Lock()
+	d.entries = entries
+	d.byKey = byKey
+	d.mu.Unlock()
+}
+
+// Merchants returns every merchant in the directory
+func (d *Directory) Merchants() []Merchant {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	seen := map[string]bool{}
+	out := []Merchant{}
+	for _, e := range d.entries {
+		if seen[e.merchant.Name] {
+			continue
+		}
+		seen[e.merchant.Name] = true
+		out = append(out, e.merchant)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Normalize resolves raw against the directory
+func (d *Directory) Normalize(raw string) Match {
+	return d.Resolve(raw, nil)
+}
+
+// Resolve resolves raw, consulting the user's overrides first. An override
+// matches either the raw key or the key of the canonical name, so a user can
+// rename "Swiggy" without listing every spelling.
+func (d *Directory) Resolve(raw string, overrides Overrides) Match {
+	key := Key(raw)
+	if key == "" {
+		return Match{Name: strings.TrimSpace(raw)}
+	}
+	if o, ok := overrides[key]; ok {
+		m, found := d.lookup(key)
+		return overrideMatch(o, m, found)
+	}
+
+	m, ok := d.lookup(key)
+	if !ok {
+		return Match{Name: displayName(raw)}
+	}
+	if o, ok := overrides[Key(m.Name)]; ok {
+		return overrideMatch(o, m, true)
+	}
+	return Match{Name: m.Name, Category: m.Category, Known: true}
+}
+
+func overrideMatch(o Override, base Merchant, found bool) Match {
+	match := Match{Name: o.MerchantName, Known: true}
+	if o.Category != nil {
+		match.Category = *o.Category
+	} else if found {
+		match.Category = base.Category
+	}
+	return match
+}
+
+func (d *Directory) lookup(key string) (Merchant, bool) {
+	if d == nil {
+		return Merchant{}, false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if m, ok := d.byKey[key]; ok {
+		return m, true
+	}
+	for _, e := range d.entries {
+		if len(e.key) >= 3 && strings.HasPrefix(key, e.key+" ") {
+			return e.merchant, true
+		}
+	}
+	return Merchant{}, false
+}
+
+// Rails and aggregators that prefix the real merchant ("PAYU*ZOMATO")
+var aggregators = map[string]bool{
+	"payu": true, "razorpay": true, "rzp": true, "paytm": true, "ccavenue": true,
+	"billdesk": true, "cashfree": true, "pg": true, "phonepe": true, "gpay": true,
+}
+
+var railPrefixes = []string{"upi-", "upi/", "upi ", "pos ", "pos/", "ecom ", "imps-", "neft-", "ach-", "www."}
+
+var corporateSuffixes = map[string]bool{
+	"pvt": true, "private": true, "ltd": true, "limited": true, "llp": true, "inc": true,
+}
+
+// Key returns the normalised matching key for a merchant name. UPI IDs are
+// kept whole so users can override individual payees.
+func Key(raw string) string {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if s == "" {
+		return ""
+	}
+	if at := strings.Index(s, "@"); at > 0 && !strings.ContainsAny(s, " *") {
+		return s
+	}
+
+	for _, p := range railPrefixes {
+		s = strings.TrimPrefix(s, p)
+	}
+	if before, after, ok := strings.Cut(s, "*"); ok {
+		before = strings.TrimSpace(before)
+		if aggregators[before] {
+			after, _, _ = strings.Cut(after, "*")
+			s = after
+		} else if before != "" {
+			s = before
+		}
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, ".com"), ".in")
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&' && r != '+'
+	})
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if isNoiseToken(f) {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	for len(tokens) > 1 && corporateSuffixes[tokens[len(tokens)-1]] {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return strings.Join(tokens, " ")
+}
+
+// isNoiseToken drops order numbers, terminal ids and similar digit runs while
+// keeping names such as "1mg" or "7eleven".
+func isNoiseToken(t string) bool {
+	digits := 0
+	for _, r := range t {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	if digits == len(t) {
+		return true
+	}
+	return len(t) >= 6 && digits >= 3
+}
+
+// displayName tidies an unknown merchant name without guessing at casing
+func displayName(raw string) string {
+	s := strings.TrimSpace(raw)
+	if before, _, ok := strings.Cut(s, "*"); ok && strings.TrimSpace(before) != "" && !aggregators[strings.ToLower(strings.TrimSpace(before))] {
+		s = before
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,81 @@
+package merchant
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"SWIGGY*ORDER 1234", "swiggy"},
+		{"Swiggy", "swiggy"},
+		{"swiggy instamart", "swiggy instamart"},
+		{"UPI-Zomato Online", "zomato online"},
+		{"PAYU*ZOMATO*4411", "zomato"},
+		{"Amazon.in", "amazon"},
+		{"Bharti Airtel Ltd", "bharti airtel"},
+		{"POS 4411223344 DMART BLR", "dmart blr"},
+		{"Tata 1mg", "tata 1mg"},
+		{"9876543210@ybl", "9876543210@ybl"},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.in); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	d := NewDirectory()
+	tests := []struct {
+		in       string
+		name     string
+		category string
+		known    bool
+	}{
+		{"SWIGGY*ORDER 1234", "Swiggy", "food", true},
+		{"Swiggy", "Swiggy", "food", true},
+		{"swiggy instamart", "Swiggy Instamart", "shopping", true},
+		{"Uber India Systems", "Uber", "transportation", true},
+		{"AMAZON PAY INDIA PVT LTD", "Amazon", "shopping", true},
+		{"Corner Chai Stall*77", "Corner Chai Stall", "", false},
+		{"  Ramesh   Kirana ", "Ramesh Kirana", "", false},
+	}
+	for _, tt := range tests {
+		got := d.Normalize(tt.in)
+		if got.Name != tt.name || got.Category != tt.category || got.Known != tt.known {
+			t.Errorf("Normalize(%q) = %+v, want %q/%q/%v", tt.in, got, tt.name, tt.category, tt.known)
+		}
+	}
+}
+
+func TestResolveOverrides(t *testing.T) {
+	d := NewDirectory()
+	shared := "shared"
+	overrides := Overrides{
+		"swiggy":         {Key: "swiggy", MerchantName: "Food delivery"},
+		"9876543210@ybl": {Key: "9876543210@ybl", MerchantName: "Mom", Category: &shared},
+	}
+
+	if got := d.Resolve("SWIGGY*ORDER 99", overrides); got.Name != "Food delivery" || got.Category != "food" {
+		t.Errorf("canonical override = %+v", got)
+	}
+	if got := d.Resolve("Bundl Technologies", overrides); got.Name != "Food delivery" {
+		t.Errorf("alias should resolve through canonical override, got %+v", got)
+	}
+	if got := d.Resolve("9876543210@ybl", overrides); got.Name != "Mom" || got.Category != "shared" {
+		t.Errorf("vpa override = %+v", got)
+	}
+}
+
+func TestSetCustomReplacesBuiltin(t *testing.T) {
+	d := NewDirectory()
+	d.SetCustom([]Merchant{{Name: "Swiggy", Aliases: []string{"swgy"}, Category: "shopping"}})
+	if got := d.Normalize("SWGY*123"); got.Name != "Swiggy" || got.Category != "shopping" {
+		t.Errorf("custom merchant = %+v", got)
+	}
+	if got := d.Normalize("Swiggy Instamart"); got.Name != "Swiggy Instamart" {
+		t.Errorf("builtin lost after SetCustom: %+v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package merchant
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LoadMerchants reads the merchants table
+func LoadMerchants(ctx context.Context, q Querier) ([]Merchant, error) {
+	rows, err := q.Query(ctx, `
+		SELECT canonical_name, aliases, coalesce(default_category, '')
+		  FROM merchants
+		 ORDER BY canonical_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Merchant{}
+	for rows.Next() {
+		var m Merchant
+		if err := rows.Scan(&m.Name, &m.Aliases, &m.Category); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// Reload replaces the runtime merchants with the contents of the merchants table
+func (d *Directory) Reload(ctx context.Context, q Querier) error {
+	merchants, err := LoadMerchants(ctx, q)
+	if err != nil {
+		return err
+	}
+	d.SetCustom(merchants)
+	return nil
+}
+
+// Watch reloads the merchants table every interval until ctx is cancelled
+func (d *Directory) Watch(ctx context.Context, q Querier, interval time.Duration) {
+	if err := d.Reload(ctx, q); err != nil {
+		log.Printf("merchant directory reload failed: %v", err)
+	}
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Reload(ctx, q); err != nil {
+				log.Printf("merchant directory reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// LoadOverrides reads a user's merchant overrides
+func LoadOverrides(ctx context.Context, q Querier, userID string) (Overrides, error) {
+	rows, err := q.Query(ctx, `
+		SELECT match_key, merchant_name, category
+		  FROM user_merchant_overrides
+		 WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := Overrides{}
+	for rows.Next() {
+		var o Override
+		if err := rows.Scan(&o.Key, &o.MerchantName, &o.Category); err != nil {
+			return nil, err
+		}
+		out[o.Key] = o
+	}
+	return out, rows.Err()
+}
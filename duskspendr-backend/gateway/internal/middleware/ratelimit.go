@@ -3,44 +3,44 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
-
 	"duskspendr-gateway/internal/db"
 )
 
-// RateLimiter provides rate limiting functionality
+// RateLimiter provides fixed-window rate limiting, shared across instances
+// through Redis when it is configured and kept in memory otherwise
 type RateLimiter struct {
 	redis   *db.RedisClient
 	max     int
 	window  time.Duration
-	keyFunc func(*fiber.Ctx) string
+	keyFunc func(*http.Request) string
+	memory  *memoryWindows
 }
 
 // RateLimiterConfig holds rate limiter configuration
 type RateLimiterConfig struct {
-	Max     int           // Maximum requests per window
-	Window  time.Duration // Time window
-	KeyFunc func(*fiber.Ctx) string // Function to generate rate limit key
+	Max     int                        // Maximum requests per window
+	Window  time.Duration              // Time window
+	KeyFunc func(*http.Request) string // Function to generate rate limit key
 }
 
 // DefaultKeyFunc returns the client IP as the rate limit key
-func DefaultKeyFunc(c *fiber.Ctx) string {
-	return c.IP()
-}
-
-// UserKeyFunc returns user ID or IP as the rate limit key
-func UserKeyFunc(c *fiber.Ctx) string {
-	if userID := GetUserID(c); userID != "" {
-		return "user:" + userID
+func DefaultKeyFunc(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	return "ip:" + c.IP()
+	return host
 }
 
-// NewRateLimiter creates a new rate limiter
+// NewRateLimiter creates a new rate limiter; redisClient may be nil
 func NewRateLimiter(redisClient *db.RedisClient, config RateLimiterConfig) *RateLimiter {
 	if config.KeyFunc == nil {
 		config.KeyFunc = DefaultKeyFunc
@@ -57,117 +57,102 @@ func NewRateLimiter(redisClient *db.RedisClient, config RateLimiterConfig) *Rate
 		max:     config.Max,
 		window:  config.Window,
 		keyFunc: config.KeyFunc,
+		memory:  &memoryWindows{entries: make(map[string]*rateLimitEntry)},
 	}
 }
 
 // Handler returns the rate limiting middleware
-func (rl *RateLimiter) Handler() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		if rl.redis == nil {
-			// Skip rate limiting if Redis is not available
-			return c.Next()
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := rl.keyFunc(r)
+		count, reset := rl.count(r.Context(), key)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.max))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(0, rl.max-count)))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if count > rl.max {
+			retryAfter := max(1, int(time.Until(reset).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"success": false,
+				"error": map[string]any{
+					"code":    "RATE_LIMITED",
+					"message": "rate limit exceeded",
+					"details": map[string]any{"retry_after": retryAfter, "limit": rl.max, "window": rl.window.String()},
+				},
+			})
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(c.Context(), 2*time.Second)
-		defer cancel()
-
-		key := fmt.Sprintf("ratelimit:%s", rl.keyFunc(c))
-
-		// Get current count
-		count, err := rl.increment(ctx, key)
-		if err != nil {
-			// On error, allow the request but log
-			return c.Next()
-		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Get remaining TTL
-		ttl, _ := rl.redis.TTL(ctx, key)
-		if ttl < 0 {
-			ttl = rl.window
-		}
+// count increments the counter for key and returns it with the window reset
+// time. Redis errors fall back to the in-memory window so an outage does not
+// disable limiting.
+func (rl *RateLimiter) count(ctx context.Context, key string) (int, time.Time) {
+	if rl.redis != nil {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
 
-		// Set rate limit headers
-		c.Set("X-RateLimit-Limit", strconv.Itoa(rl.max))
-		c.Set("X-RateLimit-Remaining", strconv.Itoa(max(0, rl.max-int(count))))
-		c.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
-
-		// Check if over limit
-		if int(count) > rl.max {
-			retryAfter := int(ttl.Seconds())
-			c.Set("Retry-After", strconv.Itoa(retryAfter))
-			
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error":       "Rate limit exceeded",
-				"retry_after": retryAfter,
-				"limit":       rl.max,
-				"window":      rl.window.String(),
-			})
+		redisKey := fmt.Sprintf("ratelimit:%s", key)
+		count, err := rl.redis.Incr(ctx, redisKey)
+		if err == nil {
+			if count == 1 {
+				_ = rl.redis.Expire(ctx, redisKey, rl.window)
+			}
+			ttl, _ := rl.redis.TTL(ctx, redisKey)
+			if ttl < 0 {
+				ttl = rl.window
+			}
+			return int(count), time.Now().Add(ttl)
 		}
-
-		return c.Next()
 	}
+	return rl.memory.increment(key, rl.window)
 }
 
-// increment increments the counter for the given key
-func (rl *RateLimiter) increment(ctx context.Context, key string) (int64, error) {
-	count, err := rl.redis.Incr(ctx, key)
-	if err != nil {
-		return 0, err
-	}
+// RateLimit returns a rate limiting middleware using in-memory storage only
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	return NewRateLimiter(nil, RateLimiterConfig{Max: limit, Window: window}).Handler
+}
 
-	// Set expiry on first request
-	if count == 1 {
-		rl.redis.Expire(ctx, key, rl.window)
-	}
+type rateLimitEntry struct {
+	count  int
+	expiry time.Time
+}
 
-	return count, nil
+// memoryWindows counts requests per key for one instance
+type memoryWindows struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+	swept   time.Time
 }
 
-// RateLimit returns a simple rate limiting middleware using in-memory storage
-// Use this when Redis is not available
-func RateLimit(limit int, window time.Duration) fiber.Handler {
-	// Simple in-memory rate limiter (not suitable for distributed systems)
-	store := make(map[string]*rateLimitEntry)
-
-	return func(c *fiber.Ctx) error {
-		key := c.IP()
-		now := time.Now()
-
-		entry, exists := store[key]
-		if !exists || now.After(entry.expiry) {
-			store[key] = &rateLimitEntry{
-				count:  1,
-				expiry: now.Add(window),
-			}
-		} else {
-			entry.count++
-		}
+func (m *memoryWindows) increment(key string, window time.Duration) (int, time.Time) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		entry = store[key]
-		remaining := max(0, limit-entry.count)
-
-		// Set headers
-		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
-		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-		c.Set("X-RateLimit-Reset", strconv.FormatInt(entry.expiry.Unix(), 10))
-
-		if entry.count > limit {
-			retryAfter := int(time.Until(entry.expiry).Seconds())
-			c.Set("Retry-After", strconv.Itoa(retryAfter))
-			
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error":       "Rate limit exceeded",
-				"retry_after": retryAfter,
-			})
+	if now.Sub(m.swept) > window {
+		for k, e := range m.entries {
+			if now.After(e.expiry) {
+				delete(m.entries, k)
+			}
 		}
-
-		return c.Next()
+		m.swept = now
 	}
-}
 
-type rateLimitEntry struct {
-	count  int
-	expiry time.Time
+	entry, exists := m.entries[key]
+	if !exists || now.After(entry.expiry) {
+		entry = &rateLimitEntry{expiry: now.Add(window)}
+		m.entries[key] = entry
+	}
+	entry.count++
+	return entry.count, entry.expiry
 }
 
 // max returns the larger of two integers
@@ -177,36 +162,3 @@ func max(a, b int) int {
 	}
 	return b
 }
-
-// RateLimitByEndpoint returns rate limiting with per-endpoint limits
-func RateLimitByEndpoint(redisClient *db.RedisClient, limits map[string]RateLimiterConfig) fiber.Handler {
-	limiters := make(map[string]*RateLimiter)
-	
-	for endpoint, config := range limits {
-		limiters[endpoint] = NewRateLimiter(redisClient, config)
-	}
-
-	defaultLimiter := NewRateLimiter(redisClient, RateLimiterConfig{
-		Max:    100,
-		Window: time.Minute,
-	})
-
-	return func(c *fiber.Ctx) error {
-		path := c.Path()
-		
-		// Check for exact match first
-		if limiter, ok := limiters[path]; ok {
-			return limiter.Handler()(c)
-		}
-
-		// Check for prefix match
-		for endpoint, limiter := range limiters {
-			if len(path) >= len(endpoint) && path[:len(endpoint)] == endpoint {
-				return limiter.Handler()(c)
-			}
-		}
-
-		// Use default limiter
-		return defaultLimiter.Handler()(c)
-	}
-}
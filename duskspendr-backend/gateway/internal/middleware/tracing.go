@@ -3,9 +3,9 @@ package middleware
 
 import (
 	"context"
+	"net/http"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
@@ -19,14 +19,16 @@ const (
 	SpanIDKey TraceKey = "span_id"
 	// ParentSpanIDKey is the context key for parent span ID
 	ParentSpanIDKey TraceKey = "parent_span_id"
+	// traceContextKey is the context key for the *TraceContext
+	traceContextKey TraceKey = "trace"
 )
 
 // Trace header names (compatible with W3C Trace Context)
 const (
-	TraceIDHeader     = "X-Trace-ID"
-	SpanIDHeader      = "X-Span-ID"
-	ParentSpanHeader  = "X-Parent-Span-ID"
-	W3CTraceParent    = "traceparent"
+	TraceIDHeader    = "X-Trace-ID"
+	SpanIDHeader     = "X-Span-ID"
+	ParentSpanHeader = "X-Parent-Span-ID"
+	W3CTraceParent   = "traceparent"
 )
 
 // TraceContext holds distributed tracing information
@@ -40,67 +42,85 @@ type TraceContext struct {
 }
 
 // Tracing returns middleware that adds distributed tracing
-func Tracing(serviceName string) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		// Extract or generate trace ID
-		traceID := c.Get(TraceIDHeader)
-		if traceID == "" {
-			traceID = c.Get(W3CTraceParent)
-		}
-		if traceID == "" {
-			traceID = generateTraceID()
-		}
-
-		// Extract parent span ID (current span becomes child)
-		parentSpanID := c.Get(SpanIDHeader)
-
-		// Generate new span ID for this request
-		spanID := generateSpanID()
-
-		// Create trace context
-		traceCtx := &TraceContext{
-			TraceID:      traceID,
-			SpanID:       spanID,
-			ParentSpanID: parentSpanID,
-			StartTime:    time.Now(),
-			Service:      serviceName,
-			Operation:    c.Method() + " " + c.Path(),
-		}
-
-		// Store in context
-		ctx := context.WithValue(c.Context(), TraceIDKey, traceID)
-		ctx = context.WithValue(ctx, SpanIDKey, spanID)
-		if parentSpanID != "" {
-			ctx = context.WithValue(ctx, ParentSpanIDKey, parentSpanID)
-		}
-		c.SetUserContext(ctx)
-
-		// Store trace context in locals for easy access
-		c.Locals("trace", traceCtx)
-
-		// Set response headers for trace propagation
-		c.Set(TraceIDHeader, traceID)
-		c.Set(SpanIDHeader, spanID)
-		if parentSpanID != "" {
-			c.Set(ParentSpanHeader, parentSpanID)
-		}
-
-		// Process request
-		err := c.Next()
-
-		// Calculate duration
-		duration := time.Since(traceCtx.StartTime)
-
-		// Add duration header
-		c.Set("X-Response-Time", duration.String())
-
-		return err
+func Tracing(serviceName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Extract or generate trace ID
+			traceID := r.Header.Get(TraceIDHeader)
+			if traceID == "" {
+				traceID = r.Header.Get(W3CTraceParent)
+			}
+			if traceID == "" {
+				traceID = generateTraceID()
+			}
+
+			// Extract parent span ID (current span becomes child)
+			parentSpanID := r.Header.Get(SpanIDHeader)
+
+			// Generate new span ID for this request
+			spanID := generateSpanID()
+
+			traceCtx := &TraceContext{
+				TraceID:      traceID,
+				SpanID:       spanID,
+				ParentSpanID: parentSpanID,
+				StartTime:    time.Now(),
+				Service:      serviceName,
+				Operation:    r.Method + " " + r.URL.Path,
+			}
+
+			ctx := context.WithValue(r.Context(), TraceIDKey, traceID)
+			ctx = context.WithValue(ctx, SpanIDKey, spanID)
+			if parentSpanID != "" {
+				ctx = context.WithValue(ctx, ParentSpanIDKey, parentSpanID)
+			}
+			ctx = context.WithValue(ctx, traceContextKey, traceCtx)
+
+			// Set response headers for trace propagation
+			w.Header().Set(TraceIDHeader, traceID)
+			w.Header().Set(SpanIDHeader, spanID)
+			if parentSpanID != "" {
+				w.Header().Set(ParentSpanHeader, parentSpanID)
+			}
+
+			next.ServeHTTP(&timedWriter{ResponseWriter: w, start: traceCtx.StartTime}, r.WithContext(ctx))
+		})
 	}
 }
 
-// GetTraceContext retrieves trace context from Fiber context
-func GetTraceContext(c *fiber.Ctx) *TraceContext {
-	if trace, ok := c.Locals("trace").(*TraceContext); ok {
+// timedWriter adds X-Response-Time once the handler starts writing, the last
+// point at which headers can still be set
+type timedWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (w *timedWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("X-Response-Time", time.Since(w.start).String())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers and proxies flush through the wrapper
+func (w *timedWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// GetTraceContext retrieves the trace context set by Tracing
+func GetTraceContext(ctx context.Context) *TraceContext {
+	if trace, ok := ctx.Value(traceContextKey).(*TraceContext); ok {
 		return trace
 	}
 	return nil
@@ -138,7 +158,7 @@ func generateSpanID() string {
 // PropagateTrace creates HTTP headers for trace propagation
 func PropagateTrace(traceCtx *TraceContext) map[string]string {
 	headers := make(map[string]string)
-	
+
 	if traceCtx == nil {
 		return headers
 	}
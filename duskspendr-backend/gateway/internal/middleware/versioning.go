@@ -51,8 +51,8 @@ func APIVersioning(config VersionConfig) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var version APIVersion
 
-			// Check URL path first
-			path := r.URL.Path
+			// Check URL path first; /api/v1 is the same tree as /v1
+			path := strings.TrimPrefix(r.URL.Path, "/api")
 			if strings.HasPrefix(path, "/v1") {
 				version = APIVersionV1
 			} else if strings.HasPrefix(path, "/v2") {
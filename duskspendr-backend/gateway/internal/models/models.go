@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+  "encoding/json"
+  "time"
+)
 
 type Transaction struct {
   ID                 string    `json:"id"`
@@ -20,8 +23,16 @@ type Transaction struct {
   IsShared           bool      `json:"is_shared"`
   Tags               []string  `json:"tags"`
   Notes              *string   `json:"notes,omitempty"`
-  CreatedAt          time.Time `json:"created_at"`
-  UpdatedAt          time.Time `json:"updated_at"`
+  CounterpartyVPA    *string   `json:"counterparty_vpa,omitempty"`
+  UPIKind            *string   `json:"upi_kind,omitempty"`
+  // Set when the transaction was made in a currency other than the user's
+  // base currency; AmountPaisa is then the converted amount.
+  OriginalCurrency    *string    `json:"original_currency,omitempty"`
+  OriginalAmountMinor *int64     `json:"original_amount_minor,omitempty"`
+  FXRate              *float64   `json:"fx_rate,omitempty"`
+  FXRateDate          *time.Time `json:"fx_rate_date,omitempty"`
+  CreatedAt           time.Time  `json:"created_at"`
+  UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type TransactionInput struct {
@@ -40,6 +51,11 @@ type TransactionInput struct {
   IsShared           bool      `json:"is_shared"`
   Tags               []string  `json:"tags"`
   Notes              *string   `json:"notes,omitempty"`
+  CounterpartyVPA    *string   `json:"counterparty_vpa,omitempty"`
+  UPIKind            *string   `json:"upi_kind,omitempty"`
+  // Amount in original_currency minor units; amount_paisa may then be omitted
+  OriginalCurrency    *string `json:"original_currency,omitempty"`
+  OriginalAmountMinor *int64  `json:"original_amount_minor,omitempty"`
 }
 
 type LinkedAccount struct {
@@ -87,6 +103,31 @@ type BudgetInput struct {
   IsActive       *bool    `json:"is_active,omitempty"`
 }
 
+type ScheduledBill struct {
+  ID              string    `json:"id"`
+  Name            string    `json:"name"`
+  Type            string    `json:"type"`
+  AmountPaisa     int64     `json:"amount_paisa"`
+  Category        *string   `json:"category,omitempty"`
+  LinkedAccountID *string   `json:"linked_account_id,omitempty"`
+  Frequency       string    `json:"frequency"`
+  NextDue         string    `json:"next_due"`
+  IsActive        bool      `json:"is_active"`
+  CreatedAt       time.Time `json:"created_at"`
+  UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ScheduledBillInput struct {
+  Name            string  `json:"name"`
+  Type            string  `json:"type"`
+  AmountPaisa     int64   `json:"amount_paisa"`
+  Category        *string `json:"category,omitempty"`
+  LinkedAccountID *string `json:"linked_account_id,omitempty"`
+  Frequency       string  `json:"frequency"`
+  NextDue         string  `json:"next_due"`
+  IsActive        *bool   `json:"is_active,omitempty"`
+}
+
 type User struct {
   ID        string    `json:"id"`
   Phone     string    `json:"phone"`
@@ -99,16 +140,24 @@ type UserInput struct {
 
 type AuthStartInput struct {
   Phone string `json:"phone"`
+  // Channel is the preferred delivery channel: sms (default), whatsapp or voice
+  Channel string `json:"channel,omitempty"`
 }
 
 type AuthStartResponse struct {
   OTPID     string    `json:"otp_id"`
   ExpiresAt time.Time `json:"expires_at"`
+  // Channel is the channel the code is sent on; it differs from the
+  // requested one on a resend after an undelivered code
+  Channel string `json:"channel"`
 }
 
 type AuthVerifyInput struct {
-  Phone string `json:"phone"`
-  Code  string `json:"code"`
+  Phone      string `json:"phone"`
+  Code       string `json:"code"`
+  DeviceName string `json:"device_name,omitempty"`
+  Platform   string `json:"platform,omitempty"`
+  AppVersion string `json:"app_version,omitempty"`
 }
 
 type AuthVerifyResponse struct {
@@ -117,25 +166,129 @@ type AuthVerifyResponse struct {
   ExpiresAt time.Time `json:"expires_at"`
 }
 
+// AuthTokenResponse is the JWT pair issued by /api/v1/auth/verify
+type AuthTokenResponse struct {
+  AccessToken  string    `json:"access_token"`
+  RefreshToken string    `json:"refresh_token"`
+  UserID       string    `json:"user_id"`
+  ExpiresIn    int       `json:"expires_in"`
+  ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// PasskeyRegisterInput finishes a passkey registration. Credential is the
+// PublicKeyCredential returned by navigator.credentials.create.
+type PasskeyRegisterInput struct {
+  ChallengeID string          `json:"challenge_id"`
+  Name        string          `json:"name,omitempty"`
+  Credential  json.RawMessage `json:"credential"`
+}
+
+// PasskeyLoginInput finishes a passkey sign-in. Credential is the
+// PublicKeyCredential returned by navigator.credentials.get; the device
+// fields are recorded on the session as in AuthVerifyInput.
+type PasskeyLoginInput struct {
+  ChallengeID string          `json:"challenge_id"`
+  Credential  json.RawMessage `json:"credential"`
+  DeviceName  string          `json:"device_name,omitempty"`
+  Platform    string          `json:"platform,omitempty"`
+  AppVersion  string          `json:"app_version,omitempty"`
+}
+
+// PasskeyChallengeResponse starts a ceremony. Options is passed to
+// navigator.credentials.create or .get as is.
+type PasskeyChallengeResponse struct {
+  ChallengeID string    `json:"challenge_id"`
+  Options     any       `json:"options"`
+  ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TOTPEnrollResponse carries a pending TOTP secret. OTPAuthURI is shown as
+// a QR code; Secret is for manual entry.
+type TOTPEnrollResponse struct {
+  Secret     string `json:"secret"`
+  OTPAuthURI string `json:"otpauth_uri"`
+  Digits     int    `json:"digits"`
+  Period     int    `json:"period"`
+}
+
+type TOTPCodeInput struct {
+  Code string `json:"code"`
+}
+
+// TOTPRecoveryCodesResponse shows recovery codes once; only hashes are kept
+type TOTPRecoveryCodesResponse struct {
+  RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// StepUpInput proves a second factor: a TOTP code, a recovery code, or for
+// users without TOTP the code from POST /auth/start
+type StepUpInput struct {
+  Code         string `json:"code,omitempty"`
+  RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// StepUpResponse is a short-lived access token carrying the elevated claim
+type StepUpResponse struct {
+  AccessToken   string    `json:"access_token"`
+  ExpiresIn     int       `json:"expires_in"`
+  ElevatedUntil time.Time `json:"elevated_until"`
+}
+
+type AuthRefreshInput struct {
+  RefreshToken string `json:"refresh_token"`
+}
+
+type AuthRefreshResponse struct {
+  AccessToken  string `json:"access_token"`
+  RefreshToken string `json:"refresh_token"`
+  ExpiresIn    int    `json:"expires_in"`
+}
+
 type SyncIngestItem struct {
-  ID                 string   `json:"id"`
-  AmountPaisa        int64    `json:"amount_paisa"`
-  Type               string   `json:"type"`
-  Category           string   `json:"category"`
-  MerchantName       *string  `json:"merchant_name,omitempty"`
-  Description        *string  `json:"description,omitempty"`
-  Timestamp          time.Time `json:"timestamp"`
-  Source             string   `json:"source"`
-  PaymentMethod      *string  `json:"payment_method,omitempty"`
-  LinkedAccountID    *string  `json:"linked_account_id,omitempty"`
-  ReferenceID        *string  `json:"reference_id,omitempty"`
-  CategoryConfidence *float64 `json:"category_confidence,omitempty"`
-  IsRecurring        bool     `json:"is_recurring"`
-  IsShared           bool     `json:"is_shared"`
-  Tags               []string `json:"tags"`
-  Notes              *string  `json:"notes,omitempty"`
+  ID                  string    `json:"id"`
+  AmountPaisa         int64     `json:"amount_paisa"`
+  Type                string    `json:"type"`
+  Category            string    `json:"category"`
+  MerchantName        *string   `json:"merchant_name,omitempty"`
+  Description         *string   `json:"description,omitempty"`
+  Timestamp           time.Time `json:"timestamp"`
+  Source              string    `json:"source"`
+  PaymentMethod       *string   `json:"payment_method,omitempty"`
+  LinkedAccountID     *string   `json:"linked_account_id,omitempty"`
+  ReferenceID         *string   `json:"reference_id,omitempty"`
+  CategoryConfidence  *float64  `json:"category_confidence,omitempty"`
+  IsRecurring         bool      `json:"is_recurring"`
+  IsShared            bool      `json:"is_shared"`
+  Tags                []string  `json:"tags"`
+  Notes               *string   `json:"notes,omitempty"`
+  CounterpartyVPA     *string   `json:"counterparty_vpa,omitempty"`
+  UPIKind             *string   `json:"upi_kind,omitempty"`
+  OriginalCurrency    *string   `json:"original_currency,omitempty"`
+  OriginalAmountMinor *int64    `json:"original_amount_minor,omitempty"`
+  // Raw SMS for source "sms"; missing fields are filled in by the server parser
+  SMSSender *string `json:"sms_sender,omitempty"`
+  SMSBody   *string `json:"sms_body,omitempty"`
 }
 
 type SyncIngestRequest struct {
   Items []SyncIngestItem `json:"items"`
 }
+
+// ErasureReceipt describes an account deletion: scheduled, cancelled, or
+// erased with the rows removed per category. ReceiptToken is only returned
+// when the deletion is requested; it is needed to read the receipt once
+// the account is gone.
+type ErasureReceipt struct {
+  ReceiptID    string           `json:"receipt_id"`
+  ReceiptToken string           `json:"receipt_token,omitempty"`
+  Status       string           `json:"status"`
+  RequestedAt  time.Time        `json:"requested_at"`
+  ScheduledFor time.Time        `json:"scheduled_for"`
+  CancelledAt  *time.Time       `json:"cancelled_at,omitempty"`
+  ErasedAt     *time.Time       `json:"erased_at,omitempty"`
+  Erased       map[string]int64 `json:"erased,omitempty"`
+  // Serverpod is the status of the erasure sent to serverpod: pending,
+  // delivered or failed
+  Serverpod            string     `json:"serverpod_status,omitempty"`
+  ServerpodDeliveredAt *time.Time `json:"serverpod_delivered_at,omitempty"`
+}
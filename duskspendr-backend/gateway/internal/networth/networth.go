@@ -0,0 +1,162 @@
+// Package networth aggregates a user's position: linked account balances,
+// investment holdings, and manually entered assets and liabilities. Every
+// value is in the user's base currency, in paisa.
+package networth
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Kinds
+const (
+	Asset     = "asset"
+	Liability = "liability"
+)
+
+// Categories of the sources the service reads itself
+const (
+	CategoryBank        = "bank"
+	CategoryInvestments = "investments"
+)
+
+// Sources
+const (
+	SourceAccount    = "account"
+	SourceInvestment = "investment"
+	SourceManual     = "manual"
+)
+
+// manualCategories are the categories a manual item of each kind may use
+var manualCategories = map[string][]string{
+	Asset:     {"gold", "property", "vehicle", "cash", "deposit", "other"},
+	Liability: {"loan", "credit_card", "bnpl", "other"},
+}
+
+// ValidManual reports whether category is allowed for a manual item of kind
+func ValidManual(kind, category string) bool {
+	for _, c := range manualCategories[kind] {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Item is one asset or liability
+type Item struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Category   string    `json:"category"`
+	Name       string    `json:"name"`
+	ValuePaisa int64     `json:"value_paisa"`
+	Source     string    `json:"source"`
+	AsOf       time.Time `json:"as_of"`
+}
+
+// CategoryTotal sums the items of one category
+type CategoryTotal struct {
+	Category   string `json:"category"`
+	ValuePaisa int64  `json:"value_paisa"`
+	Count      int    `json:"count"`
+}
+
+// Summary is the position at a point in time. Liabilities are positive
+// amounts owed.
+type Summary struct {
+	AsOf             time.Time       `json:"as_of"`
+	AssetsPaisa      int64           `json:"assets_paisa"`
+	LiabilitiesPaisa int64           `json:"liabilities_paisa"`
+	NetWorthPaisa    int64           `json:"net_worth_paisa"`
+	Assets           []CategoryTotal `json:"assets"`
+	Liabilities      []CategoryTotal `json:"liabilities"`
+	Items            []Item          `json:"items"`
+}
+
+// Compute totals items by kind and category, largest category first
+func Compute(items []Item, asOf time.Time) Summary {
+	s := Summary{AsOf: asOf, Items: items, Assets: []CategoryTotal{}, Liabilities: []CategoryTotal{}}
+	if s.Items == nil {
+		s.Items = []Item{}
+	}
+	totals := map[string]map[string]*CategoryTotal{Asset: {}, Liability: {}}
+	for _, it := range items {
+		byCat, ok := totals[it.Kind]
+		if !ok {
+			continue
+		}
+		t := byCat[it.Category]
+		if t == nil {
+			t = &CategoryTotal{Category: it.Category}
+			byCat[it.Category] = t
+		}
+		t.ValuePaisa += it.ValuePaisa
+		t.Count++
+		if it.Kind == Asset {
+			s.AssetsPaisa += it.ValuePaisa
+		} else {
+			s.LiabilitiesPaisa += it.ValuePaisa
+		}
+	}
+	s.NetWorthPaisa = s.AssetsPaisa - s.LiabilitiesPaisa
+	s.Assets = sortedTotals(totals[Asset])
+	s.Liabilities = sortedTotals(totals[Liability])
+	return s
+}
+
+func sortedTotals(m map[string]*CategoryTotal) []CategoryTotal {
+	out := make([]CategoryTotal, 0, len(m))
+	for _, t := range m {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ValuePaisa != out[j].ValuePaisa {
+			return out[i].ValuePaisa > out[j].ValuePaisa
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out
+}
+
+// Point is a stored daily snapshot
+type Point struct {
+	Date             string `json:"date"`
+	AssetsPaisa      int64  `json:"assets_paisa"`
+	LiabilitiesPaisa int64  `json:"liabilities_paisa"`
+	NetWorthPaisa    int64  `json:"net_worth_paisa"`
+}
+
+// Trend describes the movement over a series of snapshots
+type Trend struct {
+	Points      []Point  `json:"points"`
+	ChangePaisa int64    `json:"change_paisa"`
+	ChangePct   *float64 `json:"change_pct"`
+}
+
+// NewTrend compares the last point with the first. ChangePct is nil when
+// the first net worth is zero.
+func NewTrend(points []Point) Trend {
+	t := Trend{Points: points}
+	if t.Points == nil {
+		t.Points = []Point{}
+	}
+	if len(points) < 2 {
+		return t
+	}
+	first, last := points[0].NetWorthPaisa, points[len(points)-1].NetWorthPaisa
+	t.ChangePaisa = last - first
+	if first != 0 {
+		pct := float64(t.ChangePaisa) / float64(abs(first)) * 100
+		pct = math.Round(pct*100) / 100
+		t.ChangePct = &pct
+	}
+	return t
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
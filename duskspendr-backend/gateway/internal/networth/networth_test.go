@@ -0,0 +1,58 @@
+package networth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompute(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	items := []Item{
+		{Kind: Asset, Category: CategoryBank, ValuePaisa: 5000000, Source: SourceAccount},
+		{Kind: Asset, Category: CategoryBank, ValuePaisa: -100000, Source: SourceAccount},
+		{Kind: Asset, Category: CategoryInvestments, ValuePaisa: 12000000, Source: SourceInvestment},
+		{Kind: Asset, Category: "gold", ValuePaisa: 3000000, Source: SourceManual},
+		{Kind: Liability, Category: "credit_card", ValuePaisa: 400000, Source: SourceManual},
+		{Kind: Liability, Category: "loan", ValuePaisa: 8000000, Source: SourceManual},
+	}
+	s := Compute(items, now)
+	if s.AssetsPaisa != 19900000 || s.LiabilitiesPaisa != 8400000 || s.NetWorthPaisa != 11500000 {
+		t.Fatalf("totals = %d %d %d", s.AssetsPaisa, s.LiabilitiesPaisa, s.NetWorthPaisa)
+	}
+	if len(s.Assets) != 3 || s.Assets[0].Category != CategoryInvestments || s.Assets[2].Category != "gold" {
+		t.Errorf("assets = %+v", s.Assets)
+	}
+	if s.Assets[1].Count != 2 || s.Assets[1].ValuePaisa != 4900000 {
+		t.Errorf("bank = %+v", s.Assets[1])
+	}
+	if len(s.Liabilities) != 2 || s.Liabilities[0].Category != "loan" {
+		t.Errorf("liabilities = %+v", s.Liabilities)
+	}
+
+	empty := Compute(nil, now)
+	if empty.Items == nil || empty.Assets == nil || empty.NetWorthPaisa != 0 {
+		t.Errorf("empty = %+v", empty)
+	}
+}
+
+func TestValidManual(t *testing.T) {
+	if !ValidManual(Asset, "gold") || !ValidManual(Liability, "bnpl") {
+		t.Error("expected valid categories")
+	}
+	if ValidManual(Asset, "loan") || ValidManual(Liability, "gold") || ValidManual("equity", "other") {
+		t.Error("expected invalid categories")
+	}
+}
+
+func TestNewTrend(t *testing.T) {
+	tr := NewTrend([]Point{{NetWorthPaisa: -200000}, {NetWorthPaisa: 100000}, {NetWorthPaisa: 300000}})
+	if tr.ChangePaisa != 500000 || tr.ChangePct == nil || *tr.ChangePct != 250 {
+		t.Errorf("trend = %+v", tr)
+	}
+	if tr := NewTrend([]Point{{NetWorthPaisa: 0}, {NetWorthPaisa: 100}}); tr.ChangePct != nil {
+		t.Errorf("zero start pct = %v", *tr.ChangePct)
+	}
+	if tr := NewTrend(nil); tr.Points == nil || tr.ChangePaisa != 0 {
+		t.Errorf("empty trend = %+v", tr)
+	}
+}
@@ -0,0 +1,172 @@
+package networth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Load returns every asset and liability of the user. Accounts without a
+// known balance are left out.
+func Load(ctx context.Context, q Querier, userID string) ([]Item, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id::text, 'asset', 'bank', coalesce(account_name, provider), balance_paisa,
+		       'account', last_synced_at
+		  FROM linked_accounts
+		 WHERE user_id = $1 AND balance_paisa IS NOT NULL
+		UNION ALL
+		SELECT id::text, 'asset', 'investments', coalesce(name, symbol), value_paisa,
+		       'investment', as_of
+		  FROM investment_holdings
+		 WHERE user_id = $1
+		UNION ALL
+		SELECT id::text, kind, category, name, value_paisa, 'manual', as_of
+		  FROM networth_items
+		 WHERE user_id = $1
+		 ORDER BY 2, 3, 5 DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Item{}
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.ID, &it.Kind, &it.Category, &it.Name, &it.ValuePaisa, &it.Source, &it.AsOf); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// SaveSnapshot stores s as the user's snapshot for date, replacing an
+// earlier one from the same day.
+func SaveSnapshot(ctx context.Context, q Querier, userID string, date time.Time, s Summary) error {
+	breakdown, err := json.Marshal(map[string]any{"assets": s.Assets, "liabilities": s.Liabilities})
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO networth_snapshots (
+		  user_id, snapshot_date, assets_paisa, liabilities_paisa, net_worth_paisa, breakdown, created_at
+		) VALUES ($1, $2::date, $3, $4, $5, $6, now())
+		ON CONFLICT (user_id, snapshot_date) DO UPDATE SET
+		  assets_paisa = EXCLUDED.assets_paisa,
+		  liabilities_paisa = EXCLUDED.liabilities_paisa,
+		  net_worth_paisa = EXCLUDED.net_worth_paisa,
+		  breakdown = EXCLUDED.breakdown,
+		  created_at = EXCLUDED.created_at
+	`, userID, date.Format("2006-01-02"), s.AssetsPaisa, s.LiabilitiesPaisa, s.NetWorthPaisa, breakdown)
+	return err
+}
+
+// History returns the user's snapshots from from to to inclusive
+func History(ctx context.Context, q Querier, userID string, from, to time.Time) ([]Point, error) {
+	rows, err := q.Query(ctx, `
+		SELECT to_char(snapshot_date, 'YYYY-MM-DD'), assets_paisa, liabilities_paisa, net_worth_paisa
+		  FROM networth_snapshots
+		 WHERE user_id = $1 AND snapshot_date >= $2::date AND snapshot_date <= $3::date
+		 ORDER BY snapshot_date
+	`, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	points := []Point{}
+	for rows.Next() {
+		var p Point
+		if err := rows.Scan(&p.Date, &p.AssetsPaisa, &p.LiabilitiesPaisa, &p.NetWorthPaisa); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// Snapshotter stores a daily snapshot for every user
+type Snapshotter struct {
+	Pool *pgxpool.Pool
+	// Location defines the snapshot date
+	Location  *time.Location
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Run snapshots users missing today's snapshot every Interval until ctx
+// is cancelled.
+func (s *Snapshotter) Run(ctx context.Context) {
+	if s.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.SnapshotOnce(ctx, time.Now()); err != nil {
+			log.Printf("networth snapshot failed: %v", err)
+		} else if n > 0 {
+			log.Printf("networth: snapshotted %d users", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SnapshotOnce snapshots up to BatchSize users without a snapshot for the
+// local date of now.
+func (s *Snapshotter) SnapshotOnce(ctx context.Context, now time.Time) (int, error) {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	batch := s.BatchSize
+	if batch <= 0 {
+		batch = 500
+	}
+	date := now.In(loc)
+	done := 0
+	for {
+		rows, err := s.Pool.Query(ctx, `
+			SELECT u.id::text FROM users u
+			 WHERE NOT EXISTS (
+			   SELECT 1 FROM networth_snapshots n
+			    WHERE n.user_id = u.id AND n.snapshot_date = $1::date)
+			 ORDER BY u.id
+			 LIMIT $2
+		`, date.Format("2006-01-02"), batch)
+		if err != nil {
+			return done, err
+		}
+		users, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return done, err
+		}
+		for _, u := range users {
+			items, err := Load(ctx, s.Pool, u)
+			if err != nil {
+				return done, err
+			}
+			if err := SaveSnapshot(ctx, s.Pool, u, date, Compute(items, now)); err != nil {
+				return done, err
+			}
+			done++
+		}
+		if len(users) < batch {
+			return done, nil
+		}
+	}
+}
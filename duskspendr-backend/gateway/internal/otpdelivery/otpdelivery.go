@@ -0,0 +1,96 @@
+// Package otpdelivery records how each OTP was sent: every provider
+// attempt on every channel tried and the delivery reports providers post
+// back afterwards.
+package otpdelivery
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"duskspendr-gateway/internal/services"
+)
+
+// StatusPending is reported for an OTP with no recorded attempt yet
+const StatusPending = "pending"
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Status is the delivery state of an OTP: that of its latest attempt
+type Status struct {
+	Status    string    `json:"status"`
+	Channel   string    `json:"channel,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Attempts  int       `json:"attempts"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// Record stores the attempts on channel behind receipt: one failed row per
+// provider that rejected the code and, if sendErr is nil, a sent row for the
+// one that accepted it
+func Record(ctx context.Context, q Querier, otpID, channel string, receipt services.SMSReceipt, sendErr error) error {
+	for _, f := range receipt.Failed {
+		if err := insert(ctx, q, otpID, channel, f.Provider, "", services.SMSStatusFailed, f.Err.Error()); err != nil {
+			return err
+		}
+	}
+	if sendErr != nil {
+		if len(receipt.Failed) == 0 {
+			return insert(ctx, q, otpID, channel, receipt.Provider, "", services.SMSStatusFailed, sendErr.Error())
+		}
+		return nil
+	}
+	return insert(ctx, q, otpID, channel, receipt.Provider, receipt.MessageID, services.SMSStatusSent, "")
+}
+
+func insert(ctx context.Context, q Querier, otpID, channel, provider, messageID, status, detail string) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO otp_deliveries (otp_id, channel, provider, provider_message_id, status, detail)
+		VALUES ($1, $2, $3, nullif($4, ''), $5, nullif(left($6, 500), ''))
+	`, otpID, channel, provider, messageID, status, detail)
+	return err
+}
+
+// ApplyReports updates attempts of provider from its delivery reports and
+// returns how many matched. A final state is never moved back to sent by a
+// late intermediate report.
+func ApplyReports(ctx context.Context, q Querier, provider string, reports []services.SMSDeliveryReport) (int, error) {
+	matched := 0
+	for _, rep := range reports {
+		tag, err := q.Exec(ctx, `
+			UPDATE otp_deliveries
+			   SET status = $3, detail = nullif(left($4, 500), ''), updated_at = now()
+			 WHERE provider = $1 AND provider_message_id = $2
+			   AND ($3 <> 'sent' OR status = 'sent')
+		`, provider, rep.MessageID, rep.Status, rep.Detail)
+		if err != nil {
+			return matched, err
+		}
+		matched += int(tag.RowsAffected())
+	}
+	return matched, nil
+}
+
+// Latest returns the delivery status of an OTP
+func Latest(ctx context.Context, q Querier, otpID string) (Status, error) {
+	s := Status{Status: StatusPending}
+	err := q.QueryRow(ctx, `
+		SELECT status, channel, provider, updated_at, count(*) OVER ()
+		  FROM otp_deliveries
+		 WHERE otp_id = $1
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT 1
+	`, otpID).Scan(&s.Status, &s.Channel, &s.Provider, &s.UpdatedAt, &s.Attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, nil
+	}
+	return s, err
+}
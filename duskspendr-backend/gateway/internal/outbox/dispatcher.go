@@ -0,0 +1,262 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sender delivers a signed payload to serverpod. *serverpod.Client satisfies it.
+type Sender interface {
+	SyncTransactions(ctx context.Context, userID, idempotencyKey string, payload []byte) error
+}
+
+// retryable is implemented by errors that know whether a retry can help
+type retryable interface {
+	Retryable() bool
+}
+
+// Dispatcher polls the outbox and delivers pending events
+type Dispatcher struct {
+	Pool         *pgxpool.Pool
+	Sender       Sender
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// Lease is how long a claimed event is withheld from other replicas
+	// while it is being sent
+	Lease time.Duration
+}
+
+// NewDispatcher creates a dispatcher with default batching and backoff
+func NewDispatcher(pool *pgxpool.Pool, sender Sender, pollInterval time.Duration, maxAttempts int) *Dispatcher {
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	return &Dispatcher{
+		Pool:         pool,
+		Sender:       sender,
+		BatchSize:    50,
+		PollInterval: pollInterval,
+		MaxAttempts:  maxAttempts,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		Lease:        5 * time.Minute,
+	}
+}
+
+// Run dispatches until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.DispatchOnce(ctx)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					log.Printf("sync outbox dispatch failed: %v", err)
+				}
+				break
+			}
+			// Drain quickly while there is a backlog; a user's next event
+			// only becomes claimable once the previous one is delivered
+			if n == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type pendingEvent struct {
+	id             string
+	userID         string
+	idempotencyKey string
+	payload        []byte
+	attempts       int
+}
+
+// DispatchOnce delivers one batch of due events and returns how many were
+// claimed. Each event is claimed in a short transaction that leases it by
+// pushing next_attempt_at out by Lease, sent with no transaction open, and
+// its result recorded in a second transaction, so replicas can dispatch
+// concurrently without holding connections or locks across HTTP calls.
+//
+// Only the oldest pending event of a user is ever claimed. Events of one
+// user therefore reach serverpod one at a time and in order, so a delete
+// cannot overtake the upsert it follows.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	lease := d.Lease
+	if lease <= 0 {
+		lease = 5 * time.Minute
+	}
+	claimedAt := time.Now()
+	events, err := d.claim(ctx, lease)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	type result struct {
+		event pendingEvent
+		err   error
+		sent  bool
+	}
+	results := make([]result, 0, len(events))
+	for _, e := range events {
+		// Leave the rest for the next claim rather than send after the lease
+		// has run out and another replica may have taken the event
+		if ctx.Err() != nil || time.Since(claimedAt) > lease/2 {
+			results = append(results, result{event: e})
+			continue
+		}
+		err := d.Sender.SyncTransactions(ctx, e.userID, e.idempotencyKey, e.payload)
+		results = append(results, result{event: e, err: err, sent: true})
+	}
+
+	// Record results even when ctx was cancelled mid-batch
+	ctx = context.WithoutCancel(ctx)
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now().UTC()
+	for _, res := range results {
+		e := res.event
+		attempts := e.attempts + 1
+		switch {
+		case !res.sent:
+			_, err = tx.Exec(ctx, `
+				UPDATE sync_outbox SET next_attempt_at = $1 WHERE id = $2 AND status = $3
+			`, now, e.id, StatusPending)
+		case res.err == nil:
+			_, err = tx.Exec(ctx, `
+				UPDATE sync_outbox
+				   SET status = $1, attempts = $2, delivered_at = $3, last_error = NULL
+				 WHERE id = $4
+			`, StatusDelivered, attempts, now, e.id)
+		default:
+			status := StatusPending
+			var r retryable
+			if attempts >= d.MaxAttempts || (errors.As(res.err, &r) && !r.Retryable()) {
+				status = StatusFailed
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE sync_outbox
+				   SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4
+				 WHERE id = $5
+			`, status, attempts, now.Add(Backoff(attempts, d.BaseBackoff, d.MaxBackoff)), truncateError(res.err), e.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// claim leases up to BatchSize due events, each the oldest pending event of
+// its user. An event whose lease expires without a result, because its
+// replica died, becomes due again.
+func (d *Dispatcher) claim(ctx context.Context, lease time.Duration) ([]pendingEvent, error) {
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		WITH due AS (
+		  SELECT o.id
+		    FROM sync_outbox o
+		   WHERE o.status = $1 AND o.next_attempt_at <= now()
+		     AND NOT EXISTS (
+		       SELECT 1 FROM sync_outbox p
+		        WHERE p.user_id = o.user_id AND p.status = $1
+		          AND (p.created_at, p.id) < (o.created_at, o.id)
+		     )
+		   ORDER BY o.created_at
+		   LIMIT $2
+		   FOR UPDATE SKIP LOCKED
+		)
+		UPDATE sync_outbox s
+		   SET next_attempt_at = now() + make_interval(secs => $3)
+		  FROM due
+		 WHERE s.id = due.id
+		RETURNING s.id, s.user_id, s.idempotency_key, s.payload, s.attempts, s.created_at
+	`, StatusPending, d.BatchSize, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	type claimed struct {
+		pendingEvent
+		createdAt time.Time
+	}
+	var all []claimed
+	for rows.Next() {
+		var c claimed
+		if err := rows.Scan(&c.id, &c.userID, &c.idempotencyKey, &c.payload, &c.attempts, &c.createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		all = append(all, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// RETURNING has no order; send oldest first
+	sort.Slice(all, func(i, j int) bool { return all[i].createdAt.Before(all[j].createdAt) })
+	events := make([]pendingEvent, len(all))
+	for i, c := range all {
+		events[i] = c.pendingEvent
+	}
+	return events, nil
+}
+
+// Backoff returns the delay before the given attempt is retried, doubling
+// from base and capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt <= 1 {
+		return base
+	}
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > 500 {
+		return msg[:500]
+	}
+	return msg
+}
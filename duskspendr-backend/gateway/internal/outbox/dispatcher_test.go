@@ -0,0 +1,128 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestBackoff(t *testing.T) {
+	base := 5 * time.Second
+	max := time.Minute
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{4, 40 * time.Second},
+		{5, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tc := range cases {
+		if got := Backoff(tc.attempt, base, max); got != tc.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+// testPool connects to TEST_DATABASE_URL, a database with the migrations
+// applied, and skips the test when it is not set
+func testPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// orderSender records deliveries and fails the test when two events of one
+// user are in flight at once
+type orderSender struct {
+	t         *testing.T
+	mu        sync.Mutex
+	inFlight  map[string]bool
+	delivered []string
+}
+
+func (s *orderSender) SyncTransactions(ctx context.Context, userID, key string, payload []byte) error {
+	s.mu.Lock()
+	if s.inFlight[userID] {
+		s.t.Errorf("two events of user %s in flight", userID)
+	}
+	s.inFlight[userID] = true
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight[userID] = false
+	s.delivered = append(s.delivered, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestDispatchDeliversEachUserInOrder(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	userID := uuid.NewString()
+	if _, err := pool.Exec(ctx, `INSERT INTO users (id, phone) VALUES ($1, $2)`, userID, "+91"+userID[:10]); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), `DELETE FROM sync_outbox WHERE user_id = $1`, userID)
+		pool.Exec(context.Background(), `DELETE FROM users WHERE id = $1`, userID)
+	})
+	keys := []string{}
+	for i, typ := range []string{EventTransactionsUpserted, EventTransactionsDeleted, EventTransactionsUpserted} {
+		key := fmt.Sprintf("%s:%d", userID, i)
+		if err := Enqueue(ctx, pool, Event{UserID: userID, Type: typ, IdempotencyKey: key}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	// Two replicas dispatching at the same time
+	sender := &orderSender{t: t, inFlight: map[string]bool{}}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := NewDispatcher(pool, sender, time.Second, 3)
+			for tries := 0; tries < 20; tries++ {
+				if _, err := d.DispatchOnce(ctx); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	mine := []string{}
+	for _, k := range sender.delivered {
+		if strings.HasPrefix(k, userID) {
+			mine = append(mine, k)
+		}
+	}
+	if strings.Join(mine, ",") != strings.Join(keys, ",") {
+		t.Errorf("delivered %v, want %v", mine, keys)
+	}
+}
@@ -0,0 +1,85 @@
+// Package outbox implements a transactional outbox for serverpod sync events.
+//
+// Handlers write events with Enqueue inside the same database transaction as
+// the data change; the Dispatcher later delivers them to serverpod with retries.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Event types written to the outbox
+const (
+	EventTransactionsUpserted = "transactions.upserted"
+	EventTransactionsDeleted  = "transactions.deleted"
+	EventTransactionsSnapshot = "transactions.snapshot"
+	// EventUserErased tells serverpod to erase everything synced for the
+	// user; it outlives the user, whose row is already gone
+	EventUserErased = "user.erased"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Execer is satisfied by pgx.Tx, *pgxpool.Pool and *pgx.Conn.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Event is a sync event destined for serverpod
+type Event struct {
+	UserID         string
+	Type           string
+	IdempotencyKey string
+	Data           any
+}
+
+// Envelope is the JSON body delivered to serverpod
+type Envelope struct {
+	Event          string    `json:"event"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	Data           any       `json:"data"`
+}
+
+// Enqueue writes an event to the outbox using q, which should be the
+// transaction that performs the data change so both commit or neither does.
+// Enqueueing an idempotency key that already exists is a no-op.
+func Enqueue(ctx context.Context, q Execer, e Event) error {
+	if e.UserID == "" || e.Type == "" {
+		return errors.New("outbox: user id and event type are required")
+	}
+	if e.IdempotencyKey == "" {
+		e.IdempotencyKey = uuid.New().String()
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(Envelope{
+		Event:          e.Type,
+		IdempotencyKey: e.IdempotencyKey,
+		OccurredAt:     now,
+		Data:           e.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO sync_outbox (
+			id, user_id, event_type, idempotency_key, payload, status,
+			attempts, next_attempt_at, created_at
+		) VALUES ($1,$2,$3,$4,$5,$6,0,$7,$7)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`, uuid.New().String(), e.UserID, e.Type, e.IdempotencyKey, payload, StatusPending, now)
+	return err
+}
@@ -0,0 +1,112 @@
+// Package passkey stores WebAuthn credentials and pending ceremonies so a
+// user who has verified their phone can sign in with a passkey instead of
+// an SMS code.
+package passkey
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Ceremonies a challenge is issued for
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+var (
+	// ErrNotFound is returned for a passkey the user does not own
+	ErrNotFound = errors.New("passkey not found")
+	// ErrChallengeNotFound is returned for an unknown, used or expired challenge
+	ErrChallengeNotFound = errors.New("challenge not found or expired")
+	// ErrPhoneNotVerified is returned when registering before the phone was
+	// verified by OTP
+	ErrPhoneNotVerified = errors.New("phone not verified")
+	// ErrSignCount is returned when an assertion's signature counter did not
+	// advance past the stored one, which signals a cloned authenticator or a
+	// replayed assertion
+	ErrSignCount = errors.New("signature counter did not increase")
+)
+
+// User is a DuskSpendr user as seen by the WebAuthn library. Handle is a
+// random user handle so authenticators never hold the account id or phone.
+type User struct {
+	ID          string
+	Handle      []byte
+	Phone       string
+	Credentials []webauthn.Credential
+}
+
+// WebAuthnID implements webauthn.User
+func (u *User) WebAuthnID() []byte { return u.Handle }
+
+// WebAuthnName implements webauthn.User
+func (u *User) WebAuthnName() string { return u.Phone }
+
+// WebAuthnDisplayName implements webauthn.User
+func (u *User) WebAuthnDisplayName() string { return u.Phone }
+
+// WebAuthnIcon implements webauthn.User
+func (u *User) WebAuthnIcon() string { return "" }
+
+// WebAuthnCredentials implements webauthn.User
+func (u *User) WebAuthnCredentials() []webauthn.Credential { return u.Credentials }
+
+// Exclusions lists the user's credentials so an authenticator is not
+// registered twice
+func (u *User) Exclusions() []protocol.CredentialDescriptor {
+	out := make([]protocol.CredentialDescriptor, 0, len(u.Credentials))
+	for _, c := range u.Credentials {
+		out = append(out, c.Descriptor())
+	}
+	return out
+}
+
+// Find returns the stored credential of u with the given credential id
+func (u *User) Find(credentialID []byte) (webauthn.Credential, bool) {
+	for _, c := range u.Credentials {
+		if bytes.Equal(c.ID, credentialID) {
+			return c, true
+		}
+	}
+	return webauthn.Credential{}, false
+}
+
+// Passkey is a registered credential as listed to its owner
+type Passkey struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+}
+
+// CheckSignCount applies the signature counter rule to a credential that
+// the library verified against stored. Authenticators that do not
+// implement a counter always report zero and pass; otherwise the counter
+// must strictly increase.
+func CheckSignCount(stored uint32, verified *webauthn.Credential) error {
+	if verified.Authenticator.CloneWarning {
+		return ErrSignCount
+	}
+	next := verified.Authenticator.SignCount
+	if (next != 0 || stored != 0) && next <= stored {
+		return ErrSignCount
+	}
+	return nil
+}
+
+// clipName trims a passkey name and caps it at 100 runes
+func clipName(name string) string {
+	name = strings.TrimSpace(name)
+	if r := []rune(name); len(r) > 100 {
+		name = strings.TrimSpace(string(r[:100]))
+	}
+	return name
+}
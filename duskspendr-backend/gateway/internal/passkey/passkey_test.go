@@ -0,0 +1,67 @@
+package passkey
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+func TestCheckSignCount(t *testing.T) {
+	cases := []struct {
+		name   string
+		stored uint32
+		next   uint32
+		clone  bool
+		want   error
+	}{
+		{"no counter", 0, 0, false, nil},
+		{"first use", 0, 1, false, nil},
+		{"increased", 7, 8, false, nil},
+		{"repeated", 7, 7, false, ErrSignCount},
+		{"went back", 7, 3, false, ErrSignCount},
+		{"counter dropped to zero", 7, 0, false, ErrSignCount},
+		{"library flagged clone", 7, 8, true, ErrSignCount},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cred := &webauthn.Credential{Authenticator: webauthn.Authenticator{SignCount: c.next, CloneWarning: c.clone}}
+			if err := CheckSignCount(c.stored, cred); !errors.Is(err, c.want) {
+				t.Fatalf("CheckSignCount(%d, %d) = %v, want %v", c.stored, c.next, err, c.want)
+			}
+		})
+	}
+}
+
+func TestUserCredentials(t *testing.T) {
+	u := &User{
+		Handle: []byte("handle"),
+		Phone:  "+919999999999",
+		Credentials: []webauthn.Credential{
+			{ID: []byte("a"), Authenticator: webauthn.Authenticator{SignCount: 4}},
+			{ID: []byte("b")},
+		},
+	}
+	if string(u.WebAuthnID()) != "handle" || u.WebAuthnName() != u.Phone {
+		t.Fatalf("user entity = %q %q", u.WebAuthnID(), u.WebAuthnName())
+	}
+	if ex := u.Exclusions(); len(ex) != 2 || string(ex[1].CredentialID) != "b" {
+		t.Fatalf("exclusions = %+v", ex)
+	}
+	if c, ok := u.Find([]byte("a")); !ok || c.Authenticator.SignCount != 4 {
+		t.Fatalf("Find(a) = %+v %v", c, ok)
+	}
+	if _, ok := u.Find([]byte("c")); ok {
+		t.Fatal("found a credential the user does not own")
+	}
+}
+
+func TestClipName(t *testing.T) {
+	if got := clipName("  Pixel 8  "); got != "Pixel 8" {
+		t.Fatalf("clipName = %q", got)
+	}
+	if got := clipName(strings.Repeat("é", 150)); len([]rune(got)) != 100 {
+		t.Fatalf("clipName kept %d runes", len([]rune(got)))
+	}
+}
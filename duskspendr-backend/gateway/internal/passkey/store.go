@@ -0,0 +1,212 @@
+package passkey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// LoadUser loads a user for registration, creating their user handle on
+// first use. Users whose phone was never verified get ErrPhoneNotVerified.
+func LoadUser(ctx context.Context, q Querier, userID string) (*User, error) {
+	handle := make([]byte, 32)
+	if _, err := rand.Read(handle); err != nil {
+		return nil, err
+	}
+	u := &User{ID: userID}
+	var verifiedAt *time.Time
+	err := q.QueryRow(ctx, `
+		UPDATE users
+		   SET webauthn_handle = coalesce(webauthn_handle, $2)
+		 WHERE id = $1
+		RETURNING webauthn_handle, phone, phone_verified_at
+	`, userID, handle).Scan(&u.Handle, &u.Phone, &verifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	if verifiedAt == nil {
+		return nil, ErrPhoneNotVerified
+	}
+	if u.Credentials, err = credentials(ctx, q, userID); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// UserByHandle resolves the user handle an authenticator returned during a
+// discoverable login
+func UserByHandle(ctx context.Context, q Querier, handle []byte) (*User, error) {
+	u := &User{Handle: handle}
+	err := q.QueryRow(ctx, `
+		SELECT id::text, phone FROM users WHERE webauthn_handle = $1
+	`, handle).Scan(&u.ID, &u.Phone)
+	if err != nil {
+		return nil, err
+	}
+	if u.Credentials, err = credentials(ctx, q, u.ID); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func credentials(ctx context.Context, q Querier, userID string) ([]webauthn.Credential, error) {
+	rows, err := q.Query(ctx, `
+		SELECT credential_id, public_key, attestation_type, transports,
+		       coalesce(aaguid, ''::bytea), sign_count, backup_eligible, backup_state
+		  FROM webauthn_credentials
+		 WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var creds []webauthn.Credential
+	for rows.Next() {
+		var c webauthn.Credential
+		var transports []string
+		var signCount int64
+		if err := rows.Scan(&c.ID, &c.PublicKey, &c.AttestationType, &transports,
+			&c.Authenticator.AAGUID, &signCount, &c.Flags.BackupEligible, &c.Flags.BackupState); err != nil {
+			return nil, err
+		}
+		for _, t := range transports {
+			c.Transport = append(c.Transport, protocol.AuthenticatorTransport(t))
+		}
+		c.Authenticator.SignCount = uint32(signCount)
+		creds = append(creds, c)
+	}
+	return creds, rows.Err()
+}
+
+// Create stores a newly registered credential under a user-chosen name
+func Create(ctx context.Context, q Querier, userID, name string, c *webauthn.Credential) (Passkey, error) {
+	name = clipName(name)
+	transports := make([]string, 0, len(c.Transport))
+	for _, t := range c.Transport {
+		transports = append(transports, string(t))
+	}
+	p := Passkey{Name: name, Transports: transports, BackupEligible: c.Flags.BackupEligible}
+	err := q.QueryRow(ctx, `
+		INSERT INTO webauthn_credentials (user_id, credential_id, public_key, attestation_type, transports,
+		                                  aaguid, sign_count, backup_eligible, backup_state, name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, nullif($10, ''))
+		RETURNING id::text, created_at
+	`, userID, c.ID, c.PublicKey, c.AttestationType, transports,
+		c.Authenticator.AAGUID, int64(c.Authenticator.SignCount),
+		c.Flags.BackupEligible, c.Flags.BackupState, name).Scan(&p.ID, &p.CreatedAt)
+	return p, err
+}
+
+// RecordLogin stores the counter and backup state of a verified assertion.
+// The update only applies while the stored counter is still the one the
+// assertion was checked against, so two concurrent logins with the same
+// assertion can not both succeed.
+func RecordLogin(ctx context.Context, q Querier, stored uint32, c *webauthn.Credential) error {
+	tag, err := q.Exec(ctx, `
+		UPDATE webauthn_credentials
+		   SET sign_count = $3, backup_state = $4, last_used_at = now()
+		 WHERE credential_id = $1 AND sign_count = $2
+	`, c.ID, int64(stored), int64(c.Authenticator.SignCount), c.Flags.BackupState)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSignCount
+	}
+	return nil
+}
+
+// List returns the user's passkeys, newest first
+func List(ctx context.Context, q Querier, userID string) ([]Passkey, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id::text, coalesce(name, ''), transports, backup_eligible, created_at, last_used_at
+		  FROM webauthn_credentials
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	passkeys := []Passkey{}
+	for rows.Next() {
+		var p Passkey
+		if err := rows.Scan(&p.ID, &p.Name, &p.Transports, &p.BackupEligible, &p.CreatedAt, &p.LastUsedAt); err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, rows.Err()
+}
+
+// Delete removes one of the user's passkeys
+func Delete(ctx context.Context, q Querier, userID, id string) error {
+	tag, err := q.Exec(ctx, `
+		DELETE FROM webauthn_credentials WHERE id::text = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveChallenge stores the session data of a ceremony until expiresAt and
+// returns the id the client sends back to finish it. userID is empty for
+// discoverable logins. Expired challenges are purged on the way.
+func SaveChallenge(ctx context.Context, q Querier, ceremony, userID string, data *webauthn.SessionData, expiresAt time.Time) (string, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	if _, err := q.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < now()`); err != nil {
+		return "", err
+	}
+	id := uuid.NewString()
+	_, err = q.Exec(ctx, `
+		INSERT INTO webauthn_challenges (id, user_id, ceremony, session_data, expires_at)
+		VALUES ($1, nullif($2, '')::uuid, $3, $4, $5)
+	`, id, userID, ceremony, raw, expiresAt)
+	return id, err
+}
+
+// TakeChallenge consumes a challenge of the ceremony. Registration
+// challenges only match the user they were issued to. A challenge is
+// deleted whether or not the ceremony then succeeds.
+func TakeChallenge(ctx context.Context, q Querier, id, ceremony, userID string) (webauthn.SessionData, error) {
+	var data webauthn.SessionData
+	if _, err := uuid.Parse(id); err != nil {
+		return data, ErrChallengeNotFound
+	}
+	var raw []byte
+	err := q.QueryRow(ctx, `
+		DELETE FROM webauthn_challenges
+		 WHERE id = $1 AND ceremony = $2 AND coalesce(user_id::text, '') = $3 AND expires_at > now()
+		RETURNING session_data
+	`, id, ceremony, userID).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return data, ErrChallengeNotFound
+	}
+	if err != nil {
+		return data, err
+	}
+	err = json.Unmarshal(raw, &data)
+	return data, err
+}
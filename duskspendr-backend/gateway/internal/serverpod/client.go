@@ -6,6 +6,7 @@ import (
   "crypto/hmac"
   "crypto/sha256"
   "encoding/base64"
+  "errors"
   "io"
   "net/http"
   "strconv"
@@ -44,9 +45,27 @@ func (c *Client) Health(ctx context.Context) bool {
   return resp.StatusCode >= 200 && resp.StatusCode < 300
 }
 
-func (c *Client) SyncTransactions(ctx context.Context, userID string, payload []byte) bool {
+// StatusError is returned when serverpod answers with a non-2xx status.
+type StatusError struct {
+  StatusCode int
+}
+
+func (e *StatusError) Error() string {
+  return "serverpod returned status " + strconv.Itoa(e.StatusCode)
+}
+
+// Retryable reports whether the delivery may succeed if attempted again.
+func (e *StatusError) Retryable() bool {
+  return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests ||
+    e.StatusCode == http.StatusRequestTimeout
+}
+
+// SyncTransactions pushes a signed sync payload for userID. The idempotency
+// key is forwarded as X-Idempotency-Key so serverpod can drop redelivered
+// events.
+func (c *Client) SyncTransactions(ctx context.Context, userID, idempotencyKey string, payload []byte) error {
   if c.BaseURL == "" {
-    return false
+    return errors.New("serverpod url not configured")
   }
   req, err := http.NewRequestWithContext(
     ctx,
@@ -55,7 +74,7 @@ func (c *Client) SyncTransactions(ctx context.Context, userID string, payload []
     http.NoBody,
   )
   if err != nil {
-    return false
+    return err
   }
   if len(payload) > 0 {
     req.Body = io.NopCloser(bytes.NewReader(payload))
@@ -63,6 +82,9 @@ func (c *Client) SyncTransactions(ctx context.Context, userID string, payload []
     req.Header.Set("Content-Type", "application/json")
   }
   req.Header.Set("X-User-Id", userID)
+  if idempotencyKey != "" {
+    req.Header.Set("X-Idempotency-Key", idempotencyKey)
+  }
   if c.SyncSharedSecret != "" {
     timestamp := strconv.FormatInt(time.Now().UTC().Unix(), 10)
     signature := signSyncPayload(c.SyncSharedSecret, timestamp, userID, payload)
@@ -71,10 +93,14 @@ func (c *Client) SyncTransactions(ctx context.Context, userID string, payload []
   }
   resp, err := c.HTTP.Do(req)
   if err != nil {
-    return false
+    return err
   }
   defer resp.Body.Close()
-  return resp.StatusCode >= 200 && resp.StatusCode < 300
+  _, _ = io.Copy(io.Discard, resp.Body)
+  if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+    return &StatusError{StatusCode: resp.StatusCode}
+  }
+  return nil
 }
 
 func signSyncPayload(secret, timestamp, userID string, payload []byte) string {
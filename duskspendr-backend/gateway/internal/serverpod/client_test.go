@@ -0,0 +1,95 @@
+package serverpod
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSyncTransactions_SignsPayload(t *testing.T) {
+	var gotKey, gotTimestamp, gotSignature, gotUser string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-Idempotency-Key")
+		gotTimestamp = r.Header.Get("X-Sync-Timestamp")
+		gotSignature = r.Header.Get("X-Sync-Signature")
+		gotUser = r.Header.Get("X-User-Id")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "secret")
+	payload := []byte(`{"event":"transactions.upserted"}`)
+	if err := c.SyncTransactions(context.Background(), "user-1", "key-1", payload); err != nil {
+		t.Fatalf("SyncTransactions returned error: %v", err)
+	}
+
+	if gotKey != "key-1" {
+		t.Errorf("idempotency key = %q, want key-1", gotKey)
+	}
+	if gotUser != "user-1" {
+		t.Errorf("user id = %q, want user-1", gotUser)
+	}
+	if string(gotBody) != string(payload) {
+		t.Errorf("body = %q, want %q", gotBody, payload)
+	}
+	if want := signSyncPayload("secret", gotTimestamp, "user-1", payload); gotSignature != want {
+		t.Errorf("signature = %q, want %q", gotSignature, want)
+	}
+}
+
+func TestSyncTransactions_StatusError(t *testing.T) {
+	status := http.StatusBadGateway
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "")
+	err := c.SyncTransactions(context.Background(), "user-1", "key-1", []byte(`{}`))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected StatusError, got %v", err)
+	}
+	if !statusErr.Retryable() {
+		t.Errorf("status %d should be retryable", status)
+	}
+
+	status = http.StatusUnauthorized
+	err = c.SyncTransactions(context.Background(), "user-1", "key-1", []byte(`{}`))
+	if !errors.As(err, &statusErr) || statusErr.Retryable() {
+		t.Errorf("status %d should not be retryable, got %v", status, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	payload := []byte(`{"items":[]}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := signSyncPayload("secret", ts, "user-1", payload)
+
+	if err := VerifySignature("secret", ts, "user-1", payload, sig, 5*time.Minute, now); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := VerifySignature("secret", ts, "user-2", payload, sig, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("signature for another user: got %v, want ErrInvalidSignature", err)
+	}
+	if err := VerifySignature("other", ts, "user-1", payload, sig, 5*time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("wrong secret: got %v, want ErrInvalidSignature", err)
+	}
+	if err := VerifySignature("secret", ts, "user-1", payload, sig, 5*time.Minute, now.Add(6*time.Minute)); err != ErrTimestampSkew {
+		t.Errorf("stale timestamp: got %v, want ErrTimestampSkew", err)
+	}
+	if err := VerifySignature("secret", "abc", "user-1", payload, sig, 5*time.Minute, now); err != ErrInvalidTimestamp {
+		t.Errorf("bad timestamp: got %v, want ErrInvalidTimestamp", err)
+	}
+	if err := VerifySignature("secret", ts, "user-1", payload, "", 5*time.Minute, now); err != ErrMissingSignature {
+		t.Errorf("missing signature: got %v, want ErrMissingSignature", err)
+	}
+}
@@ -0,0 +1,41 @@
+package serverpod
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Signature verification errors
+var (
+	ErrMissingSignature = errors.New("missing sync signature")
+	ErrInvalidTimestamp = errors.New("invalid sync timestamp")
+	ErrTimestampSkew    = errors.New("sync timestamp outside allowed skew")
+	ErrInvalidSignature = errors.New("invalid sync signature")
+)
+
+// VerifySignature checks an X-Sync-Timestamp / X-Sync-Signature pair produced
+// with the same scheme SyncTransactions uses for outbound requests.
+func VerifySignature(secret, timestamp, userID string, payload []byte, signature string, maxSkew time.Duration, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	skew := now.UTC().Sub(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return ErrTimestampSkew
+	}
+
+	expected := signSyncPayload(secret, timestamp, userID, payload)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"duskspendr-gateway/internal/db"
+)
+
+// TokenDenylist rejects access tokens before they expire, either one token
+// by jti or every access token of a token family. Entries only need to
+// outlive the tokens they cover, so they expire with them.
+type TokenDenylist interface {
+	// DenyToken rejects the access token with this jti until it expires
+	DenyToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// DenyFamily rejects access tokens of the family for ttl, which should be
+	// the access token lifetime
+	DenyFamily(ctx context.Context, familyID string, ttl time.Duration) error
+	// Denied reports whether the access token was revoked
+	Denied(ctx context.Context, claims *Claims) (bool, error)
+}
+
+// MemoryDenylist is a process-local TokenDenylist used when Redis is unavailable
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+// NewMemoryDenylist creates an empty in-memory denylist
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time)}
+}
+
+// DenyToken implements TokenDenylist
+func (d *MemoryDenylist) DenyToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	d.add("jti:"+jti, expiresAt)
+	return nil
+}
+
+// DenyFamily implements TokenDenylist
+func (d *MemoryDenylist) DenyFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	d.add("fam:"+familyID, time.Now().Add(ttl))
+	return nil
+}
+
+// Denied implements TokenDenylist
+func (d *MemoryDenylist) Denied(ctx context.Context, claims *Claims) (bool, error) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range denyKeys(claims) {
+		if expires, ok := d.entries[key]; ok && now.Before(expires) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *MemoryDenylist) add(key string, expires time.Time) {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, e := range d.entries {
+		if !now.Before(e) {
+			delete(d.entries, k)
+		}
+	}
+	if prev, ok := d.entries[key]; !ok || expires.After(prev) {
+		d.entries[key] = expires
+	}
+}
+
+// RedisDenylist shares revocations across gateway replicas
+type RedisDenylist struct {
+	Redis  *db.RedisClient
+	Prefix string
+}
+
+// NewRedisDenylist creates a Redis-backed denylist
+func NewRedisDenylist(redis *db.RedisClient) *RedisDenylist {
+	return &RedisDenylist{Redis: redis, Prefix: "jwt_deny:"}
+}
+
+// DenyToken implements TokenDenylist
+func (d *RedisDenylist) DenyToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.Redis.Set(ctx, d.Prefix+"jti:"+jti, 1, ttl)
+}
+
+// DenyFamily implements TokenDenylist
+func (d *RedisDenylist) DenyFamily(ctx context.Context, familyID string, ttl time.Duration) error {
+	return d.Redis.Set(ctx, d.Prefix+"fam:"+familyID, 1, ttl)
+}
+
+// Denied implements TokenDenylist
+func (d *RedisDenylist) Denied(ctx context.Context, claims *Claims) (bool, error) {
+	keys := denyKeys(claims)
+	for i := range keys {
+		keys[i] = d.Prefix + keys[i]
+	}
+	n, err := d.Redis.Exists(ctx, keys...)
+	return n > 0, err
+}
+
+func denyKeys(claims *Claims) []string {
+	keys := []string{"jti:" + claims.ID}
+	if claims.FamilyID != "" {
+		keys = append(keys, "fam:"+claims.FamilyID)
+	}
+	return keys
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryDenylist(t *testing.T) {
+	ctx := context.Background()
+	d := NewMemoryDenylist()
+	claims := func(jti, fam string) *Claims {
+		c := &Claims{FamilyID: fam}
+		c.ID = jti
+		return c
+	}
+
+	if denied, _ := d.Denied(ctx, claims("a", "f1")); denied {
+		t.Fatal("empty denylist denied a token")
+	}
+
+	_ = d.DenyToken(ctx, "a", time.Now().Add(time.Minute))
+	if denied, _ := d.Denied(ctx, claims("a", "f1")); !denied {
+		t.Error("denied jti was accepted")
+	}
+	if denied, _ := d.Denied(ctx, claims("b", "f1")); denied {
+		t.Error("other jti of the family was denied")
+	}
+
+	_ = d.DenyFamily(ctx, "f1", time.Minute)
+	if denied, _ := d.Denied(ctx, claims("b", "f1")); !denied {
+		t.Error("token of a denied family was accepted")
+	}
+	if denied, _ := d.Denied(ctx, claims("c", "f2")); denied {
+		t.Error("token of another family was denied")
+	}
+
+	// Entries lapse with the tokens they cover
+	_ = d.DenyToken(ctx, "old", time.Now().Add(-time.Second))
+	if denied, _ := d.Denied(ctx, claims("old", "")); denied {
+		t.Error("expired entry still denies")
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms for issued tokens
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrNoSigningKey is returned when no key is active for signing
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// SigningKey is one asymmetric key identified by kid. A key is published in
+// the JWKS as soon as it is created, signs from ActivatesAt until RetiresAt,
+// and keeps verifying the tokens it signed for the key ring's max token age
+// after that.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	Private     crypto.Signer
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	RetiresAt   *time.Time
+}
+
+// GenerateSigningKey creates a new key for alg that signs from activatesAt
+func GenerateSigningKey(alg string, activatesAt time.Time) (*SigningKey, error) {
+	var priv crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kid, err := generateJTI()
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID:          kid,
+		Algorithm:   alg,
+		Private:     priv,
+		CreatedAt:   time.Now().UTC(),
+		ActivatesAt: activatesAt.UTC(),
+	}, nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k *SigningKey) verifyKey() any {
+	return k.Private.Public()
+}
+
+// signs reports whether k may sign at now
+func (k *SigningKey) signs(now time.Time) bool {
+	return !k.ActivatesAt.After(now) && (k.RetiresAt == nil || now.Before(*k.RetiresAt))
+}
+
+// verifies reports whether tokens signed by k are still accepted at now
+func (k *SigningKey) verifies(now time.Time, maxTokenAge time.Duration) bool {
+	return k.RetiresAt == nil || now.Before(k.RetiresAt.Add(maxTokenAge))
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517, RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the body of /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public half of k
+func (k *SigningKey) JWK() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm}
+	switch pub := k.Private.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+	return jwk
+}
+
+// KeyRing holds the signing keys of this instance. The Rotator replaces the
+// set; JWTService reads it on every sign and verify.
+type KeyRing struct {
+	mu          sync.RWMutex
+	keys        []*SigningKey
+	maxTokenAge time.Duration
+}
+
+// NewKeyRing creates a key ring. maxTokenAge is the longest lifetime of a
+// token signed by any key, i.e. the refresh token expiry.
+func NewKeyRing(maxTokenAge time.Duration, keys ...*SigningKey) *KeyRing {
+	r := &KeyRing{maxTokenAge: maxTokenAge}
+	r.Set(keys)
+	return r
+}
+
+// Set replaces the keys in the ring
+func (r *KeyRing) Set(keys []*SigningKey) {
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt) })
+	r.mu.Lock()
+	r.keys = sorted
+	r.mu.Unlock()
+}
+
+// Keys returns the keys in the ring, oldest activation first
+func (r *KeyRing) Keys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*SigningKey(nil), r.keys...)
+}
+
+// signer returns the most recently activated key that may sign at now
+func (r *KeyRing) signer(now time.Time) (*SigningKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		if r.keys[i].signs(now) {
+			return r.keys[i], nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// verifier returns the key kid if tokens it signed are accepted at now
+func (r *KeyRing) verifier(kid string, now time.Time) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.ID == kid {
+			return k, k.verifies(now, r.maxTokenAge)
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the public keys verifiers should accept at now, including
+// keys published ahead of their activation
+func (r *KeyRing) JWKS(now time.Time) JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range r.keys {
+		if k.verifies(now, r.maxTokenAge) {
+			set.Keys = append(set.Keys, k.JWK())
+		}
+	}
+	return set
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestJWTService(t *testing.T) *JWTService {
+	t.Helper()
+	key, err := GenerateSigningKey(AlgEdDSA, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewJWTService(NewKeyRing(7*24*time.Hour, key), 15*time.Minute, 7*24*time.Hour)
+}
+
+// publicFromJWK rebuilds a verification key the way an external verifier
+// would, from the JWKS alone
+func publicFromJWK(t *testing.T, k JWK) any {
+	t.Helper()
+	switch k.Kty {
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ed25519.PublicKey(x)
+	case "RSA":
+		n, _ := base64.RawURLEncoding.DecodeString(k.N)
+		e, _ := base64.RawURLEncoding.DecodeString(k.E)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	t.Fatalf("unexpected kty %q", k.Kty)
+	return nil
+}
+
+func TestJWKSVerifiesIssuedTokens(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateSigningKey(alg, time.Now().Add(-time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			svc := NewJWTService(NewKeyRing(time.Hour, key), time.Minute, time.Hour)
+			pair, err := svc.GenerateTokenPair("user-1", "", "+919999999999")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jwks := svc.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != key.ID || jwks.Keys[0].Alg != alg {
+				t.Fatalf("jwks = %+v", jwks)
+			}
+			token, err := jwt.ParseWithClaims(pair.AccessToken, &Claims{}, func(tok *jwt.Token) (any, error) {
+				if tok.Header["kid"] != jwks.Keys[0].Kid {
+					t.Errorf("kid = %v", tok.Header["kid"])
+				}
+				return publicFromJWK(t, jwks.Keys[0]), nil
+			}, jwt.WithValidMethods([]string{alg}))
+			if err != nil || !token.Valid {
+				t.Fatalf("external verification failed: %v", err)
+			}
+			if _, err := svc.ValidateAccessToken(pair.AccessToken); err != nil {
+				t.Fatalf("ValidateAccessToken: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTServiceRejectsUnknownKeysAndHS256(t *testing.T) {
+	svc := newTestJWTService(t)
+	other := newTestJWTService(t)
+	pair, err := other.GenerateTokenPair("user-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateAccessToken(pair.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token from unknown key: %v", err)
+	}
+
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID:    "user-1",
+		TokenType: "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	signed, err := legacy.SignedString([]byte("old-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateAccessToken(signed); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("HS256 accepted without legacy secret: %v", err)
+	}
+	svc.AcceptLegacyHS256("old-secret", time.Now().Add(time.Minute))
+	if _, err := svc.ValidateAccessToken(signed); err != nil {
+		t.Fatalf("legacy HS256 token rejected: %v", err)
+	}
+	if _, err := svc.ValidateRefreshToken(signed); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("legacy access token verified as a refresh token: %v", err)
+	}
+
+	legacyRefresh := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID:    "user-1",
+		TokenType: "refresh",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	signedRefresh, err := legacyRefresh.SignedString([]byte("old-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateRefreshToken(signedRefresh); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("legacy HS256 refresh token accepted: %v", err)
+	}
+
+	svc.AcceptLegacyHS256("old-secret", time.Now().Add(-time.Second))
+	if _, err := svc.ValidateAccessToken(signed); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("legacy HS256 token accepted after the cutoff: %v", err)
+	}
+}
+
+func TestElevatedAccessToken(t *testing.T) {
+	svc := newTestJWTService(t)
+	token, until, err := svc.GenerateElevatedAccessToken("user-1", "", "+919999999999", "fam-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(until); d > 15*time.Minute || d < 14*time.Minute {
+		t.Fatalf("elevation lasts %v, want capped at the access token lifetime", d)
+	}
+	claims, err := svc.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.Elevated(time.Now()) || claims.FamilyID != "fam-1" || !claims.ExpiresAt.Time.Equal(claims.ElevatedUntil.Time) {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if claims.Elevated(until.Add(time.Second)) {
+		t.Fatal("elevation outlived its expiry")
+	}
+
+	pair, err := svc.GenerateTokenPair("user-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, _ := svc.ValidateAccessToken(pair.AccessToken); plain.Elevated(time.Now()) {
+		t.Fatal("sign-in token carries the elevated claim")
+	}
+}
+
+func TestRotationLifecycle(t *testing.T) {
+	policy := RotationPolicy{
+		Algorithm:   AlgEdDSA,
+		Interval:    30 * 24 * time.Hour,
+		Prepublish:  24 * time.Hour,
+		MaxTokenAge: 7 * 24 * time.Hour,
+	}
+	store := &MemoryKeyStore{}
+	ring := NewKeyRing(policy.MaxTokenAge)
+	rotator := &Rotator{Store: store, Ring: ring, Policy: policy}
+	svc := NewJWTService(ring, 15*time.Minute, policy.MaxTokenAge)
+	ctx := context.Background()
+	start := time.Now()
+
+	if err := rotator.RotateOnce(ctx, start); err != nil {
+		t.Fatal(err)
+	}
+	first, err := ring.signer(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rotator.RotateOnce(ctx, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := ring.Keys(); len(keys) != 1 {
+		t.Fatalf("rotated before due: %d keys", len(keys))
+	}
+
+	// A day before the interval ends the successor is published but not used
+	prepublished := start.Add(policy.Interval - policy.Prepublish)
+	if err := rotator.RotateOnce(ctx, prepublished); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ring.JWKS(prepublished).Keys); n != 2 {
+		t.Fatalf("jwks has %d keys after prepublish, want 2", n)
+	}
+	if k, _ := ring.signer(prepublished); k.ID != first.ID {
+		t.Fatal("successor signs before activation")
+	}
+	oldPair, err := svc.GenerateTokenPair("user-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activated := start.Add(policy.Interval)
+	if k, _ := ring.signer(activated); k.ID == first.ID {
+		t.Fatal("successor did not take over at activation")
+	}
+	if _, ok := ring.verifier(first.ID, activated.Add(policy.MaxTokenAge-time.Minute)); !ok {
+		t.Fatal("retired key stopped verifying before tokens expired")
+	}
+	if _, err := svc.ValidateRefreshToken(oldPair.RefreshToken); err != nil {
+		t.Fatalf("token of retired key rejected: %v", err)
+	}
+
+	pruned := activated.Add(policy.MaxTokenAge + time.Minute)
+	if err := rotator.RotateOnce(ctx, pruned); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range ring.Keys() {
+		if k.ID == first.ID {
+			t.Fatal("retired key kept after max token age")
+		}
+	}
+}
+
+func TestRotationAlgorithmChange(t *testing.T) {
+	now := time.Now()
+	current, err := GenerateSigningKey(AlgRS256, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := RotationPolicy{Algorithm: AlgEdDSA, Interval: 30 * 24 * time.Hour, Prepublish: 24 * time.Hour, MaxTokenAge: time.Hour}
+	changes, err := policy.Plan([]*SigningKey{current}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Add) != 1 || changes.Add[0].Algorithm != AlgEdDSA {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if at := changes.Retire[current.ID]; !at.Equal(now.Add(policy.Prepublish)) {
+		t.Fatalf("retires at %v, want after prepublish", at)
+	}
+}
+
+func TestKeyCipherRoundTrip(t *testing.T) {
+	c, err := NewKeyCipher(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		key, err := GenerateSigningKey(alg, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := c.seal(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := c.open(key.ID, sealed)
+		if err != nil {
+			t.Fatalf("%s: open: %v", alg, err)
+		}
+		if reopened := (&SigningKey{ID: key.ID, Algorithm: alg, Private: priv}); reopened.JWK() != key.JWK() {
+			t.Fatalf("%s: key changed across seal/open", alg)
+		}
+		if _, err := c.open("another-kid", sealed); err == nil {
+			t.Fatalf("%s: sealed key opened under another kid", alg)
+		}
+	}
+	if _, err := NewKeyCipher("c2hvcnQ="); err == nil {
+		t.Fatal("short key accepted")
+	}
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RotationPolicy decides when signing keys are created and dropped
+type RotationPolicy struct {
+	// Algorithm of new keys; a change rotates the current key early
+	Algorithm string
+	// Interval is how long each key signs
+	Interval time.Duration
+	// Prepublish is how long a new key sits in the JWKS before it signs, so
+	// verifiers that cache the JWKS pick it up first
+	Prepublish time.Duration
+	// MaxTokenAge is how long a retired key keeps verifying
+	MaxTokenAge time.Duration
+}
+
+// KeyChanges is the outcome of planning a rotation
+type KeyChanges struct {
+	Add    []*SigningKey
+	Retire map[string]time.Time
+	Delete []string
+}
+
+// Empty reports whether the plan changes nothing
+func (c KeyChanges) Empty() bool {
+	return len(c.Add) == 0 && len(c.Retire) == 0 && len(c.Delete) == 0
+}
+
+// Plan returns the changes that bring keys in line with the policy at now:
+// a first key that signs immediately, a successor published Prepublish
+// before the current key's interval ends, and removal of retired keys no
+// token can still depend on.
+func (p RotationPolicy) Plan(keys []*SigningKey, now time.Time) (KeyChanges, error) {
+	changes := KeyChanges{Retire: map[string]time.Time{}}
+	var newest *SigningKey
+	for _, k := range keys {
+		if !k.verifies(now, p.MaxTokenAge) {
+			changes.Delete = append(changes.Delete, k.ID)
+			continue
+		}
+		if k.RetiresAt == nil && (newest == nil || k.ActivatesAt.After(newest.ActivatesAt)) {
+			newest = k
+		}
+	}
+
+	if newest == nil {
+		k, err := GenerateSigningKey(p.Algorithm, now)
+		if err != nil {
+			return changes, err
+		}
+		changes.Add = append(changes.Add, k)
+		return changes, nil
+	}
+
+	due := newest.ActivatesAt.Add(p.Interval - p.Prepublish)
+	if newest.Algorithm != p.Algorithm && newest.ActivatesAt.Before(now) {
+		due = now
+	}
+	if now.Before(due) {
+		return changes, nil
+	}
+
+	activatesAt := newest.ActivatesAt.Add(p.Interval)
+	if earliest := now.Add(p.Prepublish); activatesAt.Before(earliest) || newest.Algorithm != p.Algorithm {
+		activatesAt = earliest
+	}
+	k, err := GenerateSigningKey(p.Algorithm, activatesAt)
+	if err != nil {
+		return changes, err
+	}
+	changes.Add = append(changes.Add, k)
+	changes.Retire[newest.ID] = activatesAt
+	return changes, nil
+}
+
+// apply returns keys with changes applied
+func (c KeyChanges) apply(keys []*SigningKey) []*SigningKey {
+	deleted := map[string]bool{}
+	for _, id := range c.Delete {
+		deleted[id] = true
+	}
+	out := make([]*SigningKey, 0, len(keys)+len(c.Add))
+	for _, k := range keys {
+		if deleted[k.ID] {
+			continue
+		}
+		if at, ok := c.Retire[k.ID]; ok {
+			retired := *k
+			retired.RetiresAt = &at
+			k = &retired
+		}
+		out = append(out, k)
+	}
+	return append(out, c.Add...)
+}
+
+// KeyStore keeps signing keys shared by all gateway instances
+type KeyStore interface {
+	// Rotate applies policy to the stored keys under a lock shared by all
+	// instances and returns the resulting set
+	Rotate(ctx context.Context, policy RotationPolicy, now time.Time) ([]*SigningKey, error)
+}
+
+// MemoryKeyStore keeps keys in process memory; each instance then has its
+// own keys, so it only suits a single instance
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys []*SigningKey
+}
+
+// Rotate implements KeyStore
+func (s *MemoryKeyStore) Rotate(ctx context.Context, policy RotationPolicy, now time.Time) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes, err := policy.Plan(s.keys, now)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = changes.apply(s.keys)
+	return append([]*SigningKey(nil), s.keys...), nil
+}
+
+// PostgresKeyStore keeps keys in jwt_signing_keys with the private keys
+// encrypted by Cipher
+type PostgresKeyStore struct {
+	Pool   *pgxpool.Pool
+	Cipher *KeyCipher
+}
+
+// Rotate implements KeyStore. The advisory lock serialises instances so
+// only one of them creates each successor.
+func (s *PostgresKeyStore) Rotate(ctx context.Context, policy RotationPolicy, now time.Time) ([]*SigningKey, error) {
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('jwt_signing_keys'))`); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT kid, algorithm, private_key, created_at, activates_at, retires_at
+		  FROM jwt_signing_keys
+	`)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*SigningKey
+	for rows.Next() {
+		var k SigningKey
+		var sealed []byte
+		if err := rows.Scan(&k.ID, &k.Algorithm, &sealed, &k.CreatedAt, &k.ActivatesAt, &k.RetiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if k.Private, err = s.Cipher.open(k.ID, sealed); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("signing key %s: %w", k.ID, err)
+		}
+		keys = append(keys, &k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changes, err := policy.Plan(keys, now)
+	if err != nil {
+		return nil, err
+	}
+	if changes.Empty() {
+		return keys, nil
+	}
+	for _, id := range changes.Delete {
+		if _, err := tx.Exec(ctx, `DELETE FROM jwt_signing_keys WHERE kid = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+	for id, at := range changes.Retire {
+		if _, err := tx.Exec(ctx, `UPDATE jwt_signing_keys SET retires_at = $2 WHERE kid = $1`, id, at); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range changes.Add {
+		sealed, err := s.Cipher.seal(k)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO jwt_signing_keys (kid, algorithm, private_key, created_at, activates_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, k.ID, k.Algorithm, sealed, k.CreatedAt, k.ActivatesAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return changes.apply(keys), nil
+}
+
+// KeyCipher encrypts private keys at rest with AES-256-GCM; the kid is bound
+// as additional data so a sealed key can not be moved to another row
+type KeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewKeyCipher creates a cipher from a base64 encoded 32 byte key
+func NewKeyCipher(encoded string) (*KeyCipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key encryption key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("key encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyCipher{aead: aead}, nil
+}
+
+func (c *KeyCipher) seal(k *SigningKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, der, []byte(k.ID)), nil
+}
+
+func (c *KeyCipher) open(kid string, sealed []byte) (crypto.Signer, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("sealed key too short")
+	}
+	der, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(kid))
+	if err != nil {
+		return nil, err
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key type")
+	}
+	return signer, nil
+}
+
+// Rotator keeps a KeyRing in step with the KeyStore. Every instance runs
+// one: it rotates when due and picks up keys created by other instances.
+type Rotator struct {
+	Store    KeyStore
+	Ring     *KeyRing
+	Policy   RotationPolicy
+	Interval time.Duration
+}
+
+// Run rotates every Interval until ctx is cancelled
+func (r *Rotator) Run(ctx context.Context) {
+	if r.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.RotateOnce(ctx, time.Now()); err != nil {
+			log.Printf("jwt key rotation failed: %v", err)
+		}
+	}
+}
+
+// RotateOnce applies the policy at now and loads the result into the ring
+func (r *Rotator) RotateOnce(ctx context.Context, now time.Time) error {
+	keys, err := r.Store.Rotate(ctx, r.Policy, now)
+	if err != nil {
+		return err
+	}
+	r.Ring.Set(keys)
+	return nil
+}
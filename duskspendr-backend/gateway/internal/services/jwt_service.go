@@ -14,6 +14,8 @@ var (
 	ErrInvalidToken     = errors.New("invalid token")
 	ErrExpiredToken     = errors.New("token expired")
 	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrTokenReused      = errors.New("refresh token reused")
+	ErrTokenRevoked     = errors.New("token revoked")
 )
 
 // TokenPair represents access and refresh tokens
@@ -23,6 +25,12 @@ type TokenPair struct {
 	ExpiresIn    int64     `json:"expires_in"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	TokenType    string    `json:"token_type"`
+
+	// Refresh token bookkeeping for the token family store
+	RefreshID        string    `json:"-"`
+	FamilyID         string    `json:"-"`
+	IssuedAt         time.Time `json:"-"`
+	RefreshExpiresAt time.Time `json:"-"`
 }
 
 // Claims represents JWT claims
@@ -31,30 +39,72 @@ type Claims struct {
 	Email     string `json:"email,omitempty"`
 	Phone     string `json:"phone,omitempty"`
 	TokenType string `json:"token_type"`
+	FamilyID  string `json:"fam,omitempty"`
+	// ElevatedUntil is set on access tokens issued by a step-up; sensitive
+	// routes require it to be in the future
+	ElevatedUntil *jwt.NumericDate `json:"elv,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// JWTService handles JWT token operations
+// Elevated reports whether the token carries a step-up valid at now
+func (c *Claims) Elevated(now time.Time) bool {
+	return c.ElevatedUntil != nil && now.Before(c.ElevatedUntil.Time)
+}
+
+// JWTService handles JWT token operations. Tokens are signed with the
+// current key of the key ring and carry its kid, so anyone holding the JWKS
+// can verify them.
 type JWTService struct {
-	accessSecret       []byte
-	refreshSecret      []byte
-	accessExpiration   time.Duration
-	refreshExpiration  time.Duration
+	keys              *KeyRing
+	accessExpiration  time.Duration
+	refreshExpiration time.Duration
+
+	// HS256 secret accepted for access tokens only, and only until
+	// legacyUntil, so access tokens issued before the switch to asymmetric
+	// keys keep working for the rest of their lifetime
+	legacyAccessSecret []byte
+	legacyUntil        time.Time
 }
 
-// NewJWTService creates a new JWT service
-func NewJWTService(accessSecret, refreshSecret string) *JWTService {
+// NewJWTService creates a new JWT service signing with keys
+func NewJWTService(keys *KeyRing, accessExpiration, refreshExpiration time.Duration) *JWTService {
 	return &JWTService{
-		accessSecret:      []byte(accessSecret),
-		refreshSecret:     []byte(refreshSecret),
-		accessExpiration:  15 * time.Minute,
-		refreshExpiration: 7 * 24 * time.Hour,
+		keys:              keys,
+		accessExpiration:  accessExpiration,
+		refreshExpiration: refreshExpiration,
 	}
 }
 
-// GenerateTokenPair creates access and refresh tokens
+// AcceptLegacyHS256 lets the service verify access tokens signed with the
+// former shared secret until the cutoff. An empty secret is ignored; legacy
+// refresh tokens are never accepted.
+func (s *JWTService) AcceptLegacyHS256(accessSecret string, until time.Time) {
+	s.legacyAccessSecret = []byte(accessSecret)
+	s.legacyUntil = until
+}
+
+// JWKS returns the public keys that verify tokens issued by this service
+func (s *JWTService) JWKS() JWKSet {
+	return s.keys.JWKS(time.Now())
+}
+
+// GenerateTokenPair creates access and refresh tokens in a new token family
 func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair, error) {
+	familyID, err := generateJTI()
+	if err != nil {
+		return nil, err
+	}
+	return s.generateTokenPair(userID, email, phone, familyID)
+}
+
+// generateTokenPair creates access and refresh tokens; the refresh token
+// carries familyID so rotations can be traced back to the original login
+func (s *JWTService) generateTokenPair(userID, email, phone, familyID string) (*TokenPair, error) {
 	now := time.Now()
+	key, err := s.keys.signer(now)
+	if err != nil {
+		return nil, err
+	}
 	jti, err := generateJTI()
 	if err != nil {
 		return nil, err
@@ -66,6 +116,7 @@ func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair,
 		Email:     email,
 		Phone:     phone,
 		TokenType: "access",
+		FamilyID:  familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   userID,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -75,8 +126,7 @@ func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair,
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(s.accessSecret)
+	accessTokenString, err := sign(key, accessClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +139,10 @@ func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair,
 
 	refreshClaims := Claims{
 		UserID:    userID,
+		Email:     email,
+		Phone:     phone,
 		TokenType: "refresh",
+		FamilyID:  familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   userID,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -99,8 +152,7 @@ func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair,
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(s.refreshSecret)
+	refreshTokenString, err := sign(key, refreshClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -111,27 +163,93 @@ func (s *JWTService) GenerateTokenPair(userID, email, phone string) (*TokenPair,
 		ExpiresIn:    int64(s.accessExpiration.Seconds()),
 		ExpiresAt:    now.Add(s.accessExpiration),
 		TokenType:    "Bearer",
+
+		RefreshID:        refreshJTI,
+		FamilyID:         familyID,
+		IssuedAt:         now,
+		RefreshExpiresAt: now.Add(s.refreshExpiration),
 	}, nil
 }
 
+// GenerateElevatedAccessToken issues an access token carrying a step-up
+// that lasts ttl. The token expires with the elevation and stays in the
+// caller's refresh family, so revoking the session also covers it.
+func (s *JWTService) GenerateElevatedAccessToken(userID, email, phone, familyID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	key, err := s.keys.signer(now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	jti, err := generateJTI()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if ttl > s.accessExpiration {
+		ttl = s.accessExpiration
+	}
+	until := now.Add(ttl)
+	token, err := sign(key, Claims{
+		UserID:        userID,
+		Email:         email,
+		Phone:         phone,
+		TokenType:     "access",
+		FamilyID:      familyID,
+		ElevatedUntil: jwt.NewNumericDate(until),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(until),
+			ID:        jti,
+			Issuer:    "duskspendr",
+		},
+	})
+	return token, until, err
+}
+
+// AccessExpiration is the lifetime of issued access tokens
+func (s *JWTService) AccessExpiration() time.Duration {
+	return s.accessExpiration
+}
+
+// sign signs claims with key and names it in the kid header
+func sign(key *SigningKey, claims Claims) (string, error) {
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
 // ValidateAccessToken validates an access token and returns claims
 func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
-	return s.validateToken(tokenString, s.accessSecret, "access")
+	var legacySecret []byte
+	if time.Now().Before(s.legacyUntil) {
+		legacySecret = s.legacyAccessSecret
+	}
+	return s.validateToken(tokenString, legacySecret, "access")
 }
 
 // ValidateRefreshToken validates a refresh token and returns claims
 func (s *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
-	return s.validateToken(tokenString, s.refreshSecret, "refresh")
+	return s.validateToken(tokenString, nil, "refresh")
 }
 
-// validateToken validates a token with the given secret
-func (s *JWTService) validateToken(tokenString string, secret []byte, expectedType string) (*Claims, error) {
+// validateToken validates a token signed by a key of the ring, or with
+// legacySecret when it is an HS256 token from before the key ring
+func (s *JWTService) validateToken(tokenString string, legacySecret []byte, expectedType string) (*Claims, error) {
+	now := time.Now()
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if len(legacySecret) == 0 || token.Method != jwt.SigningMethodHS256 {
+				return nil, ErrInvalidToken
+			}
+			return legacySecret, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.verifier(kid, now)
+		if !ok || token.Method.Alg() != key.method().Alg() {
 			return nil, ErrInvalidToken
 		}
-		return secret, nil
-	})
+		return key.verifyKey(), nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA, "HS256"}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -152,16 +270,6 @@ func (s *JWTService) validateToken(tokenString string, secret []byte, expectedTy
 	return claims, nil
 }
 
-// RefreshTokens generates new tokens from a valid refresh token
-func (s *JWTService) RefreshTokens(refreshToken string) (*TokenPair, error) {
-	claims, err := s.ValidateRefreshToken(refreshToken)
-	if err != nil {
-		return nil, err
-	}
-
-	return s.GenerateTokenPair(claims.UserID, claims.Email, claims.Phone)
-}
-
 // generateJTI creates a unique token ID
 func generateJTI() (string, error) {
 	b := make([]byte, 16)
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OTP delivery channels a client can ask for
+const (
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+	ChannelVoice    = "voice"
+)
+
+// ValidChannel reports whether name is a known OTP channel
+func ValidChannel(name string) bool {
+	return name == ChannelSMS || name == ChannelWhatsApp || name == ChannelVoice
+}
+
+// OTPChannel delivers one-time codes over one medium. Unlike SMSSender it
+// is given the code rather than a message body, since WhatsApp templates
+// and voice calls each word the code their own way.
+type OTPChannel interface {
+	Channel() string
+	SendOTP(ctx context.Context, to, code string) (SMSReceipt, error)
+	// Reporter returns the delivery report parser of provider, if the
+	// channel sends through it and the provider posts reports
+	Reporter(provider string) (DeliveryReporter, bool)
+}
+
+// OTPChannels are the enabled channels in fallback order
+type OTPChannels []OTPChannel
+
+// Get returns the channel called name
+func (cs OTPChannels) Get(name string) (OTPChannel, bool) {
+	for _, c := range cs {
+		if c.Channel() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// From returns every channel starting at name and wrapping around, so a
+// failed send can fall back to the others in order
+func (cs OTPChannels) From(name string) OTPChannels {
+	for i, c := range cs {
+		if c.Channel() == name {
+			return append(append(OTPChannels{}, cs[i:]...), cs[:i]...)
+		}
+	}
+	return nil
+}
+
+// Next returns the channel after name, wrapping around; false when name is
+// the only channel
+func (cs OTPChannels) Next(name string) (OTPChannel, bool) {
+	order := cs.From(name)
+	if len(order) < 2 {
+		return nil, false
+	}
+	return order[1], true
+}
+
+// Reporter finds the delivery report parser of provider on any channel
+func (cs OTPChannels) Reporter(provider string) (DeliveryReporter, bool) {
+	for _, c := range cs {
+		if r, ok := c.Reporter(provider); ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// OTPMessage is the SMS body of a code. DLT templates must match it with
+// the code as the variable.
+func OTPMessage(code string) string {
+	return fmt.Sprintf("Your DuskSpendr code is: %s", code)
+}
+
+// SMSChannel sends codes as SMS through Sender
+type SMSChannel struct {
+	Sender SMSProvider
+	// TemplateID is the DLT template of OTPMessage
+	TemplateID string
+}
+
+func (c *SMSChannel) Channel() string { return ChannelSMS }
+
+func (c *SMSChannel) SendOTP(ctx context.Context, to, code string) (SMSReceipt, error) {
+	return c.Sender.Send(ctx, SMS{To: to, Body: OTPMessage(code), TemplateID: c.TemplateID})
+}
+
+func (c *SMSChannel) Reporter(provider string) (DeliveryReporter, bool) {
+	p := c.Sender
+	if f, ok := p.(*FailoverSender); ok {
+		if p, ok = f.Provider(provider); !ok {
+			return nil, false
+		}
+	} else if p.Name() != provider {
+		return nil, false
+	}
+	r, ok := p.(DeliveryReporter)
+	return r, ok
+}
+
+// LogChannel logs codes instead of delivering them; the local stand-in for
+// WhatsApp and voice when they are not configured
+type LogChannel struct {
+	Name string
+}
+
+func (c *LogChannel) Channel() string { return c.Name }
+
+func (c *LogChannel) SendOTP(ctx context.Context, to, code string) (SMSReceipt, error) {
+	log.Printf("[OTP %s] To: %s, Code: %s", c.Name, to, code)
+	return SMSReceipt{Provider: "log", MessageID: uuid.NewString()}, nil
+}
+
+func (c *LogChannel) Reporter(provider string) (DeliveryReporter, bool) { return nil, false }
+
+// WhatsAppSender sends codes as an authentication template message through
+// the WhatsApp Cloud API. The template has the code as its body variable
+// and a copy-code button carrying it again.
+type WhatsAppSender struct {
+	PhoneNumberID string
+	AccessToken   string
+	Template      string
+	Language      string
+	BaseURL       string
+	HTTPClient    *http.Client
+}
+
+func NewWhatsAppSender(phoneNumberID, accessToken, template, language string) *WhatsAppSender {
+	return &WhatsAppSender{
+		PhoneNumberID: phoneNumberID,
+		AccessToken:   accessToken,
+		Template:      template,
+		Language:      language,
+		BaseURL:       "https://graph.facebook.com/v19.0",
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WhatsAppSender) Channel() string { return ChannelWhatsApp }
+
+func (s *WhatsAppSender) Name() string { return "whatsapp_cloud" }
+
+func (s *WhatsAppSender) SendOTP(ctx context.Context, to, code string) (SMSReceipt, error) {
+	receipt := SMSReceipt{Provider: s.Name()}
+	if s.PhoneNumberID == "" || s.AccessToken == "" || s.Template == "" {
+		return receipt, errors.New("whatsapp credentials missing")
+	}
+
+	param := []map[string]string{{"type": "text", "text": code}}
+	payload, err := json.Marshal(map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                indianNumber(to),
+		"type":              "template",
+		"template": map[string]any{
+			"name":     s.Template,
+			"language": map[string]string{"code": s.Language},
+			"components": []map[string]any{
+				{"type": "body", "parameters": param},
+				{"type": "button", "sub_type": "url", "index": "0", "parameters": param},
+			},
+		},
+	})
+	if err != nil {
+		return receipt, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.BaseURL+"/"+s.PhoneNumberID+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return receipt, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return receipt, fmt.Errorf("whatsapp: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && len(body.Messages) > 0 {
+		receipt.MessageID = body.Messages[0].ID
+		return receipt, nil
+	}
+	if body.Error.Message != "" {
+		return receipt, fmt.Errorf("whatsapp error: %s", body.Error.Message)
+	}
+	return receipt, fmt.Errorf("whatsapp error (status %d)", resp.StatusCode)
+}
+
+// Reporter is not supported: WhatsApp status updates arrive on the Meta
+// app webhook, which the gateway does not subscribe to
+func (s *WhatsAppSender) Reporter(provider string) (DeliveryReporter, bool) { return nil, false }
+
+// TwilioVoiceSender reads codes out in a phone call placed through Twilio
+type TwilioVoiceSender struct {
+	AccountSID string
+	AuthToken  string
+	FromNumber string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewTwilioVoiceSender(accountSID, authToken, fromNumber string) *TwilioVoiceSender {
+	return &TwilioVoiceSender{
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		FromNumber: fromNumber,
+		BaseURL:    "https://api.twilio.com",
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *TwilioVoiceSender) Channel() string { return ChannelVoice }
+
+func (s *TwilioVoiceSender) Name() string { return "twilio_voice" }
+
+// VoiceTwiML is the call script for code: the digits spoken one by one,
+// twice
+func VoiceTwiML(code string) string {
+	digits := strings.Join(strings.Split(code, ""), ", ")
+	say := fmt.Sprintf("<Say>Your DuskSpendr code is %s.</Say>", digits)
+	return "<Response>" + say + `<Pause length="1"/>` + say + "</Response>"
+}
+
+func (s *TwilioVoiceSender) SendOTP(ctx context.Context, to, code string) (SMSReceipt, error) {
+	receipt := SMSReceipt{Provider: s.Name()}
+	if s.AccountSID == "" || s.AuthToken == "" || s.FromNumber == "" {
+		return receipt, errors.New("twilio credentials missing")
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return receipt, errors.New("twilio voice: code must be digits")
+		}
+	}
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.FromNumber)
+	form.Set("Twiml", VoiceTwiML(code))
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Calls.json", s.BaseURL, s.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return receipt, err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return receipt, fmt.Errorf("twilio voice: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		receipt.MessageID = body.SID
+		return receipt, nil
+	}
+	if body.Message != "" {
+		return receipt, fmt.Errorf("twilio voice error: %s", body.Message)
+	}
+	return receipt, fmt.Errorf("twilio voice error (status %d)", resp.StatusCode)
+}
+
+func (s *TwilioVoiceSender) Reporter(provider string) (DeliveryReporter, bool) {
+	return s, provider == s.Name()
+}
+
+// ParseDeliveryReports implements DeliveryReporter for Twilio call status
+// callbacks: an answered call counts as delivered
+func (s *TwilioVoiceSender) ParseDeliveryReports(r *http.Request) ([]SMSDeliveryReport, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	sid := r.PostForm.Get("CallSid")
+	if sid == "" {
+		return nil, errors.New("twilio: CallSid missing")
+	}
+	status := SMSStatusSent
+	switch r.PostForm.Get("CallStatus") {
+	case "completed":
+		status = SMSStatusDelivered
+	case "busy", "no-answer", "failed", "canceled":
+		status = SMSStatusUndelivered
+	}
+	return []SMSDeliveryReport{{MessageID: sid, Status: status, Detail: r.PostForm.Get("CallStatus")}}, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"duskspendr-gateway/internal/services/smstest"
+)
+
+func TestOTPChannelsOrder(t *testing.T) {
+	channels := OTPChannels{
+		&LogChannel{Name: ChannelSMS},
+		&LogChannel{Name: ChannelWhatsApp},
+		&LogChannel{Name: ChannelVoice},
+	}
+	names := func(cs OTPChannels) string {
+		var n []string
+		for _, c := range cs {
+			n = append(n, c.Channel())
+		}
+		return strings.Join(n, ",")
+	}
+
+	if got := names(channels.From(ChannelWhatsApp)); got != "whatsapp,voice,sms" {
+		t.Errorf("From(whatsapp) = %s", got)
+	}
+	if next, ok := channels.Next(ChannelVoice); !ok || next.Channel() != ChannelSMS {
+		t.Errorf("Next(voice) = %v, %v", next, ok)
+	}
+	if _, ok := channels[:1].Next(ChannelSMS); ok {
+		t.Error("Next found a fallback with a single channel")
+	}
+	if channels.From("fax") != nil {
+		t.Error("From(fax) returned channels")
+	}
+}
+
+func TestOTPChannelsSend(t *testing.T) {
+	fake := smstest.NewServer()
+	defer fake.Close()
+
+	msg91 := NewMSG91Sender("key", "DUSKSP")
+	msg91.BaseURL = fake.URL
+	whatsapp := NewWhatsAppSender("1234", "token", "otp_code", "en")
+	whatsapp.BaseURL = fake.URL + "/whatsapp"
+	voice := NewTwilioVoiceSender("AC1", "token", "+15550000000")
+	voice.BaseURL = fake.URL
+	channels := OTPChannels{&SMSChannel{Sender: NewFailoverSender(msg91), TemplateID: "1107160000000000001"}, whatsapp, voice}
+
+	for _, c := range channels {
+		receipt, err := c.SendOTP(context.Background(), "+919876543210", "482913")
+		if err != nil || receipt.MessageID == "" {
+			t.Fatalf("%s: %+v, %v", c.Channel(), receipt, err)
+		}
+	}
+
+	got := fake.Messages()
+	if len(got) != 3 {
+		t.Fatalf("fake accepted %d messages, want 3", len(got))
+	}
+	if got[0].Body != OTPMessage("482913") || got[0].TemplateID != "1107160000000000001" {
+		t.Errorf("sms = %+v", got[0])
+	}
+	if got[1].Provider != smstest.WhatsApp || got[1].Body != "482913" || got[1].TemplateID != "otp_code" || got[1].To != "+919876543210" {
+		t.Errorf("whatsapp = %+v", got[1])
+	}
+	if got[2].Provider != smstest.TwilioVoice || got[2].Body != VoiceTwiML("482913") {
+		t.Errorf("voice = %+v", got[2])
+	}
+
+	fake.Fail(smstest.WhatsApp, true)
+	if _, err := whatsapp.SendOTP(context.Background(), "+919876543210", "482913"); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("failing whatsapp: %v", err)
+	}
+	if _, err := voice.SendOTP(context.Background(), "+919876543210", "48<29"); err == nil {
+		t.Error("voice call placed for a non-numeric code")
+	}
+
+	if r, ok := channels.Reporter("msg91"); !ok || r != msg91 {
+		t.Error("msg91 reporter not found through the sms channel")
+	}
+	if r, ok := channels.Reporter("twilio_voice"); !ok || r != voice {
+		t.Error("voice reporter not found")
+	}
+	if _, ok := channels.Reporter("whatsapp_cloud"); ok {
+		t.Error("whatsapp reporter found")
+	}
+}
+
+func TestVoiceTwiML(t *testing.T) {
+	got := VoiceTwiML("123")
+	if strings.Count(got, "1, 2, 3.") != 2 || !strings.HasPrefix(got, "<Response>") {
+		t.Errorf("twiml = %s", got)
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// indianNumber returns an E.164 number without the plus, as Indian SMS
+// APIs expect it (919876543210)
+func indianNumber(to string) string {
+	return strings.TrimPrefix(strings.TrimSpace(to), "+")
+}
+
+// MSG91Sender sends SMS through MSG91's send HTTP API
+type MSG91Sender struct {
+	AuthKey  string
+	SenderID string
+	// Route 4 is transactional, which OTPs are registered as
+	Route      string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewMSG91Sender(authKey, senderID string) *MSG91Sender {
+	return &MSG91Sender{
+		AuthKey:    authKey,
+		SenderID:   senderID,
+		Route:      "4",
+		BaseURL:    "https://api.msg91.com",
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *MSG91Sender) Name() string { return "msg91" }
+
+func (s *MSG91Sender) SendSMS(ctx context.Context, to, body string) error {
+	_, err := s.Send(ctx, SMS{To: to, Body: body})
+	return err
+}
+
+// Send implements SMSProvider. MSG91 answers with the request id as plain
+// text, or a JSON error.
+func (s *MSG91Sender) Send(ctx context.Context, msg SMS) (SMSReceipt, error) {
+	receipt := SMSReceipt{Provider: s.Name()}
+	if s.AuthKey == "" || s.SenderID == "" {
+		return receipt, errors.New("msg91 credentials missing")
+	}
+	if msg.TemplateID == "" {
+		return receipt, errors.New("msg91: DLT template id required")
+	}
+
+	form := url.Values{}
+	form.Set("authkey", s.AuthKey)
+	form.Set("mobiles", indianNumber(msg.To))
+	form.Set("message", msg.Body)
+	form.Set("sender", s.SenderID)
+	form.Set("route", s.Route)
+	form.Set("DLT_TE_ID", msg.TemplateID)
+
+	body, status, err := postForm(ctx, s.HTTPClient, s.BaseURL+"/api/sendhttp.php", form)
+	if err != nil {
+		return receipt, fmt.Errorf("msg91: %w", err)
+	}
+	text := strings.TrimSpace(string(body))
+	if status < 200 || status >= 300 || strings.HasPrefix(text, "{") || text == "" {
+		var e struct {
+			Msg string `json:"msg"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Msg != "" {
+			return receipt, fmt.Errorf("msg91 error: %s", e.Msg)
+		}
+		return receipt, fmt.Errorf("msg91 error (status %d)", status)
+	}
+	receipt.MessageID = text
+	return receipt, nil
+}
+
+// ParseDeliveryReports implements DeliveryReporter. MSG91 posts a form
+// field data holding a JSON list of requests with per-number reports;
+// status 1 is delivered, 2 and above are final failures.
+func (s *MSG91Sender) ParseDeliveryReports(r *http.Request) ([]SMSDeliveryReport, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	var data []struct {
+		RequestID string `json:"requestId"`
+		Report    []struct {
+			Status string `json:"status"`
+			Desc   string `json:"desc"`
+		} `json:"report"`
+	}
+	if err := json.Unmarshal([]byte(r.PostForm.Get("data")), &data); err != nil {
+		return nil, fmt.Errorf("msg91: invalid report: %w", err)
+	}
+	var reports []SMSDeliveryReport
+	for _, d := range data {
+		for _, rep := range d.Report {
+			status := SMSStatusUndelivered
+			switch rep.Status {
+			case "1":
+				status = SMSStatusDelivered
+			case "8":
+				status = SMSStatusSent
+			}
+			reports = append(reports, SMSDeliveryReport{MessageID: d.RequestID, Status: status, Detail: rep.Desc})
+		}
+	}
+	return reports, nil
+}
+
+// GupshupSender sends SMS through the Gupshup enterprise gateway API
+type GupshupSender struct {
+	UserID   string
+	Password string
+	// EntityID is the DLT principal entity id of the business
+	EntityID   string
+	SenderID   string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewGupshupSender(userID, password, entityID, senderID string) *GupshupSender {
+	return &GupshupSender{
+		UserID:     userID,
+		Password:   password,
+		EntityID:   entityID,
+		SenderID:   senderID,
+		BaseURL:    "https://enterprise.smsgupshup.com",
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *GupshupSender) Name() string { return "gupshup" }
+
+func (s *GupshupSender) SendSMS(ctx context.Context, to, body string) error {
+	_, err := s.Send(ctx, SMS{To: to, Body: body})
+	return err
+}
+
+// Send implements SMSProvider. Gupshup answers "success | number | id" or
+// "error | code | message".
+func (s *GupshupSender) Send(ctx context.Context, msg SMS) (SMSReceipt, error) {
+	receipt := SMSReceipt{Provider: s.Name()}
+	if s.UserID == "" || s.Password == "" {
+		return receipt, errors.New("gupshup credentials missing")
+	}
+	if msg.TemplateID == "" || s.EntityID == "" {
+		return receipt, errors.New("gupshup: DLT template and entity id required")
+	}
+
+	form := url.Values{}
+	form.Set("method", "SendMessage")
+	form.Set("send_to", indianNumber(msg.To))
+	form.Set("msg", msg.Body)
+	form.Set("msg_type", "TEXT")
+	form.Set("userid", s.UserID)
+	form.Set("password", s.Password)
+	form.Set("auth_scheme", "plain")
+	form.Set("v", "1.1")
+	form.Set("format", "text")
+	form.Set("principalEntityId", s.EntityID)
+	form.Set("dltTemplateId", msg.TemplateID)
+	if s.SenderID != "" {
+		form.Set("mask", s.SenderID)
+	}
+
+	body, status, err := postForm(ctx, s.HTTPClient, s.BaseURL+"/GatewayAPI/rest", form)
+	if err != nil {
+		return receipt, fmt.Errorf("gupshup: %w", err)
+	}
+	parts := strings.Split(string(body), "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if status >= 200 && status < 300 && len(parts) == 3 && parts[0] == "success" {
+		receipt.MessageID = parts[2]
+		return receipt, nil
+	}
+	if len(parts) == 3 && parts[0] == "error" {
+		return receipt, fmt.Errorf("gupshup error %s: %s", parts[1], parts[2])
+	}
+	return receipt, fmt.Errorf("gupshup error (status %d)", status)
+}
+
+// ParseDeliveryReports implements DeliveryReporter for Gupshup's delivery
+// callback (query parameters externalId, status and cause)
+func (s *GupshupSender) ParseDeliveryReports(r *http.Request) ([]SMSDeliveryReport, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	id := r.Form.Get("externalId")
+	if id == "" {
+		return nil, errors.New("gupshup: externalId missing")
+	}
+	status := SMSStatusUndelivered
+	if strings.EqualFold(r.Form.Get("status"), "SUCCESS") {
+		status = SMSStatusDelivered
+	}
+	return []SMSDeliveryReport{{MessageID: id, Status: status, Detail: r.Form.Get("cause")}}, nil
+}
+
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return body, resp.StatusCode, err
+}
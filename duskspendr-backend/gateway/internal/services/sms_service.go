@@ -3,12 +3,15 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // SMSSender sends SMS messages
@@ -16,6 +19,59 @@ type SMSSender interface {
 	SendSMS(ctx context.Context, to, body string) error
 }
 
+// SMS is one outgoing message. TemplateID is the DLT content template the
+// body was registered under; Indian operators drop commercial SMS without
+// it. Providers outside India ignore it.
+type SMS struct {
+	To         string
+	Body       string
+	TemplateID string
+}
+
+// SMSReceipt identifies a message accepted by a provider
+type SMSReceipt struct {
+	Provider  string
+	MessageID string
+	// Failed lists the providers tried before Provider, in priority order
+	Failed []SMSFailure
+}
+
+// SMSFailure is a provider that did not accept a message
+type SMSFailure struct {
+	Provider string
+	Err      error
+}
+
+// Delivery states of an SMS. A provider accepting a message makes it
+// sent; delivery reports move it to delivered or undelivered.
+const (
+	SMSStatusSent        = "sent"
+	SMSStatusFailed      = "failed"
+	SMSStatusDelivered   = "delivered"
+	SMSStatusUndelivered = "undelivered"
+)
+
+// SMSDeliveryReport is a status update for a message, posted by its
+// provider to the delivery webhook
+type SMSDeliveryReport struct {
+	MessageID string
+	Status    string
+	Detail    string
+}
+
+// SMSProvider is an SMS API that returns the provider's message id so
+// delivery can be tracked
+type SMSProvider interface {
+	SMSSender
+	Name() string
+	Send(ctx context.Context, msg SMS) (SMSReceipt, error)
+}
+
+// DeliveryReporter is implemented by providers that post delivery reports
+type DeliveryReporter interface {
+	ParseDeliveryReports(r *http.Request) ([]SMSDeliveryReport, error)
+}
+
 // LogSender logs the SMS content to stdout (for dev/local)
 type LogSender struct{}
 
@@ -23,9 +79,16 @@ func NewLogSender() *LogSender {
 	return &LogSender{}
 }
 
+func (s *LogSender) Name() string { return "log" }
+
 func (s *LogSender) SendSMS(ctx context.Context, to, body string) error {
-	log.Printf("[SMS] To: %s, Body: %s", to, body)
-	return nil
+	_, err := s.Send(ctx, SMS{To: to, Body: body})
+	return err
+}
+
+func (s *LogSender) Send(ctx context.Context, msg SMS) (SMSReceipt, error) {
+	log.Printf("[SMS] To: %s, Template: %s, Body: %s", msg.To, msg.TemplateID, msg.Body)
+	return SMSReceipt{Provider: s.Name(), MessageID: uuid.NewString()}, nil
 }
 
 // TwilioSender sends SMS via Twilio
@@ -33,6 +96,8 @@ type TwilioSender struct {
 	AccountSID string
 	AuthToken  string
 	FromNumber string
+	// BaseURL overrides https://api.twilio.com, for tests
+	BaseURL    string
 	HTTPClient *http.Client
 }
 
@@ -41,25 +106,36 @@ func NewTwilioSender(accountSID, authToken, fromNumber string) *TwilioSender {
 		AccountSID: accountSID,
 		AuthToken:  authToken,
 		FromNumber: fromNumber,
+		BaseURL:    "https://api.twilio.com",
 		HTTPClient: &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
+func (s *TwilioSender) Name() string { return "twilio" }
+
 func (s *TwilioSender) SendSMS(ctx context.Context, to, body string) error {
+	_, err := s.Send(ctx, SMS{To: to, Body: body})
+	return err
+}
+
+// Send implements SMSProvider. Twilio registers DLT templates on its side,
+// so TemplateID is not sent.
+func (s *TwilioSender) Send(ctx context.Context, msg SMS) (SMSReceipt, error) {
+	receipt := SMSReceipt{Provider: s.Name()}
 	if s.AccountSID == "" || s.AuthToken == "" || s.FromNumber == "" {
-		return fmt.Errorf("twilio credentials missing")
+		return receipt, fmt.Errorf("twilio credentials missing")
 	}
 
-	apiURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", s.AccountSID)
+	apiURL := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.BaseURL, s.AccountSID)
 
 	data := url.Values{}
-	data.Set("To", to)
+	data.Set("To", msg.To)
 	data.Set("From", s.FromNumber)
-	data.Set("Body", body)
+	data.Set("Body", msg.Body)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return receipt, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.SetBasicAuth(s.AccountSID, s.AuthToken)
@@ -67,18 +143,94 @@ func (s *TwilioSender) SendSMS(ctx context.Context, to, body string) error {
 
 	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return receipt, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	var body struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return nil
+		receipt.MessageID = body.SID
+		return receipt, nil
+	}
+	if decodeErr != nil || body.Message == "" {
+		return receipt, fmt.Errorf("twilio error (status %d)", resp.StatusCode)
+	}
+	return receipt, fmt.Errorf("twilio error: %s", body.Message)
+}
+
+// ParseDeliveryReports implements DeliveryReporter for Twilio status
+// callbacks (form fields MessageSid and MessageStatus)
+func (s *TwilioSender) ParseDeliveryReports(r *http.Request) ([]SMSDeliveryReport, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	sid := r.PostForm.Get("MessageSid")
+	if sid == "" {
+		return nil, errors.New("twilio: MessageSid missing")
+	}
+	status := SMSStatusSent
+	switch r.PostForm.Get("MessageStatus") {
+	case "delivered":
+		status = SMSStatusDelivered
+	case "undelivered", "failed":
+		status = SMSStatusUndelivered
 	}
+	return []SMSDeliveryReport{{MessageID: sid, Status: status, Detail: r.PostForm.Get("ErrorCode")}}, nil
+}
+
+// FailoverSender tries providers in priority order until one accepts the
+// message
+type FailoverSender struct {
+	Providers []SMSProvider
+}
+
+// NewFailoverSender creates a sender over providers, highest priority first
+func NewFailoverSender(providers ...SMSProvider) *FailoverSender {
+	return &FailoverSender{Providers: providers}
+}
+
+func (s *FailoverSender) Name() string { return "failover" }
+
+func (s *FailoverSender) SendSMS(ctx context.Context, to, body string) error {
+	_, err := s.Send(ctx, SMS{To: to, Body: body})
+	return err
+}
 
-	var twilioErr map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&twilioErr); err != nil {
-		return fmt.Errorf("twilio error (status %d)", resp.StatusCode)
+// Send implements SMSProvider. The receipt names the provider that accepted
+// the message and lists the ones that failed before it.
+func (s *FailoverSender) Send(ctx context.Context, msg SMS) (SMSReceipt, error) {
+	var failed []SMSFailure
+	for _, p := range s.Providers {
+		receipt, err := p.Send(ctx, msg)
+		if err == nil {
+			receipt.Failed = append(failed, receipt.Failed...)
+			return receipt, nil
+		}
+		failed = append(failed, SMSFailure{Provider: p.Name(), Err: err})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	errs := make([]error, 0, len(failed))
+	for _, f := range failed {
+		errs = append(errs, fmt.Errorf("%s: %w", f.Provider, f.Err))
+	}
+	if len(errs) == 0 {
+		errs = append(errs, errors.New("no sms provider configured"))
 	}
+	return SMSReceipt{Failed: failed}, errors.Join(errs...)
+}
 
-	return fmt.Errorf("twilio error: %v", twilioErr["message"])
+// Provider returns the provider named name, for routing delivery reports
+func (s *FailoverSender) Provider(name string) (SMSProvider, bool) {
+	for _, p := range s.Providers {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	return nil, false
 }
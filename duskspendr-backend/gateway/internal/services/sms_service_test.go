@@ -2,7 +2,13 @@ package services
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+
+	"duskspendr-gateway/internal/services/smstest"
 )
 
 func TestLogSender(t *testing.T) {
@@ -12,3 +18,106 @@ func TestLogSender(t *testing.T) {
 		t.Errorf("LogSender.SendSMS returned error: %v", err)
 	}
 }
+
+func newFakeProviders(t *testing.T) (*smstest.Server, *MSG91Sender, *GupshupSender, *TwilioSender) {
+	t.Helper()
+	fake := smstest.NewServer()
+	t.Cleanup(fake.Close)
+	msg91 := NewMSG91Sender("key", "DUSKSP")
+	msg91.BaseURL = fake.URL
+	gupshup := NewGupshupSender("user", "pass", "1101000000000000001", "DUSKSP")
+	gupshup.BaseURL = fake.URL
+	twilio := NewTwilioSender("AC1", "token", "+15550000000")
+	twilio.BaseURL = fake.URL
+	return fake, msg91, gupshup, twilio
+}
+
+func TestProvidersSendDLTTemplate(t *testing.T) {
+	fake, msg91, gupshup, twilio := newFakeProviders(t)
+	msg := SMS{To: "+919876543210", Body: "Your DuskSpendr code is: 123456", TemplateID: "1107160000000000001"}
+
+	for _, p := range []SMSProvider{msg91, gupshup, twilio} {
+		receipt, err := p.Send(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("%s: %v", p.Name(), err)
+		}
+		if receipt.Provider != p.Name() || receipt.MessageID == "" {
+			t.Errorf("%s: receipt = %+v", p.Name(), receipt)
+		}
+	}
+
+	got := fake.Messages()
+	if len(got) != 3 {
+		t.Fatalf("fake accepted %d messages, want 3", len(got))
+	}
+	for _, m := range got[:2] {
+		if m.TemplateID != msg.TemplateID || m.To != msg.To {
+			t.Errorf("%s: message = %+v", m.Provider, m)
+		}
+	}
+
+	if _, err := msg91.Send(context.Background(), SMS{To: msg.To, Body: msg.Body}); err == nil {
+		t.Error("msg91 sent without a DLT template id")
+	}
+}
+
+func TestFailoverSender(t *testing.T) {
+	fake, msg91, gupshup, twilio := newFakeProviders(t)
+	sender := NewFailoverSender(msg91, gupshup, twilio)
+	msg := SMS{To: "+919876543210", Body: "code", TemplateID: "1107160000000000001"}
+
+	fake.Fail(smstest.MSG91, true)
+	receipt, err := sender.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if receipt.Provider != "gupshup" || len(receipt.Failed) != 1 || receipt.Failed[0].Provider != "msg91" {
+		t.Fatalf("receipt = %+v, want gupshup after msg91 failed", receipt)
+	}
+
+	fake.Fail(smstest.Gupshup, true)
+	fake.Fail(smstest.Twilio, true)
+	receipt, err = sender.Send(context.Background(), msg)
+	if err == nil {
+		t.Fatal("send succeeded with every provider failing")
+	}
+	if len(receipt.Failed) != 3 || !strings.Contains(err.Error(), "twilio") {
+		t.Fatalf("receipt = %+v, err = %v", receipt, err)
+	}
+
+	if p, ok := sender.Provider("twilio"); !ok || p != twilio {
+		t.Error("Provider(twilio) not found")
+	}
+}
+
+func TestParseDeliveryReports(t *testing.T) {
+	_, msg91, gupshup, twilio := newFakeProviders(t)
+
+	form := func(v url.Values) *http.Request {
+		r := httptest.NewRequest("POST", "/webhooks/sms", strings.NewReader(v.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	reports, err := msg91.ParseDeliveryReports(form(url.Values{
+		"data": {`[{"requestId":"msg91-1","report":[{"status":"1","desc":"DELIVERED"}]}]`},
+	}))
+	if err != nil || len(reports) != 1 || reports[0] != (SMSDeliveryReport{"msg91-1", SMSStatusDelivered, "DELIVERED"}) {
+		t.Errorf("msg91 reports = %+v, %v", reports, err)
+	}
+
+	r := httptest.NewRequest("GET", "/webhooks/sms?externalId=gupshup-2&status=FAILURE&cause=ABSENT_SUBSCRIBER", nil)
+	reports, err = gupshup.ParseDeliveryReports(r)
+	if err != nil || len(reports) != 1 || reports[0].Status != SMSStatusUndelivered || reports[0].MessageID != "gupshup-2" {
+		t.Errorf("gupshup reports = %+v, %v", reports, err)
+	}
+
+	reports, err = twilio.ParseDeliveryReports(form(url.Values{"MessageSid": {"SM1"}, "MessageStatus": {"delivered"}}))
+	if err != nil || len(reports) != 1 || reports[0].Status != SMSStatusDelivered {
+		t.Errorf("twilio reports = %+v, %v", reports, err)
+	}
+
+	if _, err := twilio.ParseDeliveryReports(form(url.Values{})); err == nil {
+		t.Error("twilio report without MessageSid accepted")
+	}
+}
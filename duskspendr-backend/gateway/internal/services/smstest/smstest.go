@@ -0,0 +1,183 @@
+// Package smstest runs a fake SMS provider for tests. One httptest server
+// speaks the MSG91, Gupshup and Twilio send APIs, the WhatsApp Cloud API and
+// Twilio calls closely enough for the senders in package services, records
+// what it accepts and can be told to fail per provider to exercise failover.
+package smstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Provider names, matching the senders' Name
+const (
+	MSG91   = "msg91"
+	Gupshup = "gupshup"
+	Twilio  = "twilio"
+	// WhatsApp and TwilioVoice are served under /whatsapp and the Twilio
+	// calls API; point WhatsAppSender.BaseURL at URL+"/whatsapp"
+	WhatsApp    = "whatsapp_cloud"
+	TwilioVoice = "twilio_voice"
+)
+
+// Message is a message the fake accepted
+type Message struct {
+	Provider string
+	ID       string
+	To       string
+	// Body is the text of an SMS, the code of a WhatsApp template message
+	// and the TwiML of a call
+	Body string
+	// TemplateID is the DLT template id, or the WhatsApp template name
+	TemplateID string
+}
+
+// Server is the fake provider; point a sender's BaseURL at URL
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	messages []Message
+	failing  map[string]bool
+	seq      int
+}
+
+// NewServer starts a fake provider; Close it when done
+func NewServer() *Server {
+	s := &Server{failing: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/sendhttp.php", s.msg91)
+	mux.HandleFunc("/GatewayAPI/rest", s.gupshup)
+	mux.HandleFunc("/2010-04-01/Accounts/", s.twilio)
+	mux.HandleFunc("/whatsapp/", s.whatsapp)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Fail makes provider reject every message until called with false
+func (s *Server) Fail(provider string, fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[provider] = fail
+}
+
+// Messages returns the accepted messages in order
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// accept records m unless provider is failing and returns its id
+func (s *Server) accept(m Message) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing[m.Provider] {
+		return "", false
+	}
+	s.seq++
+	m.ID = fmt.Sprintf("%s-%d", m.Provider, s.seq)
+	s.messages = append(s.messages, m)
+	return m.ID, true
+}
+
+func (s *Server) msg91(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	if r.PostForm.Get("authkey") == "" || r.PostForm.Get("DLT_TE_ID") == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"msg": "authkey and DLT_TE_ID required", "msgType": "error"})
+		return
+	}
+	id, ok := s.accept(Message{
+		Provider:   MSG91,
+		To:         "+" + r.PostForm.Get("mobiles"),
+		Body:       r.PostForm.Get("message"),
+		TemplateID: r.PostForm.Get("DLT_TE_ID"),
+	})
+	if !ok {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"msg": "provider unavailable", "msgType": "error"})
+		return
+	}
+	fmt.Fprint(w, id)
+}
+
+func (s *Server) gupshup(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	if r.PostForm.Get("dltTemplateId") == "" || r.PostForm.Get("principalEntityId") == "" {
+		fmt.Fprint(w, "error | 175 | dltTemplateId and principalEntityId required")
+		return
+	}
+	id, ok := s.accept(Message{
+		Provider:   Gupshup,
+		To:         "+" + r.PostForm.Get("send_to"),
+		Body:       r.PostForm.Get("msg"),
+		TemplateID: r.PostForm.Get("dltTemplateId"),
+	})
+	if !ok {
+		fmt.Fprint(w, "error | 100 | provider unavailable")
+		return
+	}
+	fmt.Fprintf(w, "success | %s | %s", r.PostForm.Get("send_to"), id)
+}
+
+func (s *Server) twilio(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	if _, _, ok := r.BasicAuth(); !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "authenticate"})
+		return
+	}
+	m := Message{Provider: Twilio, To: r.PostForm.Get("To"), Body: r.PostForm.Get("Body")}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/Calls.json"):
+		m.Provider, m.Body = TwilioVoice, r.PostForm.Get("Twiml")
+	case !strings.HasSuffix(r.URL.Path, "/Messages.json"):
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+		return
+	}
+	id, ok := s.accept(m)
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "provider unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"sid": id, "status": "queued"})
+}
+
+func (s *Server) whatsapp(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		To       string `json:"to"`
+		Template struct {
+			Name       string `json:"name"`
+			Components []struct {
+				Type       string `json:"type"`
+				Parameters []struct {
+					Text string `json:"text"`
+				} `json:"parameters"`
+			} `json:"components"`
+		} `json:"template"`
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]string{"message": "invalid request"}})
+		return
+	}
+	m := Message{Provider: WhatsApp, To: "+" + req.To, TemplateID: req.Template.Name}
+	for _, c := range req.Template.Components {
+		if c.Type == "body" && len(c.Parameters) > 0 {
+			m.Body = c.Parameters[0].Text
+		}
+	}
+	id, ok := s.accept(m)
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": map[string]string{"message": "provider unavailable"}})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"messages": []map[string]string{{"id": id}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RefreshRecord is the stored state of one issued refresh token
+type RefreshRecord struct {
+	JTI       string
+	FamilyID  string
+	UserID    string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RefreshStore persists refresh tokens by jti, grouped into families
+type RefreshStore interface {
+	// Create records the first token of a new family
+	Create(ctx context.Context, rec RefreshRecord) error
+	// Rotate marks jti as used and records next as its successor in one step,
+	// returning the family of jti. A jti that was already rotated revokes its
+	// whole family and returns ErrTokenReused; a revoked or unknown jti
+	// returns ErrTokenRevoked or ErrInvalidToken.
+	Rotate(ctx context.Context, jti string, next RefreshRecord) (string, error)
+	// RevokeFamily revokes every token in the family
+	RevokeFamily(ctx context.Context, familyID, reason string) error
+	// RevokeUser revokes every live family of the user and returns their ids
+	RevokeUser(ctx context.Context, userID, reason string) ([]string, error)
+}
+
+// TokenFamilies issues token pairs whose refresh tokens are single use
+type TokenFamilies struct {
+	JWT   *JWTService
+	Store RefreshStore
+}
+
+// NewTokenFamilies creates token families backed by Postgres
+func NewTokenFamilies(jwtSvc *JWTService, pool *pgxpool.Pool) *TokenFamilies {
+	return &TokenFamilies{JWT: jwtSvc, Store: &PostgresRefreshStore{Pool: pool}}
+}
+
+// Issue creates a token pair that starts a new family, e.g. after login
+func (f *TokenFamilies) Issue(ctx context.Context, userID, email, phone string) (*TokenPair, error) {
+	pair, err := f.JWT.GenerateTokenPair(userID, email, phone)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Store.Create(ctx, recordFor(userID, pair)); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Rotate exchanges a refresh token for a new pair in the same family. The
+// presented token can not be used again; presenting it a second time is
+// treated as theft and revokes the family, logging out every holder.
+//
+// The family id is returned with ErrTokenReused too, so the caller can deny
+// the family's access tokens and end its session.
+func (f *TokenFamilies) Rotate(ctx context.Context, refreshToken string) (*TokenPair, string, error) {
+	claims, err := f.JWT.ValidateRefreshToken(refreshToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if claims.ID == "" || claims.FamilyID == "" {
+		// Issued before token families existed
+		return nil, "", ErrInvalidToken
+	}
+
+	pair, err := f.JWT.generateTokenPair(claims.UserID, claims.Email, claims.Phone, claims.FamilyID)
+	if err != nil {
+		return nil, "", err
+	}
+	familyID, err := f.Store.Rotate(ctx, claims.ID, recordFor(claims.UserID, pair))
+	if err != nil {
+		return nil, familyID, err
+	}
+	return pair, familyID, nil
+}
+
+// Revoke revokes a token family, e.g. on logout
+func (f *TokenFamilies) Revoke(ctx context.Context, familyID, reason string) error {
+	return f.Store.RevokeFamily(ctx, familyID, reason)
+}
+
+// RevokeUser revokes all of a user's families and returns their ids
+func (f *TokenFamilies) RevokeUser(ctx context.Context, userID, reason string) ([]string, error) {
+	return f.Store.RevokeUser(ctx, userID, reason)
+}
+
+func recordFor(userID string, pair *TokenPair) RefreshRecord {
+	return RefreshRecord{
+		JTI:       pair.RefreshID,
+		FamilyID:  pair.FamilyID,
+		UserID:    userID,
+		IssuedAt:  pair.IssuedAt.UTC(),
+		ExpiresAt: pair.RefreshExpiresAt.UTC(),
+	}
+}
+
+// PostgresRefreshStore keeps refresh tokens in the refresh_tokens table
+type PostgresRefreshStore struct {
+	Pool *pgxpool.Pool
+}
+
+// Create implements RefreshStore
+func (s *PostgresRefreshStore) Create(ctx context.Context, rec RefreshRecord) error {
+	_, err := s.Pool.Exec(ctx, `
+		INSERT INTO refresh_tokens (jti, family_id, user_id, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, rec.JTI, rec.FamilyID, rec.UserID, rec.IssuedAt, rec.ExpiresAt)
+	return err
+}
+
+// Rotate implements RefreshStore
+func (s *PostgresRefreshStore) Rotate(ctx context.Context, jti string, next RefreshRecord) (string, error) {
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		familyID  string
+		rotatedAt *time.Time
+		revokedAt *time.Time
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT family_id, rotated_at, revoked_at
+		  FROM refresh_tokens
+		 WHERE jti = $1
+		 FOR UPDATE
+	`, jti).Scan(&familyID, &rotatedAt, &revokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case revokedAt != nil:
+		return familyID, ErrTokenRevoked
+	case rotatedAt != nil:
+		if _, err := tx.Exec(ctx, `
+			UPDATE refresh_tokens
+			   SET revoked_at = now(), revoke_reason = 'reuse'
+			 WHERE family_id = $1 AND revoked_at IS NULL
+		`, familyID); err != nil {
+			return familyID, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return familyID, err
+		}
+		return familyID, ErrTokenReused
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens SET rotated_at = now(), replaced_by = $2 WHERE jti = $1
+	`, jti, next.JTI); err != nil {
+		return familyID, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (jti, family_id, user_id, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, next.JTI, familyID, next.UserID, next.IssuedAt, next.ExpiresAt); err != nil {
+		return familyID, err
+	}
+	return familyID, tx.Commit(ctx)
+}
+
+// RevokeFamily implements RefreshStore
+func (s *PostgresRefreshStore) RevokeFamily(ctx context.Context, familyID, reason string) error {
+	_, err := s.Pool.Exec(ctx, `
+		UPDATE refresh_tokens
+		   SET revoked_at = now(), revoke_reason = $2
+		 WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID, reason)
+	return err
+}
+
+// RevokeUser implements RefreshStore
+func (s *PostgresRefreshStore) RevokeUser(ctx context.Context, userID, reason string) ([]string, error) {
+	rows, err := s.Pool.Query(ctx, `
+		UPDATE refresh_tokens
+		   SET revoked_at = now(), revoke_reason = $2
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		RETURNING family_id
+	`, userID, reason)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[string]bool{}
+	var families []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, err
+		}
+		if !seen[familyID] {
+			seen[familyID] = true
+			families = append(families, familyID)
+		}
+	}
+	return families, rows.Err()
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type memoryRefreshStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryRefresh
+}
+
+type memoryRefresh struct {
+	rec     RefreshRecord
+	rotated bool
+	revoked bool
+}
+
+func newMemoryRefreshStore() *memoryRefreshStore {
+	return &memoryRefreshStore{records: map[string]*memoryRefresh{}}
+}
+
+func (s *memoryRefreshStore) Create(ctx context.Context, rec RefreshRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.JTI] = &memoryRefresh{rec: rec}
+	return nil
+}
+
+func (s *memoryRefreshStore) Rotate(ctx context.Context, jti string, next RefreshRecord) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[jti]
+	switch {
+	case !ok:
+		return "", ErrInvalidToken
+	case r.revoked:
+		return r.rec.FamilyID, ErrTokenRevoked
+	case r.rotated:
+		s.revokeLocked(r.rec.FamilyID)
+		return r.rec.FamilyID, ErrTokenReused
+	}
+	r.rotated = true
+	next.FamilyID = r.rec.FamilyID
+	s.records[next.JTI] = &memoryRefresh{rec: next}
+	return r.rec.FamilyID, nil
+}
+
+func (s *memoryRefreshStore) RevokeFamily(ctx context.Context, familyID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeLocked(familyID)
+	return nil
+}
+
+func (s *memoryRefreshStore) RevokeUser(ctx context.Context, userID, reason string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var families []string
+	for _, r := range s.records {
+		if r.rec.UserID == userID && !r.revoked && !seen[r.rec.FamilyID] {
+			seen[r.rec.FamilyID] = true
+			families = append(families, r.rec.FamilyID)
+		}
+	}
+	for _, f := range families {
+		s.revokeLocked(f)
+	}
+	return families, nil
+}
+
+func (s *memoryRefreshStore) revokeLocked(familyID string) {
+	for _, r := range s.records {
+		if r.rec.FamilyID == familyID {
+			r.revoked = true
+		}
+	}
+}
+
+func TestTokenFamiliesRotation(t *testing.T) {
+	ctx := context.Background()
+	families := &TokenFamilies{JWT: newTestJWTService(t), Store: newMemoryRefreshStore()}
+
+	first, err := families.Issue(ctx, "user-1", "a@example.com", "+919800000000")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if first.FamilyID == "" || first.RefreshID == "" {
+		t.Fatalf("pair missing family bookkeeping: %+v", first)
+	}
+
+	second, _, err := families.Rotate(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if second.FamilyID != first.FamilyID {
+		t.Errorf("rotated family = %q, want %q", second.FamilyID, first.FamilyID)
+	}
+	if second.RefreshID == first.RefreshID {
+		t.Error("rotation reused the refresh jti")
+	}
+	claims, err := families.JWT.ValidateAccessToken(second.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	if claims.Email != "a@example.com" || claims.Phone != "+919800000000" {
+		t.Errorf("rotated access claims lost identity: %+v", claims)
+	}
+
+	// Replaying the spent token revokes the family, including its successor
+	_, familyID, err := families.Rotate(ctx, first.RefreshToken)
+	if !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("replay err = %v, want ErrTokenReused", err)
+	}
+	if familyID != first.FamilyID {
+		t.Errorf("replay family = %q, want %q", familyID, first.FamilyID)
+	}
+	if _, _, err := families.Rotate(ctx, second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("successor err = %v, want ErrTokenRevoked", err)
+	}
+
+	// Other families are unaffected
+	other, err := families.Issue(ctx, "user-1", "", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, err := families.Rotate(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("other family Rotate: %v", err)
+	}
+}
+
+func TestTokenFamiliesRejectsAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	families := &TokenFamilies{JWT: newTestJWTService(t), Store: newMemoryRefreshStore()}
+	pair, err := families.Issue(ctx, "user-1", "", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, err := families.Rotate(ctx, pair.AccessToken); err == nil {
+		t.Fatal("Rotate accepted an access token")
+	}
+}
+
+func TestTokenFamiliesRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	families := &TokenFamilies{JWT: newTestJWTService(t), Store: newMemoryRefreshStore()}
+	phone, _ := families.Issue(ctx, "user-1", "", "")
+	laptop, _ := families.Issue(ctx, "user-1", "", "")
+	other, _ := families.Issue(ctx, "user-2", "", "")
+
+	revoked, err := families.RevokeUser(ctx, "user-1", "logout_all")
+	if err != nil {
+		t.Fatalf("RevokeUser: %v", err)
+	}
+	if len(revoked) != 2 {
+		t.Errorf("revoked %d families, want 2", len(revoked))
+	}
+	for _, pair := range []*TokenPair{phone, laptop} {
+		if _, _, err := families.Rotate(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("Rotate after RevokeUser err = %v, want ErrTokenRevoked", err)
+		}
+	}
+	if _, _, err := families.Rotate(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other user's Rotate: %v", err)
+	}
+}
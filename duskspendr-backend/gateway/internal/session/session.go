@@ -0,0 +1,71 @@
+// Package session keeps the list of signed-in devices for both the opaque
+// token flow and the JWT refresh token flow.
+package session
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Platforms a client may report; anything else is stored as "other"
+var Platforms = map[string]bool{
+	"android": true,
+	"ios":     true,
+	"web":     true,
+	"other":   true,
+}
+
+// Session kinds
+const (
+	KindToken = "token" // opaque bearer token, sessions.token_hash
+	KindJWT   = "jwt"   // JWT refresh token family, sessions.family_id
+)
+
+// Device describes the client a session was created from. Name, platform
+// and app version are reported by the client at verify; IP and user agent
+// come from the request.
+type Device struct {
+	Name       string `json:"device_name,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+}
+
+// Session is one signed-in device
+type Session struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Device     Device    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// Normalize trims the client-reported fields and caps their length so a
+// client cannot store arbitrary blobs in the device list.
+func (d Device) Normalize() Device {
+	d.Name = clip(d.Name, 100)
+	d.AppVersion = clip(d.AppVersion, 32)
+	d.IP = clip(d.IP, 64)
+	d.UserAgent = clip(d.UserAgent, 256)
+	d.Platform = strings.ToLower(strings.TrimSpace(d.Platform))
+	if d.Platform != "" && !Platforms[d.Platform] {
+		d.Platform = "other"
+	}
+	return d
+}
+
+func clip(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,38 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDeviceNormalize(t *testing.T) {
+	d := Device{
+		Name:       "  Pixel 8  ",
+		Platform:   " Android ",
+		AppVersion: "1.4.2",
+		UserAgent:  strings.Repeat("a", 300),
+	}.Normalize()
+	if d.Name != "Pixel 8" {
+		t.Errorf("Name = %q", d.Name)
+	}
+	if d.Platform != "android" {
+		t.Errorf("Platform = %q, want android", d.Platform)
+	}
+	if len(d.UserAgent) != 256 {
+		t.Errorf("UserAgent length = %d, want 256", len(d.UserAgent))
+	}
+
+	if got := (Device{Platform: "symbian"}).Normalize().Platform; got != "other" {
+		t.Errorf("unknown platform = %q, want other", got)
+	}
+	if got := (Device{}).Normalize().Platform; got != "" {
+		t.Errorf("empty platform = %q, want empty", got)
+	}
+
+	// Clipping never splits a multi-byte character
+	name := (Device{Name: strings.Repeat("é", 60)}).Normalize().Name
+	if !utf8.ValidString(name) || len(name) > 100 {
+		t.Errorf("clipped name %q is invalid or too long", name)
+	}
+}
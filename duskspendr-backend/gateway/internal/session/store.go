@@ -0,0 +1,165 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"duskspendr-gateway/internal/services"
+)
+
+// ErrNotFound is returned for a session id the user does not own
+var ErrNotFound = errors.New("session not found")
+
+// touchEvery limits last_seen_at writes to one per session per interval
+const touchEvery = 5 * time.Minute
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// CreateToken records an opaque token session
+func CreateToken(ctx context.Context, q Querier, userID, tokenHash string, d Device, expiresAt time.Time) (string, error) {
+	return create(ctx, q, userID, &tokenHash, nil, d, expiresAt)
+}
+
+// CreateJWT records a session for a JWT refresh token family
+func CreateJWT(ctx context.Context, q Querier, userID, familyID string, d Device, expiresAt time.Time) (string, error) {
+	return create(ctx, q, userID, nil, &familyID, d, expiresAt)
+}
+
+func create(ctx context.Context, q Querier, userID string, tokenHash, familyID *string, d Device, expiresAt time.Time) (string, error) {
+	d = d.Normalize()
+	now := time.Now().UTC()
+	var id string
+	err := q.QueryRow(ctx, `
+		INSERT INTO sessions (id, user_id, token_hash, family_id, expires_at, created_at, last_seen_at,
+		                      device_name, platform, app_version, ip, user_agent)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $5,
+		        nullif($6, ''), nullif($7, ''), nullif($8, ''), nullif($9, ''), nullif($10, ''))
+		RETURNING id::text
+	`, userID, tokenHash, familyID, expiresAt, now,
+		d.Name, d.Platform, d.AppVersion, d.IP, d.UserAgent).Scan(&id)
+	return id, err
+}
+
+// List returns the user's unexpired sessions, most recently seen first.
+// The session with currentID or currentFamily is flagged as the caller's.
+func List(ctx context.Context, q Querier, userID, currentID, currentFamily string) ([]Session, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id::text, CASE WHEN family_id IS NULL THEN 'token' ELSE 'jwt' END,
+		       coalesce(device_name, ''), coalesce(platform, ''), coalesce(app_version, ''),
+		       coalesce(ip, ''), coalesce(user_agent, ''),
+		       created_at, last_seen_at, expires_at, coalesce(family_id, '')
+		  FROM sessions
+		 WHERE user_id = $1 AND expires_at > now()
+		 ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		var familyID string
+		if err := rows.Scan(&s.ID, &s.Kind, &s.Device.Name, &s.Device.Platform, &s.Device.AppVersion,
+			&s.Device.IP, &s.Device.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &familyID); err != nil {
+			return nil, err
+		}
+		s.Current = s.ID == currentID || (familyID != "" && familyID == currentFamily)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch updates last_seen_at of an opaque token session, at most once per
+// touchEvery so authenticated requests do not each cost a write.
+func Touch(ctx context.Context, q Querier, sessionID, ip string) error {
+	_, err := q.Exec(ctx, `
+		UPDATE sessions
+		   SET last_seen_at = now(), ip = coalesce(nullif($2, ''), ip)
+		 WHERE id = $1 AND last_seen_at < now() - make_interval(secs => $3)
+	`, sessionID, ip, touchEvery.Seconds())
+	return err
+}
+
+// TouchFamily records a refresh of a JWT session and extends its expiry to
+// that of the new refresh token.
+func TouchFamily(ctx context.Context, q Querier, familyID, ip string, expiresAt time.Time) error {
+	_, err := q.Exec(ctx, `
+		UPDATE sessions
+		   SET last_seen_at = now(), expires_at = $3, ip = coalesce(nullif($2, ''), ip)
+		 WHERE family_id = $1
+	`, familyID, ip, expiresAt)
+	return err
+}
+
+// Revoker ends sessions. Deleting the row ends an opaque token session; a
+// JWT session also needs its refresh family revoked and its live access
+// tokens denied.
+type Revoker struct {
+	Pool      *pgxpool.Pool
+	Refresh   services.RefreshStore
+	Denylist  services.TokenDenylist
+	AccessTTL time.Duration
+}
+
+// Revoke ends one session of the user
+func (r *Revoker) Revoke(ctx context.Context, userID, sessionID string) error {
+	var familyID *string
+	err := r.Pool.QueryRow(ctx, `
+		DELETE FROM sessions WHERE id::text = $1 AND user_id = $2
+		RETURNING family_id
+	`, sessionID, userID).Scan(&familyID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if familyID == nil {
+		return nil
+	}
+	return r.endFamily(ctx, *familyID, "session_revoked")
+}
+
+// RevokeFamily ends the JWT session of a refresh token family, e.g. on logout
+func (r *Revoker) RevokeFamily(ctx context.Context, familyID, reason string) error {
+	if _, err := r.Pool.Exec(ctx, `DELETE FROM sessions WHERE family_id = $1`, familyID); err != nil {
+		return err
+	}
+	return r.endFamily(ctx, familyID, reason)
+}
+
+// RevokeAll ends every session of the user in both flows and reports how
+// many refresh token families were revoked
+func (r *Revoker) RevokeAll(ctx context.Context, userID, reason string) (int, error) {
+	if _, err := r.Pool.Exec(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return 0, err
+	}
+	families, err := r.Refresh.RevokeUser(ctx, userID, reason)
+	if err != nil {
+		return 0, err
+	}
+	for _, familyID := range families {
+		if err := r.Denylist.DenyFamily(ctx, familyID, r.AccessTTL); err != nil {
+			return 0, err
+		}
+	}
+	return len(families), nil
+}
+
+func (r *Revoker) endFamily(ctx context.Context, familyID, reason string) error {
+	if err := r.Refresh.RevokeFamily(ctx, familyID, reason); err != nil {
+		return err
+	}
+	return r.Denylist.DenyFamily(ctx, familyID, r.AccessTTL)
+}
@@ -0,0 +1,340 @@
+// Package smsparse extracts transactions from Indian bank and wallet SMS alerts.
+//
+// Parsing is template driven: each Template is a regular expression with named
+// groups (amount, account, merchant, vpa, ref, balance, type). Built-in
+// templates cover the major banks and wallets; more can be loaded at runtime
+// from the sms_templates table without a redeploy.
+package smsparse
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoMatch is returned when no template recognises the message
+var ErrNoMatch = errors.New("smsparse: no template matched")
+
+// Template describes one SMS format
+type Template struct {
+	Name          string   `json:"name"`
+	Bank          string   `json:"bank"`
+	Senders       []string `json:"senders,omitempty"`
+	Pattern       string   `json:"pattern"`
+	Type          string   `json:"type,omitempty"`
+	PaymentMethod string   `json:"payment_method,omitempty"`
+	Priority      int      `json:"priority"`
+}
+
+// Result is a parsed transaction. Amounts are in paisa.
+type Result struct {
+	Template      string  `json:"template"`
+	Bank          string  `json:"bank"`
+	AmountPaisa   int64   `json:"amount_paisa"`
+	Type          string  `json:"type"`
+	AccountLast4  *string `json:"account_last4,omitempty"`
+	Merchant      *string `json:"merchant,omitempty"`
+	VPA           *string `json:"vpa,omitempty"`
+	Reference     *string `json:"reference,omitempty"`
+	BalancePaisa  *int64  `json:"balance_paisa,omitempty"`
+	PaymentMethod *string `json:"payment_method,omitempty"`
+}
+
+type compiledTemplate struct {
+	Template
+	re      *regexp.Regexp
+	senders []string
+}
+
+// Registry holds the active templates. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	builtins  []compiledTemplate
+	templates []compiledTemplate
+}
+
+// NewRegistry creates a registry with the built-in templates
+func NewRegistry() *Registry {
+	r := &Registry{}
+	for _, t := range builtinTemplates {
+		ct, err := compile(t)
+		if err != nil {
+			panic("smsparse: invalid builtin template " + t.Name + ": " + err.Error())
+		}
+		r.builtins = append(r.builtins, ct)
+	}
+	r.templates = sortTemplates(append([]compiledTemplate(nil), r.builtins...))
+	return r
+}
+
+// SetCustom replaces the runtime-loaded templates, keeping the built-ins.
+// Custom templates sharing a built-in's name override it. Templates that do
+// not compile are skipped, so one bad row cannot block the others; the
+// returned error names them.
+func (r *Registry) SetCustom(custom []Template) error {
+	compiled := make([]compiledTemplate, 0, len(custom))
+	overridden := map[string]bool{}
+	var skipped []error
+	for _, t := range custom {
+		ct, err := compile(t)
+		if err != nil {
+			skipped = append(skipped, errors.New("smsparse: skipped template "+t.Name+": "+err.Error()))
+			continue
+		}
+		compiled = append(compiled, ct)
+		overridden[t.Name] = true
+	}
+	for _, b := range r.builtins {
+		if !overridden[b.Name] {
+			compiled = append(compiled, b)
+		}
+	}
+
+	r.mu.Lock()
+	r.templates = sortTemplates(compiled)
+	r.mu.Unlock()
+	return errors.Join(skipped...)
+}
+
+// Templates returns the active templates in match order
+func (r *Registry) Templates() []Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Template, 0, len(r.templates))
+	for _, t := range r.templates {
+		out = append(out, t.Template)
+	}
+	return out
+}
+
+// Parse matches body against the templates whose senders match sender.
+// An empty sender matches every template.
+func (r *Registry) Parse(sender, body string) (*Result, error) {
+	body = normalizeBody(body)
+	sender = strings.ToUpper(strings.TrimSpace(sender))
+
+	r.mu.RLock()
+	templates := r.templates
+	r.mu.RUnlock()
+
+	for _, t := range templates {
+		if !t.matchesSender(sender) {
+			continue
+		}
+		m := t.re.FindStringSubmatch(body)
+		if m == nil {
+			continue
+		}
+		if res := t.build(m, body); res != nil {
+			return res, nil
+		}
+	}
+	return nil, ErrNoMatch
+}
+
+func compile(t Template) (compiledTemplate, error) {
+	if t.Name == "" || t.Pattern == "" {
+		return compiledTemplate{}, errors.New("name and pattern are required")
+	}
+	if t.Type != "" && t.Type != "debit" && t.Type != "credit" {
+		return compiledTemplate{}, errors.New("type must be debit or credit")
+	}
+	re, err := regexp.Compile("(?is)" + t.Pattern)
+	if err != nil {
+		return compiledTemplate{}, err
+	}
+	if re.SubexpIndex("amount") < 0 {
+		return compiledTemplate{}, errors.New("pattern must capture amount")
+	}
+	if t.Type == "" && re.SubexpIndex("type") < 0 {
+		return compiledTemplate{}, errors.New("pattern must capture type when type is not fixed")
+	}
+	senders := make([]string, 0, len(t.Senders))
+	for _, s := range t.Senders {
+		senders = append(senders, strings.ToUpper(s))
+	}
+	return compiledTemplate{Template: t, re: re, senders: senders}, nil
+}
+
+func sortTemplates(ts []compiledTemplate) []compiledTemplate {
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Priority > ts[j].Priority
+	})
+	return ts
+}
+
+func (t compiledTemplate) matchesSender(sender string) bool {
+	if sender == "" || len(t.senders) == 0 {
+		return true
+	}
+	for _, s := range t.senders {
+		if strings.Contains(sender, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t compiledTemplate) build(m []string, body string) *Result {
+	group := func(name string) string {
+		if i := t.re.SubexpIndex(name); i >= 0 && i < len(m) {
+			return strings.TrimSpace(m[i])
+		}
+		return ""
+	}
+
+	amount, ok := ParseAmount(group("amount"))
+	if !ok || amount <= 0 {
+		return nil
+	}
+
+	txType := t.Type
+	if txType == "" {
+		txType = classifyType(group("type"))
+		if txType == "" {
+			return nil
+		}
+	}
+
+	res := &Result{
+		Template:    t.Name,
+		Bank:        t.Bank,
+		AmountPaisa: amount,
+		Type:        txType,
+	}
+	account := group("account")
+	if account == "" {
+		account = findFirst(accountPattern, body)
+	}
+	if v := lastDigits(account, 4); v != "" {
+		res.AccountLast4 = &v
+	}
+	if v := cleanMerchant(group("merchant")); v != "" {
+		res.Merchant = &v
+	}
+	if v := strings.ToLower(group("vpa")); v != "" {
+		res.VPA = &v
+	}
+
+	ref := group("ref")
+	if ref == "" {
+		ref = findFirst(referencePattern, body)
+	}
+	if ref != "" {
+		res.Reference = &ref
+	}
+
+	balance := group("balance")
+	if balance == "" {
+		balance = findFirst(balancePattern, body)
+	}
+	if v, ok := ParseAmount(balance); ok {
+		res.BalancePaisa = &v
+	}
+
+	method := t.PaymentMethod
+	if method == "" && (res.VPA != nil || strings.Contains(strings.ToUpper(body), "UPI")) {
+		method = "upi"
+	}
+	if method != "" {
+		res.PaymentMethod = &method
+	}
+	return res
+}
+
+var (
+	referencePattern = regexp.MustCompile(`(?i)\b(?:UPI\s*Ref(?:erence)?(?:\s*No)?|Ref(?:\s*No)?|Txn\s*ID|UTR)[\s.:#-]*([A-Z0-9]{8,22})`)
+	balancePattern   = regexp.MustCompile(`(?i)\b(?:Avl\.?\s*Bal(?:ance)?|Available\s+Balance|Updated\s+Balance|Wallet\s+balance|Bal(?:ance)?)[\s:.-]*(?:is\s+)?(?:Rs\.?|INR|₹)?\s*([0-9,]*[0-9](?:\.[0-9]{1,2})?)`)
+	accountPattern   = regexp.MustCompile(`(?i)\b(?:A/c|Acct|Account|Card)\s*(?:no\.?|ending(?:\s+with)?)?\s*[X*]*(\d{3,6})\b`)
+	whitespace       = regexp.MustCompile(`\s+`)
+)
+
+func normalizeBody(body string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(body, " "))
+}
+
+func findFirst(re *regexp.Regexp, body string) string {
+	m := re.FindStringSubmatch(body)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
+
+func classifyType(word string) string {
+	w := strings.ToLower(word)
+	switch {
+	case strings.Contains(w, "debit"), strings.Contains(w, "spent"), strings.Contains(w, "sent"),
+		strings.Contains(w, "paid"), strings.Contains(w, "withdrawn"):
+		return "debit"
+	case strings.Contains(w, "credit"), strings.Contains(w, "received"), strings.Contains(w, "deposit"):
+		return "credit"
+	}
+	return ""
+}
+
+func lastDigits(account string, n int) string {
+	digits := make([]byte, 0, len(account))
+	for i := 0; i < len(account); i++ {
+		if account[i] >= '0' && account[i] <= '9' {
+			digits = append(digits, account[i])
+		}
+	}
+	if len(digits) == 0 {
+		return ""
+	}
+	if len(digits) > n {
+		digits = digits[len(digits)-n:]
+	}
+	return string(digits)
+}
+
+// maxMerchantBytes matches the merchant_name limit on ingest
+const maxMerchantBytes = 120
+
+func cleanMerchant(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), ".,;:-")
+	// Cut to the 120 bytes ingest accepts, backing up to a rune boundary so
+	// Devanagari or ₹ in a name never end up half a character
+	if len(s) > maxMerchantBytes {
+		n := maxMerchantBytes
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = strings.TrimSpace(s[:n])
+	}
+	return s
+}
+
+// ParseAmount converts "1,234.5" style amounts into paisa
+func ParseAmount(s string) (int64, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, false
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	rupees, err := strconv.ParseInt(whole, 10, 64)
+	// Bounded so rupees*100 plus the paisa cannot overflow
+	if err != nil || rupees < 0 || rupees >= math.MaxInt64/100 {
+		return 0, false
+	}
+	if len(frac) > 2 {
+		frac = frac[:2]
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	paisa, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return rupees*100 + paisa, true
+}
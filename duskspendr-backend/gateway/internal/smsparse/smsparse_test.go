@@ -0,0 +1,141 @@
+package smsparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// TestGolden parses every testdata/*.txt message (first line is the sender,
+// the rest is the body) and compares the result with the .golden file.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no corpus files found")
+	}
+
+	reg := NewRegistry()
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sender, body, _ := strings.Cut(string(raw), "\n")
+
+			var got []byte
+			res, err := reg.Parse(sender, body)
+			if err != nil {
+				got, _ = json.MarshalIndent(map[string]string{"error": err.Error()}, "", "  ")
+			} else {
+				got, _ = json.MarshalIndent(res, "", "  ")
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(file, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file (run go test -update): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("mismatch for %s\ngot:\n%s\nwant:\n%s", name, got, want)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]int64{
+		"1,234.50":  123450,
+		"250":       25000,
+		"250.0":     25000,
+		"0.5":       50,
+		"10,00,000": 100000000,
+	}
+	for in, want := range cases {
+		got, ok := ParseAmount(in)
+		if !ok || got != want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", in, got, ok, want)
+		}
+	}
+	if _, ok := ParseAmount("abc"); ok {
+		t.Error("ParseAmount(abc) should fail")
+	}
+	if got, ok := ParseAmount("92233720368547758.07"); ok {
+		t.Errorf("ParseAmount accepted an amount that overflows int64: %d", got)
+	}
+}
+
+func TestCleanMerchantKeepsUTF8(t *testing.T) {
+	name := strings.Repeat("किराना", 30)
+	got := cleanMerchant(name)
+	if !utf8.ValidString(got) {
+		t.Fatalf("cleanMerchant cut a character: %q", got)
+	}
+	// 120 bytes is 40 three-byte Devanagari runes
+	if len(got) != 120 || utf8.RuneCountInString(got) != 40 {
+		t.Errorf("kept %d bytes, %d runes; want 120 bytes", len(got), utf8.RuneCountInString(got))
+	}
+	if got := cleanMerchant("a" + strings.Repeat("₹", 50)); len(got) > 120 || !utf8.ValidString(got) {
+		t.Errorf("cut mid-rune or over 120 bytes: %d bytes", len(got))
+	}
+	if got := cleanMerchant(" ₹ Store. "); got != "₹ Store" {
+		t.Errorf("cleanMerchant = %q", got)
+	}
+}
+
+func TestSetCustomOverridesAndExtends(t *testing.T) {
+	reg := NewRegistry()
+	err := reg.SetCustom([]Template{{
+		Name:     "yes_upi_debit",
+		Bank:     "YES",
+		Senders:  []string{"YESBNK"},
+		Pattern:  `INR\s*(?P<amount>[\d,.]+)\s+paid\s+to\s+(?P<merchant>.+?)\s+from\s+YES\s+Bank`,
+		Type:     "debit",
+		Priority: 20,
+	}})
+	if err != nil {
+		t.Fatalf("SetCustom: %v", err)
+	}
+
+	res, err := reg.Parse("VM-YESBNK", "INR 99.00 paid to BLINKIT from YES Bank a/c XX1111")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if res.Template != "yes_upi_debit" || res.AmountPaisa != 9900 || res.Merchant == nil || *res.Merchant != "BLINKIT" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if res.AccountLast4 == nil || *res.AccountLast4 != "1111" {
+		t.Errorf("account fallback not applied: %+v", res.AccountLast4)
+	}
+
+	// A template that does not compile is reported and skipped; the rest
+	// still take effect
+	err = reg.SetCustom([]Template{
+		{Name: "broken", Pattern: `(?P<amount>\d+`},
+		{Name: "yes_upi_debit", Senders: []string{"YESBNK"}, Pattern: `INR\s*(?P<amount>[\d,.]+)\s+paid`, Type: "debit"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected error naming the invalid template, got %v", err)
+	}
+	if res, err := reg.Parse("VM-YESBNK", "INR 99.00 paid to BLINKIT from YES Bank"); err != nil || res.Template != "yes_upi_debit" {
+		t.Errorf("valid template dropped with the invalid one: %+v, %v", res, err)
+	}
+}
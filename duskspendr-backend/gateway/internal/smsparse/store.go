@@ -0,0 +1,86 @@
+package smsparse
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// LoadTemplates reads the active custom templates from the sms_templates table
+func LoadTemplates(ctx context.Context, q Querier) ([]Template, error) {
+	rows, err := q.Query(ctx, `
+		SELECT name, bank, senders, pattern, type, payment_method, priority
+		  FROM sms_templates
+		 WHERE is_active
+		 ORDER BY priority DESC, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var t Template
+		var senders []byte
+		var txType, method *string
+		if err := rows.Scan(&t.Name, &t.Bank, &senders, &t.Pattern, &txType, &method, &t.Priority); err != nil {
+			return nil, err
+		}
+		if len(senders) > 0 {
+			if err := json.Unmarshal(senders, &t.Senders); err != nil {
+				return nil, err
+			}
+		}
+		if txType != nil {
+			t.Type = *txType
+		}
+		if method != nil {
+			t.PaymentMethod = *method
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Reload replaces the registry's custom templates with the rows in
+// sms_templates. Rows that do not compile are skipped and reported in the
+// error while the rest take effect.
+func (r *Registry) Reload(ctx context.Context, q Querier) error {
+	templates, err := LoadTemplates(ctx, q)
+	if err != nil {
+		return err
+	}
+	return r.SetCustom(templates)
+}
+
+// Watch reloads custom templates every interval until ctx is cancelled, so
+// new rows in sms_templates take effect without a redeploy.
+func (r *Registry) Watch(ctx context.Context, q Querier, interval time.Duration) {
+	if err := r.Reload(ctx, q); err != nil {
+		log.Printf("sms template reload: %v", err)
+	}
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(ctx, q); err != nil {
+				log.Printf("sms template reload: %v", err)
+			}
+		}
+	}
+}
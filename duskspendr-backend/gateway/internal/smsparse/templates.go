@@ -0,0 +1,197 @@
+package smsparse
+
+// Pattern fragments shared by the built-in templates. Message bodies are
+// whitespace-normalised and matched case-insensitively.
+const (
+	amt = `(?P<amount>[\d,]+(?:\.\d{1,2})?)`
+	cur = `(?:Rs\.?|INR|₹)\s*`
+	vpa = `(?P<vpa>[\w.\-]+@[\w.\-]+)`
+	ref = `(?P<ref>\d{8,20})`
+)
+
+var builtinTemplates = []Template{
+	// HDFC Bank
+	{
+		Name:          "hdfc_upi_debit",
+		Bank:          "HDFC",
+		Senders:       []string{"HDFCBK"},
+		Pattern:       cur + amt + `\s+debited\s+from\s+a/c\s+[*X]*(?P<account>\d{3,6})\s+on\s+\S+\s+to\s+VPA\s+` + vpa + `(?:\s*\(UPI\s+Ref\s+No\.?\s*` + ref + `\))?`,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "hdfc_upi_sent",
+		Bank:          "HDFC",
+		Senders:       []string{"HDFCBK"},
+		Pattern:       `Sent\s+` + cur + amt + `\s+From\s+HDFC\s+Bank\s+A/C\s+[*X]*(?P<account>\d{3,6})\s+To\s+(?P<merchant>.+?)\s+On\s+\S+\s+Ref\s+` + ref,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "hdfc_upi_credit",
+		Bank:          "HDFC",
+		Senders:       []string{"HDFCBK"},
+		Pattern:       cur + amt + `\s+credited\s+to\s+HDFC\s+Bank\s+A/c\s+[*X]*(?P<account>\d{3,6})\s+on\s+\S+\s+from\s+VPA\s+` + vpa + `\s*\(UPI\s+` + ref + `\)`,
+		Type:          "credit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "hdfc_card_spent",
+		Bank:          "HDFC",
+		Senders:       []string{"HDFCBK"},
+		Pattern:       `Spent\s+` + cur + amt + `\s+On\s+HDFC\s+Bank\s+Card\s+[*X]*(?P<account>\d{4})\s+At\s+(?P<merchant>.+?)\s+On\s+\d{4}-\d{2}-\d{2}`,
+		Type:          "debit",
+		PaymentMethod: "card",
+		Priority:      10,
+	},
+
+	// State Bank of India
+	{
+		Name:          "sbi_upi_debit",
+		Bank:          "SBI",
+		Senders:       []string{"SBI"},
+		Pattern:       `A/C\s+X*(?P<account>\d{3,6})\s+debited\s+by\s+(?:` + cur + `)?` + amt + `\s+on\s+date\s+\S+\s+trf\s+to\s+(?P<merchant>.+?)\s+Ref\s*no\s+` + ref,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:     "sbi_credit",
+		Bank:     "SBI",
+		Senders:  []string{"SBI"},
+		Pattern:  `A/c\s+X*(?P<account>\d{3,6})-?\s*credited\s+by\s+` + cur + amt + `\s+on\s+\S+\s+transfer\s+from\s+(?P<merchant>.+?)\s+Ref\s+No\s+` + ref,
+		Type:     "credit",
+		Priority: 10,
+	},
+	{
+		Name:          "sbi_card_spent",
+		Bank:          "SBI",
+		Senders:       []string{"SBI"},
+		Pattern:       cur + amt + `\s+spent\s+on\s+your\s+SBI\s+Credit\s+Card\s+ending\s+(?:with\s+)?(?P<account>\d{4})\s+at\s+(?P<merchant>.+?)\s+on\s+\d{2}/\d{2}/\d{2,4}`,
+		Type:          "debit",
+		PaymentMethod: "card",
+		Priority:      10,
+	},
+
+	// ICICI Bank
+	{
+		Name:          "icici_upi_debit",
+		Bank:          "ICICI",
+		Senders:       []string{"ICICI"},
+		Pattern:       `ICICI\s+Bank\s+Acc(?:oun)?t\s+X*(?P<account>\d{3,6})\s+debited\s+(?:for|with)\s+` + cur + amt + `\s+on\s+\S+?;?\s+(?P<merchant>.+?)\s+credited\.\s+UPI:\s*` + ref,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "icici_upi_credit",
+		Bank:          "ICICI",
+		Senders:       []string{"ICICI"},
+		Pattern:       `Acct\s+X*(?P<account>\d{3,6})\s+is\s+credited\s+with\s+` + cur + amt + `\s+on\s+\S+\s+from\s+(?P<merchant>.+?)\.\s+UPI:\s*` + ref,
+		Type:          "credit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "icici_card_spent",
+		Bank:          "ICICI",
+		Senders:       []string{"ICICI"},
+		Pattern:       cur + amt + `\s+spent\s+(?:using|on)\s+ICICI\s+Bank\s+Card\s+X*(?P<account>\d{4})\s+on\s+\S+\s+(?:on|at)\s+(?P<merchant>.+?)\.\s`,
+		Type:          "debit",
+		PaymentMethod: "card",
+		Priority:      10,
+	},
+
+	// Axis Bank
+	{
+		Name:          "axis_upi",
+		Bank:          "AXIS",
+		Senders:       []string{"AXIS"},
+		Pattern:       cur + amt + `\s+(?P<type>debited|credited)\s+A/c\s+no\.\s+X*(?P<account>\d{3,6})\s+.*?UPI/P2[AM]/` + ref + `/(?P<merchant>[^/]+?)(?:\s+Not\s+you|\s*-\s*Axis|$)`,
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "axis_card_spent",
+		Bank:          "AXIS",
+		Senders:       []string{"AXIS"},
+		Pattern:       `Spent\s+` + cur + amt + `\s+Axis\s+Bank\s+Card\s+no\.\s+X*(?P<account>\d{4})\s+\S+\s+\S+(?:\s+IST)?\s+(?P<merchant>.+?)\s+Avl\s+Limit`,
+		Type:          "debit",
+		PaymentMethod: "card",
+		Priority:      10,
+	},
+
+	// Kotak Mahindra Bank
+	{
+		Name:          "kotak_upi_sent",
+		Bank:          "KOTAK",
+		Senders:       []string{"KOTAK"},
+		Pattern:       `Sent\s+` + cur + amt + `\s+from\s+Kotak\s+Bank\s+AC\s+X*(?P<account>\d{3,6})\s+to\s+` + vpa + `\s+on\s+[\d-]+\.?\s*UPI\s+Ref:?\s*` + ref,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+	{
+		Name:          "kotak_upi_received",
+		Bank:          "KOTAK",
+		Senders:       []string{"KOTAK"},
+		Pattern:       `Received\s+` + cur + amt + `\s+in\s+your\s+Kotak\s+Bank\s+AC\s+X*(?P<account>\d{3,6})\s+from\s+` + vpa + `\s+on\s+[\d-]+\.?\s*UPI\s+Ref:?\s*` + ref,
+		Type:          "credit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+
+	// Paytm wallet and Paytm Payments Bank
+	{
+		Name:          "paytm_wallet_paid",
+		Bank:          "PAYTM",
+		Senders:       []string{"PAYTM", "PYTM"},
+		Pattern:       `Paid\s+` + cur + amt + `\s+to\s+(?P<merchant>.+?)\s+from\s+Paytm\s+(?:Balance|Wallet)(?:.*?Paytm\s+Wallet-?\s*` + cur + `(?P<balance>[\d,]+(?:\.\d{1,2})?))?`,
+		Type:          "debit",
+		PaymentMethod: "wallet",
+		Priority:      10,
+	},
+	{
+		Name:          "paytm_bank_upi_sent",
+		Bank:          "PAYTM",
+		Senders:       []string{"PAYTM", "PYTM"},
+		Pattern:       cur + amt + `\s+sent\s+to\s+` + vpa + `\s+from\s+PPBL\s+a/c\s+(?P<account>[\dX]+)`,
+		Type:          "debit",
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+
+	// PhonePe
+	{
+		Name:          "phonepe_wallet_debit",
+		Bank:          "PHONEPE",
+		Senders:       []string{"PHONPE", "PHNPE", "PHONEPE"},
+		Pattern:       cur + amt + `\s+debited\s+from\s+your\s+PhonePe\s+Wallet\s+for\s+(?P<merchant>.+?)\.\s+Txn\s+ID\s+(?P<ref>[A-Z0-9]{8,30})`,
+		Type:          "debit",
+		PaymentMethod: "wallet",
+		Priority:      10,
+	},
+	{
+		Name:          "phonepe_upi",
+		Bank:          "PHONEPE",
+		Senders:       []string{"PHONPE", "PHNPE", "PHONEPE"},
+		Pattern:       `(?P<type>Paid|Received)\s+` + cur + amt + `\s+(?:to|from)\s+(?P<merchant>.+?)\s+(?:using|on)\s+PhonePe`,
+		PaymentMethod: "upi",
+		Priority:      10,
+	},
+
+	// Fallbacks for unknown senders and formats
+	{
+		Name:     "generic_amount_first",
+		Pattern:  cur + amt + `\s+(?:has\s+been\s+|is\s+|was\s+)?(?P<type>debited|credited|spent|withdrawn|received|sent|paid)`,
+		Priority: -100,
+	},
+	{
+		Name:     "generic_verb_first",
+		Pattern:  `(?P<type>debited|credited)\s+(?:by|with|for)\s+` + cur + amt,
+		Priority: -100,
+	},
+}
@@ -0,0 +1,155 @@
+package statement
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+)
+
+// camtDocument covers the parts of ISO 20022 camt.053 (all versions) that
+// describe booked entries. Element names are matched without namespace.
+type camtDocument struct {
+	Statements []struct {
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtEntry struct {
+	NtryRef     string `xml:"NtryRef"`
+	AcctSvcrRef string `xml:"AcctSvcrRef"`
+	Amount      struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CreditDebit string `xml:"CdtDbtInd"`
+	Status      struct {
+		Value string `xml:",chardata"`
+		Code  string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate camtDate `xml:"BookgDt"`
+	ValueDate   camtDate `xml:"ValDt"`
+	Info        string   `xml:"AddtlNtryInf"`
+	Details     []struct {
+		Refs struct {
+			AcctSvcrRef string `xml:"AcctSvcrRef"`
+			EndToEndID  string `xml:"EndToEndId"`
+			TxID        string `xml:"TxId"`
+		} `xml:"Refs"`
+		Creditor   string   `xml:"RltdPties>Cdtr>Nm"`
+		CreditorV8 string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+		Debtor     string   `xml:"RltdPties>Dbtr>Nm"`
+		DebtorV8   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+		Remittance []string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+	}
+	if d.DateTime != "" {
+		v := strings.TrimSpace(d.DateTime)
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", v)
+	}
+	return time.Time{}, errors.New("statement: missing date")
+}
+
+// ParseCAMT053 reads booked entries from an ISO 20022 bank-to-customer
+// statement. Pending entries are skipped. The bank's AcctSvcrRef (or the
+// entry or transaction reference) becomes the record's ExternalID.
+func ParseCAMT053(data []byte) ([]Record, Report, error) {
+	report := Report{Format: FormatCAMT053}
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, report, err
+	}
+
+	records := []Record{}
+	index := 0
+	for _, stmt := range doc.Statements {
+		for _, e := range stmt.Entries {
+			index++
+			skip := func(reason string) {
+				report.RowsSkipped++
+				report.Skipped = append(report.Skipped, Skip{Line: index, Reason: reason})
+			}
+
+			status := strings.ToUpper(strings.TrimSpace(e.Status.Code + e.Status.Value))
+			if status != "" && status != "BOOK" {
+				skip("not booked")
+				continue
+			}
+			date, err := e.BookingDate.parse()
+			if err != nil {
+				if date, err = e.ValueDate.parse(); err != nil {
+					skip("invalid date")
+					continue
+				}
+			}
+			amount, ok := ParseAmount(e.Amount.Value)
+			if !ok || amount <= 0 {
+				skip("missing amount")
+				continue
+			}
+
+			rec := Record{
+				Line:        index,
+				Date:        date,
+				AmountPaisa: amount,
+				Currency:    strings.ToUpper(e.Amount.Currency),
+			}
+			switch strings.ToUpper(strings.TrimSpace(e.CreditDebit)) {
+			case "DBIT":
+				rec.Type = "debit"
+			case "CRDT":
+				rec.Type = "credit"
+			default:
+				skip("missing credit/debit indicator")
+				continue
+			}
+
+			refs := []string{e.AcctSvcrRef, e.NtryRef}
+			parts := []string{}
+			for _, d := range e.Details {
+				refs = append(refs, d.Refs.AcctSvcrRef, d.Refs.TxID, d.Refs.EndToEndID)
+				for _, v := range append([]string{d.Creditor, d.CreditorV8, d.Debtor, d.DebtorV8}, d.Remittance...) {
+					if v = strings.TrimSpace(v); v != "" && !containsFold(parts, v) {
+						parts = append(parts, v)
+					}
+				}
+			}
+			if v := strings.TrimSpace(e.Info); v != "" && !containsFold(parts, v) {
+				parts = append(parts, v)
+			}
+			rec.Description = strings.Join(strings.Fields(strings.Join(parts, " - ")), " ")
+
+			for _, ref := range refs {
+				ref = strings.TrimSpace(ref)
+				if ref == "" || strings.EqualFold(ref, "NOTPROVIDED") {
+					continue
+				}
+				if rec.ExternalID == "" {
+					rec.ExternalID = ref
+				}
+				if rec.Reference == "" {
+					rec.Reference = ref
+				}
+			}
+			records = append(records, rec)
+		}
+	}
+	if index == 0 {
+		return nil, report, errors.New("statement: no camt.053 entries found")
+	}
+	report.RowsParsed = len(records)
+	return records, report, nil
+}
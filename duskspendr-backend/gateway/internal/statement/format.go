@@ -0,0 +1,65 @@
+package statement
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Formats recognised by DetectFormat
+const (
+	FormatCSV     = "csv"
+	FormatXLSX    = "xlsx"
+	FormatOFX     = "ofx"
+	FormatQFX     = "qfx"
+	FormatQIF     = "qif"
+	FormatCAMT053 = "camt.053"
+)
+
+// DetectFormat identifies a statement file from its contents, using the
+// file name only to tell QFX from OFX.
+func DetectFormat(fileName string, data []byte) string {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(head)
+	upper := bytes.ToUpper(trimmed)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return FormatXLSX
+	case bytes.HasPrefix(upper, []byte("OFXHEADER")) || bytes.Contains(upper, []byte("<OFX>")):
+		if strings.EqualFold(filepath.Ext(fileName), ".qfx") {
+			return FormatQFX
+		}
+		return FormatOFX
+	case bytes.HasPrefix(upper, []byte("!TYPE:")) || bytes.HasPrefix(upper, []byte("!ACCOUNT")) || bytes.HasPrefix(upper, []byte("!OPTION")):
+		return FormatQIF
+	case bytes.Contains(head, []byte("BkToCstmrStmt")):
+		return FormatCAMT053
+	}
+	return FormatCSV
+}
+
+// Parse reads a statement in one of the self-describing formats (OFX, QFX,
+// QIF, CAMT.053). Tabular formats need a Mapping and go through ReadTable
+// and ParseTable instead; for them Parse returns ErrUnsupportedFormat.
+func Parse(format string, data []byte) ([]Record, Report, error) {
+	var records []Record
+	var report Report
+	var err error
+	switch format {
+	case FormatOFX, FormatQFX:
+		records, report, err = ParseOFX(data)
+	case FormatQIF:
+		records, report, err = ParseQIF(data)
+	case FormatCAMT053:
+		records, report, err = ParseCAMT053(data)
+	default:
+		return nil, Report{Format: format}, ErrUnsupportedFormat
+	}
+	report.Format = format
+	return records, report, err
+}
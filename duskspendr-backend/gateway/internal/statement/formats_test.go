@@ -0,0 +1,138 @@
+package statement
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"statement.ofx":  FormatOFX,
+		"statement2.ofx": FormatOFX,
+		"statement3.ofx": FormatOFX,
+		"statement.qif":  FormatQIF,
+		"camt053.xml":    FormatCAMT053,
+		"hdfc.csv":       FormatCSV,
+	}
+	for name, want := range tests {
+		if got := DetectFormat(name, readFixture(t, name)); got != want {
+			t.Errorf("DetectFormat(%s) = %q, want %q", name, got, want)
+		}
+	}
+	if got := DetectFormat("export.QFX", readFixture(t, "statement.ofx")); got != FormatQFX {
+		t.Errorf("qfx extension detected as %q", got)
+	}
+}
+
+func TestParseOFXSGML(t *testing.T) {
+	records, report, err := Parse(FormatOFX, readFixture(t, "statement.ofx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 45000, "debit", ""},
+		{"2024-04-02", 8500000, "credit", "SBIN424092345678"},
+	})
+	if records[0].ExternalID != "2024040100001" || records[0].Currency != "INR" {
+		t.Errorf("record = %+v", records[0])
+	}
+	if records[0].Description != "ZOMATO - UPI/412345678901/zomato@hdfcbank" {
+		t.Errorf("description = %q", records[0].Description)
+	}
+	if records[1].Description != "ACME TECHNOLOGIES & CO" {
+		t.Errorf("description = %q", records[1].Description)
+	}
+	if report.Format != FormatOFX || report.RowsParsed != 2 || report.RowsSkipped != 1 || report.Skipped[0].Reason != "invalid date" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestParseOFXXML(t *testing.T) {
+	records, _, err := Parse(FormatOFX, readFixture(t, "statement2.ofx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{{"2024-04-03", 1549, "debit", ""}})
+	if records[0].ExternalID != "CC-98765" || records[0].Currency != "USD" {
+		t.Errorf("record = %+v", records[0])
+	}
+}
+
+func TestParseOFXCurrencyAggregates(t *testing.T) {
+	records, _, err := Parse(FormatOFX, readFixture(t, "statement3.ofx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-05-03", 1549, "debit", ""},
+		{"2024-05-06", 181240, "debit", ""},
+	})
+	// CURRENCY restates the amount's currency; ORIGCURRENCY leaves it in CURDEF
+	if records[0].Currency != "USD" || records[1].Currency != "INR" {
+		t.Errorf("currencies = %q, %q", records[0].Currency, records[1].Currency)
+	}
+	if records[0].Description != "NETFLIX.COM" {
+		t.Errorf("description = %q", records[0].Description)
+	}
+}
+
+func TestParseQIF(t *testing.T) {
+	records, report, err := Parse(FormatQIF, readFixture(t, "statement.qif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 45000, "debit", "412345678901"},
+		{"2024-04-02", 8500000, "credit", ""},
+		{"2024-04-13", 129900, "debit", ""},
+	})
+	if records[0].Description != "Zomato - UPI dinner" {
+		t.Errorf("description = %q", records[0].Description)
+	}
+	if report.RowsSkipped != 1 || report.Skipped[0].Line != 22 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestParseCAMT053(t *testing.T) {
+	records, report, err := Parse(FormatCAMT053, readFixture(t, "camt053.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-03", 4250, "debit", "BANKREF-0001"},
+		{"2024-04-05", 150000, "credit", "TX-778899"},
+	})
+	if records[0].ExternalID != "BANKREF-0001" || records[0].Currency != "EUR" {
+		t.Errorf("record = %+v", records[0])
+	}
+	if records[0].Description != "Deutsche Bahn - Ticket Berlin Munich" {
+		t.Errorf("description = %q", records[0].Description)
+	}
+	if report.RowsSkipped != 1 || report.Skipped[0].Reason != "not booked" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestReimportIDsFromExternalID(t *testing.T) {
+	data := readFixture(t, "statement.ofx")
+	first, _, _ := Parse(FormatOFX, data)
+	second, _, _ := Parse(FormatOFX, data)
+	a := AssignIDs("u", "acct", first)
+	b := AssignIDs("u", "acct", second)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("id %d changed between imports", i)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package statement
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mapping describes how the columns of a tabular statement map onto a
+// Record. Columns are referenced by header text so a saved mapping keeps
+// working when a bank reorders columns. Amounts come from either separate
+// Debit and Credit columns, one Amount column with a DrCr indicator column,
+// or one signed Amount column.
+type Mapping struct {
+	Delimiter   string `json:"delimiter,omitempty"`
+	HeaderRow   int    `json:"header_row"`
+	Date        string `json:"date"`
+	DateFormat  string `json:"date_format"`
+	Description string `json:"description"`
+	Amount      string `json:"amount,omitempty"`
+	Debit       string `json:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty"`
+	DrCr        string `json:"dr_cr,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+	Balance     string `json:"balance,omitempty"`
+	// PositiveIsDebit is set for card statements where purchases are positive
+	PositiveIsDebit bool `json:"positive_is_debit,omitempty"`
+}
+
+// DateFormatExcel marks dates stored as spreadsheet serial day numbers
+const DateFormatExcel = "excel"
+
+// Header spellings used by common Indian bank exports (HDFC, SBI, ICICI,
+// Axis, Kotak and others), compared after normalizeHeader.
+var headerAliases = map[string][]string{
+	"date":        {"txn date", "transaction date", "tran date", "date", "posting date", "value date", "value dt"},
+	"description": {"narration", "description", "particulars", "transaction remarks", "remarks", "transaction details", "details", "transaction particulars"},
+	"debit":       {"withdrawal amt", "withdrawal amount", "withdrawals", "withdrawal", "debit amount", "debit", "dr amount", "debits", "dr"},
+	"credit":      {"deposit amt", "deposit amount", "deposits", "deposit", "credit amount", "credit", "cr amount", "credits", "cr"},
+	"amount":      {"amount", "transaction amount", "txn amount", "amt"},
+	"drcr":        {"dr cr", "cr dr", "debit credit", "dr cr indicator", "transaction type", "type"},
+	"reference":   {"chq ref no", "chq no ref no", "ref no cheque no", "cheque no", "chq no", "reference no", "ref no", "reference", "utr", "utr no", "chqno"},
+	"balance":     {"closing balance", "balance", "available balance", "running balance", "bal"},
+}
+
+var dateLayouts = []string{
+	"02/01/2006", "02/01/06", "02-01-2006", "02-01-06", "2006-01-02", "02.01.2006",
+	"02 Jan 2006", "02-Jan-2006", "02-Jan-06", "02 Jan 06", "2 Jan 2006", "Jan 02, 2006",
+	"02/01/2006 15:04:05", "02/01/2006 15:04", "02-01-2006 15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04:05",
+	"01/02/2006", "01/02/06", "2006/01/02",
+}
+
+func normalizeHeader(s string) string {
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return ' '
+	}, s)
+	fields := strings.Fields(s)
+	out := fields[:0]
+	for _, f := range fields {
+		if f == "inr" || f == "rs" || f == "in" {
+			continue
+		}
+		out = append(out, f)
+	}
+	return strings.Join(out, " ")
+}
+
+// matchColumns assigns header columns to roles. Exact matches are taken
+// before prefix matches so "debit" does not steal a "debit credit" column.
+func matchColumns(header []string) map[string]string {
+	norm := make([]string, len(header))
+	for i, h := range header {
+		norm[i] = normalizeHeader(h)
+	}
+	used := map[int]bool{}
+	roles := map[string]string{}
+	order := []string{"date", "debit", "credit", "drcr", "amount", "description", "reference", "balance"}
+
+	for _, exact := range []bool{true, false} {
+		for _, role := range order {
+			if _, done := roles[role]; done {
+				continue
+			}
+		aliases:
+			for _, alias := range headerAliases[role] {
+				for i, h := range norm {
+					if used[i] || h == "" {
+						continue
+					}
+					if h == alias || (!exact && strings.HasPrefix(h, alias+" ")) {
+						roles[role] = header[i]
+						used[i] = true
+						break aliases
+					}
+				}
+			}
+		}
+	}
+	return roles
+}
+
+// DetectMapping finds the header row and column roles of a table and
+// infers the date format from the data below it.
+func DetectMapping(rows [][]string) (Mapping, error) {
+	limit := len(rows)
+	if limit > 40 {
+		limit = 40
+	}
+	for i := 0; i < limit; i++ {
+		roles := matchColumns(rows[i])
+		if roles["date"] == "" {
+			continue
+		}
+		if roles["amount"] == "" && (roles["debit"] == "" || roles["credit"] == "") {
+			continue
+		}
+
+		m := Mapping{
+			HeaderRow:   i,
+			Date:        roles["date"],
+			Description: roles["description"],
+			Reference:   roles["reference"],
+			Balance:     roles["balance"],
+		}
+		if roles["debit"] != "" && roles["credit"] != "" {
+			m.Debit, m.Credit = roles["debit"], roles["credit"]
+		} else {
+			m.Amount = roles["amount"]
+			m.DrCr = roles["drcr"]
+		}
+
+		col := columnOf(rows[i], m.Date)
+		samples := []string{}
+		for _, row := range rows[i+1:] {
+			if col < len(row) && row[col] != "" {
+				samples = append(samples, row[col])
+			}
+			if len(samples) == 25 {
+				break
+			}
+		}
+		m.DateFormat = DetectDateFormat(samples)
+		if m.DateFormat == "" {
+			return m, errors.New("statement: could not detect date format")
+		}
+		return m, nil
+	}
+	return Mapping{}, errors.New("statement: no header row with date and amount columns")
+}
+
+// DetectDateFormat returns the first layout that parses at least
+// four-fifths of the samples. Footer lines such as "Opening Balance" make
+// a strict all-or-nothing test too brittle. Day-first layouts are tried
+// before month-first ones.
+func DetectDateFormat(samples []string) string {
+	if len(samples) == 0 {
+		return ""
+	}
+	need := (len(samples)*4 + 4) / 5
+	for _, layout := range dateLayouts {
+		ok := 0
+		for _, s := range samples {
+			if _, err := time.Parse(layout, s); err == nil {
+				ok++
+			}
+		}
+		if ok >= need {
+			return layout
+		}
+	}
+	ok := 0
+	for _, s := range samples {
+		if _, err := parseExcelDate(s); err == nil {
+			ok++
+		}
+	}
+	if ok >= need {
+		return DateFormatExcel
+	}
+	return ""
+}
+
+// ParseDate parses value with a Mapping date format
+func ParseDate(layout, value string) (time.Time, error) {
+	if layout == DateFormatExcel {
+		return parseExcelDate(value)
+	}
+	return time.Parse(layout, strings.TrimSpace(value))
+}
+
+func parseExcelDate(s string) (time.Time, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 20000 || f > 80000 {
+		return time.Time{}, errors.New("not a spreadsheet date")
+	}
+	days := math.Floor(f)
+	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)), nil
+}
+
+func columnOf(header []string, name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, h := range header {
+		if h == name {
+			return i
+		}
+	}
+	target := normalizeHeader(name)
+	for i, h := range header {
+		if normalizeHeader(h) == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseTable turns the rows below the mapping's header into Records.
+// Rows without a parseable date or amount are reported as skipped; the
+// line numbers are 1-based file rows.
+func ParseTable(rows [][]string, m Mapping) ([]Record, Report, error) {
+	report := Report{Format: "csv"}
+	if m.HeaderRow < 0 || m.HeaderRow >= len(rows) {
+		return nil, report, errors.New("statement: header row out of range")
+	}
+	header := rows[m.HeaderRow]
+	cols := map[string]int{
+		"date":        columnOf(header, m.Date),
+		"description": columnOf(header, m.Description),
+		"amount":      columnOf(header, m.Amount),
+		"debit":       columnOf(header, m.Debit),
+		"credit":      columnOf(header, m.Credit),
+		"drcr":        columnOf(header, m.DrCr),
+		"reference":   columnOf(header, m.Reference),
+		"balance":     columnOf(header, m.Balance),
+	}
+	if cols["date"] < 0 {
+		return nil, report, errors.New("statement: date column not found")
+	}
+	if cols["amount"] < 0 && (cols["debit"] < 0 || cols["credit"] < 0) {
+		return nil, report, errors.New("statement: amount columns not found")
+	}
+
+	cell := func(row []string, role string) string {
+		if i := cols[role]; i >= 0 && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	skip := func(line int, reason string) {
+		report.RowsSkipped++
+		report.Skipped = append(report.Skipped, Skip{Line: line, Reason: reason})
+	}
+
+	records := []Record{}
+	for i := m.HeaderRow + 1; i < len(rows); i++ {
+		row := rows[i]
+		line := i + 1
+		if isBlankRow(row) {
+			continue
+		}
+		rawDate := cell(row, "date")
+		date, err := ParseDate(m.DateFormat, rawDate)
+		if err != nil {
+			skip(line, "invalid date")
+			continue
+		}
+
+		amount, txType, ok := rowAmount(
+			cell(row, "debit"), cell(row, "credit"), cell(row, "amount"), cell(row, "drcr"),
+			cols["amount"] >= 0 && (cols["debit"] < 0 || cols["credit"] < 0), m.PositiveIsDebit,
+		)
+		if !ok {
+			skip(line, "missing amount")
+			continue
+		}
+
+		rec := Record{
+			Line:        line,
+			Date:        date,
+			Description: strings.Join(strings.Fields(cell(row, "description")), " "),
+			AmountPaisa: amount,
+			Type:        txType,
+			Reference:   cleanReference(cell(row, "reference")),
+		}
+		if v, ok := ParseAmount(stripDrCr(cell(row, "balance"))); ok {
+			rec.BalancePaisa = &v
+		}
+		records = append(records, rec)
+	}
+	report.RowsParsed = len(records)
+	return records, report, nil
+}
+
+// rowAmount applies the statement's debit/credit convention and returns a
+// positive amount with its type.
+func rowAmount(debit, credit, amount, drcr string, singleColumn, positiveIsDebit bool) (int64, string, bool) {
+	if !singleColumn {
+		if v, ok := ParseAmount(stripDrCr(debit)); ok && v != 0 {
+			return abs(v), "debit", true
+		}
+		if v, ok := ParseAmount(stripDrCr(credit)); ok && v != 0 {
+			return abs(v), "credit", true
+		}
+		return 0, "", false
+	}
+
+	value, marker := splitDrCr(amount)
+	v, ok := ParseAmount(value)
+	if !ok || v == 0 {
+		return 0, "", false
+	}
+	if t := indicatorType(drcr); t != "" {
+		return abs(v), t, true
+	}
+	if marker != "" {
+		return abs(v), marker, true
+	}
+	debitSign := v < 0
+	if positiveIsDebit {
+		debitSign = v > 0
+	}
+	if debitSign {
+		return abs(v), "debit", true
+	}
+	return abs(v), "credit", true
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func isBlankRow(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitDrCr removes a trailing "Dr"/"Cr" marker and reports which it was
+func splitDrCr(s string) (string, string) {
+	t := strings.TrimSpace(s)
+	lower := strings.ToLower(t)
+	for _, suffix := range []string{"dr.", "dr", "cr.", "cr"} {
+		if strings.HasSuffix(lower, suffix) {
+			kind := "debit"
+			if strings.HasPrefix(suffix, "cr") {
+				kind = "credit"
+			}
+			return strings.TrimSpace(t[:len(t)-len(suffix)]), kind
+		}
+	}
+	return t, ""
+}
+
+func stripDrCr(s string) string {
+	v, _ := splitDrCr(s)
+	return v
+}
+
+func indicatorType(s string) string {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(s), ".")) {
+	case "dr", "d", "debit", "withdrawal", "w":
+		return "debit"
+	case "cr", "c", "credit", "deposit":
+		return "credit"
+	}
+	return ""
+}
+
+// cleanReference drops placeholder references such as "0" or "-"
+func cleanReference(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.Trim(s, "0-. ") == "" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package statement
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OFX 1.x is SGML with unterminated leaf elements, OFX 2.x is XML. Both
+// are read with the same tag scanner: aggregates open and close, leaves
+// carry their value up to the next tag.
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads bank and credit card transactions from an OFX or QFX file.
+// FITID becomes the record's ExternalID.
+func ParseOFX(data []byte) ([]Record, Report, error) {
+	report := Report{Format: FormatOFX}
+	matches := ofxTag.FindAllStringSubmatch(string(data), -1)
+	if len(matches) == 0 {
+		return nil, report, errors.New("statement: no OFX elements found")
+	}
+
+	records := []Record{}
+	currency := ""
+	var txn map[string]string
+	// aggregate is the open CURRENCY or ORIGCURRENCY inside a STMTTRN; its
+	// leaves are stored as e.g. "CURRENCY.CURSYM"
+	aggregate := ""
+	index := 0
+	for _, m := range matches {
+		closing, name, value := m[1] == "/", strings.ToUpper(m[2]), strings.TrimSpace(unescapeOFX(m[3]))
+		switch {
+		case name == "STMTTRN" && !closing:
+			txn = map[string]string{}
+		case name == "STMTTRN" && closing:
+			if txn != nil {
+				index++
+				if rec, reason := ofxRecord(txn, currency, index); reason != "" {
+					report.RowsSkipped++
+					report.Skipped = append(report.Skipped, Skip{Line: index, Reason: reason})
+				} else {
+					records = append(records, rec)
+				}
+			}
+			txn = nil
+			aggregate = ""
+		case txn != nil && (name == "CURRENCY" || name == "ORIGCURRENCY") && value == "":
+			if closing {
+				aggregate = ""
+			} else {
+				aggregate = name
+			}
+		case closing || value == "":
+		case name == "CURDEF":
+			currency = strings.ToUpper(value)
+		case txn != nil:
+			if aggregate != "" {
+				name = aggregate + "." + name
+			}
+			txn[name] = value
+		}
+	}
+	report.RowsParsed = len(records)
+	return records, report, nil
+}
+
+// ofxRecord converts one STMTTRN. Line is the transaction's position in the
+// file since OFX has no meaningful line numbers.
+func ofxRecord(txn map[string]string, currency string, index int) (Record, string) {
+	date, err := parseOFXDate(txn["DTPOSTED"])
+	if err != nil {
+		return Record{}, "invalid date"
+	}
+	amount, ok := ParseAmount(txn["TRNAMT"])
+	if !ok || amount == 0 {
+		return Record{}, "missing amount"
+	}
+	rec := Record{
+		Line:        index,
+		Date:        date,
+		AmountPaisa: abs(amount),
+		Type:        "credit",
+		ExternalID:  txn["FITID"],
+		Currency:    currency,
+	}
+	if amount < 0 {
+		rec.Type = "debit"
+	}
+	// CURRENCY means the amounts are in CURSYM. ORIGCURRENCY only names the
+	// currency the bank converted from; the amounts are already in CURDEF.
+	if c := txn["CURRENCY.CURSYM"]; c != "" {
+		rec.Currency = strings.ToUpper(c)
+	}
+
+	parts := []string{}
+	for _, key := range []string{"NAME", "PAYEE", "MEMO"} {
+		if v := txn[key]; v != "" && !containsFold(parts, v) {
+			parts = append(parts, v)
+		}
+	}
+	rec.Description = strings.Join(parts, " - ")
+	for _, key := range []string{"REFNUM", "CHECKNUM", "SRVRTID"} {
+		if v := cleanReference(txn[key]); v != "" {
+			rec.Reference = v
+			break
+		}
+	}
+	return rec, ""
+}
+
+// parseOFXDate reads YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]]. The calendar
+// date is what matters for a statement line, so the time zone is dropped.
+func parseOFXDate(s string) (time.Time, error) {
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	switch len(s) {
+	case 8:
+		return time.Parse("20060102", s)
+	case 12:
+		return time.Parse("200601021504", s)
+	case 14:
+		return time.Parse("20060102150405", s)
+	}
+	return time.Time{}, errors.New("statement: invalid OFX date")
+}
+
+func unescapeOFX(s string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(s)
+}
+
+func containsFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
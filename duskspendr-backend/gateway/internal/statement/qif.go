@@ -0,0 +1,141 @@
+package statement
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// ParseQIF reads a Quicken Interchange Format file. QIF has no transaction
+// ids, so records are identified by their contents (see AssignIDs). The
+// date order is detected across the whole file, preferring day-first.
+func ParseQIF(data []byte) ([]Record, Report, error) {
+	report := Report{Format: FormatQIF}
+
+	type entry struct {
+		line   int
+		fields map[byte]string
+	}
+	entries := []entry{}
+	current := entry{fields: map[byte]string{}}
+	inTransactions := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if text[0] == '!' {
+			header := strings.ToLower(text)
+			inTransactions = strings.HasPrefix(header, "!type:bank") || strings.HasPrefix(header, "!type:ccard") ||
+				strings.HasPrefix(header, "!type:cash") || strings.HasPrefix(header, "!type:oth")
+			continue
+		}
+		if !inTransactions {
+			continue
+		}
+		if text[0] == '^' {
+			if len(current.fields) > 0 {
+				entries = append(entries, current)
+			}
+			current = entry{fields: map[byte]string{}}
+			continue
+		}
+		if len(current.fields) == 0 {
+			current.line = line
+		}
+		// Split lines (S, E, $) repeat; the first value of each code is kept
+		if _, seen := current.fields[text[0]]; !seen {
+			current.fields[text[0]] = strings.TrimSpace(text[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, report, err
+	}
+	if len(entries) == 0 {
+		return nil, report, errors.New("statement: no QIF transactions found")
+	}
+
+	samples := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if d := normalizeQIFDate(e.fields['D']); d != "" {
+			samples = append(samples, d)
+		}
+	}
+	layout := DetectDateFormat(samples)
+
+	records := []Record{}
+	for _, e := range entries {
+		skip := func(reason string) {
+			report.RowsSkipped++
+			report.Skipped = append(report.Skipped, Skip{Line: e.line, Reason: reason})
+		}
+		if layout == "" {
+			skip("invalid date")
+			continue
+		}
+		date, err := ParseDate(layout, normalizeQIFDate(e.fields['D']))
+		if err != nil {
+			skip("invalid date")
+			continue
+		}
+		raw := e.fields['T']
+		if raw == "" {
+			raw = e.fields['U']
+		}
+		amount, ok := ParseAmount(raw)
+		if !ok || amount == 0 {
+			skip("missing amount")
+			continue
+		}
+
+		rec := Record{
+			Line:        e.line,
+			Date:        date,
+			AmountPaisa: abs(amount),
+			Type:        "credit",
+			Reference:   cleanReference(e.fields['N']),
+		}
+		if amount < 0 {
+			rec.Type = "debit"
+		}
+		parts := []string{}
+		for _, code := range []byte{'P', 'M'} {
+			if v := e.fields[code]; v != "" && !containsFold(parts, v) {
+				parts = append(parts, v)
+			}
+		}
+		rec.Description = strings.Join(parts, " - ")
+		records = append(records, rec)
+	}
+	report.RowsParsed = len(records)
+	return records, report, nil
+}
+
+// normalizeQIFDate rewrites Quicken's "4/ 1'24" style dates as zero-padded
+// "04/01/2024" so the standard layouts apply and files mixing two and four
+// digit years detect as one format.
+func normalizeQIFDate(s string) string {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "'", "/"), " ", "")
+	sep := "/"
+	if !strings.Contains(s, "/") && strings.Count(s, "-") == 2 {
+		sep = "-"
+	}
+	parts := strings.Split(s, sep)
+	if len(parts) != 3 {
+		return s
+	}
+	for i, p := range parts {
+		if len(p) == 1 {
+			parts[i] = "0" + p
+		}
+	}
+	if len(parts[0]) <= 2 && len(parts[2]) == 2 {
+		parts[2] = "20" + parts[2]
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,232 @@
+package statement
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type wantRecord struct {
+	date   string
+	amount int64
+	typ    string
+	ref    string
+}
+
+func loadTable(t *testing.T, name string) [][]string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadTable(data, 0)
+	if err != nil {
+		t.Fatalf("ReadTable(%s): %v", name, err)
+	}
+	return rows
+}
+
+func checkRecords(t *testing.T, got []Record, want []wantRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Date.Format("2006-01-02") != w.date || g.AmountPaisa != w.amount || g.Type != w.typ || g.Reference != w.ref {
+			t.Errorf("record %d = %s %d %s %q, want %s %d %s %q",
+				i, g.Date.Format("2006-01-02"), g.AmountPaisa, g.Type, g.Reference, w.date, w.amount, w.typ, w.ref)
+		}
+	}
+}
+
+func TestHDFCDebitCreditColumns(t *testing.T) {
+	rows := loadTable(t, "hdfc.csv")
+	m, err := DetectMapping(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.HeaderRow != 4 || m.Debit != "Withdrawal Amt." || m.Credit != "Deposit Amt." || m.DateFormat != "02/01/06" {
+		t.Fatalf("mapping = %+v", m)
+	}
+	records, report, err := ParseTable(rows, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 45000, "debit", "0000412345678901"},
+		{"2024-04-02", 8500000, "credit", "SBIN424092345678"},
+		{"2024-04-03", 2000, "debit", "0000412398765432"},
+		{"2024-04-03", 2000, "debit", "0000412398765432"},
+	})
+	if report.RowsSkipped != 1 || report.Skipped[0].Line != 11 {
+		t.Errorf("report = %+v", report)
+	}
+	if records[1].BalancePaisa == nil || *records[1].BalancePaisa != 10955000 {
+		t.Errorf("balance = %v", records[1].BalancePaisa)
+	}
+}
+
+func TestSBITabSeparated(t *testing.T) {
+	rows := loadTable(t, "sbi.tsv")
+	m, err := DetectMapping(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Date != "Txn Date" || m.DateFormat != "2 Jan 2006" {
+		t.Fatalf("mapping = %+v", m)
+	}
+	records, _, err := ParseTable(rows, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 32550, "debit", "TRANSFER TO 4897690162095"},
+		{"2024-04-05", 500000, "credit", "TRANSFER FROM 3199411111111"},
+		{"2024-04-10", 200000, "debit", ""},
+	})
+}
+
+func TestKotakAmountWithIndicator(t *testing.T) {
+	rows := loadTable(t, "kotak.csv")
+	m, err := DetectMapping(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Amount != "Amount" || m.DrCr != "Dr / Cr" {
+		t.Fatalf("mapping = %+v", m)
+	}
+	records, report, err := ParseTable(rows, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 129900, "debit", "UPI-412300001111"},
+		{"2024-04-04", 50000, "credit", "IMPS-412300002222"},
+	})
+	if report.RowsSkipped != 1 || report.Skipped[0].Reason != "missing amount" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestSignedAmountWithProfile(t *testing.T) {
+	rows := loadTable(t, "card.csv")
+	m, err := DetectMapping(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.PositiveIsDebit = true
+	records, _, err := ParseTable(rows, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-03", 64900, "debit", ""},
+		{"2024-04-09", 500000, "credit", ""},
+		{"2024-04-12", 31245, "debit", ""},
+	})
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name, body string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	write("xl/sharedStrings.xml", `<sst><si><t>Date</t></si><si><t>Particulars</t></si><si><t>Debit</t></si><si><t>Credit</t></si><si><r><t>SWIGGY</t></r><r><t> ORDER</t></r></si></sst>`)
+	write("xl/worksheets/sheet1.xml", `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+<row r="2"><c r="A2"><v>45383</v></c><c r="B2" t="s"><v>4</v></c><c r="C2"><v>250.5</v></c></row>
+<row r="3"><c r="A3"><v>45384</v></c><c r="B3" t="inlineStr"><is><t>REFUND</t></is></c><c r="D3"><v>99</v></c></row>
+</sheetData></worksheet>`)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadTable(buf.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := DetectMapping(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.DateFormat != DateFormatExcel {
+		t.Fatalf("date format = %q", m.DateFormat)
+	}
+	records, _, err := ParseTable(rows, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, records, []wantRecord{
+		{"2024-04-01", 25050, "debit", ""},
+		{"2024-04-02", 9900, "credit", ""},
+	})
+	if records[0].Description != "SWIGGY ORDER" {
+		t.Errorf("description = %q", records[0].Description)
+	}
+}
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := map[string]rune{
+		"a,b,c\n1,2,3\n4,5,6\n":              ',',
+		"a;b;c\n1;2,5;3\n4;5;6\n":            ';',
+		"title\na\tb\tc\n1\t2\t3\n4\t5\t6\n": '\t',
+		"a|b\n1|2\n":                         '|',
+	}
+	for in, want := range tests {
+		if got := DetectDelimiter([]byte(in)); got != want {
+			t.Errorf("DetectDelimiter(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAssignIDsStable(t *testing.T) {
+	rows := loadTable(t, "hdfc.csv")
+	m, _ := DetectMapping(rows)
+	records, _, _ := ParseTable(rows, m)
+
+	first := AssignIDs("user-1", "acct-1", records)
+	second := AssignIDs("user-1", "acct-1", records)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("id %d not stable", i)
+		}
+	}
+	if first[2] == first[3] {
+		t.Fatal("identical rows must get distinct ids")
+	}
+	if other := AssignIDs("user-2", "acct-1", records); other[0] == first[0] {
+		t.Fatal("ids must be scoped to the user")
+	}
+
+	withExternal := []Record{{ExternalID: "FIT123", AmountPaisa: 100}}
+	changed := []Record{{ExternalID: "FIT123", AmountPaisa: 200}}
+	if AssignIDs("u", "", withExternal)[0] != AssignIDs("u", "", changed)[0] {
+		t.Fatal("external ids must take precedence over contents")
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := map[string]int64{
+		"1,23,456.78": 12345678,
+		"(120.00)":    -12000,
+		"₹ 45":        4500,
+		"-12.5":       -1250,
+		"INR 7.05":    705,
+	}
+	for in, want := range tests {
+		if got, ok := ParseAmount(in); !ok || got != want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", in, got, ok, want)
+		}
+	}
+	if _, ok := ParseAmount(" "); ok {
+		t.Error("blank amount should not parse")
+	}
+}
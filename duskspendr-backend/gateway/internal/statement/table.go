@@ -0,0 +1,66 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+)
+
+// ReadTable reads a CSV, TSV or XLSX file into rows of cells. delimiter
+// forces the CSV separator; 0 detects it.
+func ReadTable(data []byte, delimiter rune) ([][]string, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readXLSX(data)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if delimiter == 0 {
+		delimiter = DetectDelimiter(data)
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	// Leading-space trimming would also eat empty tab-separated cells
+	r.TrimLeadingSpace = delimiter != '\t'
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	return rows, nil
+}
+
+// DetectDelimiter picks the separator that splits the most lines into the
+// same, largest number of fields. Bank exports often start with a few
+// free-text lines, so consistency is measured over the most common width.
+func DetectDelimiter(data []byte) rune {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 50 {
+		lines = lines[:50]
+	}
+
+	best, bestScore := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		widths := map[int]int{}
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := strings.Count(line, string(d))
+			if n > 0 {
+				widths[n]++
+			}
+		}
+		for width, count := range widths {
+			if score := count * width; count >= 2 && score > bestScore {
+				best, bestScore = d, score
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,219 @@
+package totp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotEnrolled is returned when the user has no confirmed TOTP secret
+	ErrNotEnrolled = errors.New("totp not enrolled")
+	// ErrAlreadyEnrolled is returned when enrolling over a confirmed secret
+	ErrAlreadyEnrolled = errors.New("totp already enrolled")
+	// ErrInvalidCode is returned for a wrong, replayed or used code
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrLocked is returned while verification is locked after failures
+	ErrLocked = errors.New("too many failed attempts")
+)
+
+// Querier is satisfied by *pgxpool.Pool and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Cipher encrypts TOTP secrets at rest with AES-256-GCM. Secrets must be
+// readable to verify codes, so unlike recovery codes they can not be
+// hashed; the user id is bound as additional data.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64 encoded 32 byte key
+func NewCipher(encoded string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("totp encryption key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("totp encryption key must be 32 bytes")
+	}
+	return newCipher(key)
+}
+
+// DerivedCipher derives the key from a secret such as the auth pepper; for
+// local runs without TOTP_ENCRYPTION_KEY
+func DerivedCipher(secret string) (*Cipher, error) {
+	key := sha256.Sum256([]byte("totp:" + secret))
+	return newCipher(key[:])
+}
+
+func newCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts the secret of userID
+func (c *Cipher) Seal(userID, secret string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, []byte(secret), []byte(userID)), nil
+}
+
+// Open decrypts a secret sealed for userID
+func (c *Cipher) Open(userID string, sealed []byte) (string, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("sealed secret too short")
+	}
+	secret, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// Enrollment is the stored TOTP state of a user
+type Enrollment struct {
+	Sealed      []byte
+	ConfirmedAt *time.Time
+	LastStep    int64
+	LockedUntil *time.Time
+}
+
+// Confirmed reports whether the user finished enrollment
+func (e *Enrollment) Confirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// Load returns the user's enrollment, confirmed or pending
+func Load(ctx context.Context, q Querier, userID string) (*Enrollment, error) {
+	var e Enrollment
+	err := q.QueryRow(ctx, `
+		SELECT secret, confirmed_at, last_used_step, locked_until
+		  FROM user_totp
+		 WHERE user_id = $1
+	`, userID).Scan(&e.Sealed, &e.ConfirmedAt, &e.LastStep, &e.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotEnrolled
+	}
+	return &e, err
+}
+
+// Begin stores a new pending secret, replacing an earlier unconfirmed one
+func Begin(ctx context.Context, q Querier, userID string, sealed []byte) error {
+	tag, err := q.Exec(ctx, `
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (user_id) DO UPDATE
+		   SET secret = EXCLUDED.secret, created_at = now(), last_used_step = 0,
+		       failed_attempts = 0, locked_until = NULL
+		 WHERE user_totp.confirmed_at IS NULL
+	`, userID, sealed)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlreadyEnrolled
+	}
+	return nil
+}
+
+// Use records a verified step. It fails with ErrInvalidCode if the step was
+// already used, so two requests racing with one code can not both pass.
+// confirm also completes a pending enrollment.
+func Use(ctx context.Context, q Querier, userID string, step int64, confirm bool) error {
+	tag, err := q.Exec(ctx, `
+		UPDATE user_totp
+		   SET last_used_step = $2, failed_attempts = 0, locked_until = NULL,
+		       confirmed_at = CASE WHEN $3 THEN coalesce(confirmed_at, now()) ELSE confirmed_at END
+		 WHERE user_id = $1 AND last_used_step < $2
+	`, userID, step, confirm)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// Fail counts a failed verification and locks verification for lockFor
+// once maxAttempts failures in a row are reached
+func Fail(ctx context.Context, q Querier, userID string, maxAttempts int, lockFor time.Duration) error {
+	_, err := q.Exec(ctx, `
+		UPDATE user_totp
+		   SET failed_attempts = failed_attempts + 1,
+		       locked_until = CASE WHEN failed_attempts + 1 >= $2
+		                           THEN now() + make_interval(secs => $3) ELSE locked_until END
+		 WHERE user_id = $1
+	`, userID, maxAttempts, lockFor.Seconds())
+	return err
+}
+
+// Disable removes the user's secret and recovery codes
+func Disable(ctx context.Context, q Querier, userID string) error {
+	if _, err := q.Exec(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	return err
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores
+// the hashes of new ones
+func ReplaceRecoveryCodes(ctx context.Context, q Querier, userID string, hashes []string) error {
+	if _, err := q.Exec(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := q.Exec(ctx, `
+		INSERT INTO totp_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])
+	`, userID, hashes)
+	return err
+}
+
+// UseRecoveryCode spends the unused recovery code with the given hash
+func UseRecoveryCode(ctx context.Context, q Querier, userID, hash string) error {
+	tag, err := q.Exec(ctx, `
+		UPDATE totp_recovery_codes
+		   SET used_at = now()
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes
+func RemainingRecoveryCodes(ctx context.Context, q Querier, userID string) (int, error) {
+	var n int
+	err := q.QueryRow(ctx, `
+		SELECT count(*) FROM totp_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+	`, userID).Scan(&n)
+	return n, err
+}
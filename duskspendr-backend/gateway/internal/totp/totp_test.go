@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 with the ASCII secret "12345678901234567890"
+func TestRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, want := range vectors {
+		if got := hotp(key, uint64(Step(time.Unix(unix, 0))), 8); got != want {
+			t.Errorf("T=%d: got %s, want %s", unix, got, want)
+		}
+	}
+	secret := b32.EncodeToString(key)
+	if got, _ := Code(secret, time.Unix(1234567890, 0)); got != "005924" {
+		t.Errorf("6 digit code = %s", got)
+	}
+}
+
+func TestVerifySkewAndReplay(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	prev, _ := Code(secret, now.Add(-Period))
+	step, ok := Verify(secret, prev, now, 0)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("code of previous period rejected: %d %v", step, ok)
+	}
+	if _, ok := Verify(secret, prev, now, step); ok {
+		t.Fatal("used code accepted again")
+	}
+	old, _ := Code(secret, now.Add(-2*Period))
+	if _, ok := Verify(secret, old, now, 0); ok {
+		t.Fatal("code outside the skew window accepted")
+	}
+	current, _ := Code(secret, now)
+	if _, ok := Verify(secret, current[:3]+" "+current[3:], now, 0); !ok {
+		t.Fatal("code with a space rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("DuskSpendr", "+919999999999", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/DuskSpendr:+919999999999" {
+		t.Fatalf("uri = %s", u)
+	}
+	if q := u.Query(); q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "DuskSpendr" || q.Get("digits") != "6" {
+		t.Fatalf("query = %v", q)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || seen[c] {
+			t.Fatalf("bad or repeated code %q", c)
+		}
+		seen[c] = true
+	}
+	typed := strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))
+	if HashRecoveryCode("pepper", "u1", typed) != HashRecoveryCode("pepper", "u1", codes[0]) {
+		t.Fatal("hash depends on case or separator")
+	}
+	if HashRecoveryCode("pepper", "u2", codes[0]) == HashRecoveryCode("pepper", "u1", codes[0]) {
+		t.Fatal("hash not bound to the user")
+	}
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal("user-1", "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Open("user-1", sealed); err != nil || got != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("open = %q %v", got, err)
+	}
+	if _, err := c.Open("user-2", sealed); err == nil {
+		t.Fatal("secret opened for another user")
+	}
+	if _, err := NewCipher("c2hvcnQ="); err == nil {
+		t.Fatal("short key accepted")
+	}
+}
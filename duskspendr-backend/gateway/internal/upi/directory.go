@@ -0,0 +1,106 @@
+package upi
+
+import "strings"
+
+// Merchant is a canonical merchant behind one or more VPAs. Category uses the
+// transaction category names.
+type Merchant struct {
+	Name     string
+	Category string
+}
+
+// knownHandles maps VPA handles to merchants regardless of PSP, since large
+// merchants collect through several banks.
+var knownHandles = map[string]Merchant{
+	"zomato":          {"Zomato", "food"},
+	"zomatoonline":    {"Zomato", "food"},
+	"zomato-order":    {"Zomato", "food"},
+	"swiggy":          {"Swiggy", "food"},
+	"swiggyupi":       {"Swiggy", "food"},
+	"swiggyinstamart": {"Swiggy Instamart", "shopping"},
+	"dominos":         {"Domino's", "food"},
+	"mcdonalds":       {"McDonald's", "food"},
+	"uber":            {"Uber", "transportation"},
+	"uberindia":       {"Uber", "transportation"},
+	"olacabs":         {"Ola", "transportation"},
+	"rapido":          {"Rapido", "transportation"},
+	"irctc":           {"IRCTC", "transportation"},
+	"irctconline":     {"IRCTC", "transportation"},
+	"redbus":          {"redBus", "transportation"},
+	"amazon":          {"Amazon", "shopping"},
+	"amazonpay":       {"Amazon", "shopping"},
+	"amazonupi":       {"Amazon", "shopping"},
+	"flipkart":        {"Flipkart", "shopping"},
+	"myntra":          {"Myntra", "shopping"},
+	"bigbasket":       {"BigBasket", "shopping"},
+	"blinkit":         {"Blinkit", "shopping"},
+	"zepto":           {"Zepto", "shopping"},
+	"bookmyshow":      {"BookMyShow", "entertainment"},
+	"netflix":         {"Netflix", "subscriptions"},
+	"spotify":         {"Spotify", "subscriptions"},
+	"hotstar":         {"Disney+ Hotstar", "subscriptions"},
+	"youtube":         {"YouTube", "subscriptions"},
+	"airtel":          {"Airtel", "utilities"},
+	"airtelpayments":  {"Airtel", "utilities"},
+	"jio":             {"Jio", "utilities"},
+	"jiomobility":     {"Jio", "utilities"},
+	"bsnl":            {"BSNL", "utilities"},
+	"bescom":          {"BESCOM", "utilities"},
+	"tatapower":       {"Tata Power", "utilities"},
+	"apollopharmacy":  {"Apollo Pharmacy", "healthcare"},
+	"pharmeasy":       {"PharmEasy", "healthcare"},
+	"1mg":             {"Tata 1mg", "healthcare"},
+	"zerodha":         {"Zerodha", "investments"},
+	"groww":           {"Groww", "investments"},
+	"upstox":          {"Upstox", "investments"},
+	"byjus":           {"BYJU'S", "education"},
+	"unacademy":       {"Unacademy", "education"},
+}
+
+// knownPrefixes catches handle variants such as "swiggy.rzp" or
+// "netflixupi". Longer prefixes are listed before shorter ones they extend.
+var knownPrefixes = []struct {
+	prefix   string
+	merchant Merchant
+}{
+	{"swiggyinstamart", Merchant{"Swiggy Instamart", "shopping"}},
+	{"swiggy", Merchant{"Swiggy", "food"}},
+	{"zomato", Merchant{"Zomato", "food"}},
+	{"uber", Merchant{"Uber", "transportation"}},
+	{"irctc", Merchant{"IRCTC", "transportation"}},
+	{"amazon", Merchant{"Amazon", "shopping"}},
+	{"flipkart", Merchant{"Flipkart", "shopping"}},
+	{"netflix", Merchant{"Netflix", "subscriptions"}},
+	{"spotify", Merchant{"Spotify", "subscriptions"}},
+	{"airtel", Merchant{"Airtel", "utilities"}},
+}
+
+// Handles issued to merchants by aggregators and QR programmes
+var merchantHandlePrefixes = []string{
+	"paytmqr", "paytm-", "bharatpe", "gpay-", "rzp", "razorpay", "cf.", "payu",
+	"cashfree", "pinelabs", "mswipe", "ezetap", "billdesk", "merchant",
+}
+
+var merchantHandleMarkers = []string{
+	".rzp", ".payu", ".cf", "merchant", "store", "pvt", "ltd",
+}
+
+func lookupMerchant(v VPA) (Merchant, bool) {
+	if m, ok := knownHandles[v.Handle]; ok {
+		return m, true
+	}
+	// Aggregator handles embed the merchant: "swiggy.rzp", "zomato.payu"
+	base := v.Handle
+	if i := strings.IndexAny(base, ".-_"); i > 0 {
+		base = base[:i]
+	}
+	if m, ok := knownHandles[base]; ok {
+		return m, true
+	}
+	for _, p := range knownPrefixes {
+		if strings.HasPrefix(v.Handle, p.prefix) {
+			return p.merchant, true
+		}
+	}
+	return Merchant{}, false
+}
@@ -0,0 +1,101 @@
+// Package upi parses UPI IDs (VPAs) and reference numbers and recognises the
+// merchants behind well-known VPAs.
+//
+// A VPA has the form handle@psp. Merchant collections usually arrive on
+// handles such as "zomato@hdfcbank" or "paytmqr2810050501011abc@paytm", while
+// person-to-person transfers use phone numbers or names ("9876543210@ybl").
+package upi
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Payment kinds
+const (
+	KindP2P = "p2p"
+	KindP2M = "p2m"
+)
+
+// VPA is a parsed UPI ID
+type VPA struct {
+	Address string `json:"address"`
+	Handle  string `json:"handle"`
+	PSP     string `json:"psp"`
+}
+
+var (
+	vpaPattern       = regexp.MustCompile(`(?i)\b([a-z0-9][a-z0-9._\-]{1,255})@([a-z][a-z0-9]{1,63})\b`)
+	referencePattern = regexp.MustCompile(`(?i)(?:UPI[\s/:-]*(?:Ref(?:erence)?|RRN|Txn)?(?:\s*(?:No|ID))?|RRN|UTR|Ref(?:\s*No)?)[\s.:#/-]*(\d{12})\b`)
+	bareReference    = regexp.MustCompile(`^\d{12}$`)
+	phoneHandle      = regexp.MustCompile(`^(?:\+?91)?[6-9]\d{9}(?:[._-]?\d{0,3})?$`)
+)
+
+// ParseVPA parses a UPI ID. The input may be a bare VPA or contain one, as in
+// "UPI/zomato@hdfcbank/Payment".
+func ParseVPA(s string) (VPA, bool) {
+	m := vpaPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return VPA{}, false
+	}
+	handle := strings.ToLower(m[1])
+	psp := strings.ToLower(m[2])
+	return VPA{Address: handle + "@" + psp, Handle: handle, PSP: psp}, true
+}
+
+// ParseReference extracts a 12 digit UPI reference (RRN) from text. A bare
+// 12 digit string is accepted as is.
+func ParseReference(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if bareReference.MatchString(s) {
+		return s, true
+	}
+	m := referencePattern.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// Info describes the counterparty of a UPI payment
+type Info struct {
+	VPA      VPA    `json:"vpa"`
+	Kind     string `json:"kind"`
+	Merchant string `json:"merchant,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Resolve classifies a VPA and, for known merchants, returns the canonical
+// name and category.
+func Resolve(v VPA) Info {
+	info := Info{VPA: v, Kind: KindP2P}
+	if m, ok := lookupMerchant(v); ok {
+		info.Kind = KindP2M
+		info.Merchant = m.Name
+		info.Category = m.Category
+		return info
+	}
+	if isMerchantHandle(v.Handle) {
+		info.Kind = KindP2M
+	}
+	return info
+}
+
+// isMerchantHandle recognises collection handles issued by payment
+// aggregators and QR programmes. Phone-number handles are always personal.
+func isMerchantHandle(handle string) bool {
+	if phoneHandle.MatchString(handle) {
+		return false
+	}
+	for _, prefix := range merchantHandlePrefixes {
+		if strings.HasPrefix(handle, prefix) {
+			return true
+		}
+	}
+	for _, marker := range merchantHandleMarkers {
+		if strings.Contains(handle, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package upi
+
+import "testing"
+
+func TestParseVPA(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"zomato@hdfcbank", "zomato@hdfcbank", true},
+		{"  Swiggy.RZP@icici ", "swiggy.rzp@icici", true},
+		{"UPI/9876543210@ybl/Payment", "9876543210@ybl", true},
+		{"Zomato", "", false},
+		{"@ybl", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseVPA(tt.in)
+		if ok != tt.ok || got.Address != tt.want {
+			t.Errorf("ParseVPA(%q) = %q, %v; want %q, %v", tt.in, got.Address, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"412345678901", "412345678901", true},
+		{"UPI Ref No 412345678901", "412345678901", true},
+		{"paid via UPI/412345678901/zomato", "412345678901", true},
+		{"RRN: 412345678901.", "412345678901", true},
+		{"Ref 1234", "", false},
+		{"order 412345678901", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseReference(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseReference(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		vpa      string
+		kind     string
+		merchant string
+		category string
+	}{
+		{"zomato@hdfcbank", KindP2M, "Zomato", "food"},
+		{"swiggy.rzp@icici", KindP2M, "Swiggy", "food"},
+		{"swiggyinstamart@axisbank", KindP2M, "Swiggy Instamart", "shopping"},
+		{"netflixupi@hdfcbank", KindP2M, "Netflix", "subscriptions"},
+		{"paytmqr2810050501011abc@paytm", KindP2M, "", ""},
+		{"bharatpe09876543210@yesbankltd", KindP2M, "", ""},
+		{"9876543210@ybl", KindP2P, "", ""},
+		{"rahul.sharma@okaxis", KindP2P, "", ""},
+	}
+	for _, tt := range tests {
+		v, ok := ParseVPA(tt.vpa)
+		if !ok {
+			t.Fatalf("ParseVPA(%q) failed", tt.vpa)
+		}
+		info := Resolve(v)
+		if info.Kind != tt.kind || info.Merchant != tt.merchant || info.Category != tt.category {
+			t.Errorf("Resolve(%q) = %s/%q/%q; want %s/%q/%q",
+				tt.vpa, info.Kind, info.Merchant, info.Category, tt.kind, tt.merchant, tt.category)
+		}
+	}
+}
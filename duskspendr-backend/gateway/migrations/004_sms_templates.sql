@@ -0,0 +1,15 @@
+-- Custom SMS parsing templates loaded by the gateway at runtime.
+-- Rows override built-in templates with the same name.
+CREATE TABLE IF NOT EXISTS sms_templates (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  name TEXT NOT NULL UNIQUE,
+  bank TEXT NOT NULL DEFAULT '',
+  senders JSONB NOT NULL DEFAULT '[]',
+  pattern TEXT NOT NULL,
+  type TEXT,
+  payment_method TEXT,
+  priority INT NOT NULL DEFAULT 0,
+  is_active BOOLEAN NOT NULL DEFAULT true,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS counterparty_vpa TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS upi_kind TEXT;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_upi_kind_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_upi_kind_check
+  CHECK (upi_kind IS NULL OR upi_kind IN ('p2p', 'p2m'));
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_vpa
+  ON transactions (user_id, counterparty_vpa)
+  WHERE counterparty_vpa IS NOT NULL;
@@ -0,0 +1,28 @@
+-- Runtime additions to the built-in merchant directory. Aliases are matched
+-- after normalisation, so "SWIGGY*ORDER 1234" only needs the alias "swiggy".
+CREATE TABLE IF NOT EXISTS merchants (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  canonical_name TEXT NOT NULL UNIQUE,
+  aliases TEXT[] NOT NULL DEFAULT '{}',
+  default_category TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS user_merchant_overrides (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  match_key TEXT NOT NULL,
+  merchant_name TEXT NOT NULL,
+  category TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, match_key)
+);
+
+-- merchant_name holds the canonical name; the original string is kept so
+-- transactions can be re-normalised when the directory changes.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS merchant_raw TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_user_merchant
+  ON transactions (user_id, merchant_name)
+  WHERE merchant_name IS NOT NULL;
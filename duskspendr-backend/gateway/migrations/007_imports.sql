@@ -0,0 +1,29 @@
+-- Saved column mappings for statement imports
+CREATE TABLE IF NOT EXISTS import_profiles (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  mapping JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, name)
+);
+
+-- One row per uploaded statement. Parsed rows are kept until the preview
+-- is committed so the commit writes exactly what the user reviewed.
+CREATE TABLE IF NOT EXISTS imports (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  file_name TEXT NOT NULL,
+  format TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'preview' CHECK (status IN ('preview', 'committed')),
+  linked_account_id UUID REFERENCES linked_accounts(id) ON DELETE SET NULL,
+  mapping JSONB,
+  rows JSONB NOT NULL DEFAULT '[]',
+  report JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  committed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_imports_user_created
+  ON imports (user_id, created_at DESC);
@@ -0,0 +1,27 @@
+-- Reference exchange rates: 1 base_currency = rate quote_currency on rate_date
+CREATE TABLE IF NOT EXISTS fx_rates (
+  base_currency TEXT NOT NULL,
+  quote_currency TEXT NOT NULL,
+  rate_date DATE NOT NULL,
+  rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+  source TEXT NOT NULL DEFAULT 'upload',
+  loaded_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (base_currency, quote_currency, rate_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fx_rates_date ON fx_rates (rate_date DESC);
+
+-- Currency that amount_paisa, budgets and analytics are expressed in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS base_currency TEXT NOT NULL DEFAULT 'INR';
+
+-- amount_paisa stays the amount in the user's base currency (minor units).
+-- Foreign-currency transactions also keep what was actually charged and the
+-- rate used to convert it.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_currency TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_amount_minor BIGINT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20, 10);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate_date DATE;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_original_amount_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_original_amount_check
+  CHECK ((original_currency IS NULL) = (original_amount_minor IS NULL));
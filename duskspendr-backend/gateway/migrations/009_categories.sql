@@ -0,0 +1,40 @@
+-- Spending categories. Rows without a user are the system defaults every
+-- user sees; user rows add custom categories and subcategories, or restyle
+-- a system category by reusing its key. Transactions and budgets store the key.
+CREATE TABLE IF NOT EXISTS categories (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  key TEXT NOT NULL,
+  name TEXT NOT NULL,
+  parent_key TEXT,
+  icon TEXT,
+  color TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (parent_key IS NULL OR parent_key <> key)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_system_key
+  ON categories (key) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_user_key
+  ON categories (user_id, key) WHERE user_id IS NOT NULL;
+
+INSERT INTO categories (key, name, icon, color) VALUES
+  ('food', 'Food & Dining', 'restaurant', '#FF7043'),
+  ('transportation', 'Transportation', 'directions_car', '#42A5F5'),
+  ('entertainment', 'Entertainment', 'movie', '#AB47BC'),
+  ('education', 'Education', 'school', '#5C6BC0'),
+  ('shopping', 'Shopping', 'shopping_bag', '#EC407A'),
+  ('utilities', 'Utilities', 'bolt', '#FFA726'),
+  ('healthcare', 'Healthcare', 'local_hospital', '#EF5350'),
+  ('subscriptions', 'Subscriptions', 'autorenew', '#7E57C2'),
+  ('investments', 'Investments', 'trending_up', '#66BB6A'),
+  ('loans', 'Loans', 'account_balance', '#8D6E63'),
+  ('shared', 'Shared', 'group', '#26A69A'),
+  ('pocketMoney', 'Pocket Money', 'savings', '#FFCA28'),
+  ('other', 'Other', 'more_horiz', '#9E9E9E')
+ON CONFLICT (key) WHERE user_id IS NULL DO NOTHING;
+
+-- Category filters, budgets and re-pointing on rename/merge
+CREATE INDEX IF NOT EXISTS idx_transactions_user_category
+  ON transactions (user_id, category);
@@ -0,0 +1,4 @@
+-- Containment (@>) and key (?, ?|) lookups on transaction tags, used by the
+-- tag filter, per-tag totals and tag rename/merge
+CREATE INDEX IF NOT EXISTS idx_transactions_tags
+  ON transactions USING GIN (tags);
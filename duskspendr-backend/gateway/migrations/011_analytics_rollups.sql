@@ -0,0 +1,29 @@
+-- Per-user transaction aggregates for analytics, keyed by local day/month
+-- (ANALYTICS_TIMEZONE) and the dimensions analytics groups by. Maintained
+-- in the same database transaction as every transaction write; rebuild with
+-- `go run ./cmd/rollup rebuild` after a backfill or a timezone change.
+CREATE TABLE IF NOT EXISTS txn_rollup_daily (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  type TEXT NOT NULL,
+  category TEXT NOT NULL,
+  merchant TEXT NOT NULL DEFAULT '',
+  payment_method TEXT NOT NULL DEFAULT '',
+  account_id TEXT NOT NULL DEFAULT '',
+  amount_paisa BIGINT NOT NULL DEFAULT 0,
+  txn_count BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (user_id, day, type, category, merchant, payment_method, account_id)
+);
+
+CREATE TABLE IF NOT EXISTS txn_rollup_monthly (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  month DATE NOT NULL,
+  type TEXT NOT NULL,
+  category TEXT NOT NULL,
+  merchant TEXT NOT NULL DEFAULT '',
+  payment_method TEXT NOT NULL DEFAULT '',
+  account_id TEXT NOT NULL DEFAULT '',
+  amount_paisa BIGINT NOT NULL DEFAULT 0,
+  txn_count BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (user_id, month, type, category, merchant, payment_method, account_id)
+);
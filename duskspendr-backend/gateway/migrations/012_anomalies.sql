@@ -0,0 +1,34 @@
+-- Per-user anomaly detector preferences; users without a row get the
+-- defaults in internal/anomaly
+CREATE TABLE IF NOT EXISTS anomaly_settings (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  sensitivity TEXT NOT NULL DEFAULT 'medium',
+  amount_rule BOOLEAN NOT NULL DEFAULT TRUE,
+  new_merchant_rule BOOLEAN NOT NULL DEFAULT TRUE,
+  unusual_hour_rule BOOLEAN NOT NULL DEFAULT TRUE,
+  rapid_repeat_rule BOOLEAN NOT NULL DEFAULT TRUE,
+  min_amount_paisa BIGINT NOT NULL DEFAULT 50000,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT anomaly_settings_sensitivity_check CHECK (sensitivity IN ('low', 'medium', 'high'))
+);
+
+-- One row per rule that fired on a transaction, kept for review
+CREATE TABLE IF NOT EXISTS anomaly_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+  rule TEXT NOT NULL,
+  reason TEXT,
+  score DOUBLE PRECISION NOT NULL,
+  detail TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'open',
+  notified_at TIMESTAMPTZ,
+  reviewed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (transaction_id, rule),
+  CONSTRAINT anomaly_events_status_check CHECK (status IN ('open', 'dismissed', 'confirmed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_anomaly_events_user_status
+  ON anomaly_events (user_id, status, created_at DESC);
@@ -0,0 +1,21 @@
+-- Bills and expected receipts entered by the user, projected by the
+-- cash-flow forecast alongside detected recurring transactions
+CREATE TABLE IF NOT EXISTS scheduled_bills (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  type TEXT NOT NULL DEFAULT 'debit',
+  amount_paisa BIGINT NOT NULL,
+  category TEXT,
+  linked_account_id UUID REFERENCES linked_accounts(id) ON DELETE SET NULL,
+  frequency TEXT NOT NULL,
+  next_due DATE NOT NULL,
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT scheduled_bills_type_check CHECK (type IN ('debit', 'credit')),
+  CONSTRAINT scheduled_bills_amount_check CHECK (amount_paisa > 0),
+  CONSTRAINT scheduled_bills_frequency_check CHECK (frequency IN ('once', 'weekly', 'monthly', 'yearly'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_bills_user ON scheduled_bills (user_id);
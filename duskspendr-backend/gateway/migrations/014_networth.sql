@@ -0,0 +1,43 @@
+-- Investment holdings synced by clients from brokers, replaced per provider
+CREATE TABLE IF NOT EXISTS investment_holdings (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  symbol TEXT NOT NULL,
+  name TEXT,
+  quantity DOUBLE PRECISION NOT NULL DEFAULT 0,
+  value_paisa BIGINT NOT NULL,
+  as_of TIMESTAMPTZ NOT NULL,
+  UNIQUE (user_id, provider, symbol)
+);
+
+-- Manually entered assets (gold, property, ...) and liabilities (loans,
+-- credit cards, BNPL); value_paisa is positive for both kinds
+CREATE TABLE IF NOT EXISTS networth_items (
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  category TEXT NOT NULL,
+  name TEXT NOT NULL,
+  value_paisa BIGINT NOT NULL,
+  notes TEXT,
+  as_of TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  CONSTRAINT networth_items_kind_check CHECK (kind IN ('asset', 'liability')),
+  CONSTRAINT networth_items_value_check CHECK (value_paisa >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_networth_items_user ON networth_items (user_id);
+
+-- One snapshot per user and local day (ANALYTICS_TIMEZONE)
+CREATE TABLE IF NOT EXISTS networth_snapshots (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  snapshot_date DATE NOT NULL,
+  assets_paisa BIGINT NOT NULL,
+  liabilities_paisa BIGINT NOT NULL,
+  net_worth_paisa BIGINT NOT NULL,
+  breakdown JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (user_id, snapshot_date)
+);
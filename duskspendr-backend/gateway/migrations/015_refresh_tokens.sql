@@ -0,0 +1,20 @@
+-- Issued refresh tokens, one row per jti. Every login starts a family;
+-- each refresh marks the presented token rotated and adds its successor to
+-- the same family. Presenting a rotated token again revokes the family.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  jti TEXT PRIMARY KEY,
+  family_id TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  issued_at TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  rotated_at TIMESTAMPTZ,
+  replaced_by TEXT,
+  revoked_at TIMESTAMPTZ,
+  revoke_reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family
+  ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user
+  ON refresh_tokens (user_id, issued_at DESC);
@@ -0,0 +1,17 @@
+-- Sessions become the device list for both sign-in flows: opaque tokens
+-- (token_hash) from /v1/auth/verify and JWT refresh token families
+-- (family_id, see 015_refresh_tokens.sql) from /api/v1/auth/verify.
+ALTER TABLE sessions ALTER COLUMN token_hash DROP NOT NULL;
+
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS family_id TEXT UNIQUE;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS device_name TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS platform TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS app_version TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+
+UPDATE sessions SET last_seen_at = created_at WHERE last_seen_at IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET DEFAULT now();
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET NOT NULL;
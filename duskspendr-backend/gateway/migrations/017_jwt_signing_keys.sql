@@ -0,0 +1,12 @@
+-- Asymmetric JWT signing keys shared by all gateway instances. A key is
+-- published in the JWKS from created_at, signs from activates_at until
+-- retires_at, and verifies for the longest token lifetime after that.
+-- private_key is the PKCS#8 key sealed with JWT_KEY_ENCRYPTION_KEY.
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+  kid TEXT PRIMARY KEY,
+  algorithm TEXT NOT NULL CHECK (algorithm IN ('RS256', 'EdDSA')),
+  private_key BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  activates_at TIMESTAMPTZ NOT NULL,
+  retires_at TIMESTAMPTZ
+);
@@ -0,0 +1,48 @@
+-- Passkeys (WebAuthn) as an alternative to SMS OTP sign-in. A user may
+-- register passkeys only after verifying their phone once.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMPTZ;
+-- Random user handle given to authenticators instead of the user id
+ALTER TABLE users ADD COLUMN IF NOT EXISTS webauthn_handle BYTEA UNIQUE;
+
+UPDATE users u
+   SET phone_verified_at = o.verified_at
+  FROM (
+    SELECT user_id, min(consumed_at) AS verified_at
+      FROM auth_otps
+     WHERE consumed_at IS NOT NULL AND verify_ip IS NOT NULL
+     GROUP BY user_id
+  ) o
+ WHERE o.user_id = u.id AND u.phone_verified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  credential_id BYTEA NOT NULL UNIQUE,
+  public_key BYTEA NOT NULL,
+  attestation_type TEXT NOT NULL DEFAULT '',
+  transports TEXT[] NOT NULL DEFAULT '{}',
+  aaguid BYTEA,
+  sign_count BIGINT NOT NULL DEFAULT 0,
+  backup_eligible BOOLEAN NOT NULL DEFAULT false,
+  backup_state BOOLEAN NOT NULL DEFAULT false,
+  name TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user
+  ON webauthn_credentials (user_id);
+
+-- Pending ceremonies. A challenge is deleted when it is used, so each one
+-- is good for a single attempt.
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+  id UUID PRIMARY KEY,
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  ceremony TEXT NOT NULL CHECK (ceremony IN ('registration', 'login')),
+  session_data JSONB NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_expires
+  ON webauthn_challenges (expires_at);
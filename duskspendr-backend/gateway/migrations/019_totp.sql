@@ -0,0 +1,22 @@
+-- Optional TOTP second factor (RFC 6238), used for step-up before
+-- sensitive operations. The secret is AES-GCM sealed by the gateway;
+-- recovery codes are stored as peppered SHA-256 hashes only.
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret BYTEA NOT NULL,
+  confirmed_at TIMESTAMPTZ,
+  -- Last accepted time step; codes of this or earlier steps are replays
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  failed_attempts INT NOT NULL DEFAULT 0,
+  locked_until TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,19 @@
+-- Delivery tracking for OTP messages: one row per provider attempt, updated
+-- by the providers' delivery report webhooks.
+CREATE TABLE IF NOT EXISTS otp_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  otp_id UUID NOT NULL REFERENCES auth_otps(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  provider_message_id TEXT,
+  status TEXT NOT NULL CHECK (status IN ('sent', 'failed', 'delivered', 'undelivered')),
+  detail TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_otp_deliveries_otp
+  ON otp_deliveries (otp_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_otp_deliveries_message
+  ON otp_deliveries (provider, provider_message_id)
+  WHERE provider_message_id IS NOT NULL;
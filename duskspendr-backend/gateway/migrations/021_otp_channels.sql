@@ -0,0 +1,9 @@
+-- OTP delivery channels: the channel a code was requested on (for
+-- per-channel rate limits and resend fallback) and the channel of each
+-- delivery attempt, which differs after an immediate fallback.
+ALTER TABLE auth_otps ADD COLUMN IF NOT EXISTS channel TEXT NOT NULL DEFAULT 'sms';
+
+CREATE INDEX IF NOT EXISTS idx_auth_otps_phone_channel_created
+  ON auth_otps (phone, channel, created_at DESC);
+
+ALTER TABLE otp_deliveries ADD COLUMN IF NOT EXISTS channel TEXT NOT NULL DEFAULT 'sms';
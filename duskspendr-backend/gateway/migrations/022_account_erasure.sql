@@ -0,0 +1,26 @@
+-- Account deletion requests, kept as erasure receipts once the account is
+-- erased. Rows outlive the user on purpose, so user_id has no foreign key;
+-- they hold no personal data beyond that identifier.
+CREATE TABLE IF NOT EXISTS account_deletions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('scheduled', 'cancelled', 'erased')),
+  receipt_token_hash TEXT NOT NULL,
+  requested_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  scheduled_for TIMESTAMPTZ NOT NULL,
+  cancelled_at TIMESTAMPTZ,
+  erased_at TIMESTAMPTZ,
+  erased_counts JSONB
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_scheduled_user
+  ON account_deletions (user_id)
+  WHERE status = 'scheduled';
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due
+  ON account_deletions (scheduled_for)
+  WHERE status = 'scheduled';
+
+-- The user.erased event is enqueued in the same transaction that deletes
+-- the user and must survive it until serverpod has been told
+ALTER TABLE sync_outbox DROP CONSTRAINT IF EXISTS sync_outbox_user_id_fkey;
@@ -26,17 +26,40 @@ class SyncDb {
         created_at TIMESTAMPTZ NOT NULL DEFAULT now()
       );
     ''');
+    // The gateway delivers at least once; the idempotency key of each
+    // stored event lets a redelivery be recognised and skipped
+    await connection.query('''
+      ALTER TABLE sync_transactions ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+    ''');
+    await connection.query('''
+      CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_transactions_idempotency_key
+        ON sync_transactions (idempotency_key);
+    ''');
     return SyncDb(connection);
   }
 
-  Future<void> insertSync(String userId, String payload) async {
-    await connection.query(
-      'INSERT INTO sync_transactions (user_id, payload) VALUES (@userId, @payload::jsonb);',
+  /// Stores an event and reports whether it was new; false when an event
+  /// with the same idempotency key was stored before.
+  Future<bool> insertSync(
+      String userId, String? idempotencyKey, String payload) async {
+    final inserted = await connection.execute(
+      'INSERT INTO sync_transactions (user_id, idempotency_key, payload) '
+      'VALUES (@userId, @key, @payload::jsonb) '
+      'ON CONFLICT (idempotency_key) DO NOTHING;',
       substitutionValues: {
         'userId': userId,
+        'key': idempotencyKey,
         'payload': payload,
       },
     );
+    return inserted > 0;
+  }
+
+  Future<int> eraseUser(String userId) async {
+    return connection.execute(
+      'DELETE FROM sync_transactions WHERE user_id = @userId;',
+      substitutionValues: {'userId': userId},
+    );
   }
 
   Future<void> close() async {
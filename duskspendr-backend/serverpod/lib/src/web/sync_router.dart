@@ -64,7 +64,20 @@ class SyncRoute extends Route {
 
     final db = await SyncDb.connect(dbUrl);
     try {
-      await db.insertSync(userId, payload);
+      // An erased account: drop everything synced for it instead of
+      // storing the event
+      if (_eventOf(payload) == 'user.erased') {
+        final deleted = await db.eraseUser(userId);
+        request.response
+            .write(jsonEncode({'status': 'erased', 'deleted': deleted}));
+        return true;
+      }
+      final key = request.headers.value('x-idempotency-key') ??
+          _idempotencyKeyOf(payload);
+      if (!await db.insertSync(userId, key, payload)) {
+        request.response.write(jsonEncode({'status': 'duplicate'}));
+        return true;
+      }
     } finally {
       await db.close();
     }
@@ -74,6 +87,31 @@ class SyncRoute extends Route {
   }
 }
 
+String? _idempotencyKeyOf(String payload) {
+  try {
+    final decoded = jsonDecode(payload);
+    if (decoded is Map<String, dynamic> &&
+        decoded['idempotency_key'] is String) {
+      return decoded['idempotency_key'] as String;
+    }
+  } on FormatException {
+    return null;
+  }
+  return null;
+}
+
+String? _eventOf(String payload) {
+  try {
+    final decoded = jsonDecode(payload);
+    if (decoded is Map<String, dynamic> && decoded['event'] is String) {
+      return decoded['event'] as String;
+    }
+  } on FormatException {
+    return null;
+  }
+  return null;
+}
+
 bool _isValidSignature(
   String secret,
   String timestamp,